@@ -0,0 +1,291 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// completionShells are the shells `saws completion` writes scripts for.
+var completionShells = []string{"bash", "zsh", "fish", "powershell"}
+
+// flagChoices are the fixed values of flags that take one of a few words.
+var flagChoices = map[string][]string{
+	"ca-tools":        {"pip", "npm", "maven"},
+	"findings-source": {"all", "securityhub", "guardduty"},
+	"output":          {"text", "json"},
+	"param-type":      {"String", "StringList", "SecureString"},
+}
+
+// completionCommand is a subcommand as the completion scripts see it: the words
+// that select it, the flags it takes, and the values of its first operand.
+type completionCommand struct {
+	words   []string
+	flags   []string
+	choices []string
+}
+
+// key joins the words for use as a case label, e.g. "config_validate".
+func (c completionCommand) key() string {
+	return strings.Join(c.words, "_")
+}
+
+func completionCommands() []completionCommand {
+	commands := make([]completionCommand, 0, len(subcommands))
+	for _, sub := range subcommands {
+		cmd := completionCommand{words: strings.Fields(sub.name), choices: sub.choices}
+		for _, name := range append(append([]string(nil), sub.flags...), commonFlags...) {
+			cmd.flags = append(cmd.flags, "-"+name)
+		}
+		if len(cmd.choices) == 0 && sub.operandModes != nil {
+			for operand := range sub.operandModes {
+				cmd.choices = append(cmd.choices, operand)
+			}
+			sort.Strings(cmd.choices)
+		}
+		commands = append(commands, cmd)
+	}
+	return commands
+}
+
+// topLevelWords are the words completed right after "saws".
+func topLevelWords() []string {
+	var words []string
+	seen := make(map[string]bool)
+	for _, sub := range subcommands {
+		word := strings.Fields(sub.name)[0]
+		if !seen[word] {
+			seen[word] = true
+			words = append(words, word)
+		}
+	}
+	return words
+}
+
+// isValueFlag reports whether the flag takes a value (the next word).
+func isValueFlag(name string) bool {
+	f := flag.Lookup(name)
+	if f == nil {
+		return false
+	}
+	boolFlag, ok := f.Value.(interface{ IsBoolFlag() bool })
+	return !ok || !boolFlag.IsBoolFlag()
+}
+
+func sortedChoiceFlags() []string {
+	names := make([]string, 0, len(flagChoices))
+	for name := range flagChoices {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// writeCompletion writes the completion script for shell.
+func writeCompletion(w io.Writer, shell string) error {
+	switch shell {
+	case "bash":
+		writeBashCompletion(w)
+	case "zsh":
+		writeZshCompletion(w)
+	case "fish":
+		writeFishCompletion(w)
+	case "powershell":
+		writePowerShellCompletion(w)
+	default:
+		return fmt.Errorf("unsupported shell '%s' (use one of %s)", shell, strings.Join(completionShells, ", "))
+	}
+	return nil
+}
+
+func writeBashCompletion(w io.Writer) {
+	fmt.Fprintf(w, `# bash completion for saws. Load with: source <(saws completion bash)
+_saws() {
+    local cur="${COMP_WORDS[COMP_CWORD]}" prev="${COMP_WORDS[COMP_CWORD-1]}"
+    local cmd="${COMP_WORDS[1]}" operand_index=2
+    if [[ $COMP_CWORD -eq 1 ]]; then
+        COMPREPLY=($(compgen -W "%s" -- "$cur"))
+        return
+    fi
+    if [[ $cmd == config ]]; then
+        if [[ $COMP_CWORD -eq 2 ]]; then
+            COMPREPLY=($(compgen -W "validate init" -- "$cur"))
+            return
+        fi
+        cmd="config_${COMP_WORDS[2]}" operand_index=3
+    fi
+    case "$prev" in
+`, strings.Join(topLevelWords(), " "))
+	fmt.Fprintf(w, "        %s)\n", dynamicFlagPattern())
+	fmt.Fprint(w, "            mapfile -t COMPREPLY < <(\"${COMP_WORDS[0]}\" "+completeCommand+" \"$prev\" \"$cur\" \"${COMP_WORDS[@]:1:COMP_CWORD-1}\" 2>/dev/null)\n            return ;;\n")
+	for _, name := range sortedChoiceFlags() {
+		fmt.Fprintf(w, "        -%s|--%s) COMPREPLY=($(compgen -W \"%s\" -- \"$cur\")); return ;;\n", name, name, strings.Join(flagChoices[name], " "))
+	}
+	fmt.Fprint(w, "    esac\n    local flags=\"\" choices=\"\"\n    case \"$cmd\" in\n")
+	for _, cmd := range completionCommands() {
+		fmt.Fprintf(w, "        %s) flags=\"%s\" choices=\"%s\" ;;\n", cmd.key(), strings.Join(cmd.flags, " "), strings.Join(cmd.choices, " "))
+	}
+	fmt.Fprint(w, `    esac
+    if [[ $cur == -* ]]; then
+        COMPREPLY=($(compgen -W "$flags" -- "$cur"))
+    elif [[ $COMP_CWORD -eq $operand_index && -n $choices ]]; then
+        COMPREPLY=($(compgen -W "$choices" -- "$cur"))
+    fi
+}
+complete -o default -F _saws saws
+`)
+}
+
+func writeZshCompletion(w io.Writer) {
+	fmt.Fprintf(w, `#compdef saws
+# zsh completion for saws. Load with: source <(saws completion zsh)
+_saws() {
+    local cur=${words[CURRENT]} prev=${words[CURRENT-1]}
+    local cmd=${words[2]} operand_index=3
+    if (( CURRENT == 2 )); then
+        compadd -- %s
+        return
+    fi
+    if [[ $cmd == config ]]; then
+        if (( CURRENT == 3 )); then
+            compadd -- validate init
+            return
+        fi
+        cmd="config_${words[3]}" operand_index=4
+    fi
+    case $prev in
+`, strings.Join(topLevelWords(), " "))
+	fmt.Fprintf(w, "        %s)\n", dynamicFlagPattern())
+	fmt.Fprint(w, "            compadd -- ${(f)\"$(${words[1]} "+completeCommand+" $prev $cur ${words[2,CURRENT-1]} 2>/dev/null)\"}\n            return ;;\n")
+	for _, name := range sortedChoiceFlags() {
+		fmt.Fprintf(w, "        -%s|--%s) compadd -- %s; return ;;\n", name, name, strings.Join(flagChoices[name], " "))
+	}
+	fmt.Fprint(w, "    esac\n    local -a flags choices\n    case $cmd in\n")
+	for _, cmd := range completionCommands() {
+		fmt.Fprintf(w, "        %s) flags=(%s) choices=(%s) ;;\n", cmd.key(), strings.Join(cmd.flags, " "), strings.Join(cmd.choices, " "))
+	}
+	fmt.Fprint(w, `    esac
+    if [[ $cur == -* ]]; then
+        compadd -- $flags
+    elif (( CURRENT == operand_index )) && (( ${#choices} )); then
+        compadd -- $choices
+    else
+        _files
+    fi
+}
+compdef _saws saws
+`)
+}
+
+func writeFishCompletion(w io.Writer) {
+	fmt.Fprintln(w, "# fish completion for saws. Load with: saws completion fish | source")
+	fmt.Fprintln(w, "complete -c saws -f")
+	for _, sub := range subcommands {
+		words := strings.Fields(sub.name)
+		if len(words) == 1 {
+			fmt.Fprintf(w, "complete -c saws -n __fish_use_subcommand -a %s -d %s\n", words[0], fishQuote(sub.summary))
+		}
+	}
+	fmt.Fprintln(w, "complete -c saws -n __fish_use_subcommand -a config -d 'Validate or create the config.'")
+	fmt.Fprintf(w, "complete -c saws -n '__fish_seen_subcommand_from config; and not __fish_seen_subcommand_from validate init' -a 'validate init'\n")
+	for _, cmd := range completionCommands() {
+		condition := "__fish_seen_subcommand_from " + cmd.words[0]
+		if len(cmd.words) > 1 {
+			condition += "; and __fish_seen_subcommand_from " + cmd.words[1]
+		}
+		for _, f := range cmd.flags {
+			name := strings.TrimPrefix(f, "-")
+			line := fmt.Sprintf("complete -c saws -n '%s' -o %s", condition, name)
+			if isValueFlag(name) {
+				line += " -r"
+				if choices, ok := flagChoices[name]; ok {
+					line += " -a '" + strings.Join(choices, " ") + "'"
+				} else if _, ok := dynamicFlags[name]; ok {
+					line += " -a '(saws " + completeCommand + " " + name + " (commandline -ct) (commandline -opc)[2..-1])'"
+				}
+			}
+			if fl := flag.Lookup(name); fl != nil {
+				line += " -d " + fishQuote(fl.Usage)
+			}
+			fmt.Fprintln(w, line)
+		}
+		if len(cmd.choices) > 0 {
+			fmt.Fprintf(w, "complete -c saws -n '%s' -a '%s'\n", condition, strings.Join(cmd.choices, " "))
+		}
+	}
+}
+
+func fishQuote(s string) string {
+	return "'" + strings.ReplaceAll(strings.ReplaceAll(s, `\`, `\\`), "'", `\'`) + "'"
+}
+
+func writePowerShellCompletion(w io.Writer) {
+	fmt.Fprintln(w, "# PowerShell completion for saws. Load with: saws completion powershell | Out-String | Invoke-Expression")
+	fmt.Fprintf(w, "$sawsTopLevel = @(%s)\n", psList(topLevelWords()))
+	fmt.Fprintln(w, "$sawsCommands = @{")
+	for _, cmd := range completionCommands() {
+		fmt.Fprintf(w, "    '%s' = @{ Flags = @(%s); Choices = @(%s) }\n", cmd.key(), psList(cmd.flags), psList(cmd.choices))
+	}
+	fmt.Fprintln(w, "}")
+	fmt.Fprintln(w, "$sawsFlagChoices = @{")
+	for _, name := range sortedChoiceFlags() {
+		fmt.Fprintf(w, "    '-%s' = @(%s)\n", name, psList(flagChoices[name]))
+	}
+	fmt.Fprintln(w, "}")
+	dynamic := make([]string, 0, len(dynamicFlags))
+	for _, name := range sortedDynamicFlags() {
+		dynamic = append(dynamic, "-"+name)
+	}
+	fmt.Fprintf(w, "$sawsDynamicFlags = @(%s)\n", psList(dynamic))
+	fmt.Fprint(w, `Register-ArgumentCompleter -Native -CommandName saws, saws.exe -ScriptBlock {
+    param($wordToComplete, $commandAst, $cursorPosition)
+    $words = @($commandAst.CommandElements | ForEach-Object { $_.ToString() })
+    if ($wordToComplete -eq '') { $words += '' }
+    $index = $words.Count - 1
+    $candidates = @()
+    if ($index -eq 1) {
+        $candidates = $sawsTopLevel
+    } elseif ($words[1] -eq 'config' -and $index -eq 2) {
+        $candidates = @('validate', 'init')
+    } else {
+        $key = $words[1]
+        $operandIndex = 2
+        if ($key -eq 'config') { $key = "config_$($words[2])"; $operandIndex = 3 }
+        $prev = $words[$index - 1] -replace '^--', '-'
+        $entry = $sawsCommands[$key]
+        if ($sawsDynamicFlags -contains $prev) {
+            $candidates = @(& $words[0] `+completeCommand+` $prev $wordToComplete $words[1..($index - 1)] 2>$null)
+        } elseif ($sawsFlagChoices.ContainsKey($prev)) {
+            $candidates = $sawsFlagChoices[$prev]
+        } elseif ($wordToComplete.StartsWith('-') -and $entry) {
+            $candidates = $entry.Flags
+        } elseif ($index -eq $operandIndex -and $entry) {
+            $candidates = $entry.Choices
+        }
+    }
+    $candidates | Where-Object { $_ -like "$wordToComplete*" } | ForEach-Object {
+        [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterValue', $_)
+    }
+}
+`)
+}
+
+// dynamicFlagPattern is the case pattern matching the flags completed by
+// `saws __complete`, e.g. "-r|--r|-s|--s".
+func dynamicFlagPattern() string {
+	var patterns []string
+	for _, name := range sortedDynamicFlags() {
+		patterns = append(patterns, "-"+name, "--"+name)
+	}
+	return strings.Join(patterns, "|")
+}
+
+func psList(values []string) string {
+	quoted := make([]string, len(values))
+	for i, v := range values {
+		quoted[i] = "'" + strings.ReplaceAll(v, "'", "''") + "'"
+	}
+	return strings.Join(quoted, ", ")
+}
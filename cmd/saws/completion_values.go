@@ -0,0 +1,154 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+
+	"saws/internal/pkg"
+)
+
+// completeCommand is the hidden command the completion scripts call back into:
+//
+//	saws __complete <flag> <current word> [<words before it>...]
+//
+// It prints the values of flag that start with the current word, one per line.
+const completeCommand = "__complete"
+
+// Kinds of values completed from the loaded config.
+const (
+	completeAccounts  = "accounts"
+	completeRoles     = "roles"
+	completeRegions   = "regions"
+	completeInstances = "instances"
+	completeContexts  = "contexts"
+)
+
+// dynamicFlags maps the flags whose values come from the config (or the recent
+// selections) to the kind of value they take.
+var dynamicFlags = map[string]string{
+	"s":          completeAccounts,
+	"share-to":   completeAccounts,
+	"r":          completeRoles,
+	"dest-role":  completeRoles,
+	"region":     completeRegions,
+	"regions":    completeRegions,
+	"sts-region": completeRegions,
+	"i":          completeInstances,
+	"context":    completeContexts,
+}
+
+// listFlags take comma-separated values; only the part after the last comma is completed.
+var listFlags = []string{"s", "share-to", "regions"}
+
+func sortedDynamicFlags() []string {
+	names := make([]string, 0, len(dynamicFlags))
+	for name := range dynamicFlags {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// flagValueIn returns the value of -name (or --name, -name=value) in words.
+func flagValueIn(words []string, name string) string {
+	value := ""
+	for i, word := range words {
+		trimmed := strings.TrimLeft(word, "-")
+		if trimmed == word {
+			continue
+		}
+		if trimmed == name && i+1 < len(words) {
+			value = words[i+1]
+		} else if v, ok := strings.CutPrefix(trimmed, name+"="); ok {
+			value = v
+		}
+	}
+	return value
+}
+
+// completionValues returns the candidate values of kind. The config is loaded
+// honoring any -config and -context already on the command line.
+func completionValues(kind string, words []string) []string {
+	if kind == completeInstances {
+		var ids []string
+		for _, r := range pkg.LoadRecentSelections() {
+			if r.Instance != "" && !slices.Contains(ids, r.Instance) {
+				ids = append(ids, r.Instance)
+			}
+		}
+		return ids
+	}
+
+	configPath, err := pkg.FindConfigPath(flagValueIn(words, "config"))
+	if err != nil {
+		return nil
+	}
+	contextName := flagValueIn(words, "context")
+	if kind == completeContexts {
+		// The context being completed must not select one while loading.
+		contextName = ""
+	}
+	appConfig, err := pkg.LoadConfig(configPath, contextName)
+	if err != nil {
+		return nil
+	}
+
+	var values []string
+	switch kind {
+	case completeAccounts:
+		for name := range appConfig.Accounts {
+			values = append(values, name)
+		}
+		for name := range appConfig.Groups {
+			values = append(values, "@"+name)
+		}
+	case completeRoles:
+		for name := range appConfig.Roles {
+			values = append(values, name)
+		}
+	case completeRegions:
+		values = append(values, appConfig.CommonRegions...)
+	case completeContexts:
+		for name := range appConfig.Contexts {
+			values = append(values, name)
+		}
+	}
+	sort.Strings(values)
+	return slices.Compact(values)
+}
+
+// writeDynamicCompletions handles `saws __complete`. Errors are swallowed: a
+// failed completion must not print into the user's command line.
+func writeDynamicCompletions(w io.Writer, args []string) {
+	if len(args) < 2 {
+		return
+	}
+	name, current, words := strings.TrimLeft(args[0], "-"), args[1], args[2:]
+	kind, ok := dynamicFlags[name]
+	if !ok {
+		return
+	}
+	if devNull, err := os.Open(os.DevNull); err == nil {
+		os.Stderr = devNull
+	}
+	log.SetOutput(io.Discard)
+
+	head, partial := "", current
+	var chosen []string
+	if slices.Contains(listFlags, name) {
+		if i := strings.LastIndex(current, ","); i >= 0 {
+			head, partial = current[:i+1], current[i+1:]
+			chosen = strings.Split(current[:i], ",")
+		}
+	}
+	for _, value := range completionValues(kind, words) {
+		if strings.HasPrefix(value, partial) && !slices.Contains(chosen, value) {
+			fmt.Fprintln(w, head+value)
+		}
+	}
+}
@@ -9,161 +9,921 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
-	"sort"
+	"slices"
+	"strconv"
 	"strings"
-	"sync"
-	"sync/atomic"
-	"time"
+	"text/template"
 
 	"saws/internal/app/saws"
 	"saws/internal/pkg"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
 	awsconfig "github.com/aws/aws-sdk-go-v2/config"
 )
 
 func usage() {
-	fmt.Fprintf(os.Stderr, `Usage: saws <mode> [options]
-
-Modes:
-  -c <cmd>      Command Execution: Run <cmd> across accounts/regions.
-                  Requires: -r, (-a | -s)
-                  Optional: -regions
-  -e            Interactive Sub-Shell: Start a sub-shell with assumed role credentials.
-                  Optional: -s, -r, -region (or use env vars / interactive prompts)
-  -ssm          SSM Session: Start an interactive SSM session to an EC2 instance.
-                  Optional: -i, -s, -r, -region (prompts if needed)
-  -ecs          ECS Exec Session: Start an interactive exec session to an ECS container.
-                  Optional: --ecs-cluster, --ecs-task, --ecs-container, --ecs-command,
-                            -s, -r, -region (prompts if needed)
+	fmt.Fprintf(os.Stderr, `Usage: saws <command> [options] [args]
+
+Commands:
+%s
+Run 'saws <command> -h' for the options of a command. The older mode flags (-c, -e, -ssm, -ecs, -lambda, ...)
+still work but are deprecated; each prints the command that replaces it.
 
 Common Options:
-  -r <role>     IAM role name to assume.
-  -s <selector> Account selector (Cmd Mode: comma-sep names/wildcards; Others: single name/wildcard).
-  -region <reg> AWS region (for -e, -ssm, -ecs modes).
-  -config <path> Path to saws-config.yaml file.
-  -v            Enable verbose logging.
+  -r <role>     IAM role name to assume, or a full role ARN (arn:aws:iam::<id>:role/<path/name>),
+                whose account is used when -s is left out.
+  -s <selector> Account selector (fan-out commands: comma-sep names/wildcards/aliases/@groups; others: single name/wildcard/alias/@group, or any 12-digit account ID).
+  -region <reg> AWS region (for shell, ssm, ecs, and other single-account commands).
+  -last         Reuse the most recent account, role, and region (and -ssm instance) for any flag not given.
+  -config <path> Path to saws-config.yaml or .json file, or an s3:// / https:// URL cached by ETag
+                (or SAWS_CONFIG; default: ~/.aws, then $XDG_CONFIG_HOME/saws).
+  -context <name> Named context from the config's 'contexts:' (or SAWS_CONTEXT / default_context).
+  -output <fmt> Output format for findings and tags: text or json (default: defaults.output).
+  -no-color     Disable colored output (also NO_COLOR, defaults.color: never, or output that is not a terminal).
+  -no-input     Never prompt: fail with a JSON error line on stderr and exit code 4 instead (scripts and CI).
+  -force        Start a new session inside a saws sub-shell, or over exported AWS credentials, without confirming.
+  -no-cache     Assume roles afresh instead of reusing credentials cached by earlier runs (defaults.credential_cache).
+  -external-id <id> sts:ExternalId to pass when assuming roles, overriding the external_id of config accounts
+                (for third-party-managed accounts whose trust policy requires one).
+  -sts-endpoint <regional|url> STS endpoint of AssumeRole calls: 'regional' never uses the global endpoint,
+                a URL (e.g. an STS VPC endpoint) receives every call (defaults.sts_endpoint).
+  -sts-region <reg> Region whose STS endpoint AssumeRole calls use, instead of the base profile's (defaults.sts_region).
+  -v            Enable verbose logging. -vv adds the service, operation, duration, and outcome of every AWS call
+                (with the role ARN of AssumeRole); -vvv adds SDK request/response logging with credentials redacted.
   -h            Display this help message.
+  -version      Print the version and commit of this build. With defaults.update_check: true or
+                SAWS_UPDATE_CHECK=1, saws also checks GitHub once a day for a newer release.
 
-Command Mode Options (-c):
-  -regions <regs> Comma-separated regions for command execution.
+Exec Options (exec):
+  -regions <regs> Comma-separated regions for command execution (also lambda, alarms, param, ecr-login, stack, findings,
+                 tags, find, find-ip, automation, patch-compliance, config-compliance, logs-insights).
   -a             Process all accounts defined in config.
+  -q             Quiet: print only each target's output (command stdout, Lambda payload) on stdout, with no banners;
+                 failed targets and their stderr are reported on stderr.
+  -max-failures <n|n%%> Exit 0 while at most n targets (or n%% of them) fail, e.g. for known-broken sandbox accounts
+                 (exec, lambda, ecr-login, automation).
+  -publish <arn> After the run, send a JSON summary (command, role, user, counts, status) to an SNS topic or an
+                 EventBridge event bus (source "saws", detail-type "saws Run Completed"). A topic or bus in a
+                 configured account is reached with -r there; otherwise the base credentials are used.
+  -metrics-file <path> Write Prometheus metrics of the run (duration, failed targets, and per-target duration,
+                 assume-role latency, and outcome) for the node_exporter textfile collector (or SAWS_METRICS_FILE).
+  -pushgateway <url> Push the same metrics to a Prometheus Pushgateway as job "saws" (or SAWS_PUSHGATEWAY).
+  -otlp-endpoint <url> Send a trace of the run (a span per target, with its sts:AssumeRole call) over OTLP/HTTP,
+                 e.g. http://localhost:4318 (or OTEL_EXPORTER_OTLP_ENDPOINT; TRACEPARENT joins an existing trace).
+                 These apply to every fan-out command.
+  -publish-targets Include each target's account, region, status, exit code, and error in the summary.
+  -timings       After the run, print each target's sts:AssumeRole and command time, their p50/p95/max, and the
+                 slowest and most failing accounts, to stderr.
+  -adaptive      Start with 8 concurrent targets, add more while they finish cleanly, and halve them whenever STS
+                 or a command reports throttling, up to defaults.parallelism if set (or defaults.adaptive_parallelism).
+  -resume <run-id> Re-run only the targets of a saved run that failed or never started. Every fan-out saves its
+                 plan and per-target outcomes under ~/.aws/saws-runs/ and prints its run ID when targets failed;
+                 repeat the same command with -resume to pick up where it left off.
+  -retry-failed  Like -resume, for the most recent run of the same command with failed or unstarted targets.
+  -workdir <dir> Run each execution in its own directory, a template over {{.AccountName}}, {{.AccountID}}, and
+                 {{.Region}}, e.g. ./envs/{{.AccountName}}/{{.Region}}. Targets whose directory is missing fail.
+  -preflight     Before running, simulate the IAM actions of the command's 'aws <service> <operation>' calls for
+                 the role in every account (iam:SimulatePrincipalPolicy) and stop if any account would deny them.
+  -preflight-actions <actions> Comma-separated IAM actions to simulate as well, e.g. for calls inside scripts
+                 (s3:GetObject,kms:Decrypt); implies -preflight.
 
-SSM Session Mode Options (-ssm):
+SSM Session Options (ssm):
   -i <inst-id>  Target EC2 instance ID (if omitted, instances will be listed for selection).
+  -instance-state <states> Only list instances in these EC2 states or SSM ping statuses, e.g. running or
+                stopped,connectionlost. Adds the EC2 state to each entry.
+  -all-instances Also list stopped instances SSM no longer reports. Picking a stopped instance offers to start it
+                and connects once its agent is online.
 
-ECS Exec Session Mode Options (-ecs):
+ECS Exec Session Options (ecs):
   --ecs-cluster <name|arn>  Target ECS cluster.
   --ecs-task <id|arn>       Target ECS task.
   --ecs-container <name>    Target container name within the task.
   --ecs-command <cmd>       Command to execute in container (default: /bin/sh).
+  --ecs-host                Open an SSM session to the EC2 container instance running the task instead (EC2 launch
+                            type). Tasks with several containers also offer the host in the container prompt.
+
+ECS Service Exec Options (ecs-service-exec -- <cmd...>):
+  --ecs-cluster <name|arn>  Cluster of the service (prompts if omitted).
+  --ecs-service <name>      Service whose running tasks all run the command at once (prompts if omitted).
+  --ecs-container <name>    Container to run it in (default: the first container of each task).
+                            Every output line is prefixed with [task-id/container].
+
+EKS Token Options (eks-token):
+  --eks-cluster <name>      Target EKS cluster name, or an entry of eks_clusters (then -s, -r, and -region are optional).
+
+Kubeconfig Options (kubeconfig [<cluster>...]):
+  -kubeconfig <path>        File to update (default: the first KUBECONFIG entry, then ~/.kube/config). Writes a context
+                            per eks_clusters entry whose user runs 'saws eks-token', so no credentials are stored.
+
+RDS Token Options (rds-token):
+  --rds-instance <id>       Target DB instance identifier (if omitted, instances will be listed for selection).
+  --db-user <user>          Database user to authenticate as (prompts if omitted).
+  --rds-tunnel              Also open an SSM port forward through a bastion instance.
+  --local-port <port>       Local port for --rds-tunnel (default: the DB port).
+
+Database Connect Options (db):
+  --rds-instance <id>       Target DB instance or cluster identifier (prompts if omitted).
+  --db-user <user>          Database user (default: the master user; IAM auth token used when enabled).
+  --db-name <name>          Database name to connect to.
+  --bastion <id|pattern>    Bastion instance ID or SSM computer-name wildcard (prompts if omitted).
+  --local-port <port>       Local tunnel port (default: a free port).
+
+Cache Connect Options (redis):
+  --cache <name>            Replication group, serverless cache, or MemoryDB cluster name (prompts if omitted).
+  --bastion <id|pattern>    Bastion instance ID or SSM computer-name wildcard (prompts if omitted).
+  --local-port <port>       Local tunnel port (default: a free port).
+
+OpenSearch Tunnel Options (opensearch):
+  --os-domain <name>        Target OpenSearch domain (prompts if omitted).
+  --bastion <id|pattern>    Bastion instance ID or SSM computer-name wildcard (prompts if omitted).
+  --local-port <port>       Local tunnel port (default: a free port).
+  --open                    Open the Dashboards URL in the default browser.
+
+Lambda Invoke Options (lambda):
+  -payload <file>           JSON file to send as the invocation payload.
+  -regions <regs>           Comma-separated regions to invoke in.
+  -a | -s <selector>        All accounts or comma-separated names/wildcards/aliases/@groups.
+
+SSM Automation Options (automation <document> [key=value...]):
+  key=value                 Document parameter; repeat a key for a list. {account}, {account_id}, and {region}
+                            are replaced per target, e.g. AutomationAssumeRole=arn:aws:iam::{account_id}:role/Ops.
+                            Each execution is polled until it finishes; its outputs are printed with the result.
+
+Parameter Store Options (param):
+  get <path>                Show the parameter's value in every target.
+  put <path> <value>        Write the parameter in every target (overwrites).
+  -param-type <type>        String, StringList, or SecureString for put (default: keep existing, else String).
+
+KMS Options (kms encrypt, kms decrypt):
+  --kms-key <id|arn|alias>  Key to encrypt with (or to pin for decryption).
+  --in <file>               Read input from a file instead of stdin.
+                            Pass -s, -r, and -region when piping input so no prompts are needed.
+
+Terraform Options (tf):
+  -tf-bin <path>            Terraform binary to run (default: terraform; terragrunt is supported).
+  -tf-state-key <template>  Backend key per target when running across accounts (default: {account}/{region}/terraform.tfstate;
+                            {account_id} is also replaced). Each target gets its own TF_DATA_DIR under .terraform/saws.
+                            -a, -regions, or an -s matching several accounts runs the targets one at a time, stopping
+                            at the first failure. TF_VAR_account_id, TF_VAR_account_name, and TF_VAR_region are set.
+
+Deploy Options (cdk, sam):
+  -a | -s <selector>        Accounts to deploy to, in the order -s lists them (e.g. -s dev,staging,prod).
+  -regions <regs>           Comma-separated regions. Targets run one at a time and stop at the first failure;
+                            a report of every target follows. cdk gets --context account=<id>, --context region=<region>,
+                            and CDK_DEFAULT_ACCOUNT/CDK_DEFAULT_REGION; sam gets --region unless given.
+
+Daemon Options (daemon):
+  -socket <path>            Unix socket to serve credentials on (default: ~/.aws/saws.sock, owner-only).
+                            GET /v1/credentials?account=<name|alias|id>&role=<role>[&region=<region>] returns
+                            credential_process JSON; credentials are cached and renewed before they expire.
+
+Git Credential Helper (git-credential):
+  Map repositories to accounts under 'codecommit_repos:' and run
+    git config --global credential.helper '!saws git-credential'
+    git config --global credential.UseHttpPath true
+  (or symlink saws as 'git-credential-saws' and use credential.helper saws). Other hosts are left to other helpers.
+
+CodeArtifact Login Options (codeartifact-login):
+  -ca-domain <name>         CodeArtifact domain (required).
+  -ca-repo <name>           Repository in the domain (required).
+  -ca-domain-owner <acct>   Account name or ID owning the domain (default: the selected account).
+  -ca-tools <list>          pip, npm, and/or maven (default: those found on PATH). pip gets the index URL with the
+                            token, npm the registry and its _authToken, maven a <server> in ~/.m2/settings.xml
+                            with id <domain>-<repo>. Tokens last 12 hours; run again to renew.
+
+Docker Credential Helper (docker-credential):
+  Symlink saws as 'docker-credential-saws' on your PATH and set "credsStore": "saws" (or per-registry
+  "credHelpers") in ~/.docker/config.json. Registries of accounts not in the SAWS config are left anonymous.
+
+Security Findings Options (findings):
+  --findings-source <src>   all (default), securityhub, or guardduty.
+  --json                    Print findings as a JSON array instead of a table.
+
+Logs Insights Options (logs-insights <log-group> '<query>'):
+  -since <duration>         Time range of the query, ending now (default: 1h). Results of every target are merged
+                            into one table with ACCOUNT and REGION columns, newest first; --json prints them as JSON.
+
+Tag Search Options (tags):
+  key=value                 Match a tag value; repeat a key to match any of several values.
+  key                       Match any value of the tag.
+  --json                    Print resources and their tags as a JSON array.
+
+Share Image Options (share):
+  --share-to <selector>     Comma-separated destination account names/wildcards from the config.
+
+S3 Copy Options (s3-copy):
+  --dest-role <role>        Role to assume in the destination account (default: the source role).
+
+Presign Options (presign s3://bucket/key):
+  -expires <duration>       How long the URL is valid (default: 1h, at most 168h). It stops working earlier when
+                            the assumed-role session that signed it expires; saws warns when that happens.
+  -put                      Presign an upload (PUT) instead of a download (GET).
 
 Examples:
   # Command Execution: Run 'aws s3 ls' in eu-west-1 for prod-* accounts as 'ReadOnly'
-  saws -c "aws s3 ls" -r ReadOnly -s "prod-*,dev-account" -regions "eu-west-1,us-east-1"
+  saws exec "aws s3 ls" -r ReadOnly -s "prod-*,dev-account" -regions "eu-west-1,us-east-1"
+
+  # ...and tell the compliance pipeline about it:
+  saws exec "aws s3 ls" -r ReadOnly -a -publish arn:aws:events:eu-west-1:111122223333:event-bus/ops -publish-targets
 
   # Interactive Sub-Shell: Start shell
-  saws -e
-  saws -e -s dev-1 -r Admin -region us-east-1
+  saws shell
+  saws shell -s dev-1 -r Admin -region us-east-1
+  # Run one command with the credentials instead of a shell; saws exits with its status:
+  saws shell -s dev-1 -r Admin -region us-east-1 -- terraform plan
 
   # SSM Session (direct connect):
-  saws -ssm
-  saws -ssm -i i-0123... -s prod-web -r Admin -region eu-central-1
-  saws -ssm -s prod-db -r DBAccess -region us-west-2
+  saws ssm
+  saws ssm -i i-0123... -s prod-web -r Admin -region eu-central-1
+  saws ssm -s prod-db -r DBAccess -region us-west-2
 
   # ECS Exec Session (direct connect to a specific container):
-  saws -ecs --ecs-cluster my-cluster --ecs-task a1b2c3d4e5 --ecs-container my-app-container -s prod-app -r AppAdmin -region us-east-1
+  saws ecs --ecs-cluster my-cluster --ecs-task a1b2c3d4e5 --ecs-container my-app-container -s prod-app -r AppAdmin -region us-east-1
 
   # ECS Exec Session (interactive selection):
-  saws -ecs -s dev-app -r Developer -region eu-west-1
-`)
-	os.Exit(1)
+  saws ecs -s dev-app -r Developer -region eu-west-1
+
+  # ECS Service Exec: flush the cache of every replica of a service:
+  saws ecs-service-exec --ecs-cluster prod --ecs-service api -s prod-app -r AppAdmin -region eu-west-1 -- curl -s -X POST localhost:8080/cache/flush
+
+  # EKS Token (use as the exec command of a kubeconfig user):
+  saws eks-token --eks-cluster my-cluster -s prod-app -r Admin -region eu-west-1
+
+  # Kubeconfig for the clusters under 'eks_clusters:' (users call 'saws eks-token'; no stored credentials):
+  saws kubeconfig prod-api
+
+  # RDS IAM Token (with an SSM tunnel through a bastion):
+  saws rds-token --rds-instance orders-db --db-user app_ro --rds-tunnel -s prod-data -r DatabaseAdmin -region eu-west-1
+
+  # Database Connect (tunnel + client in one step):
+  saws db -s prod-data -r DatabaseAdmin -region eu-west-1 --rds-instance orders-db --db-name orders
+
+  # Named Tunnel (defined under 'tunnels:' in saws-config.yaml):
+  saws tunnel orders-db
+
+  # Cache Connect (tunnel + redis-cli in one step):
+  saws redis --cache sessions -s prod-main-api -r Admin -region eu-west-1
+
+  # OpenSearch Dashboards through a VPC bastion:
+  saws opensearch --os-domain logs --open -s prod-logging -r ReadOnly -region eu-west-1
+
+  # Lambda Invoke across all dev accounts:
+  saws lambda cache-warmer -payload event.json -r Admin -s "dev-*" -regions eu-west-1
+
+  # Alarm Status: everything firing in prod, one table:
+  saws alarms -r ReadOnly -s "prod-*" -regions "eu-west-1,us-east-1"
+
+  # SSM Automation: run a runbook in every prod account and wait for it:
+  saws automation AWS-RestartEC2Instance InstanceId=i-0abc1234 -r Admin -s "prod-*" -regions eu-west-1
+
+  # Parameter Store: compare a value across all prod accounts:
+  saws param get /app/feature-flags -r ReadOnly -s "prod-*"
+
+  # KMS Decrypt a ciphertext blob copied from logs:
+  echo "AQICAHh..." | saws kms decrypt -s prod-app -r Admin -region eu-west-1
+
+  # ECR Login to every shared-services registry:
+  saws ecr-login -r Developer -s "shared-*" -regions "eu-west-1,us-east-1"
+
+  # CodeArtifact Login: point pip and npm at the shared package repository:
+  saws codeartifact-login -ca-domain acme -ca-repo internal -ca-tools pip,npm -s shared-network -r Developer -region eu-west-1
+
+  # Stack Status: did the baseline StackSet converge everywhere?
+  saws stack StackSet-org-baseline -r ReadOnly -a -regions "eu-west-1,us-east-1"
+
+  # Security Findings: critical/high across every account as JSON:
+  saws findings -r SecurityAudit -a -regions "eu-west-1,us-east-1" --json > findings.json
+
+  # Patch Compliance: the monthly patching report, one row per account/region:
+  saws patch-compliance -r ReadOnly -a -regions "eu-west-1,us-east-1"
+
+  # Config Compliance: which Config rules fail where, worst first:
+  saws config-compliance -r ReadOnly -a -regions "eu-west-1,us-east-1"
+
+  # Logs Insights: the same error search in every prod account during an incident:
+  saws logs-insights /aws/lambda/checkout 'fields @timestamp, @message | filter @message like /ERROR/ | limit 50' -r ReadOnly -s "prod-*" -since 30m
+
+  # Find Resource: which account is this instance in?
+  saws find i-0abc1234def567890 -r ReadOnly
+
+  # Find IP: who owns this address from a flow log?
+  saws find-ip 10.12.34.56 -r ReadOnly -s "prod-*"
+
+  # Tag Search: everything still tagged for a decommissioned project:
+  saws tags project=phoenix -r ReadOnly -a -regions "eu-west-1,us-east-1" --json
+
+  # Share Image: hand a golden AMI to every workload account:
+  saws share ami-0abc1234 --share-to "prod-*,staging-main" -s shared-network -r Admin -region eu-west-1
+
+  # S3 Copy: move a build artifact from the build account to prod:
+  saws s3-copy s3://build-artifacts/app/1.4.2.zip prod-main-api:s3://prod-deploy/app/ -s shared-network -r AppDeployer
+
+  # Presign: hand a partner a download link without sharing credentials:
+  saws presign s3://prod-exports/report-2024-06.csv -expires 4h -s prod-main-api -r ReadOnly
+
+  # Decode an authorization failure from an error message:
+  saws decode "$BLOB" -s prod-main-api -r Admin
+
+  # Validate Config, including an AssumeRole probe for the ReadOnly role:
+  saws config validate --probe -r ReadOnly
+
+  # Sign in to IAM Identity Center for the base profile (or a named profile), no AWS CLI needed:
+  saws sso-login
+  saws sso-login org-sso
+
+  # Init from IAM Identity Center (logs in to the profile when needed):
+  saws config init --from-sso
+
+  # Init from the named profiles in ~/.aws/config (role_arn, SSO, or granted profiles):
+  saws config init --from-aws-config
+
+  # Access matrix: after a trust-policy change, which account/role pairs can still be assumed?
+  saws verify -a -r ReadOnly,Admin
+
+  # Usage statistics: which accounts fail most, which roles are used where:
+  saws stats
+
+  # Tab completion for the current bash session (zsh: source <(saws completion zsh)):
+  source <(saws completion bash)
+`, subcommandList())
+	exit(1)
 }
 
 func main() {
 	log.SetFlags(log.Ltime)
 
+	// Installed as docker-credential-saws, docker calls "<helper> get|store|erase|list".
+	if strings.TrimSuffix(filepath.Base(os.Args[0]), ".exe") == saws.DockerCredentialHelperName && len(os.Args) > 1 {
+		os.Args = append([]string{os.Args[0], "docker-credential"}, os.Args[1:]...)
+	}
+	// Installed as git-credential-saws, git calls "<helper> [options] get|store|erase".
+	if strings.TrimSuffix(filepath.Base(os.Args[0]), ".exe") == saws.GitCredentialHelperName && len(os.Args) > 1 {
+		os.Args = append([]string{os.Args[0], "git-credential"}, os.Args[1:]...)
+	}
+
 	// Common flags
-	roleCmd := flag.String("r", "", "IAM role name.")
+	roleCmd := flag.String("r", "", "IAM role name or role ARN.")
 	selector := flag.String("s", "", "Account name selector(s).")
 	configFile := flag.String("config", "", fmt.Sprintf("Path to SAWS %s file.", pkg.ConfigFileName))
+	probeRolesFlag := flag.Bool("probe", false, "Also try AssumeRole for every account/role.")
+	aliasesFlag := flag.Bool("aliases", false, "Compare account names with their IAM account aliases.")
+	fromSSOFlag := flag.Bool("from-sso", false, "Fill accounts and roles from IAM Identity Center assignments.")
+	fromAWSConfigFlag := flag.Bool("from-aws-config", false, "Fill accounts and roles from the profiles in ~/.aws/config.")
+	contextFlag := flag.String("context", "", "Named SAWS context from the config (or SAWS_CONTEXT).")
 	help := flag.Bool("h", false, "Display help message.")
-	contextRegionFlag := flag.String("region", "", "AWS region (for -e, -ssm, or -ecs modes).")
-	verbose := flag.Bool("v", false, "Enable verbose logging.")
+	versionFlag := flag.Bool("version", false, "Print the saws version and exit.")
+	contextRegionFlag := flag.String("region", "", "AWS region (single-account commands).")
+	verboseLevel := 0
+	resumeFlag := flag.String("resume", "", "Re-run only the targets of this saved run that failed or never started (fan-out commands).")
+	retryFailedFlag := flag.Bool("retry-failed", false, "Re-run only the targets of the last run of this command that failed or never started.")
+	flag.Var(&maxFailures, "max-failures", "Exit 0 when at most this many targets fail, or this percentage with a trailing % (exec, lambda, ecr-login, automation).")
+	flag.Var(verbosity{&verboseLevel, pkg.VerboseLog}, "v", "Enable verbose logging (repeat, or use -vv/-vvv, for more).")
+	flag.Var(verbosity{&verboseLevel, pkg.VerboseTiming}, "vv", "Verbose logging plus the timing of every AWS call.")
+	flag.Var(verbosity{&verboseLevel, pkg.VerboseSDKTrace}, "vvv", "Verbose logging, AWS call timing, and SDK request/response logging (credentials redacted).")
+	adaptiveFlag := flag.Bool("adaptive", false, "Adapt the number of concurrent targets to throttling errors (fan-out commands).")
+	quietFlag := flag.Bool("q", false, "Print only the targets' output on stdout; banners and status go to stderr (fan-out commands).")
+	noColorFlag := flag.Bool("no-color", false, "Disable colored output (also NO_COLOR).")
+	noInputFlag := flag.Bool("no-input", false, "Fail instead of prompting when a value is missing (scripts and CI).")
+	noCacheFlag := flag.Bool("no-cache", false, "Do not reuse or cache assumed-role credentials for this run.")
+	externalIDFlag := flag.String("external-id", "", "sts:ExternalId passed when assuming roles; overrides the accounts' external_id.")
+	stsEndpointFlag := flag.String("sts-endpoint", "", "STS endpoint of AssumeRole calls: 'regional' or a URL; overrides defaults.sts_endpoint.")
+	stsRegionFlag := flag.String("sts-region", "", "Region whose STS endpoint AssumeRole calls use; overrides defaults.sts_region.")
+	forceFlag := flag.Bool("force", false, "Skip the confirmation when saws runs inside another saws session or over exported AWS credentials.")
 
 	// Command Mode flags
 	command := flag.String("c", "", "Command to execute (enables Command Execution Mode).")
-	cmdRegionsStr := flag.String("regions", "", "Comma-separated regions for command execution (Command Mode only).")
-	processAll := flag.Bool("a", false, "Process ALL accounts (Command Mode only).")
+	cmdRegionsStr := flag.String("regions", "", "Comma-separated regions (fan-out commands).")
+	processAll := flag.Bool("a", false, "Process ALL accounts (fan-out commands).")
+	publishFlag := flag.String("publish", "", "SNS topic or EventBridge event bus ARN to send a summary of the run to (exec).")
+	publishTargetsFlag := flag.Bool("publish-targets", false, "Include the result of every target in the -publish event.")
+	timingsFlag := flag.Bool("timings", false, "Print an assume-role/command timing breakdown and the slowest and most failing accounts (exec).")
+	workdirFlag := flag.String("workdir", "", "Working directory of each execution, a template such as ./envs/{{.AccountName}} (exec).")
+	preflightFlag := flag.Bool("preflight", false, "Simulate the command's IAM actions in every account before running it (exec).")
+	preflightActionsFlag := flag.String("preflight-actions", "", "Comma-separated IAM actions to simulate before running (exec); implies -preflight.")
+	metricsFileFlag := flag.String("metrics-file", os.Getenv("SAWS_METRICS_FILE"), "Write Prometheus metrics of fan-out runs to this file (textfile collector).")
+	pushgatewayFlag := flag.String("pushgateway", os.Getenv("SAWS_PUSHGATEWAY"), "Push Prometheus metrics of fan-out runs to this Pushgateway URL.")
+	otlpEndpointFlag := flag.String("otlp-endpoint", "", "Send traces of fan-out runs to this OTLP/HTTP endpoint (or OTEL_EXPORTER_OTLP_ENDPOINT).")
 
 	// Interactive Sub-Shell Mode flag
 	sessionModeFlag := flag.Bool("e", false, "Enable interactive sub-shell session mode.")
 
 	// SSM Session Mode flags
 	ssmSessionFlag := flag.Bool("ssm", false, "Enable interactive SSM session to an EC2 instance.")
-	instanceIDFlag := flag.String("i", "", "Target EC2 instance ID for SSM session (Optional).")
+	instanceIDFlag := flag.String("i", "", "Target EC2 instance ID (prompts if omitted).")
+	instanceStateFlag := flag.String("instance-state", "", "Only offer instances in these comma-separated EC2 states or SSM ping statuses (running, stopped, connectionlost, ...).")
+	allInstancesFlag := flag.Bool("all-instances", false, "Also offer stopped instances from EC2, with an offer to start the one picked.")
+	socketFlag := flag.String("socket", "", "Unix socket the daemon listens on (default: ~/.aws/saws.sock).")
+	tfBinFlag := flag.String("tf-bin", saws.DefaultTerraformBinary, "Terraform binary for 'saws tf', e.g. terragrunt.")
+	tfStateKeyFlag := flag.String("tf-state-key", saws.DefaultTerraformStateKey, "Backend state key of each target when 'saws tf' runs across accounts; {account}, {account_id}, and {region} are replaced.")
+	lastFlag := flag.Bool("last", false, "Reuse the most recent account, role, and region (and -ssm instance) instead of prompting.")
 
 	// ECS Exec Session Mode flags
 	ecsModeFlag := flag.Bool("ecs", false, "Enable interactive ECS exec session mode.")
-	ecsClusterFlag := flag.String("ecs-cluster", "", "Target ECS cluster name or ARN (ECS Mode only).")
-	ecsTaskFlag := flag.String("ecs-task", "", "Target ECS task ID or ARN (ECS Mode only).")
-	ecsContainerFlag := flag.String("ecs-container", "", "Target ECS container name (ECS Mode only).")
-	ecsCommandFlag := flag.String("ecs-command", "", "Command to run in the ECS container (default: /bin/sh) (ECS Mode only).")
+	ecsClusterFlag := flag.String("ecs-cluster", "", "Target ECS cluster name or ARN.")
+	ecsTaskFlag := flag.String("ecs-task", "", "Target ECS task ID or ARN.")
+	ecsContainerFlag := flag.String("ecs-container", "", "Target ECS container name.")
+	ecsCommandFlag := flag.String("ecs-command", "", "Command to run in the ECS container (default: /bin/sh).")
+	ecsServiceFlag := flag.String("ecs-service", "", "Target ECS service name for 'saws ecs-service-exec' (prompts if omitted).")
+	ecsHostFlag := flag.Bool("ecs-host", false, "Open an SSM session to the EC2 container instance of the task instead of the container.")
+
+	// EKS Token Mode flags
+	eksTokenFlag := flag.Bool("eks-token", false, "Print a kubectl ExecCredential token for an EKS cluster.")
+	eksClusterFlag := flag.String("eks-cluster", "", "Target EKS cluster name, or an entry of eks_clusters in the config.")
+	kubeconfigFlag := flag.String("kubeconfig", "", "Kubeconfig file 'saws kubeconfig' updates (default: first KUBECONFIG entry, then ~/.kube/config).")
+
+	// RDS Token Mode flags
+	rdsTokenFlag := flag.Bool("rds-token", false, "Generate an RDS IAM authentication token.")
+	rdsInstanceFlag := flag.String("rds-instance", "", "Target RDS DB instance identifier.")
+	dbUserFlag := flag.String("db-user", "", "Database user to authenticate as.")
+	rdsTunnelFlag := flag.Bool("rds-tunnel", false, "Open an SSM port forward to the DB instance.")
+	localPortFlag := flag.Int("local-port", 0, "Local port for SSM port forwarding (default: remote port for rds-token, a free port otherwise).")
+
+	// Database Connect Mode flags
+	dbModeFlag := flag.Bool("db", false, "Enable database connect mode through an SSM tunnel.")
+	dbNameFlag := flag.String("db-name", "", "Database name to connect to.")
+	bastionFlag := flag.String("bastion", "", "Bastion instance ID or computer-name wildcard for SSM tunnels (prompts if omitted).")
+
+	// Named Tunnel Mode flag
+	tunnelFlag := flag.String("tunnel", "", "Bring up a named tunnel from the SAWS config.")
+
+	// Cache Connect Mode flags
+	redisModeFlag := flag.Bool("redis", false, "Enable ElastiCache/MemoryDB connect mode through an SSM tunnel.")
+	cacheFlag := flag.String("cache", "", "Target cache name.")
+
+	// OpenSearch Tunnel Mode flags
+	opensearchModeFlag := flag.Bool("opensearch", false, "Enable OpenSearch Dashboards tunnel mode.")
+	opensearchDomainFlag := flag.String("os-domain", "", "Target OpenSearch domain name.")
+	openBrowserFlag := flag.Bool("open", false, "Open the Dashboards URL in a browser.")
+
+	// Lambda Invoke Mode flags
+	lambdaFunctionFlag := flag.String("lambda", "", "Lambda function name to invoke (enables Lambda Invoke Mode).")
+	lambdaPayloadFlag := flag.String("payload", "", "Path to a JSON payload file.")
+
+	// Alarm Status Mode flags
+	alarmsModeFlag := flag.Bool("alarms", false, "List CloudWatch alarms in ALARM state across accounts/regions.")
+
+	// Parameter Store Mode flags
+	paramOpFlag := flag.String("param", "", "Parameter Store operation: get or put (enables Parameter Store Mode).")
+	paramTypeFlag := flag.String("param-type", "", "Parameter type for 'param put' (String, StringList, SecureString).")
+
+	// KMS Mode flags
+	kmsEncryptFlag := flag.Bool("kms-encrypt", false, "Encrypt input with KMS under the assumed role.")
+	kmsDecryptFlag := flag.Bool("kms-decrypt", false, "Decrypt KMS ciphertext under the assumed role.")
+	kmsKeyFlag := flag.String("kms-key", "", "KMS key ID, ARN, or alias.")
+	kmsInFlag := flag.String("in", "", "Input file (default: stdin).")
+
+	// ECR Login Mode flags
+	ecrLoginFlag := flag.Bool("ecr-login", false, "Log docker in to the ECR registries of the selected accounts/regions.")
+
+	// CodeArtifact Login Mode flags
+	codeArtifactLoginFlag := flag.Bool("codeartifact-login", false, "Configure pip, npm, and maven for a CodeArtifact repository.")
+	caDomainFlag := flag.String("ca-domain", "", "CodeArtifact domain name.")
+	caRepoFlag := flag.String("ca-repo", "", "CodeArtifact repository name.")
+	caDomainOwnerFlag := flag.String("ca-domain-owner", "", "Account name or ID owning the CodeArtifact domain (default: the selected account).")
+	caToolsFlag := flag.String("ca-tools", "", "Comma-separated tools to configure: pip, npm, maven (default: those installed).")
+
+	// Docker Credential Helper flags
+	dockerCredentialFlag := flag.String("docker-credential", "", "Docker credential-helper action: get, store, erase, or list.")
+
+	// Stack Status Mode flags
+	stackNameFlag := flag.String("stack", "", "CloudFormation stack name to report across accounts/regions (enables Stack Status Mode).")
+
+	// Security Findings Mode flags
+	findingsModeFlag := flag.Bool("findings", false, "List active HIGH/CRITICAL security findings across accounts/regions.")
+	findingsSourceFlag := flag.String("findings-source", saws.FindingsSourceAll, "Findings source: all, securityhub, or guardduty.")
+	jsonOutputFlag := flag.Bool("json", false, "Print results as JSON.")
+	outputFlag := flag.String("output", "", "Output format, text or json (default: defaults.output from the config, then text).")
+
+	// Logs Insights Mode flags
+	sinceFlag := flag.Duration("since", saws.DefaultLogsQuerySince, "How far back 'saws logs-insights' queries, e.g. 15m or 24h.")
+
+	// Tag Search Mode flags
+	tagSearchFlag := flag.String("tags", "", "Tag filters key=value[,key2=value2] to search for (enables Tag Search Mode).")
+
+	// Share Image Mode flags
+	shareResourceFlag := flag.String("share", "", "AMI or snapshot ID to share (enables Share Image Mode).")
+	shareToFlag := flag.String("share-to", "", "Destination account names/wildcards.")
+
+	// S3 Copy Mode flags
+	destRoleFlag := flag.String("dest-role", "", "Role to assume in the destination account.")
+
+	// Presign Mode flags
+	expiresFlag := flag.Duration("expires", saws.DefaultPresignExpires, "How long a 'saws presign' URL is valid, e.g. 15m or 24h.")
+	presignPutFlag := flag.Bool("put", false, "Presign an upload (PUT) instead of a download with 'saws presign'.")
 
 	flag.Usage = usage
-	flag.Parse()
+	// The completion scripts call back with flag values to complete; answer
+	// before parsing, as those arguments are not saws's own.
+	if len(os.Args) > 1 && os.Args[1] == completeCommand {
+		writeDynamicCompletions(os.Stdout, os.Args[2:])
+		os.Exit(0)
+	}
+	positionalArgs := resolveSubcommand(parseFlagsAndArgs())
 
-	pkg.VerboseMode = *verbose
+	pkg.VerboseLevel = min(verboseLevel, pkg.VerboseSDKTrace)
+	pkg.VerboseMode = pkg.VerboseLevel >= pkg.VerboseLog
+	pkg.NoInput = *noInputFlag
+	pkg.Force = *forceFlag
+	pkg.ExternalIDOverride = *externalIDFlag
+	pkg.NoColor = *noColorFlag
+	pkg.QuietMode = *quietFlag
 
 	if !pkg.VerboseMode {
 		log.SetOutput(io.Discard)
 	} else {
-		log.SetOutput(os.Stderr)
+		log.SetOutput(pkg.RedactingWriter(os.Stderr))
+	}
+
+	if *help {
+		usage()
+	}
+	if *versionFlag {
+		fmt.Println(versionLine())
+		v, _ := buildVersion()
+		pkg.NotifyUpdate(context.Background(), v)
+		exit(0)
+	}
+
+	if len(positionalArgs) > 0 && positionalArgs[0] == "completion" {
+		if len(positionalArgs) != 2 {
+			fmt.Fprintf(os.Stderr, "Error: Use 'saws completion %s'.\n", strings.Join(completionShells, "|"))
+			exit(1)
+		}
+		if err := writeCompletion(os.Stdout, positionalArgs[1]); err != nil {
+			fmt.Fprintf(os.Stderr, "Completion: %v\n", err)
+			exit(1)
+		}
+		exit(0)
+	}
+
+	// time-left runs from shell prompts, so it needs no config and is not recorded.
+	if len(positionalArgs) > 0 && positionalArgs[0] == "time-left" {
+		if err := saws.HandleTimeLeft(); err != nil {
+			fmt.Fprintf(os.Stderr, "Time Left: %v\n", err)
+			exit(1)
+		}
+		exit(0)
+	}
+
+	// history, replay, and stats read the history rather than add to it, and the helper
+	// modes docker and kubectl call on every use would drown it out.
+	if len(positionalArgs) > 0 && positionalArgs[0] == "history" {
+		if err := saws.HandleHistory(); err != nil {
+			fmt.Fprintf(os.Stderr, "History Mode: %v\n", err)
+			exit(1)
+		}
+		exit(0)
+	}
+	if len(positionalArgs) > 0 && positionalArgs[0] == "replay" {
+		id, errID := 0, error(nil)
+		if len(positionalArgs) == 2 {
+			id, errID = strconv.Atoi(positionalArgs[1])
+		}
+		if len(positionalArgs) != 2 || errID != nil {
+			fmt.Fprintln(os.Stderr, "Error: Use 'saws replay <n>' with an ID from 'saws history'.")
+			usage()
+		}
+		code, err := saws.HandleReplay(id)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Replay Mode: %v\n", err)
+		}
+		exit(code)
+	}
+	// docker, kubectl, and git call the credential helpers on every use.
+	isGitCredentialMode := len(positionalArgs) > 0 && positionalArgs[0] == "git-credential"
+	isCredentialHelper := *dockerCredentialFlag != "" || *eksTokenFlag || isGitCredentialMode
+	isStatsMode := len(positionalArgs) > 0 && positionalArgs[0] == "stats"
+	if !isCredentialHelper && !isStatsMode {
+		pkg.StartHistory(os.Args[1:])
+		if *paramOpFlag == saws.ParamOpPut && len(positionalArgs) == 2 {
+			pkg.RedactHistoryArg(positionalArgs[1])
+		}
+	}
+
+	// sso-login runs without a config when given a profile, e.g. before
+	// 'saws init --from-sso'; otherwise it logs in to the config's base profile.
+	if len(positionalArgs) > 0 && positionalArgs[0] == "sso-login" {
+		if len(positionalArgs) > 2 {
+			fmt.Fprintln(os.Stderr, "Error: Use 'saws sso-login [profile]'.")
+			exit(1)
+		}
+		profile := ""
+		if len(positionalArgs) == 2 {
+			profile = positionalArgs[1]
+		} else {
+			profile = configuredBaseProfile(*configFile, *contextFlag)
+		}
+		if err := saws.HandleSSOLogin(context.Background(), profile); err != nil {
+			fmt.Fprintf(os.Stderr, "SSO Login: %v\n", err)
+			exit(1)
+		}
+		exit(0)
+	}
+
+	// init creates the config, so it runs before one is looked for.
+	if len(positionalArgs) > 0 && positionalArgs[0] == "init" {
+		if err := saws.HandleInit(context.Background(), *fromSSOFlag, *fromAWSConfigFlag); err != nil {
+			fmt.Fprintf(os.Stderr, "Init failed: %v\n", err)
+			exit(1)
+		}
+		exit(0)
 	}
 
 	sawsConfigPath, err := pkg.FindConfigPath(*configFile)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "SAWS Config Error: %v\n", err)
-		os.Exit(1)
+		exit(1)
+	}
+
+	// validate-config must run on configs that LoadConfig would reject.
+	if len(positionalArgs) > 0 && positionalArgs[0] == "validate-config" {
+		if err := saws.HandleValidateConfig(context.Background(), sawsConfigPath, *contextFlag, *probeRolesFlag, *aliasesFlag, *roleCmd); err != nil {
+			fmt.Fprintf(os.Stderr, "Config validation failed: %v\n", err)
+			exit(1)
+		}
+		exit(0)
+	}
+
+	// A .saws.yaml in the working directory's repository supplies defaults.
+	project, err := pkg.FindProjectFile()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "SAWS Project Error: %v\n", err)
+		exit(1)
 	}
-	appConfig, err := pkg.LoadConfig(sawsConfigPath)
+	appConfig, err := pkg.LoadConfig(sawsConfigPath, project.ContextName(*contextFlag))
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "SAWS Config Error: %v\n", err)
-		os.Exit(1)
+		exit(1)
+	}
+	if *adaptiveFlag {
+		pkg.AdaptiveParallelism = true
+	}
+	if *noCacheFlag {
+		pkg.CredentialCache = false
+	}
+	if *stsEndpointFlag != "" {
+		if err := pkg.ValidateSTSEndpoint(*stsEndpointFlag); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: -sts-endpoint: %v\n", err)
+			exit(1)
+		}
+		pkg.STSEndpoint = *stsEndpointFlag
+	}
+	if *stsRegionFlag != "" {
+		if err := pkg.ValidateRegions("-sts-region", *stsRegionFlag); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			exit(1)
+		}
+		pkg.STSRegion = *stsRegionFlag
 	}
 	ctx := context.Background()
+	// The credential helpers run constantly and their callers relay stderr.
+	if !isCredentialHelper && !pkg.QuietMode {
+		v, _ := buildVersion()
+		pkg.NotifyUpdate(ctx, v)
+	}
 
-	if *help {
-		usage()
-		return
+	asJSON := *jsonOutputFlag
+	switch *outputFlag {
+	case "":
+		asJSON = asJSON || pkg.OutputFormat == pkg.OutputJSON
+	case pkg.OutputJSON:
+		asJSON = true
+	case pkg.OutputText:
+	default:
+		fmt.Fprintf(os.Stderr, "Error: -output must be '%s' or '%s'.\n", pkg.OutputText, pkg.OutputJSON)
+		exit(1)
+	}
+
+	if len(positionalArgs) > 0 && positionalArgs[0] == "daemon" {
+		// Requests cannot answer prompts.
+		pkg.NoInput = true
+		pkg.SkipNestedSessionCheck = true
+		if err := saws.HandleDaemon(ctx, appConfig, *socketFlag); err != nil {
+			fmt.Fprintf(os.Stderr, "Daemon Mode: %v\n", err)
+			exit(1)
+		}
+		exit(0)
+	}
+
+	if isGitCredentialMode {
+		if len(positionalArgs) != 2 {
+			fmt.Fprintln(os.Stderr, "Error: Use 'saws git-credential get|store|erase'.")
+			exit(1)
+		}
+		// git runs the helper inside saws sub-shells too, and cannot answer prompts.
+		pkg.SkipNestedSessionCheck = true
+		pkg.NoInput = true
+		if err := saws.HandleGitCredential(ctx, appConfig, positionalArgs[1], *selector, *roleCmd, os.Stdin, os.Stdout); err != nil {
+			fmt.Fprintf(os.Stderr, "saws git-credential: %v\n", err)
+			exit(1)
+		}
+		exit(0)
+	}
+
+	if len(positionalArgs) > 0 && positionalArgs[0] == "kubeconfig" {
+		if err := saws.HandleKubeconfig(ctx, appConfig, *configFile, *contextFlag, *kubeconfigFlag, positionalArgs[1:]); err != nil {
+			fmt.Fprintf(os.Stderr, "Kubeconfig Mode: %v\n", err)
+			exit(1)
+		}
+		exit(0)
+	}
+
+	// stats runs after the config is loaded for defaults.audit_log.
+	if isStatsMode {
+		if err := saws.HandleStats(asJSON); err != nil {
+			fmt.Fprintf(os.Stderr, "Stats Mode: %v\n", err)
+			exit(1)
+		}
+		exit(0)
+	}
+
+	if len(positionalArgs) > 0 && positionalArgs[0] == "list" {
+		if len(positionalArgs) != 2 {
+			fmt.Fprintf(os.Stderr, "Error: Use 'saws list %s'.\n", strings.Join(saws.ListKinds, "|"))
+			exit(1)
+		}
+		if err := saws.HandleList(appConfig, positionalArgs[1], asJSON); err != nil {
+			fmt.Fprintf(os.Stderr, "List Mode: %v\n", err)
+			exit(1)
+		}
+		exit(0)
+	}
+
+	// recent resumes a remembered selection as an -ssm session or an -e shell;
+	// last does the same with the newest one, without the picker.
+	if len(positionalArgs) > 0 && (positionalArgs[0] == "recent" || positionalArgs[0] == "last") {
+		pick := saws.HandleRecent
+		if positionalArgs[0] == "last" {
+			pick = saws.HandleLast
+		}
+		sel, err := pick()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Recent Mode: %v\n", err)
+			exit(1)
+		}
+		*selector, *roleCmd, *contextRegionFlag = sel.Account, sel.Role, sel.Region
+		if sel.Session == saws.RecentSSMSession && sel.Instance != "" {
+			*ssmSessionFlag, *instanceIDFlag = true, sel.Instance
+		} else {
+			*sessionModeFlag = true
+		}
+	} else if *lastFlag {
+		// -last fills in what the command line leaves out, so any session mode
+		// reconnects to the newest selection without prompts.
+		sel, err := saws.HandleLast()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: -last: %v\n", err)
+			exit(1)
+		}
+		if *selector == "" {
+			*selector = sel.Account
+		}
+		if *roleCmd == "" {
+			*roleCmd = sel.Role
+		}
+		if *contextRegionFlag == "" {
+			*contextRegionFlag = sel.Region
+		}
+		if *ssmSessionFlag && *instanceIDFlag == "" && sel.Session == saws.RecentSSMSession {
+			*instanceIDFlag = sel.Instance
+		}
 	}
 
 	isCommandMode := *command != ""
 	isSessionMode := *sessionModeFlag
 	isSSMSessionMode := *ssmSessionFlag
 	isECSMode := *ecsModeFlag
-
-	modeCount := 0
-	if isCommandMode {
-		modeCount++
+	isEKSTokenMode := *eksTokenFlag
+	isRDSTokenMode := *rdsTokenFlag
+	isDBMode := *dbModeFlag
+	isTunnelMode := *tunnelFlag != ""
+	isRedisMode := *redisModeFlag
+	isOpensearchMode := *opensearchModeFlag
+	isLambdaMode := *lambdaFunctionFlag != ""
+	isAlarmsMode := *alarmsModeFlag
+	isParamMode := *paramOpFlag != ""
+	isKMSMode := *kmsEncryptFlag || *kmsDecryptFlag
+	isECRLoginMode := *ecrLoginFlag
+	isCodeArtifactLoginMode := *codeArtifactLoginFlag
+	isDockerCredentialMode := *dockerCredentialFlag != ""
+	// The credential helpers docker and kubectl call run inside saws sub-shells by design.
+	pkg.SkipNestedSessionCheck = isEKSTokenMode || isDockerCredentialMode
+	isStackMode := *stackNameFlag != ""
+	isFindingsMode := *findingsModeFlag
+	// With -e, the positional arguments are the command to run, e.g. 'saws -e -- find . -name x'.
+	isFindMode := !isSessionMode && len(positionalArgs) > 0 && positionalArgs[0] == "find"
+	isFindIPMode := !isSessionMode && len(positionalArgs) > 0 && positionalArgs[0] == "find-ip"
+	isTagSearchMode := *tagSearchFlag != ""
+	isShareMode := *shareResourceFlag != ""
+	isS3CopyMode := !isSessionMode && len(positionalArgs) > 0 && positionalArgs[0] == "s3-copy"
+	isPresignMode := !isSessionMode && len(positionalArgs) > 0 && positionalArgs[0] == "presign"
+	isDecodeMode := !isSessionMode && len(positionalArgs) > 0 && positionalArgs[0] == "decode"
+	isTerraformMode := !isSessionMode && len(positionalArgs) > 0 && positionalArgs[0] == "tf"
+	isVerifyMode := !isSessionMode && len(positionalArgs) > 0 && positionalArgs[0] == "verify"
+	isAutomationMode := !isSessionMode && len(positionalArgs) > 0 && positionalArgs[0] == "automation"
+	isPatchComplianceMode := !isSessionMode && len(positionalArgs) > 0 && positionalArgs[0] == "patch-compliance"
+	isConfigComplianceMode := !isSessionMode && len(positionalArgs) > 0 && positionalArgs[0] == "config-compliance"
+	isECSServiceExecMode := !isSessionMode && len(positionalArgs) > 0 && positionalArgs[0] == "ecs-service-exec"
+	isLogsInsightsMode := !isSessionMode && len(positionalArgs) > 0 && positionalArgs[0] == "logs-insights"
+	deployTool := ""
+	if !isSessionMode && len(positionalArgs) > 0 && slices.Contains(saws.DeployTools, positionalArgs[0]) {
+		deployTool = positionalArgs[0]
 	}
-	if isSessionMode {
-		modeCount++
-	}
-	if isSSMSessionMode {
-		modeCount++
+	isDeployMode := deployTool != ""
+
+	modes := []struct {
+		enabled bool
+		name    string
+	}{
+		{isCommandMode, "exec"},
+		{isSessionMode, "shell"},
+		{isSSMSessionMode, "ssm"},
+		{isECSMode, "ecs"},
+		{isEKSTokenMode, "eks-token"},
+		{isRDSTokenMode, "rds-token"},
+		{isDBMode, "db"},
+		{isTunnelMode, "tunnel"},
+		{isRedisMode, "redis"},
+		{isOpensearchMode, "opensearch"},
+		{isLambdaMode, "lambda"},
+		{isAlarmsMode, "alarms"},
+		{isParamMode, "param"},
+		{isKMSMode, "kms"},
+		{isECRLoginMode, "ecr-login"},
+		{isCodeArtifactLoginMode, "codeartifact-login"},
+		{isDockerCredentialMode, "docker-credential"},
+		{isStackMode, "stack"},
+		{isFindingsMode, "findings"},
+		{isFindMode, "find"},
+		{isFindIPMode, "find-ip"},
+		{isTagSearchMode, "tags"},
+		{isShareMode, "share"},
+		{isS3CopyMode, "s3-copy"},
+		{isPresignMode, "presign"},
+		{isDecodeMode, "decode"},
+		{isTerraformMode, "tf"},
+		{isVerifyMode, "verify"},
+		{isAutomationMode, "automation"},
+		{isPatchComplianceMode, "patch-compliance"},
+		{isConfigComplianceMode, "config-compliance"},
+		{isECSServiceExecMode, "ecs-service-exec"},
+		{isLogsInsightsMode, "logs-insights"},
+		{isDeployMode, deployTool},
 	}
-	if isECSMode {
-		modeCount++
+	modeCount, modeName := 0, ""
+	for _, mode := range modes {
+		if mode.enabled {
+			modeCount++
+			modeName = mode.name
+		}
 	}
 
 	if modeCount > 1 {
-		fmt.Fprintln(os.Stderr, "Error: Cannot use -c, -e, -ssm, and -ecs flags together. Please choose one mode.")
+		fmt.Fprintln(os.Stderr, "Error: Cannot combine commands or mode flags (-c, -e, -ssm, -ecs, ...). Please choose one.")
+		usage()
+	}
+	// docker and kubectl run the credential helpers from arbitrary directories.
+	if project != nil && !isEKSTokenMode && !isDockerCredentialMode {
+		accountSelector := selector
+		if *processAll {
+			// -a already targets every account.
+			accountSelector = new(string)
+		}
+		project.ApplyDefaults(accountSelector, roleCmd, contextRegionFlag, slices.Contains(fanOutModes, modeName))
+		if project.Runbook != "" && !pkg.QuietMode {
+			fmt.Fprintf(os.Stderr, "Runbook (%s): %s\n", project.Path, project.Runbook)
+		}
+	}
+	// `saws <name>` runs the plugin saws-<name> from PATH in the selected context.
+	if modeCount == 0 && len(positionalArgs) > 0 {
+		if pluginPath, ok := saws.FindPlugin(positionalArgs[0]); ok {
+			pkg.NoteHistoryMode(positionalArgs[0])
+			code, errPlugin := saws.HandlePlugin(ctx, pluginPath, positionalArgs[0], positionalArgs[1:], sawsConfigPath, *selector, *roleCmd, *contextRegionFlag)
+			if errPlugin != nil {
+				fmt.Fprintf(os.Stderr, "Plugin Mode: %v\n", errPlugin)
+			}
+			exit(code)
+		}
+		fmt.Fprintf(os.Stderr, "Error: Unknown command '%s', and no plugin '%s%s' on PATH.\n", positionalArgs[0], "saws-", positionalArgs[0])
 		usage()
 	}
 	if modeCount == 0 {
-		fmt.Fprintln(os.Stderr, "Error: No mode selected. Please specify -c, -e, -ssm, or -ecs.")
+		fmt.Fprintln(os.Stderr, "Error: No command given. Please specify one such as exec, shell, ssm, or ecs (see -h).")
 		usage()
 	}
+	pkg.NoteHistoryMode(modeName)
+	saws.Telemetry = saws.TelemetryOptions{Mode: modeName, MetricsFile: *metricsFileFlag, Pushgateway: *pushgatewayFlag, OTLPEndpoint: *otlpEndpointFlag}
+	if *resumeFlag != "" || *retryFailedFlag {
+		if !slices.Contains(fanOutModes, modeName) {
+			fmt.Fprintf(os.Stderr, "Error: -resume and -retry-failed apply to fan-out commands, not %s.\n", modeName)
+			exit(1)
+		}
+		saws.Resume = saws.ResumeOptions{RunID: *resumeFlag, RetryFailed: *retryFailedFlag}
+		if err := saws.CheckResume(modeName); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			exit(1)
+		}
+	}
 
 	if isSessionMode {
 		if *cmdRegionsStr != "" {
@@ -183,21 +943,20 @@ func main() {
 		sCtx, creds, errCtx := pkg.EstablishAWSContextAndAssumeRole(ctx, *selector, *roleCmd, *contextRegionFlag, "InteractiveSubShell")
 		if errCtx != nil {
 			fmt.Fprintf(os.Stderr, "Failed to establish AWS context for sub-shell: %v\n", errCtx)
-			os.Exit(1)
+			exit(1)
 		}
-		fmt.Fprintln(os.Stderr, "# Optional: To show saws context in your prompt (for -e sub-shell), add to your ~/.bashrc or ~/.zshrc:")
-		fmt.Fprintln(os.Stderr, "#   if [ -n \"$SAWS_INFO_ACCOUNT_NAME\" ]; then")
-		fmt.Fprintln(os.Stderr, "#     SAWS_PROMPT=\"(\\[\\033[01;32m\\]${SAWS_INFO_ACCOUNT_NAME}(${SAWS_INFO_ACCOUNT_ID})/${SAWS_INFO_ROLE_NAME}/${SAWS_INFO_REGION}\\[\\033[00m\\]):\\[\\033[01;34m\\]\\w\\[\\033[00m\\]\\$ \"")
-		fmt.Fprintln(os.Stderr, "#     PS1=\"$SAWS_PROMPT\" # Or integrate into your existing PS1 logic")
-		fmt.Fprintln(os.Stderr, "#   fi")
-		fmt.Fprintln(os.Stderr, "# -------------------------------------------------------------------------------------------------")
-
-		errCtx = saws.StartInteractiveSubShell(sCtx, creds)
+		if len(positionalArgs) > 0 {
+			code, errRun := saws.RunInSessionContext(sCtx, creds, positionalArgs)
+			if errRun != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", errRun)
+			}
+			exit(code)
+		}
+		code, errCtx := saws.StartInteractiveSubShell(sCtx, creds)
 		if errCtx != nil {
 			fmt.Fprintf(os.Stderr, "Interactive sub-shell session failed: %v\n", errCtx)
-			os.Exit(1)
 		}
-		os.Exit(0)
+		exit(code)
 
 	} else if isSSMSessionMode {
 		if *cmdRegionsStr != "" {
@@ -214,12 +973,13 @@ func main() {
 			fmt.Fprintln(os.Stderr, "Warning: --ecs-* flags are ignored in SSM session mode (-ssm). Used with -ecs.")
 		}
 
-		errCtx := saws.HandleSSMSession(ctx, *instanceIDFlag, *selector, *roleCmd, *contextRegionFlag)
+		errCtx := saws.HandleSSMSession(ctx, *instanceIDFlag, *selector, *roleCmd, *contextRegionFlag,
+			saws.SSMInstanceFilter{States: saws.ParseInstanceStates(*instanceStateFlag), IncludeStopped: *allInstancesFlag})
 		if errCtx != nil {
 			fmt.Fprintf(os.Stderr, "SSM session failed: %v\n", errCtx)
-			os.Exit(1)
+			exit(1)
 		}
-		os.Exit(0)
+		exit(0)
 
 	} else if isECSMode {
 		if *cmdRegionsStr != "" {
@@ -235,29 +995,442 @@ func main() {
 			fmt.Fprintln(os.Stderr, "Warning: -i (instance-id) flag ignored in ECS exec session mode (-ecs).")
 		}
 
-		errCtx := saws.HandleEcsExecSession(ctx, appConfig, *ecsClusterFlag, *ecsTaskFlag, *ecsContainerFlag, *ecsCommandFlag, *selector, *roleCmd, *contextRegionFlag)
+		errCtx := saws.HandleEcsExecSession(ctx, appConfig, *ecsClusterFlag, *ecsTaskFlag, *ecsContainerFlag, *ecsCommandFlag, *selector, *roleCmd, *contextRegionFlag, *ecsHostFlag)
 		if errCtx != nil {
 			fmt.Fprintf(os.Stderr, "ECS exec session failed: %v\n", errCtx)
-			os.Exit(1)
+			exit(1)
 		}
-		os.Exit(0)
+		exit(0)
 
-	} else if isCommandMode {
-		if *roleCmd == "" {
-			fmt.Fprintln(os.Stderr, "Error: Role (-r) is mandatory for Command Execution Mode.")
+	} else if isECSServiceExecMode {
+		if len(positionalArgs) < 2 {
+			fmt.Fprintln(os.Stderr, "Error: Use 'saws ecs-service-exec [options] -- <cmd...>'.")
 			usage()
 		}
-		if *processAll && *selector != "" {
-			fmt.Fprintln(os.Stderr, "Error: Cannot use both -a and -s in Command Mode.")
+		errExec := saws.HandleEcsServiceExec(ctx, *ecsClusterFlag, *ecsServiceFlag, *ecsContainerFlag, saws.ShellJoin(positionalArgs[1:]), *selector, *roleCmd, *contextRegionFlag)
+		if errExec != nil {
+			fmt.Fprintf(os.Stderr, "ECS service exec failed: %v\n", errExec)
+			exit(1)
+		}
+		exit(0)
+
+	} else if isEKSTokenMode {
+		// kubectl runs exec plugins without a usable TTY on stdout, so every
+		// piece of context must be supplied up front instead of prompted for.
+		// A cluster under eks_clusters: supplies its own account, role, and region.
+		if cluster, ok := appConfig.EKSClusters[*eksClusterFlag]; ok {
+			*eksClusterFlag = cluster.ClusterName(*eksClusterFlag)
+			if *selector == "" {
+				*selector = cluster.Account
+			}
+			if *roleCmd == "" {
+				*roleCmd = cluster.Role
+			}
+			if *contextRegionFlag == "" {
+				*contextRegionFlag = cluster.Region
+			}
+		}
+		if *eksClusterFlag == "" || *selector == "" || *roleCmd == "" || *contextRegionFlag == "" {
+			fmt.Fprintln(os.Stderr, "Error: --eks-cluster, -s, -r, and -region are mandatory for EKS Token Mode (unless the cluster is under eks_clusters:).")
 			usage()
 		}
-		if !*processAll && *selector == "" {
-			fmt.Fprintln(os.Stderr, "Error: Must use -a or -s in Command Mode.")
+
+		errCtx := saws.HandleEksToken(ctx, *eksClusterFlag, *selector, *roleCmd, *contextRegionFlag)
+		if errCtx != nil {
+			fmt.Fprintf(os.Stderr, "EKS token generation failed: %v\n", errCtx)
+			exit(1)
+		}
+		exit(0)
+
+	} else if isRDSTokenMode {
+		errCtx := saws.HandleRdsToken(ctx, *rdsInstanceFlag, *dbUserFlag, *rdsTunnelFlag, *localPortFlag, *selector, *roleCmd, *contextRegionFlag)
+		if errCtx != nil {
+			fmt.Fprintf(os.Stderr, "RDS token generation failed: %v\n", errCtx)
+			exit(1)
+		}
+		exit(0)
+
+	} else if isDBMode {
+		errCtx := saws.HandleDbConnect(ctx, *rdsInstanceFlag, *dbUserFlag, *dbNameFlag, *bastionFlag, *localPortFlag, *selector, *roleCmd, *contextRegionFlag)
+		if errCtx != nil {
+			fmt.Fprintf(os.Stderr, "Database connect session failed: %v\n", errCtx)
+			exit(1)
+		}
+		exit(0)
+
+	} else if isTunnelMode {
+		if *selector != "" {
+			fmt.Fprintln(os.Stderr, "Warning: -s flag ignored in named tunnel mode (-tunnel). The tunnel definition sets the account.")
+		}
+
+		errCtx := saws.HandleNamedTunnel(ctx, appConfig, *tunnelFlag, *roleCmd, *contextRegionFlag, *localPortFlag)
+		if errCtx != nil {
+			fmt.Fprintf(os.Stderr, "Tunnel failed: %v\n", errCtx)
+			exit(1)
+		}
+		exit(0)
+
+	} else if isRedisMode {
+		errCtx := saws.HandleRedisConnect(ctx, *cacheFlag, *bastionFlag, *localPortFlag, *selector, *roleCmd, *contextRegionFlag)
+		if errCtx != nil {
+			fmt.Fprintf(os.Stderr, "Cache connect session failed: %v\n", errCtx)
+			exit(1)
+		}
+		exit(0)
+
+	} else if isOpensearchMode {
+		errCtx := saws.HandleOpensearchTunnel(ctx, *opensearchDomainFlag, *bastionFlag, *localPortFlag, *openBrowserFlag, *selector, *roleCmd, *contextRegionFlag)
+		if errCtx != nil {
+			fmt.Fprintf(os.Stderr, "OpenSearch tunnel failed: %v\n", errCtx)
+			exit(1)
+		}
+		exit(0)
+
+	} else if isLambdaMode {
+		targetAccountNames, targetRegions, baseCfgAWS := prepareFanOut(ctx, appConfig, "Lambda Invoke Mode", *roleCmd, *processAll, *selector, *cmdRegionsStr)
+
+		var payload []byte
+		if *lambdaPayloadFlag != "" {
+			data, errRead := os.ReadFile(*lambdaPayloadFlag)
+			if errRead != nil {
+				fmt.Fprintf(os.Stderr, "Error: Could not read payload file: %v\n", errRead)
+				exit(1)
+			}
+			payload = data
+		}
+
+		summary := saws.RunFanOut(ctx, baseCfgAWS, appConfig, targetAccountNames, targetRegions,
+			saws.FanOutOptions{Label: "Lambda Mode", RoleToAssume: *roleCmd, SessionName: "LambdaInvoke"},
+			saws.NewLambdaInvokeTask(*lambdaFunctionFlag, payload))
+		exitWithFanOutSummary("Lambda Mode", summary)
+
+	} else if isAlarmsMode {
+		targetAccountNames, targetRegions, baseCfgAWS := prepareFanOut(ctx, appConfig, "Alarm Status Mode", *roleCmd, *processAll, *selector, *cmdRegionsStr)
+		if err := saws.HandleAlarmStatus(ctx, baseCfgAWS, appConfig, targetAccountNames, targetRegions, *roleCmd); err != nil {
+			fmt.Fprintf(os.Stderr, "Alarm Status Mode: %v\n", err)
+			exit(1)
+		}
+		exit(0)
+
+	} else if isParamMode {
+		paramName, paramValue := "", ""
+		switch {
+		case *paramOpFlag == saws.ParamOpGet && len(positionalArgs) == 1:
+			paramName = positionalArgs[0]
+		case *paramOpFlag == saws.ParamOpPut && len(positionalArgs) == 2:
+			paramName, paramValue = positionalArgs[0], positionalArgs[1]
+		default:
+			fmt.Fprintln(os.Stderr, "Error: Use '-param get <path>' or '-param put <path> <value>'.")
+			usage()
+		}
+		targetAccountNames, targetRegions, baseCfgAWS := prepareFanOut(ctx, appConfig, "Parameter Store Mode", *roleCmd, *processAll, *selector, *cmdRegionsStr)
+		if err := saws.HandleParam(ctx, baseCfgAWS, appConfig, targetAccountNames, targetRegions, *roleCmd, *paramOpFlag, paramName, paramValue, *paramTypeFlag); err != nil {
+			fmt.Fprintf(os.Stderr, "Parameter Store Mode: %v\n", err)
+			exit(1)
+		}
+		exit(0)
+
+	} else if isKMSMode {
+		if *kmsEncryptFlag && *kmsDecryptFlag {
+			fmt.Fprintln(os.Stderr, "Error: Cannot use -kms-encrypt and -kms-decrypt together.")
+			usage()
+		}
+
+		errCtx := saws.HandleKms(ctx, *kmsEncryptFlag, *kmsKeyFlag, *kmsInFlag, *selector, *roleCmd, *contextRegionFlag)
+		if errCtx != nil {
+			fmt.Fprintf(os.Stderr, "KMS operation failed: %v\n", errCtx)
+			exit(1)
+		}
+		exit(0)
+
+	} else if isECRLoginMode {
+		targetAccountNames, targetRegions, baseCfgAWS := prepareFanOut(ctx, appConfig, "ECR Login Mode", *roleCmd, *processAll, *selector, *cmdRegionsStr)
+
+		dockerPath, errLook := exec.LookPath("docker")
+		if errLook != nil {
+			fmt.Fprintf(os.Stderr, "Error: Docker CLI ('docker') not found in PATH. Required for ECR Login Mode.\n")
+			exit(1)
+		}
+
+		summary := saws.RunFanOut(ctx, baseCfgAWS, appConfig, targetAccountNames, targetRegions,
+			saws.FanOutOptions{Label: "ECR Login Mode", RoleToAssume: *roleCmd, SessionName: "EcrLogin"},
+			saws.NewEcrLoginTask(dockerPath))
+		exitWithFanOutSummary("ECR Login Mode", summary)
+
+	} else if isCodeArtifactLoginMode {
+		var tools []string
+		if *caToolsFlag != "" {
+			tools = strings.Split(*caToolsFlag, ",")
+			for i := range tools {
+				tools[i] = strings.TrimSpace(tools[i])
+			}
+		}
+		errCtx := saws.HandleCodeArtifactLogin(ctx, appConfig, *caDomainFlag, *caRepoFlag, *caDomainOwnerFlag, tools, *selector, *roleCmd, *contextRegionFlag)
+		if errCtx != nil {
+			fmt.Fprintf(os.Stderr, "CodeArtifact login failed: %v\n", errCtx)
+			exit(1)
+		}
+		exit(0)
+
+	} else if isDockerCredentialMode {
+		// The protocol reports errors on stdout, which docker relays to the user.
+		errCtx := saws.HandleDockerCredential(ctx, appConfig, *dockerCredentialFlag, *roleCmd, os.Stdin, os.Stdout)
+		if errCtx != nil {
+			fmt.Fprintln(os.Stdout, errCtx)
+			exit(1)
+		}
+		exit(0)
+
+	} else if isStackMode {
+		targetAccountNames, targetRegions, baseCfgAWS := prepareFanOut(ctx, appConfig, "Stack Status Mode", *roleCmd, *processAll, *selector, *cmdRegionsStr)
+		if err := saws.HandleStackStatus(ctx, baseCfgAWS, appConfig, targetAccountNames, targetRegions, *roleCmd, *stackNameFlag); err != nil {
+			fmt.Fprintf(os.Stderr, "Stack Status Mode: %v\n", err)
+			exit(1)
+		}
+		exit(0)
+
+	} else if isFindingsMode {
+		targetAccountNames, targetRegions, baseCfgAWS := prepareFanOut(ctx, appConfig, "Security Findings Mode", *roleCmd, *processAll, *selector, *cmdRegionsStr)
+		if err := saws.HandleFindings(ctx, baseCfgAWS, appConfig, targetAccountNames, targetRegions, *roleCmd, *findingsSourceFlag, asJSON); err != nil {
+			fmt.Fprintf(os.Stderr, "Security Findings Mode: %v\n", err)
+			exit(1)
+		}
+		exit(0)
+
+	} else if isLogsInsightsMode {
+		if len(positionalArgs) != 3 {
+			fmt.Fprintln(os.Stderr, "Error: Use 'saws logs-insights <log-group> <query>', quoting the query.")
+			usage()
+		}
+		if *sinceFlag <= 0 {
+			fmt.Fprintln(os.Stderr, "Error: -since must be a positive duration such as 1h.")
+			exit(1)
+		}
+		targetAccountNames, targetRegions, baseCfgAWS := prepareFanOut(ctx, appConfig, "Logs Insights Mode", *roleCmd, *processAll, *selector, *cmdRegionsStr)
+		if err := saws.HandleLogsInsights(ctx, baseCfgAWS, appConfig, targetAccountNames, targetRegions, *roleCmd, positionalArgs[1], positionalArgs[2], *sinceFlag, asJSON); err != nil {
+			fmt.Fprintf(os.Stderr, "Logs Insights Mode: %v\n", err)
+			exit(1)
+		}
+		exit(0)
+
+	} else if isPatchComplianceMode {
+		targetAccountNames, targetRegions, baseCfgAWS := prepareFanOut(ctx, appConfig, "Patch Compliance Mode", *roleCmd, *processAll, *selector, *cmdRegionsStr)
+		if err := saws.HandlePatchCompliance(ctx, baseCfgAWS, appConfig, targetAccountNames, targetRegions, *roleCmd, asJSON); err != nil {
+			fmt.Fprintf(os.Stderr, "Patch Compliance Mode: %v\n", err)
+			exit(1)
+		}
+		exit(0)
+
+	} else if isConfigComplianceMode {
+		targetAccountNames, targetRegions, baseCfgAWS := prepareFanOut(ctx, appConfig, "Config Compliance Mode", *roleCmd, *processAll, *selector, *cmdRegionsStr)
+		if err := saws.HandleConfigCompliance(ctx, baseCfgAWS, appConfig, targetAccountNames, targetRegions, *roleCmd, asJSON); err != nil {
+			fmt.Fprintf(os.Stderr, "Config Compliance Mode: %v\n", err)
+			exit(1)
+		}
+		exit(0)
+
+	} else if isFindMode {
+		if len(positionalArgs) != 2 {
+			fmt.Fprintln(os.Stderr, "Error: Use 'saws find <resource-id|arn>'.")
+			usage()
+		}
+		resourceID := positionalArgs[1]
+
+		var targetAccountNames, targetRegions []string
+		var baseCfgAWS aws.Config
+		if !strings.HasPrefix(resourceID, "arn:") {
+			targetAccountNames, targetRegions, baseCfgAWS = prepareSearch(ctx, appConfig, "Find Mode", *roleCmd, *processAll, *selector, *cmdRegionsStr)
+		}
+		if err := saws.HandleFind(ctx, baseCfgAWS, appConfig, targetAccountNames, targetRegions, *roleCmd, resourceID); err != nil {
+			fmt.Fprintf(os.Stderr, "Find Mode: %v\n", err)
+			exit(1)
+		}
+		exit(0)
+
+	} else if isFindIPMode {
+		if len(positionalArgs) != 2 {
+			fmt.Fprintln(os.Stderr, "Error: Use 'saws find-ip <address>'.")
+			usage()
+		}
+		targetAccountNames, targetRegions, baseCfgAWS := prepareSearch(ctx, appConfig, "Find IP Mode", *roleCmd, *processAll, *selector, *cmdRegionsStr)
+		if err := saws.HandleFindIP(ctx, baseCfgAWS, appConfig, targetAccountNames, targetRegions, *roleCmd, positionalArgs[1]); err != nil {
+			fmt.Fprintf(os.Stderr, "Find IP Mode: %v\n", err)
+			exit(1)
+		}
+		exit(0)
+
+	} else if isTagSearchMode {
+		targetAccountNames, targetRegions, baseCfgAWS := prepareFanOut(ctx, appConfig, "Tag Search Mode", *roleCmd, *processAll, *selector, *cmdRegionsStr)
+		if err := saws.HandleTagSearch(ctx, baseCfgAWS, appConfig, targetAccountNames, targetRegions, *roleCmd, *tagSearchFlag, asJSON); err != nil {
+			fmt.Fprintf(os.Stderr, "Tag Search Mode: %v\n", err)
+			exit(1)
+		}
+		exit(0)
+
+	} else if isShareMode {
+		if *shareToFlag == "" {
+			fmt.Fprintln(os.Stderr, "Error: --share-to is mandatory for Share Image Mode.")
+			usage()
+		}
+		destAccountNames, errAccounts := pkg.ResolveTargetAccounts(appConfig, false, *shareToFlag)
+		if errAccounts != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", errAccounts)
+			exit(1)
+		}
+
+		errCtx := saws.HandleShare(ctx, appConfig, *shareResourceFlag, destAccountNames, *selector, *roleCmd, *contextRegionFlag)
+		if errCtx != nil {
+			fmt.Fprintf(os.Stderr, "Share Image Mode: %v\n", errCtx)
+			exit(1)
+		}
+		exit(0)
+
+	} else if isS3CopyMode {
+		if len(positionalArgs) != 3 {
+			fmt.Fprintln(os.Stderr, "Error: Use 'saws s3-copy s3://src-bucket/key <dest-account>:s3://dst-bucket/key'.")
+			usage()
+		}
+
+		errCtx := saws.HandleS3Copy(ctx, appConfig, positionalArgs[1], positionalArgs[2], *destRoleFlag, *selector, *roleCmd, *contextRegionFlag)
+		if errCtx != nil {
+			fmt.Fprintf(os.Stderr, "S3 copy failed: %v\n", errCtx)
+			exit(1)
+		}
+		exit(0)
+
+	} else if isPresignMode {
+		if len(positionalArgs) != 2 {
+			fmt.Fprintln(os.Stderr, "Error: Use 'saws presign s3://bucket/key'.")
 			usage()
 		}
+
+		errCtx := saws.HandlePresign(ctx, positionalArgs[1], *expiresFlag, *presignPutFlag, *selector, *roleCmd, *contextRegionFlag)
+		if errCtx != nil {
+			fmt.Fprintf(os.Stderr, "Presign failed: %v\n", errCtx)
+			exit(1)
+		}
+		exit(0)
+
+	} else if isDecodeMode {
+		if len(positionalArgs) != 2 {
+			fmt.Fprintln(os.Stderr, "Error: Use 'saws decode <encoded-message>'.")
+			usage()
+		}
+
+		errCtx := saws.HandleDecode(ctx, positionalArgs[1], *selector, *roleCmd, *contextRegionFlag)
+		if errCtx != nil {
+			fmt.Fprintf(os.Stderr, "Decode failed: %v\n", errCtx)
+			exit(1)
+		}
+		exit(0)
+
+	} else if isTerraformMode {
+		tfArgs := positionalArgs[1:]
+		if len(tfArgs) == 0 {
+			fmt.Fprintln(os.Stderr, "Error: Use 'saws tf [options] -- <terraform args...>'.")
+			usage()
+		}
+		if _, errLook := exec.LookPath(*tfBinFlag); errLook != nil {
+			fmt.Fprintf(os.Stderr, "Error: '%s' not found in PATH. Required for Terraform Mode.\n", *tfBinFlag)
+			exit(1)
+		}
+		opts := saws.TerraformOptions{Binary: *tfBinFlag, StateKey: *tfStateKeyFlag}
+
+		// -a, -regions, or a selector matching several accounts runs the module
+		// in each target in turn; otherwise it runs once in the chosen context.
+		multiAccount := *processAll || *cmdRegionsStr != ""
+		if !multiAccount && *selector != "" {
+			matched, _ := pkg.ResolveTargetAccounts(appConfig, false, *selector)
+			multiAccount = len(matched) > 1
+		}
+		if multiAccount {
+			targetAccountNames, targetRegions, baseCfgAWS := prepareFanOut(ctx, appConfig, "Terraform Mode", *roleCmd, *processAll, *selector, *cmdRegionsStr)
+			targetAccountNames = pkg.OrderBySelector(appConfig, targetAccountNames, *selector)
+			summary := saws.HandleTerraformAccounts(ctx, baseCfgAWS, appConfig, targetAccountNames, targetRegions, *roleCmd, opts, tfArgs)
+			exitWithDeploySummary("Terraform Mode", summary)
+		}
+		code, errTF := saws.HandleTerraform(ctx, *selector, *roleCmd, *contextRegionFlag, opts, tfArgs)
+		if errTF != nil {
+			fmt.Fprintf(os.Stderr, "Terraform Mode: %v\n", errTF)
+		}
+		exit(code)
+
+	} else if isVerifyMode {
+		if len(positionalArgs) != 1 {
+			fmt.Fprintln(os.Stderr, "Error: Use 'saws verify -r <role>[,<role>...] -a|-s <selector>'.")
+			usage()
+		}
+		var roleNames []string
+		for _, role := range strings.Split(*roleCmd, ",") {
+			if role = strings.TrimSpace(role); role != "" {
+				roleNames = append(roleNames, role)
+			}
+		}
+		if len(roleNames) == 0 {
+			fmt.Fprintln(os.Stderr, "Error: Role (-r) is mandatory for Verify Mode; list several separated by commas.")
+			usage()
+		}
+		targetAccountNames, _, baseCfgAWS := prepareFanOut(ctx, appConfig, "Verify Mode", strings.Join(roleNames, ","), *processAll, *selector, "")
+		if err := saws.HandleVerify(ctx, baseCfgAWS, appConfig, targetAccountNames, roleNames, asJSON); err != nil {
+			fmt.Fprintf(os.Stderr, "Verify Mode: %v\n", err)
+			exit(1)
+		}
+		exit(0)
+
+	} else if isAutomationMode {
+		if len(positionalArgs) < 2 {
+			fmt.Fprintln(os.Stderr, "Error: Use 'saws automation <document> [key=value...]'.")
+			usage()
+		}
+		params, errParams := saws.ParseAutomationParameters(positionalArgs[2:])
+		if errParams != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", errParams)
+			exit(1)
+		}
+		targetAccountNames, targetRegions, baseCfgAWS := prepareFanOut(ctx, appConfig, "Automation Mode", *roleCmd, *processAll, *selector, *cmdRegionsStr)
+
+		summary := saws.RunFanOut(ctx, baseCfgAWS, appConfig, targetAccountNames, targetRegions,
+			saws.FanOutOptions{Label: "Automation Mode", RoleToAssume: *roleCmd, SessionName: "SsmAutomation"},
+			saws.NewAutomationTask(positionalArgs[1], params))
+		exitWithFanOutSummary("Automation Mode", summary)
+
+	} else if isDeployMode {
+		label := strings.ToUpper(deployTool) + " Mode"
+		deployArgs := positionalArgs[1:]
+		if len(deployArgs) == 0 {
+			fmt.Fprintf(os.Stderr, "Error: Use 'saws %s [options] -- <%s args...>', e.g. 'saws %s -s dev,prod -r Admin -- deploy'.\n", deployTool, deployTool, deployTool)
+			usage()
+		}
+		if _, errLook := exec.LookPath(deployTool); errLook != nil {
+			fmt.Fprintf(os.Stderr, "Error: '%s' not found in PATH. Required for %s.\n", deployTool, label)
+			exit(1)
+		}
+		targetAccountNames, targetRegions, baseCfgAWS := prepareFanOut(ctx, appConfig, label, *roleCmd, *processAll, *selector, *cmdRegionsStr)
+		// Accounts deploy in the order -s names them, e.g. dev before prod.
+		targetAccountNames = pkg.OrderBySelector(appConfig, targetAccountNames, *selector)
+		summary := saws.HandleDeploy(ctx, baseCfgAWS, appConfig, deployTool, targetAccountNames, targetRegions, *roleCmd, deployArgs)
+		exitWithDeploySummary(label, summary)
+
+	} else if isCommandMode {
+		if *publishFlag != "" {
+			if _, errPub := saws.ParsePublishDestination(*publishFlag); errPub != nil {
+				fmt.Fprintf(os.Stderr, "Error: -publish: %v\n", errPub)
+				exit(1)
+			}
+		}
+		var workdir *template.Template
+		if *workdirFlag != "" {
+			var errWorkdir error
+			if workdir, errWorkdir = saws.ParseWorkdir(*workdirFlag); errWorkdir != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", errWorkdir)
+				exit(1)
+			}
+		}
+		targetAccountNames, targetRegionsCmd, baseCfgAWS := prepareFanOut(ctx, appConfig, "Command Execution Mode", *roleCmd, *processAll, *selector, *cmdRegionsStr)
+
 		if _, errLook := exec.LookPath("aws"); errLook != nil {
 			fmt.Fprintf(os.Stderr, "Error: AWS CLI ('aws') not found in PATH. Required for Command Mode.\n")
-			os.Exit(1)
+			exit(1)
 		}
 		// Warnings for ECS flags if -c is used
 		if *ecsClusterFlag != "" || *ecsTaskFlag != "" || *ecsContainerFlag != "" || *ecsCommandFlag != "" {
@@ -266,116 +1439,283 @@ func main() {
 		if *instanceIDFlag != "" {
 			fmt.Fprintln(os.Stderr, "Warning: -i (instance-id) flag ignored in command execution mode (-c). Used with -ssm.")
 		}
-
-		var targetRegionsCmd []string
-		regionsInput := strings.TrimSpace(*cmdRegionsStr)
-		if regionsInput != "" {
-			rawRegions := strings.Split(regionsInput, ",")
-			for _, r := range rawRegions {
-				trimmed := strings.TrimSpace(r)
-				if trimmed != "" {
-					targetRegionsCmd = append(targetRegionsCmd, trimmed)
+		if *preflightFlag || *preflightActionsFlag != "" {
+			actions, errActions := saws.ParseActionList(*preflightActionsFlag)
+			if errActions != nil {
+				fmt.Fprintf(os.Stderr, "Error: -preflight-actions: %v\n", errActions)
+				exit(1)
+			}
+			for _, action := range saws.CommandActions(*command) {
+				if !slices.Contains(actions, action) {
+					actions = append(actions, action)
 				}
 			}
-			if len(targetRegionsCmd) == 0 {
-				fmt.Fprintln(os.Stderr, "Error: -regions flag provided but contained no valid region names after trimming.")
-				os.Exit(1)
+			if len(actions) == 0 {
+				fmt.Fprintln(os.Stderr, "Error: -preflight found no 'aws <service> <operation>' calls in the command; name the actions with -preflight-actions.")
+				exit(1)
 			}
-			pkg.LogVerbosef("Cmd Mode: Using specified regions: %v", targetRegionsCmd)
-		} else {
-			pkg.LogVerbosef("Cmd Mode: No -regions flag provided. Determining default region...")
-			tempCfg, errCfg := awsconfig.LoadDefaultConfig(ctx, awsconfig.WithSharedConfigProfile(pkg.BaseProfileForAssume))
-			defaultCmdRegion := pkg.FallbackRegion
-			if errCfg != nil {
-				pkg.LogVerbosef("Warning: Could not load AWS config to determine default region: %v. Falling back to '%s'.", errCfg, defaultCmdRegion)
-			} else if tempCfg.Region == "" {
-				pkg.LogVerbosef("Warning: Could not determine default region from AWS config/environment. Falling back to '%s'.", defaultCmdRegion)
-			} else {
-				defaultCmdRegion = tempCfg.Region
-				pkg.LogVerbosef("Cmd Mode: Using default region from AWS config/environment: %s", defaultCmdRegion)
+			if errPre := saws.RunPreflight(ctx, baseCfgAWS, appConfig, targetAccountNames, targetRegionsCmd, *roleCmd, actions); errPre != nil {
+				fmt.Fprintf(os.Stderr, "Cmd Mode: Preflight failed: %v\n", errPre)
+				exit(1)
 			}
-			targetRegionsCmd = []string{defaultCmdRegion}
 		}
 
-		var targetAccountNames []string
-		allAccountNamesSorted := make([]string, 0, len(appConfig.Accounts))
-		for name := range appConfig.Accounts {
-			allAccountNamesSorted = append(allAccountNamesSorted, name)
+		summary := saws.RunFanOut(ctx, baseCfgAWS, appConfig, targetAccountNames, targetRegionsCmd,
+			saws.FanOutOptions{Label: "Cmd Mode", RoleToAssume: *roleCmd, SessionName: "CmdExecSess"},
+			saws.NewCommandTask(*command, workdir))
+		if *timingsFlag {
+			saws.PrintFanOutTimings("Cmd Mode", summary)
 		}
-		sort.Strings(allAccountNamesSorted)
-		if *processAll {
-			targetAccountNames = allAccountNamesSorted
-			pkg.LogVerbosef("Cmd Mode Accounts: Processing all %d defined accounts.", len(targetAccountNames))
-		} else {
-			rawPatterns := strings.Split(*selector, ",")
-			selectorPatterns := []string{}
-			for _, p := range rawPatterns {
-				trimmed := strings.TrimSpace(p)
-				if trimmed != "" {
-					selectorPatterns = append(selectorPatterns, trimmed)
-				}
-			}
-			if len(selectorPatterns) == 0 {
-				fmt.Fprintf(os.Stderr, "Error: Selector flag '-s \"%s\"' provided no valid names/patterns.\n", *selector)
-				os.Exit(1)
-			}
-			matchedAccountsMap := make(map[string]struct{})
-			pkg.LogVerbosef("Cmd Mode: Applying selector patterns: %v", selectorPatterns)
-			for _, accName := range allAccountNamesSorted {
-				for _, pattern := range selectorPatterns {
-					match, errMatch := filepath.Match(pattern, accName)
-					if errMatch != nil {
-						pkg.LogVerbosef("Warning: Invalid pattern '%s' in selector: %v.", pattern, errMatch)
-						continue
-					}
-					if match {
-						matchedAccountsMap[accName] = struct{}{}
-						break
-					}
-				}
-			}
-			for accName := range matchedAccountsMap {
-				targetAccountNames = append(targetAccountNames, accName)
-			}
-			sort.Strings(targetAccountNames)
-			pkg.LogVerbosef("Cmd Mode: Selected %d account(s) using selector '%s': %v", len(targetAccountNames), *selector, targetAccountNames)
-			if len(targetAccountNames) == 0 {
-				fmt.Fprintf(os.Stderr, "Error: No accounts found matching selector patterns: %v\n", selectorPatterns)
-				os.Exit(1)
+		if *publishFlag != "" {
+			event := saws.NewRunEvent(modeName, *command, *roleCmd, summary, *publishTargetsFlag)
+			if errPub := saws.PublishRunEvent(ctx, baseCfgAWS, appConfig, *publishFlag, *roleCmd, event); errPub != nil {
+				// Automation waiting for the event would otherwise never hear of this run.
+				fmt.Fprintf(os.Stderr, "Error: Could not publish the run summary: %v\n", errPub)
+				exit(1)
 			}
 		}
+		exitWithFanOutSummary("Cmd Mode", summary)
+	}
+}
+
+// configuredBaseProfile is the base profile of the config and context, or
+// the default profile when there is no usable config.
+func configuredBaseProfile(configFlag, contextFlag string) string {
+	path, err := pkg.FindConfigPath(configFlag)
+	if err != nil {
+		return pkg.BaseProfileForAssume
+	}
+	project, err := pkg.FindProjectFile()
+	if err != nil {
+		return pkg.BaseProfileForAssume
+	}
+	if _, err := pkg.LoadConfig(path, project.ContextName(contextFlag)); err != nil {
+		pkg.LogVerbosef("Warning: Could not load the config for its base profile: %v", err)
+	}
+	return pkg.BaseProfileForAssume
+}
 
-		baseCfgAWS, errCfg := awsconfig.LoadDefaultConfig(ctx, awsconfig.WithSharedConfigProfile(pkg.BaseProfileForAssume), awsconfig.WithRegion(pkg.FallbackRegion))
-		if errCfg != nil {
-			fmt.Fprintf(os.Stderr, "Error loading base AWS configuration (profile '%s'): %v\n", pkg.BaseProfileForAssume, errCfg)
-			os.Exit(1)
+// exit records this invocation in the history with its exit code, then exits.
+func exit(code int) {
+	if code != 0 && pkg.InputRequired {
+		code = pkg.ExitInputRequired
+	}
+	saws.ExportTelemetry()
+	pkg.FinishAudit(code)
+	pkg.FinishHistory(code)
+	os.Exit(code)
+}
+
+// verbosity is the -v flag family: each use raises the level by step, so -v -v
+// equals -vv.
+type verbosity struct {
+	level *int
+	step  int
+}
+
+func (v verbosity) String() string {
+	if v.level == nil {
+		return "0"
+	}
+	return strconv.Itoa(*v.level)
+}
+
+func (v verbosity) Set(value string) error {
+	on, err := strconv.ParseBool(value)
+	if err != nil {
+		return err
+	}
+	if on {
+		*v.level += v.step
+	}
+	return nil
+}
+
+func (verbosity) IsBoolFlag() bool { return true }
+
+// failureThreshold is -max-failures: a number of failed targets, or a
+// percentage of them with a trailing "%", that still counts as success.
+type failureThreshold struct {
+	count     int
+	percent   float64
+	isPercent bool
+	isSet     bool
+}
+
+// fanOutModes run against several accounts and regions at once.
+var fanOutModes = []string{"exec", "lambda", "alarms", "param", "ecr-login", "stack", "findings", "find", "find-ip", "tags", "cdk", "sam", "verify", "automation", "patch-compliance", "config-compliance", "logs-insights"}
+
+// maxFailures is the parsed -max-failures, read by exitWithFanOutSummary.
+var maxFailures failureThreshold
+
+func (t *failureThreshold) String() string {
+	switch {
+	case !t.isSet:
+		return ""
+	case t.isPercent:
+		return strconv.FormatFloat(t.percent, 'f', -1, 64) + "%"
+	default:
+		return strconv.Itoa(t.count)
+	}
+}
+
+func (t *failureThreshold) Set(value string) error {
+	if number, ok := strings.CutSuffix(value, "%"); ok {
+		percent, err := strconv.ParseFloat(number, 64)
+		if err != nil || percent < 0 || percent > 100 {
+			return fmt.Errorf("'%s' is not a percentage between 0%% and 100%%", value)
 		}
+		*t = failureThreshold{percent: percent, isPercent: true, isSet: true}
+		return nil
+	}
+	count, err := strconv.Atoi(value)
+	if err != nil || count < 0 {
+		return fmt.Errorf("'%s' is not a number of targets or a percentage such as 5%%", value)
+	}
+	*t = failureThreshold{count: count, isSet: true}
+	return nil
+}
 
-		totalExecutions := len(targetAccountNames) * len(targetRegionsCmd)
-		pkg.LogVerbosef("Cmd Mode: Planning %d executions (%d accounts x %d regions).", totalExecutions, len(targetAccountNames), len(targetRegionsCmd))
-		var wg sync.WaitGroup
-		var successfulExecutions atomic.Int64
-		startTime := time.Now()
+// tolerates reports whether failed out of total targets stay within the threshold.
+func (t *failureThreshold) tolerates(failed int64, total int) bool {
+	if !t.isSet {
+		return failed == 0
+	}
+	if t.isPercent {
+		return float64(failed)*100 <= t.percent*float64(total)
+	}
+	return failed <= int64(t.count)
+}
+
+// parseFlagsAndArgs parses the command line like flag.Parse but keeps going past
+// positional arguments, so operands such as the path in "-param get /path" can be
+// followed by further flags. The positional arguments are returned in order.
+func parseFlagsAndArgs() []string {
+	args := os.Args[1:]
+	var positional []string
+	for {
+		_ = flag.CommandLine.Parse(args)
+		// Everything after "--" is positional, e.g. the command of 'saws exec -- aws s3 ls --recursive'.
+		if consumed := len(args) - flag.NArg(); consumed > 0 && args[consumed-1] == "--" {
+			return append(positional, flag.Args()...)
+		}
+		if flag.NArg() == 0 {
+			return positional
+		}
+		positional = append(positional, flag.Arg(0))
+		args = flag.Args()[1:]
+	}
+}
 
-		for _, accountName := range targetAccountNames {
-			for _, region := range targetRegionsCmd {
-				wg.Add(1)
-				accName := accountName
-				reg := region
-				go saws.ProcessAccountRegion(ctx, &wg, baseCfgAWS, appConfig, accName, *roleCmd, *command, reg, &successfulExecutions)
+// prepareFanOut validates the flags shared by all multi-account modes and resolves
+// the target accounts, regions, and base AWS config, exiting on any error.
+func prepareFanOut(ctx context.Context, appConfig *pkg.AppConfig, modeName, role string, processAll bool, selector, regionsStr string) ([]string, []string, aws.Config) {
+	if role == "" {
+		fmt.Fprintf(os.Stderr, "Error: Role (-r) is mandatory for %s.\n", modeName)
+		usage()
+	}
+	if processAll && selector != "" {
+		fmt.Fprintf(os.Stderr, "Error: Cannot use both -a and -s in %s.\n", modeName)
+		usage()
+	}
+	if err := pkg.ValidateRoleARN(role); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		exit(1)
+	}
+	// A role ARN names its account, which -s then defaults to.
+	roleARN, isRoleARN := pkg.ParseRoleARN(role)
+	if isRoleARN && !processAll && selector == "" {
+		var names []string
+		for name, id := range appConfig.Accounts {
+			if id == roleARN.AccountID {
+				names = append(names, name)
 			}
 		}
-		wg.Wait()
-		totalDuration := time.Since(startTime)
+		if len(names) == 0 {
+			fmt.Fprintf(os.Stderr, "Error: Account %s of role ARN %s is not in the SAWS config; %s needs it under accounts:.\n", roleARN.AccountID, role, modeName)
+			exit(1)
+		}
+		slices.Sort(names)
+		selector = strings.Join(names, ",")
+	}
+	if !processAll && selector == "" {
+		fmt.Fprintf(os.Stderr, "Error: Must use -a or -s in %s.\n", modeName)
+		usage()
+	}
 
-		finalSuccessCount := successfulExecutions.Load()
-		pkg.LogVerbosef("Cmd Mode: Finished %d executions in %s.", totalExecutions, totalDuration.Round(time.Second))
-		if finalSuccessCount == int64(totalExecutions) {
-			pkg.LogVerbosef("Cmd Mode: All %d executions completed successfully.", finalSuccessCount)
-			os.Exit(0)
-		} else {
-			fmt.Fprintf(os.Stderr, "Cmd Mode: %d out of %d targeted executions completed successfully. %d failed.\n", finalSuccessCount, totalExecutions, int64(totalExecutions)-finalSuccessCount)
-			os.Exit(1)
+	targetRegions, errRegions := pkg.ResolveTargetRegions(ctx, regionsStr)
+	if errRegions != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", errRegions)
+		exit(1)
+	}
+	targetAccountNames, errAccounts := pkg.ResolveTargetAccounts(appConfig, processAll, selector)
+	if errAccounts != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", errAccounts)
+		exit(1)
+	}
+	if isRoleARN {
+		for _, name := range targetAccountNames {
+			if appConfig.Accounts[name] != roleARN.AccountID {
+				fmt.Fprintf(os.Stderr, "Error: Role ARN %s is in account %s, but the selection includes '%s' (%s).\n", role, roleARN.AccountID, name, appConfig.Accounts[name])
+				exit(1)
+			}
 		}
 	}
+	pkg.NoteHistoryTargets(targetAccountNames, targetRegions)
+	pkg.NoteHistoryRole(role)
+	if err := pkg.ConfirmNestedSession(fmt.Sprintf("%d account(s) x %d region(s) as role %s", len(targetAccountNames), len(targetRegions), role)); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		exit(1)
+	}
+
+	baseCfgAWS, errCfg := pkg.LoadAWSConfig(ctx, awsconfig.WithSharedConfigProfile(pkg.BaseProfileForAssume), awsconfig.WithRegion(pkg.FallbackRegion))
+	if errCfg != nil {
+		fmt.Fprintf(os.Stderr, "Error loading base AWS configuration (profile '%s'): %v\n", pkg.BaseProfileForAssume, errCfg)
+		exit(1)
+	}
+	return targetAccountNames, targetRegions, baseCfgAWS
+}
+
+// prepareSearch is prepareFanOut for the search modes (find, find-ip), which
+// default to every configured account and the config's common_regions.
+func prepareSearch(ctx context.Context, appConfig *pkg.AppConfig, modeName, role string, processAll bool, selector, regionsStr string) ([]string, []string, aws.Config) {
+	if !processAll && selector == "" {
+		processAll = true
+	}
+	if strings.TrimSpace(regionsStr) == "" && len(appConfig.CommonRegions) > 0 {
+		regionsStr = strings.Join(appConfig.CommonRegions, ",")
+	}
+	return prepareFanOut(ctx, appConfig, modeName, role, processAll, selector, regionsStr)
+}
+
+// exitWithFanOutSummary reports the outcome of a fan-out run and exits non-zero
+// if more targets failed than -max-failures allows.
+func exitWithFanOutSummary(label string, summary saws.FanOutSummary) {
+	exitWithSummaryWithin(label, summary, maxFailures)
+}
+
+// exitWithDeploySummary reports the outcome of a terraform, cdk, or sam run
+// over several accounts and exits non-zero if any target failed. These runs
+// stop at the first failure, so -max-failures does not apply to them.
+func exitWithDeploySummary(label string, summary saws.FanOutSummary) {
+	if maxFailures.isSet {
+		fmt.Fprintf(os.Stderr, "Warning: -max-failures does not apply to %s, which stops at the first failure.\n", label)
+	}
+	exitWithSummaryWithin(label, summary, failureThreshold{})
+}
+
+// exitWithSummaryWithin reports summary and exits 0 when its failures stay
+// within threshold.
+func exitWithSummaryWithin(label string, summary saws.FanOutSummary, threshold failureThreshold) {
+	if summary.Succeeded == int64(summary.Total) {
+		pkg.LogVerbosef("%s: All %d executions completed successfully.", label, summary.Succeeded)
+		exit(0)
+	}
+	message := fmt.Sprintf("%s: %d out of %d targeted executions completed successfully. %d failed.", label, summary.Succeeded, summary.Total, summary.Failed())
+	if threshold.tolerates(summary.Failed(), summary.Total) {
+		fmt.Fprintln(os.Stderr, pkg.Colorize(os.Stderr, pkg.ColorYellow, fmt.Sprintf("%s Within -max-failures %s.", message, threshold.String())))
+		exit(0)
+	}
+	fmt.Fprintln(os.Stderr, pkg.Colorize(os.Stderr, pkg.ColorRed, message))
+	exit(1)
 }
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+
+	"saws/internal/app/saws"
+)
+
+// subcommand is one `saws <name>` form. It maps onto the flag-based mode it
+// replaces, so both spellings share one implementation while the mode flags
+// remain as deprecated aliases.
+type subcommand struct {
+	name     string
+	operands string // operand synopsis, e.g. "<name>"
+	summary  string
+	// modeFlag is the legacy mode flag the subcommand sets. Boolean flags are
+	// set to true; others take the first operand, or all operands as one shell
+	// command line (saws.ShellJoin) when joinOperands is set. positional is
+	// used instead for modes that were already positional (find, decode, ...).
+	modeFlag     string
+	joinOperands bool
+	// operandSep joins all operands into the mode flag's value instead
+	// (tags env=prod team=x is -tags env=prod,team=x), and restOperands passes
+	// the operands after the first on as positional arguments (param put).
+	// Without either, operands beyond the first are an error.
+	operandSep   string
+	restOperands bool
+	positional   string
+	// operandModes picks the mode flag from the first operand (kms encrypt|decrypt).
+	operandModes map[string]string
+	// choices are the fixed values of the first operand, for completion.
+	choices []string
+	flags   []string
+}
+
+// commonFlags are accepted by every subcommand.
+var commonFlags = []string{"config", "context", "no-color", "no-input", "force", "no-cache", "external-id", "sts-endpoint", "sts-region", "v", "vv", "vvv", "h"}
+
+var (
+	telemetryFlags = []string{"metrics-file", "pushgateway", "otlp-endpoint"}
+	fanOutFlags    = flagList([]string{"r", "s", "a", "regions", "q", "adaptive", "resume", "retry-failed"}, telemetryFlags)
+	sessionFlags   = []string{"r", "s", "region", "last"}
+	tunnelFlags    = []string{"bastion", "local-port"}
+)
+
+func flagList(groups ...[]string) []string {
+	var all []string
+	for _, g := range groups {
+		all = append(all, g...)
+	}
+	return all
+}
+
+var subcommands = []subcommand{
+	{name: "exec", operands: "<cmd...>", summary: "Run a command across accounts/regions.", modeFlag: "c", joinOperands: true, flags: flagList(fanOutFlags, []string{"max-failures", "publish", "publish-targets", "timings", "workdir", "preflight", "preflight-actions"})},
+	{name: "shell", summary: "Start a sub-shell with assumed role credentials, or run '-- <cmd...>' with them.", modeFlag: "e", flags: sessionFlags},
+	{name: "ssm", summary: "Start an interactive SSM session to an EC2 instance.", modeFlag: "ssm", flags: flagList(sessionFlags, []string{"i", "instance-state", "all-instances"})},
+	{name: "ecs", summary: "Start an interactive exec session to an ECS container.", modeFlag: "ecs", flags: flagList(sessionFlags, []string{"ecs-cluster", "ecs-task", "ecs-container", "ecs-command", "ecs-host"})},
+	{name: "ecs-service-exec", operands: "-- <cmd...>", summary: "Run a command in every running task of an ECS service, with prefixed output.", positional: "ecs-service-exec", flags: flagList(sessionFlags, []string{"ecs-cluster", "ecs-service", "ecs-container"})},
+	{name: "eks-token", summary: "Print a kubectl ExecCredential for an EKS cluster.", modeFlag: "eks-token", flags: flagList(sessionFlags, []string{"eks-cluster"})},
+	{name: "rds-token", summary: "Generate an RDS IAM auth token and connection line.", modeFlag: "rds-token", flags: flagList(sessionFlags, []string{"rds-instance", "db-user", "rds-tunnel", "local-port"})},
+	{name: "db", summary: "Tunnel to an RDS/Aurora endpoint and launch psql/mysql.", modeFlag: "db", flags: flagList(sessionFlags, tunnelFlags, []string{"rds-instance", "db-user", "db-name"})},
+	{name: "tunnel", operands: "<name>", summary: "Bring up a tunnel defined under 'tunnels:' in the config.", modeFlag: "tunnel", flags: []string{"r", "region", "local-port"}},
+	{name: "redis", summary: "Tunnel to an ElastiCache/MemoryDB endpoint and launch redis-cli.", modeFlag: "redis", flags: flagList(sessionFlags, tunnelFlags, []string{"cache"})},
+	{name: "opensearch", summary: "Tunnel to an OpenSearch domain and print a Dashboards URL.", modeFlag: "opensearch", flags: flagList(sessionFlags, tunnelFlags, []string{"os-domain", "open"})},
+	{name: "lambda", operands: "<fn>", summary: "Invoke a Lambda function in every selected account/region.", modeFlag: "lambda", flags: flagList(fanOutFlags, []string{"payload", "max-failures"})},
+	{name: "automation", operands: "<document> [key=value...]", summary: "Run an SSM Automation document in every selected account/region and wait for it.", positional: "automation", flags: flagList(fanOutFlags, []string{"max-failures"})},
+	{name: "alarms", summary: "List CloudWatch alarms in ALARM state across accounts/regions.", modeFlag: "alarms", flags: fanOutFlags},
+	{name: "param", operands: "get <path> | put <path> <value>", summary: "Read or write a parameter across accounts/regions.", modeFlag: "param", restOperands: true, choices: []string{"get", "put"}, flags: flagList(fanOutFlags, []string{"param-type"})},
+	{name: "kms", operands: "encrypt|decrypt", summary: "Encrypt or decrypt stdin (or --in) with KMS.", operandModes: map[string]string{"encrypt": "kms-encrypt", "decrypt": "kms-decrypt"}, flags: flagList(sessionFlags, []string{"kms-key", "in"})},
+	{name: "ecr-login", summary: "Run 'docker login' for the ECR registry of every selected account/region.", modeFlag: "ecr-login", flags: flagList(fanOutFlags, []string{"max-failures"})},
+	{name: "codeartifact-login", summary: "Configure pip, npm, and maven for a CodeArtifact repository.", modeFlag: "codeartifact-login", flags: flagList(sessionFlags, []string{"ca-domain", "ca-repo", "ca-domain-owner", "ca-tools"})},
+	{name: "docker-credential", operands: "<action>", summary: "Serve docker credential-helper get/store/erase/list.", modeFlag: "docker-credential", choices: []string{"get", "store", "erase", "list"}, flags: []string{"r"}},
+	{name: "git-credential", operands: "<action>", summary: "Serve git credential-helper get/store/erase for CodeCommit HTTPS remotes.", positional: "git-credential", choices: []string{"get", "store", "erase"}, flags: []string{"r", "s"}},
+	{name: "stack", operands: "<name>", summary: "Show a CloudFormation stack's status and drift across accounts/regions.", modeFlag: "stack", flags: fanOutFlags},
+	{name: "findings", summary: "List active HIGH/CRITICAL security findings across accounts/regions.", modeFlag: "findings", flags: flagList(fanOutFlags, []string{"findings-source", "json", "output"})},
+	{name: "logs-insights", operands: "<log-group> <query>", summary: "Run a CloudWatch Logs Insights query across accounts/regions and merge the results.", positional: "logs-insights", flags: flagList(fanOutFlags, []string{"since", "json", "output"})},
+	{name: "patch-compliance", summary: "Summarize patch and association compliance and SSM agent coverage across accounts/regions.", positional: "patch-compliance", flags: flagList(fanOutFlags, []string{"json", "output"})},
+	{name: "config-compliance", summary: "Summarize AWS Config rule compliance and non-compliant resource counts across accounts/regions.", positional: "config-compliance", flags: flagList(fanOutFlags, []string{"json", "output"})},
+	{name: "tags", operands: "<filters...>", summary: "List resources matching tag filters across accounts/regions.", modeFlag: "tags", operandSep: ",", flags: flagList(fanOutFlags, []string{"json", "output"})},
+	{name: "share", operands: "<id>", summary: "Share an AMI or EBS snapshot with other accounts.", modeFlag: "share", flags: flagList(sessionFlags, []string{"share-to"})},
+	{name: "find", operands: "<id>", summary: "Locate an instance, ENI, volume, or security group.", positional: "find", flags: fanOutFlags},
+	{name: "find-ip", operands: "<ip>", summary: "Locate the ENI or Elastic IP holding an address.", positional: "find-ip", flags: fanOutFlags},
+	{name: "presign", operands: "<s3-url>", summary: "Print a presigned GET or PUT URL for an S3 object.", positional: "presign", flags: flagList(sessionFlags, []string{"expires", "put"})},
+	{name: "s3-copy", operands: "<src> <account>:<dst>", summary: "Copy an S3 object between accounts.", positional: "s3-copy", flags: flagList(sessionFlags, []string{"dest-role"})},
+	{name: "decode", operands: "<blob>", summary: "Decode an encoded authorization failure message.", positional: "decode", flags: sessionFlags},
+	{name: "tf", operands: "-- <terraform args...>", summary: "Run terraform in the selected account, or in each of several accounts/regions in turn.", positional: "tf", flags: flagList(sessionFlags, []string{"a", "regions", "tf-bin", "tf-state-key"}, telemetryFlags)},
+	{name: "cdk", operands: "-- <cdk args...>", summary: "Run cdk (e.g. deploy) in each selected account/region in turn.", positional: "cdk", flags: flagList([]string{"r", "s", "a", "regions"}, telemetryFlags)},
+	{name: "sam", operands: "-- <sam args...>", summary: "Run sam (e.g. deploy) in each selected account/region in turn.", positional: "sam", flags: flagList([]string{"r", "s", "a", "regions"}, telemetryFlags)},
+	{name: "verify", summary: "Try AssumeRole for every account and -r role (comma-separated) and print an access matrix.", positional: "verify", flags: []string{"r", "s", "a", "json", "output"}},
+	{name: "kubeconfig", operands: "[<cluster>...]", summary: "Write kubeconfig contexts for eks_clusters that get tokens from saws.", positional: "kubeconfig", flags: []string{"kubeconfig"}},
+	{name: "list", operands: "accounts|roles|regions|groups", summary: "Print the configured accounts, roles, common regions, or groups.", positional: "list", choices: saws.ListKinds, flags: []string{"json", "output"}},
+	{name: "daemon", summary: "Serve cached credentials to local tools over a unix socket.", positional: "daemon", flags: []string{"socket"}},
+	{name: "recent", summary: "Resume a recently used account/role/region (and instance).", positional: "recent"},
+	{name: "last", summary: "Resume the most recent account/role/region (and instance) without prompts.", positional: "last"},
+	{name: "sso-login", operands: "[profile]", summary: "Sign in to IAM Identity Center for the base profile, or profile, without the AWS CLI.", positional: "sso-login"},
+	{name: "time-left", summary: "Print how long the credentials of the current saws session remain valid.", positional: "time-left"},
+	{name: "history", summary: "List recorded invocations.", positional: "history"},
+	{name: "stats", summary: "Summarize the history: runs, failure rates, and durations per command, account, and role.", positional: "stats", flags: []string{"json", "output"}},
+	{name: "replay", operands: "<n>", summary: "Re-run history entry <n>.", positional: "replay"},
+	{name: "completion", operands: "bash|zsh|fish|powershell", summary: "Print a shell completion script.", positional: "completion", choices: completionShells},
+	{name: "config validate", summary: "Validate the config and print a pass/fail report.", positional: "validate-config", flags: []string{"probe", "aliases", "r"}},
+	{name: "config init", summary: "Interactively create a config.", positional: "init", flags: []string{"from-sso", "from-aws-config"}},
+	// validate-config and init predate `saws config` and stay as aliases.
+	{name: "validate-config", summary: "Same as 'saws config validate'.", positional: "validate-config", flags: []string{"probe", "aliases", "r"}},
+	{name: "init", summary: "Same as 'saws config init'.", positional: "init", flags: []string{"from-sso", "from-aws-config"}},
+}
+
+// findSubcommand returns the subcommand the positional arguments start with,
+// and the operands after its name.
+func findSubcommand(positional []string) (*subcommand, []string) {
+	if len(positional) == 0 {
+		return nil, nil
+	}
+	name, operands := positional[0], positional[1:]
+	if name == "config" && len(operands) > 0 {
+		name, operands = "config "+operands[0], operands[1:]
+	}
+	for i := range subcommands {
+		if subcommands[i].name == name {
+			return &subcommands[i], operands
+		}
+	}
+	return nil, nil
+}
+
+// subcommandUsage prints the help of one subcommand, with the usage text of
+// each flag it accepts.
+func subcommandUsage(sub *subcommand) {
+	synopsis := "saws " + sub.name
+	if sub.operands != "" {
+		synopsis += " " + sub.operands
+	}
+	fmt.Fprintf(os.Stderr, "Usage: %s [options]\n\n%s\n", synopsis, sub.summary)
+	if slices.Contains(sub.flags, "a") && sub.positional == "" {
+		fmt.Fprintln(os.Stderr, "Requires -r and one of -a or -s.")
+	}
+	fmt.Fprintln(os.Stderr, "\nOptions:")
+	names := append(append([]string(nil), sub.flags...), commonFlags...)
+	for _, name := range names {
+		f := flag.Lookup(name)
+		if f == nil {
+			continue
+		}
+		arg, usageText := flag.UnquoteUsage(f)
+		fmt.Fprintf(os.Stderr, "  -%-22s %s\n", strings.TrimSpace(name+" "+arg), usageText)
+	}
+}
+
+// subcommandList renders the Commands section of the top-level usage.
+func subcommandList() string {
+	var b strings.Builder
+	for _, sub := range subcommands {
+		synopsis := sub.name
+		if sub.operands != "" {
+			synopsis += " " + sub.operands
+		}
+		fmt.Fprintf(&b, "  %-38s %s\n", synopsis, sub.summary)
+	}
+	if plugins := saws.PluginNames(); len(plugins) > 0 {
+		b.WriteString("\nPlugins (saws-<name> on PATH; run in the selected context, arguments after --):\n")
+		for _, name := range plugins {
+			fmt.Fprintf(&b, "  %s\n", name)
+		}
+	}
+	return b.String()
+}
+
+// resolveSubcommand turns `saws <subcommand> ...` into the flags and positional
+// arguments of the mode it stands for, rejecting flags the subcommand does not
+// use. Without a subcommand, legacy mode flags are accepted with a deprecation
+// warning. It returns the positional arguments main dispatches on.
+func resolveSubcommand(positional []string) []string {
+	sub, operands := findSubcommand(positional)
+	if sub == nil {
+		warnDeprecatedModeFlags()
+		return positional
+	}
+
+	var invalid []string
+	flag.Visit(func(f *flag.Flag) {
+		if !slices.Contains(sub.flags, f.Name) && !slices.Contains(commonFlags, f.Name) {
+			invalid = append(invalid, "-"+f.Name)
+		}
+	})
+	if len(invalid) > 0 {
+		fmt.Fprintf(os.Stderr, "Error: %s not valid for 'saws %s' (see 'saws %s -h').\n", strings.Join(invalid, ", "), sub.name, sub.name)
+		exit(1)
+	}
+	if help := flag.Lookup("h"); help != nil && help.Value.String() == "true" {
+		subcommandUsage(sub)
+		exit(0)
+	}
+
+	switch {
+	case sub.positional != "":
+		return append([]string{sub.positional}, operands...)
+	case sub.operandModes != nil:
+		if len(operands) == 0 || sub.operandModes[operands[0]] == "" {
+			fmt.Fprintf(os.Stderr, "Error: Use 'saws %s %s'.\n", sub.name, sub.operands)
+			exit(1)
+		}
+		flag.Set(sub.operandModes[operands[0]], "true")
+		return operands[1:]
+	case sub.joinOperands:
+		if len(operands) == 0 {
+			fmt.Fprintf(os.Stderr, "Error: Use 'saws %s %s'.\n", sub.name, sub.operands)
+			exit(1)
+		}
+		flag.Set(sub.modeFlag, saws.ShellJoin(operands))
+		return nil
+	case sub.operands != "":
+		if len(operands) == 0 {
+			fmt.Fprintf(os.Stderr, "Error: Use 'saws %s %s'.\n", sub.name, sub.operands)
+			exit(1)
+		}
+		switch {
+		case sub.operandSep != "":
+			flag.Set(sub.modeFlag, strings.Join(operands, sub.operandSep))
+			return nil
+		case len(operands) > 1 && !sub.restOperands:
+			fmt.Fprintf(os.Stderr, "Error: Unexpected argument(s) %s; use 'saws %s %s'.\n", strings.Join(operands[1:], " "), sub.name, sub.operands)
+			exit(1)
+		}
+		flag.Set(sub.modeFlag, operands[0])
+		return operands[1:]
+	default:
+		flag.Set(sub.modeFlag, "true")
+		return operands
+	}
+}
+
+// warnDeprecatedModeFlags points users of the flag-based modes at their subcommands.
+func warnDeprecatedModeFlags() {
+	replacements := make(map[string]string)
+	for _, sub := range subcommands {
+		if sub.modeFlag != "" {
+			replacements[sub.modeFlag] = "saws " + sub.name
+		}
+		for operand, modeFlag := range sub.operandModes {
+			replacements[modeFlag] = "saws " + sub.name + " " + operand
+		}
+	}
+	var used []string
+	flag.Visit(func(f *flag.Flag) {
+		if replacement, ok := replacements[f.Name]; ok {
+			used = append(used, fmt.Sprintf("-%s is deprecated; use '%s'", f.Name, replacement))
+		}
+	})
+	sort.Strings(used)
+	for _, msg := range used {
+		fmt.Fprintf(os.Stderr, "Warning: %s.\n", msg)
+	}
+}
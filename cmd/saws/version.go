@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"strings"
+)
+
+// version and commit are set at build time:
+//
+//	go build -ldflags "-X main.version=v1.2.3 -X main.commit=$(git rev-parse --short HEAD)" ./cmd/saws
+//
+// Otherwise they come from the module and VCS information Go embeds.
+var (
+	version = ""
+	commit  = ""
+)
+
+// buildVersion returns the version and commit of this binary.
+func buildVersion() (string, string) {
+	v, c := version, commit
+	info, ok := debug.ReadBuildInfo()
+	// Pseudo-versions of untagged or modified checkouts are not releases.
+	if ok && v == "" && info.Main.Version != "(devel)" && !strings.ContainsAny(info.Main.Version, "-+") {
+		v = info.Main.Version
+	}
+	if ok && c == "" {
+		modified := false
+		for _, setting := range info.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				c = setting.Value
+			case "vcs.modified":
+				modified = setting.Value == "true"
+			}
+		}
+		if len(c) > 12 {
+			c = c[:12]
+		}
+		if c != "" && modified {
+			c += "-dirty"
+		}
+	}
+	if v == "" {
+		v = "dev"
+	}
+	if c == "" {
+		c = "unknown"
+	}
+	return v, c
+}
+
+// versionLine is what -version prints.
+func versionLine() string {
+	v, c := buildVersion()
+	return fmt.Sprintf("saws %s (commit %s, %s, %s/%s)", v, c, runtime.Version(), runtime.GOOS, runtime.GOARCH)
+}
@@ -0,0 +1,166 @@
+package saws
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"sync"
+	"text/tabwriter"
+
+	"saws/internal/pkg"
+
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+)
+
+var accountIDOnlyName = regexp.MustCompile(`^\d{12}$`)
+
+// aliasProbe is the IAM account alias found for one configured account.
+type aliasProbe struct {
+	Account string
+	ID      string
+	Alias   string
+	Err     error
+}
+
+// lookupAccountAlias returns the IAM account alias of the account cfg signs for, or "".
+func lookupAccountAlias(ctx context.Context, cfg aws.Config) (string, error) {
+	out, err := iam.NewFromConfig(cfg).ListAccountAliases(ctx, &iam.ListAccountAliasesInput{})
+	if err != nil {
+		return "", fmt.Errorf("iam:ListAccountAliases failed: %w", err)
+	}
+	if len(out.AccountAliases) == 0 {
+		return "", nil
+	}
+	return out.AccountAliases[0], nil
+}
+
+// probeAccountAliases assumes roleName in every account and reads its alias,
+// at most parallelism (see probeSlots) accounts at a time.
+func probeAccountAliases(ctx context.Context, baseCfg aws.Config, accounts map[string]string, roleName string, parallelism int) []aliasProbe {
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	var probes []aliasProbe
+	slots := probeSlots(parallelism)
+	for accountName, accountID := range accounts {
+		wg.Add(1)
+		go func(accountName, accountID string) {
+			defer wg.Done()
+			slots <- struct{}{}
+			defer func() { <-slots }()
+			probe := aliasProbe{Account: accountName, ID: accountID}
+			accountBaseCfg, err := pkg.BaseConfigForAccount(ctx, accountName, baseCfg)
+			if err == nil {
+				creds, errAssume := pkg.AssumeRole(ctx, accountBaseCfg, accountID, roleName, "AccountAlias")
+				err = errAssume
+				if err == nil {
+					var cfg aws.Config
+					if cfg, err = pkg.LoadAssumedRoleConfig(ctx, creds, accountBaseCfg.Region); err == nil {
+						probe.Alias, err = lookupAccountAlias(ctx, cfg)
+					}
+				}
+			}
+			probe.Err = err
+			mu.Lock()
+			probes = append(probes, probe)
+			mu.Unlock()
+		}(accountName, accountID)
+	}
+	wg.Wait()
+	sort.Slice(probes, func(i, j int) bool { return probes[i].Account < probes[j].Account })
+	return probes
+}
+
+// nameAccountsByAlias offers `saws init` to rename the accounts of cfg that
+// are named by their ID after their IAM account alias, read as one of
+// cfg.Roles with the base profile. Accounts without an alias, or whose alias
+// is taken, keep their ID.
+func nameAccountsByAlias(ctx context.Context, profile string, cfg *initConfig) error {
+	idOnly := make(map[string]string)
+	for name, accountID := range cfg.Accounts {
+		if accountIDOnlyName.MatchString(name) {
+			idOnly[name] = accountID
+		}
+	}
+	if len(idOnly) == 0 {
+		return nil
+	}
+	lookup := true
+	if err := pkg.AskOne(&survey.Confirm{Message: fmt.Sprintf("Name the %d account(s) known only by ID after their IAM account alias?", len(idOnly)), Default: true}, &lookup); err != nil || !lookup {
+		return err
+	}
+	roleName := ""
+	switch friendly := sortedKeys(cfg.Roles); len(friendly) {
+	case 0:
+		if err := pkg.AskOne(&survey.Input{Message: "IAM role to read the aliases with (needs iam:ListAccountAliases):"}, &roleName, survey.WithValidator(survey.Required)); err != nil {
+			return err
+		}
+	case 1:
+		roleName = cfg.Roles[friendly[0]]
+	default:
+		if err := pkg.AskOne(&survey.Select{Message: "Role to read the aliases with (needs iam:ListAccountAliases):", Options: friendly}, &roleName, pkg.WithFuzzyFilter); err != nil {
+			return err
+		}
+		roleName = cfg.Roles[roleName]
+	}
+
+	baseCfg, err := pkg.LoadAWSConfig(ctx, awsconfig.WithSharedConfigProfile(profile), awsconfig.WithRegion(pkg.FallbackRegion))
+	if err != nil {
+		return fmt.Errorf("could not load AWS profile '%s': %w", profile, err)
+	}
+	fmt.Fprintf(os.Stderr, "Reading IAM account aliases of %d account(s) as role '%s'...\n", len(idOnly), roleName)
+	renamed := 0
+	for _, probe := range probeAccountAliases(ctx, baseCfg, idOnly, roleName, 0) {
+		name := configAccountName(probe.Alias)
+		switch {
+		case probe.Err != nil:
+			fmt.Fprintf(os.Stderr, "Warning: %s keeps its ID as name: %v\n", probe.ID, pkg.RedactSecrets(probe.Err.Error()))
+			continue
+		case name == "":
+			pkg.LogVerbosef("Account %s has no IAM account alias.", probe.ID)
+			continue
+		}
+		if _, taken := cfg.Accounts[name]; taken {
+			name = fmt.Sprintf("%s-%s", name, probe.ID[len(probe.ID)-4:])
+		}
+		delete(cfg.Accounts, probe.Account)
+		cfg.Accounts[name] = probe.ID
+		if roles, ok := cfg.RolesByAccount[probe.Account]; ok {
+			delete(cfg.RolesByAccount, probe.Account)
+			cfg.RolesByAccount[name] = roles
+		}
+		renamed++
+	}
+	fmt.Fprintf(os.Stderr, "Named %d of %d account(s) after their IAM account alias.\n", renamed, len(idOnly))
+	return nil
+}
+
+// printAliasReport prints the alias check of `validate-config --aliases` and
+// returns the number of accounts whose name does not match their alias.
+func printAliasReport(probes []aliasProbe) int {
+	mismatches := 0
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprint(w, pkg.ColorRow(os.Stdout, pkg.ColorPlain, "RESULT\tACCOUNT\tID\tALIAS\tDETAIL\n"))
+	for _, p := range probes {
+		result, detail := "PASS", ""
+		switch {
+		case p.Err != nil:
+			result, detail = pkg.IssueWarning, p.Err.Error()
+		case p.Alias == "":
+			detail = "no IAM account alias set"
+		case accountIDOnlyName.MatchString(p.Account):
+			result, detail = pkg.IssueWarning, fmt.Sprintf("named by its ID; suggested name: %s", p.Alias)
+			mismatches++
+		case p.Alias != p.Account:
+			result, detail = pkg.IssueWarning, fmt.Sprintf("config name does not match the account alias '%s'", p.Alias)
+			mismatches++
+		}
+		fmt.Fprint(w, pkg.ColorRow(os.Stdout, statusColor(result), fmt.Sprintf("[%s]\t%s\t%s\t%s\t%s\n", result, p.Account, p.ID, p.Alias, detail)))
+	}
+	w.Flush()
+	return mismatches
+}
@@ -0,0 +1,113 @@
+package saws
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"saws/internal/pkg"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	cwtypes "github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+	ststypes "github.com/aws/aws-sdk-go-v2/service/sts/types"
+)
+
+// firingAlarm is one row of the -alarms table.
+type firingAlarm struct {
+	AccountName string
+	Region      string
+	Name        string
+	StateSince  time.Time
+	Metric      string
+}
+
+// metricAlarmLabel describes what a metric alarm watches, e.g. "AWS/EC2 CPUUtilization".
+func metricAlarmLabel(alarm cwtypes.MetricAlarm) string {
+	if alarm.MetricName != nil {
+		return fmt.Sprintf("%s %s", aws.ToString(alarm.Namespace), *alarm.MetricName)
+	}
+	if len(alarm.Metrics) > 0 {
+		return "(metric math)"
+	}
+	return "-"
+}
+
+// listFiringAlarms returns the metric and composite alarms currently in ALARM state.
+func listFiringAlarms(ctx context.Context, target FanOutTarget, creds *ststypes.Credentials) ([]firingAlarm, error) {
+	cfg, err := pkg.LoadAssumedRoleConfig(ctx, creds, target.Region)
+	if err != nil {
+		return nil, err
+	}
+	client := cloudwatch.NewFromConfig(cfg)
+
+	pkg.LogVerbosef("Fetching alarms in ALARM state for Account: %s, Region: %s", target.AccountName, target.Region)
+	var alarms []firingAlarm
+	paginator := cloudwatch.NewDescribeAlarmsPaginator(client, &cloudwatch.DescribeAlarmsInput{
+		StateValue: cwtypes.StateValueAlarm,
+		AlarmTypes: []cwtypes.AlarmType{cwtypes.AlarmTypeMetricAlarm, cwtypes.AlarmTypeCompositeAlarm},
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("cloudwatch:DescribeAlarms failed: %w", err)
+		}
+		for _, alarm := range page.MetricAlarms {
+			alarms = append(alarms, firingAlarm{
+				AccountName: target.AccountName,
+				Region:      target.Region,
+				Name:        aws.ToString(alarm.AlarmName),
+				StateSince:  aws.ToTime(alarm.StateUpdatedTimestamp),
+				Metric:      metricAlarmLabel(alarm),
+			})
+		}
+		for _, alarm := range page.CompositeAlarms {
+			alarms = append(alarms, firingAlarm{
+				AccountName: target.AccountName,
+				Region:      target.Region,
+				Name:        aws.ToString(alarm.AlarmName),
+				StateSince:  aws.ToTime(alarm.StateUpdatedTimestamp),
+				Metric:      "(composite)",
+			})
+		}
+	}
+	return alarms, nil
+}
+
+// HandleAlarmStatus handles the logic for the -alarms mode. Exported.
+// It prints every alarm in ALARM state across the targets as one table, most
+// recently triggered first.
+func HandleAlarmStatus(ctx context.Context, baseCfg aws.Config, appCfg *pkg.AppConfig, accountNames, regions []string, roleToAssume string) error {
+	alarms, failures, summary := CollectFanOut(ctx, baseCfg, appCfg, accountNames, regions,
+		FanOutOptions{Label: "Alarms Mode", RoleToAssume: roleToAssume, SessionName: "AlarmStatus"},
+		listFiringAlarms)
+
+	sort.Slice(alarms, func(i, j int) bool {
+		if !alarms[i].StateSince.Equal(alarms[j].StateSince) {
+			return alarms[i].StateSince.After(alarms[j].StateSince)
+		}
+		return alarms[i].Name < alarms[j].Name
+	})
+
+	if len(alarms) == 0 {
+		fmt.Fprintf(os.Stderr, "No alarms in ALARM state across %d account/region target(s).\n", summary.Succeeded)
+	} else {
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ACCOUNT\tREGION\tALARM\tSTATE SINCE\tMETRIC")
+		for _, a := range alarms {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", a.AccountName, a.Region, a.Name, a.StateSince.Local().Format("2006-01-02 15:04:05"), a.Metric)
+		}
+		w.Flush()
+	}
+
+	for _, f := range failures {
+		fmt.Fprintf(os.Stderr, "Warning: Could not fetch alarms for Account: %s, Region: %s: %s\n", f.Target.AccountName, f.Target.Region, f.Err)
+	}
+	if len(failures) > 0 {
+		return fmt.Errorf("%d out of %d target(s) could not be queried", len(failures), summary.Total)
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package saws
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"saws/internal/pkg"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	ssmtypes "github.com/aws/aws-sdk-go-v2/service/ssm/types"
+	ststypes "github.com/aws/aws-sdk-go-v2/service/sts/types"
+)
+
+// automationPollInterval is how often a running Automation execution is checked.
+const automationPollInterval = 10 * time.Second
+
+// ParseAutomationParameters parses "key=value" operands into Automation
+// document parameters; repeating a key gives it several values.
+func ParseAutomationParameters(args []string) (map[string][]string, error) {
+	params := make(map[string][]string)
+	for _, arg := range args {
+		key, value, ok := strings.Cut(arg, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("document parameter '%s' is not key=value", arg)
+		}
+		params[key] = append(params[key], value)
+	}
+	return params, nil
+}
+
+// expandAutomationParameters replaces {account}, {account_id}, and {region} in
+// the parameter values for target, e.g. in an AutomationAssumeRole ARN.
+func expandAutomationParameters(params map[string][]string, target FanOutTarget) map[string][]string {
+	replacer := strings.NewReplacer("{account}", target.AccountName, "{account_id}", target.AccountID, "{region}", target.Region)
+	expanded := make(map[string][]string, len(params))
+	for key, values := range params {
+		for _, value := range values {
+			expanded[key] = append(expanded[key], replacer.Replace(value))
+		}
+	}
+	return expanded
+}
+
+// automationDone reports whether status is final, and whether it is a success.
+func automationDone(status ssmtypes.AutomationExecutionStatus) (done, succeeded bool) {
+	switch status {
+	case ssmtypes.AutomationExecutionStatusSuccess, ssmtypes.AutomationExecutionStatusCompletedWithSuccess:
+		return true, true
+	case ssmtypes.AutomationExecutionStatusFailed, ssmtypes.AutomationExecutionStatusTimedout,
+		ssmtypes.AutomationExecutionStatusCancelled, ssmtypes.AutomationExecutionStatusRejected,
+		ssmtypes.AutomationExecutionStatusCompletedWithFailure, ssmtypes.AutomationExecutionStatusExited,
+		ssmtypes.AutomationExecutionStatusChangeCalendarOverrideRejected:
+		return true, false
+	}
+	return false, false
+}
+
+// NewAutomationTask returns the fan-out task for `saws automation`, which
+// starts documentName with params in each target, waits for the execution to
+// finish, and reports its status, failure message, and outputs.
+func NewAutomationTask(documentName string, params map[string][]string) FanOutTask {
+	return func(ctx context.Context, target FanOutTarget, creds *ststypes.Credentials) FanOutResult {
+		cfg, err := pkg.LoadAssumedRoleConfig(ctx, creds, target.Region)
+		if err != nil {
+			return failedResult(err)
+		}
+		client := ssm.NewFromConfig(cfg)
+
+		pkg.LogVerbosef("Starting Automation document %s in Account: %s, Region: %s", documentName, target.AccountName, target.Region)
+		started, err := client.StartAutomationExecution(ctx, &ssm.StartAutomationExecutionInput{
+			DocumentName: aws.String(documentName),
+			Parameters:   expandAutomationParameters(params, target),
+		})
+		if err != nil {
+			return failedResult(fmt.Errorf("ssm:StartAutomationExecution failed for document %s: %w", documentName, err))
+		}
+		executionID := aws.ToString(started.AutomationExecutionId)
+
+		for {
+			out, err := client.GetAutomationExecution(ctx, &ssm.GetAutomationExecutionInput{AutomationExecutionId: started.AutomationExecutionId})
+			if err != nil {
+				return failedResult(fmt.Errorf("ssm:GetAutomationExecution failed for execution %s: %w", executionID, err))
+			}
+			execution := out.AutomationExecution
+			done, succeeded := automationDone(execution.AutomationExecutionStatus)
+			if !done {
+				pkg.LogVerbosef("Automation execution %s in Account: %s, Region: %s is %s", executionID, target.AccountName, target.Region, execution.AutomationExecutionStatus)
+				select {
+				case <-ctx.Done():
+					return failedResult(fmt.Errorf("stopped waiting for execution %s: %w", executionID, ctx.Err()))
+				case <-time.After(automationPollInterval):
+				}
+				continue
+			}
+
+			result := FanOutResult{Status: StatusSuccess, Info: fmt.Sprintf("Execution: %s (%s)", executionID, execution.AutomationExecutionStatus)}
+			if !succeeded {
+				result.Status, result.ExitCode = StatusFailed, 1
+				if message := aws.ToString(execution.FailureMessage); message != "" {
+					result.Sections = append(result.Sections, FanOutSection{Label: "FAILURE", Body: message})
+				}
+			}
+			if len(execution.Outputs) > 0 {
+				keys := make([]string, 0, len(execution.Outputs))
+				for key := range execution.Outputs {
+					keys = append(keys, key)
+				}
+				sort.Strings(keys)
+				var b strings.Builder
+				for _, key := range keys {
+					fmt.Fprintf(&b, "%s: %s\n", key, strings.Join(execution.Outputs[key], ", "))
+				}
+				result.Sections = append(result.Sections, FanOutSection{Label: "OUTPUTS", Body: b.String(), Output: true})
+			}
+			return result
+		}
+	}
+}
@@ -0,0 +1,232 @@
+package saws
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"saws/internal/pkg"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/codeartifact"
+	catypes "github.com/aws/aws-sdk-go-v2/service/codeartifact/types"
+)
+
+// Package tools -codeartifact-login can configure.
+const (
+	CodeArtifactPip   = "pip"
+	CodeArtifactNpm   = "npm"
+	CodeArtifactMaven = "maven"
+)
+
+// CodeArtifactTools are the tools -ca-tools accepts.
+var CodeArtifactTools = []string{CodeArtifactPip, CodeArtifactNpm, CodeArtifactMaven}
+
+// codeArtifactFormats maps each tool to the repository endpoint format it reads.
+var codeArtifactFormats = map[string]catypes.PackageFormat{
+	CodeArtifactPip:   catypes.PackageFormatPypi,
+	CodeArtifactNpm:   catypes.PackageFormatNpm,
+	CodeArtifactMaven: catypes.PackageFormatMaven,
+}
+
+// codeArtifactToolBinaries are the programs whose presence on PATH selects a
+// tool when -ca-tools is not given.
+var codeArtifactToolBinaries = map[string][]string{
+	CodeArtifactPip:   {"pip3", "pip"},
+	CodeArtifactNpm:   {"npm"},
+	CodeArtifactMaven: {"mvn"},
+}
+
+// lookPathAny returns the first of names found on PATH.
+func lookPathAny(names []string) (string, bool) {
+	for _, name := range names {
+		if path, err := exec.LookPath(name); err == nil {
+			return path, true
+		}
+	}
+	return "", false
+}
+
+// defaultCodeArtifactTools are the tools installed on this machine.
+func defaultCodeArtifactTools() []string {
+	var tools []string
+	for _, tool := range CodeArtifactTools {
+		if _, ok := lookPathAny(codeArtifactToolBinaries[tool]); ok {
+			tools = append(tools, tool)
+		}
+	}
+	return tools
+}
+
+func runToolCommand(argv ...string) error {
+	cmd := exec.Command(argv[0], argv[1:]...)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("'%s %s' failed: %v: %s", filepath.Base(argv[0]), argv[1], err, pkg.RedactSecrets(strings.TrimSpace(string(out))))
+	}
+	return nil
+}
+
+// configurePip points pip's global index at endpoint, with the token in the URL.
+func configurePip(endpoint, token string) error {
+	pip, ok := lookPathAny(codeArtifactToolBinaries[CodeArtifactPip])
+	if !ok {
+		return errors.New("pip not found in PATH")
+	}
+	indexURL, err := url.Parse(endpoint)
+	if err != nil {
+		return err
+	}
+	indexURL.User = url.UserPassword("aws", token)
+	indexURL.Path = strings.TrimSuffix(indexURL.Path, "/") + "/simple/"
+	return runToolCommand(pip, "config", "set", "global.index-url", indexURL.String())
+}
+
+// configureNpm sets npm's registry to endpoint and its auth token for it.
+func configureNpm(endpoint, token string) error {
+	npm, ok := lookPathAny(codeArtifactToolBinaries[CodeArtifactNpm])
+	if !ok {
+		return errors.New("npm not found in PATH")
+	}
+	if err := runToolCommand(npm, "config", "set", "registry", endpoint); err != nil {
+		return err
+	}
+	authKey := "//" + strings.TrimPrefix(strings.TrimPrefix(endpoint, "https://"), "http://") + ":_authToken"
+	return runToolCommand(npm, "config", "set", authKey, token)
+}
+
+// mavenServerPattern matches the <server> block with a given id in settings.xml.
+func mavenServerPattern(id string) *regexp.Regexp {
+	return regexp.MustCompile(`(?s)<server>\s*<id>` + regexp.QuoteMeta(id) + `</id>.*?</server>`)
+}
+
+// configureMaven writes a <server> with the token for serverID into
+// ~/.m2/settings.xml, replacing one of the same id and keeping the rest of
+// the file. Maven takes the repository URL from the project's pom.xml.
+func configureMaven(serverID, token string) (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(homeDir, ".m2", "settings.xml")
+	server := fmt.Sprintf("<server>\n      <id>%s</id>\n      <username>aws</username>\n      <password>%s</password>\n    </server>", html.EscapeString(serverID), html.EscapeString(token))
+	unlock, err := pkg.LockState(path)
+	if err != nil {
+		return "", err
+	}
+	defer unlock()
+
+	data, err := os.ReadFile(path)
+	var settings string
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		settings = "<settings>\n  <servers>\n    " + server + "\n  </servers>\n</settings>\n"
+	case err != nil:
+		return "", err
+	default:
+		settings = string(data)
+		if pattern := mavenServerPattern(serverID); pattern.MatchString(settings) {
+			settings = pattern.ReplaceAllLiteralString(settings, server)
+		} else if strings.Contains(settings, "</servers>") {
+			settings = strings.Replace(settings, "</servers>", "  "+server+"\n  </servers>", 1)
+		} else if strings.Contains(settings, "</settings>") {
+			settings = strings.Replace(settings, "</settings>", "  <servers>\n    "+server+"\n  </servers>\n</settings>", 1)
+		} else {
+			return "", fmt.Errorf("'%s' has no </settings> element", path)
+		}
+	}
+	return path, pkg.WriteFileAtomic(path, []byte(settings), 0o600)
+}
+
+// HandleCodeArtifactLogin handles the logic for the -codeartifact-login mode. Exported.
+// Under the assumed role it fetches a CodeArtifact authorization token for
+// domain (owned by domainOwner, an account name or ID, default the selected
+// account) and points each of tools at the repository: pip's global index,
+// npm's registry, and a <server> of id <domain>-<repository> in
+// ~/.m2/settings.xml for maven. Without tools, those installed are configured.
+func HandleCodeArtifactLogin(ctx context.Context, appCfg *pkg.AppConfig, domain, repository, domainOwner string, tools []string, accountSelectorFlag, roleFlag, regionFlagFromCmd string) error {
+	if domain == "" || repository == "" {
+		return errors.New("a CodeArtifact domain (-ca-domain) and repository (-ca-repo) are required")
+	}
+	for _, tool := range tools {
+		if _, ok := codeArtifactFormats[tool]; !ok {
+			return fmt.Errorf("unknown tool '%s' in -ca-tools (expected %s)", tool, strings.Join(CodeArtifactTools, ", "))
+		}
+	}
+	if len(tools) == 0 {
+		if tools = defaultCodeArtifactTools(); len(tools) == 0 {
+			return fmt.Errorf("none of %s is installed; name the tools to configure with -ca-tools", strings.Join(CodeArtifactTools, ", "))
+		}
+	}
+
+	sCtx, creds, err := pkg.EstablishAWSContextAndAssumeRole(ctx, accountSelectorFlag, roleFlag, regionFlagFromCmd, "CodeArtifactLogin")
+	if err != nil {
+		return fmt.Errorf("could not establish AWS context: %w", err)
+	}
+	ownerID := sCtx.AccountID
+	if domainOwner != "" {
+		ownerID = domainOwner
+		if id, ok := appCfg.Accounts[domainOwner]; ok {
+			ownerID = id
+		}
+	}
+	cfg, err := pkg.LoadAssumedRoleConfig(ctx, creds, sCtx.Region)
+	if err != nil {
+		return err
+	}
+	client := codeartifact.NewFromConfig(cfg)
+
+	tokenOut, err := client.GetAuthorizationToken(ctx, &codeartifact.GetAuthorizationTokenInput{Domain: aws.String(domain), DomainOwner: aws.String(ownerID)})
+	if err != nil {
+		return fmt.Errorf("codeartifact:GetAuthorizationToken for domain %s (owner %s) failed: %w", domain, ownerID, err)
+	}
+	token := aws.ToString(tokenOut.AuthorizationToken)
+	pkg.RegisterSecret(token)
+	expires := "unknown"
+	if tokenOut.Expiration != nil {
+		expires = tokenOut.Expiration.Local().Format("2006-01-02 15:04")
+	}
+
+	var failed []string
+	for _, tool := range tools {
+		endpointOut, err := client.GetRepositoryEndpoint(ctx, &codeartifact.GetRepositoryEndpointInput{
+			Domain: aws.String(domain), DomainOwner: aws.String(ownerID), Repository: aws.String(repository), Format: codeArtifactFormats[tool],
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: codeartifact:GetRepositoryEndpoint failed: %v\n", tool, err)
+			failed = append(failed, tool)
+			continue
+		}
+		endpoint := aws.ToString(endpointOut.RepositoryEndpoint)
+		switch tool {
+		case CodeArtifactPip:
+			err = configurePip(endpoint, token)
+		case CodeArtifactNpm:
+			err = configureNpm(endpoint, token)
+		case CodeArtifactMaven:
+			var settingsPath string
+			serverID := domain + "-" + repository
+			if settingsPath, err = configureMaven(serverID, token); err == nil {
+				fmt.Fprintf(os.Stderr, "maven: wrote server '%s' to %s; use it in pom.xml with <id>%s</id> and <url>%s</url>.\n", serverID, settingsPath, serverID, endpoint)
+			}
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", tool, err)
+			failed = append(failed, tool)
+			continue
+		}
+		fmt.Printf("Configured %s for %s/%s (%s).\n", tool, domain, repository, endpoint)
+	}
+	fmt.Printf("Token for domain %s (owner %s) expires %s.\n", domain, ownerID, expires)
+	if len(failed) > 0 {
+		return fmt.Errorf("could not configure %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
@@ -1,110 +1,114 @@
 package saws
 
 import (
-	"bytes"
 	"context"
 	"fmt"
 	"log"
 	"os"
 	"os/exec"
 	"strings"
-	"sync"
-	"sync/atomic"
+	"text/template"
 	"time"
 
-	"saws/internal/pkg"
-
-	"github.com/aws/aws-sdk-go-v2/aws"
+	ststypes "github.com/aws/aws-sdk-go-v2/service/sts/types"
 )
 
-func ProcessAccountRegion(
-	ctx context.Context,
-	wg *sync.WaitGroup,
-	baseCfg aws.Config,
-	appCfg *pkg.AppConfig,
-	accountName string,
-	roleToAssume string,
-	commandToRun string,
-	region string,
-	successCounter *atomic.Int64,
-) {
-	defer wg.Done()
-
-	accountID, accountExists := appCfg.Accounts[accountName]
-	if !accountExists {
-		log.Printf("ERROR: Account ID not found for SAWS config account name '%s'. Skipping.", accountName)
-		return
+// ParseWorkdir parses a -workdir value, a Go template over the fields of
+// FanOutTarget such as ./envs/{{.AccountName}}/{{.Region}}.
+func ParseWorkdir(value string) (*template.Template, error) {
+	tmpl, err := template.New("workdir").Option("missingkey=error").Parse(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid -workdir template '%s': %w", value, err)
 	}
+	return tmpl, nil
+}
 
-	assumedRoleCreds, err := pkg.AssumeRole(ctx, baseCfg, accountID, roleToAssume, "CmdExecSess")
+// targetWorkdir expands workdir for target and checks that it is a directory.
+func targetWorkdir(workdir *template.Template, target FanOutTarget) (string, error) {
+	var b strings.Builder
+	if err := workdir.Execute(&b, target); err != nil {
+		return "", fmt.Errorf("could not expand -workdir: %w", err)
+	}
+	dir := b.String()
+	info, err := os.Stat(dir)
 	if err != nil {
-		log.Printf("ERROR: Assume Role Failed Account:%s Region:%s Role:%s: %v", accountName, region, roleToAssume, err)
-		return
+		return "", fmt.Errorf("working directory '%s' not found: %w", dir, err)
 	}
+	if !info.IsDir() {
+		return "", fmt.Errorf("working directory '%s' is not a directory", dir)
+	}
+	return dir, nil
+}
 
-	cmd := exec.CommandContext(ctx, "bash", "-c", commandToRun)
+// ShellJoin turns the operands of `saws exec` or `ecs-service-exec` into one
+// command line. A single operand is taken as a command line already
+// ("aws s3 ls | wc -l"); several are quoted for the shell one by one, so each
+// reaches the command as one argument however it is spaced or quoted.
+func ShellJoin(operands []string) string {
+	if len(operands) == 1 {
+		return operands[0]
+	}
+	quoted := make([]string, len(operands))
+	for i, operand := range operands {
+		quoted[i] = shellQuote(operand)
+	}
+	return strings.Join(quoted, " ")
+}
 
-	var cleanEnv []string
-	originalEnv := os.Environ()
-	for _, envVar := range originalEnv {
-		if !strings.HasPrefix(envVar, "AWS_PROFILE=") &&
-			!strings.HasPrefix(envVar, "AWS_ACCESS_KEY_ID=") &&
-			!strings.HasPrefix(envVar, "AWS_SECRET_ACCESS_KEY=") &&
-			!strings.HasPrefix(envVar, "AWS_SESSION_TOKEN=") &&
-			!strings.HasPrefix(envVar, "AWS_SECURITY_TOKEN=") &&
-			!strings.HasPrefix(envVar, "AWS_REGION=") &&
-			!strings.HasPrefix(envVar, "AWS_DEFAULT_REGION=") &&
-			!strings.HasPrefix(envVar, "AWS_CONFIG_FILE=") &&
-			!strings.HasPrefix(envVar, "AWS_SHARED_CREDENTIALS_FILE=") {
-			cleanEnv = append(cleanEnv, envVar)
+// NewCommandTask returns the fan-out task for -c, which runs commandToRun with
+// bash (PowerShell on Windows) under the assumed-role credentials of each target.
+// With workdir, the command runs in the directory it expands to for the target,
+// and targets whose directory is missing fail without running it.
+func NewCommandTask(commandToRun string, workdir *template.Template) FanOutTask {
+	return func(ctx context.Context, target FanOutTarget, assumedRoleCreds *ststypes.Credentials) FanOutResult {
+		cmd := shellCommand(ctx, commandToRun)
+		if workdir != nil {
+			dir, err := targetWorkdir(workdir, target)
+			if err != nil {
+				return failedResult(err)
+			}
+			cmd.Dir = dir
 		}
-	}
-	cmd.Env = cleanEnv
-	cmd.Env = append(cmd.Env, fmt.Sprintf("AWS_ACCESS_KEY_ID=%s", *assumedRoleCreds.AccessKeyId))
-	cmd.Env = append(cmd.Env, fmt.Sprintf("AWS_SECRET_ACCESS_KEY=%s", *assumedRoleCreds.SecretAccessKey))
-	cmd.Env = append(cmd.Env, fmt.Sprintf("AWS_SESSION_TOKEN=%s", *assumedRoleCreds.SessionToken))
-	cmd.Env = append(cmd.Env, fmt.Sprintf("AWS_REGION=%s", region))
-	cmd.Env = append(cmd.Env, fmt.Sprintf("AWS_DEFAULT_REGION=%s", region))
+		cmd.Env = withoutEnv(os.Environ(), append(inheritedAWSEnv, "AWS_CONFIG_FILE", "AWS_SHARED_CREDENTIALS_FILE")...)
+		cmd.Env = append(cmd.Env, fmt.Sprintf("AWS_ACCESS_KEY_ID=%s", *assumedRoleCreds.AccessKeyId))
+		cmd.Env = append(cmd.Env, fmt.Sprintf("AWS_SECRET_ACCESS_KEY=%s", *assumedRoleCreds.SecretAccessKey))
+		cmd.Env = append(cmd.Env, fmt.Sprintf("AWS_SESSION_TOKEN=%s", *assumedRoleCreds.SessionToken))
+		cmd.Env = append(cmd.Env, fmt.Sprintf("AWS_REGION=%s", target.Region))
+		cmd.Env = append(cmd.Env, fmt.Sprintf("AWS_DEFAULT_REGION=%s", target.Region))
 
-	var outb, errb bytes.Buffer
-	cmd.Stdout = &outb
-	cmd.Stderr = &errb
+		// Output beyond outputSpillBytes goes to temporary files.
+		outw, errw := newSpillWriter(target, "stdout"), newSpillWriter(target, "stderr")
+		cmd.Stdout = outw
+		cmd.Stderr = errw
 
-	startTime := time.Now()
-	err = cmd.Run()
-	duration := time.Since(startTime)
+		startTime := time.Now()
+		err := cmd.Run()
+		duration := time.Since(startTime)
 
-	exitCode := 0
-	status := "SUCCESS"
-	if err != nil {
-		status = "FAILED"
-		if exitErr, ok := err.(*exec.ExitError); ok {
-			exitCode = exitErr.ExitCode()
-		} else {
-			log.Printf("ERROR executing command '%s' for Account: %s, Region: %s: %v", commandToRun, accountName, region, err)
-			exitCode = -1
+		exitCode := 0
+		status := StatusSuccess
+		if err != nil {
+			status = StatusFailed
+			if exitErr, ok := err.(*exec.ExitError); ok {
+				exitCode = exitErr.ExitCode()
+			} else {
+				log.Printf("ERROR executing command '%s' for Account: %s, Region: %s: %v", commandToRun, target.AccountName, target.Region, err)
+				exitCode = -1
+			}
 		}
-	}
 
-	fmt.Printf("--- Result (Account: %s, Region: %s, Status: %s, Exit Code: %d, Duration: %s) ---\n",
-		accountName, region, status, exitCode, duration.Round(time.Millisecond))
-	stdOutput := strings.TrimSpace(outb.String())
-	errOutput := strings.TrimSpace(errb.String())
-	if stdOutput != "" {
-		fmt.Println("[STDOUT]")
-		fmt.Println(stdOutput)
-	}
-	if errOutput != "" {
-		if exitCode != 0 {
-			fmt.Println("[STDERR]")
-		} else {
-			fmt.Println("[STDERR (Exit Code 0)]")
+		stderrLabel := "STDERR"
+		if exitCode == 0 {
+			stderrLabel = "STDERR (Exit Code 0)"
+		}
+		return FanOutResult{
+			Status:   status,
+			ExitCode: exitCode,
+			Duration: duration,
+			Sections: []FanOutSection{
+				outw.section("STDOUT", true),
+				errw.section(stderrLabel, false),
+			},
 		}
-		fmt.Println(errOutput)
-	}
-	fmt.Println("--- End Result ---")
-
-	if exitCode == 0 {
-		successCounter.Add(1)
 	}
 }
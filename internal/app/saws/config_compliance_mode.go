@@ -0,0 +1,181 @@
+package saws
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+
+	"saws/internal/pkg"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/configservice"
+	configtypes "github.com/aws/aws-sdk-go-v2/service/configservice/types"
+	ststypes "github.com/aws/aws-sdk-go-v2/service/sts/types"
+)
+
+// configComplianceRow is one Config rule of one target in the config-compliance table.
+type configComplianceRow struct {
+	Account    string `json:"account"`
+	Region     string `json:"region"`
+	Rule       string `json:"rule"`
+	Compliance string `json:"compliance"`
+	// NonCompliant counts the resources failing the rule; Config caps the
+	// count at 100, which CapExceeded reports.
+	NonCompliant int  `json:"non_compliant"`
+	CapExceeded  bool `json:"cap_exceeded,omitempty"`
+}
+
+// nonCompliantCount formats the non-compliant resource count of a row.
+func (r configComplianceRow) nonCompliantCount() string {
+	if r.CapExceeded {
+		return fmt.Sprintf("%d+", r.NonCompliant)
+	}
+	return fmt.Sprintf("%d", r.NonCompliant)
+}
+
+// collectConfigCompliance lists the compliance of every Config rule of one target.
+func collectConfigCompliance(ctx context.Context, target FanOutTarget, creds *ststypes.Credentials) ([]configComplianceRow, error) {
+	cfg, err := pkg.LoadAssumedRoleConfig(ctx, creds, target.Region)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []configComplianceRow
+	paginator := configservice.NewDescribeComplianceByConfigRulePaginator(configservice.NewFromConfig(cfg), &configservice.DescribeComplianceByConfigRuleInput{})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("config:DescribeComplianceByConfigRule failed: %w", err)
+		}
+		for _, rule := range page.ComplianceByConfigRules {
+			row := configComplianceRow{Account: target.AccountName, Region: target.Region, Rule: aws.ToString(rule.ConfigRuleName), Compliance: string(configtypes.ComplianceTypeInsufficientData)}
+			if rule.Compliance != nil {
+				if rule.Compliance.ComplianceType != "" {
+					row.Compliance = string(rule.Compliance.ComplianceType)
+				}
+				if count := rule.Compliance.ComplianceContributorCount; count != nil {
+					row.NonCompliant, row.CapExceeded = int(count.CappedCount), count.CapExceeded
+				}
+			}
+			rows = append(rows, row)
+		}
+	}
+	pkg.LogVerbosef("Found %d Config rule(s) in Account: %s, Region: %s", len(rows), target.AccountName, target.Region)
+	return rows, nil
+}
+
+// HandleConfigCompliance handles the logic for the `config-compliance` mode. Exported.
+// It prints one table of every AWS Config rule in every target with its
+// compliance and non-compliant resource count, worst first, followed by the
+// non-compliant totals per rule name when several targets report the same rule.
+func HandleConfigCompliance(ctx context.Context, baseCfg aws.Config, appCfg *pkg.AppConfig, accountNames, regions []string, roleToAssume string, asJSON bool) error {
+	rows, failures, summary := CollectFanOut(ctx, baseCfg, appCfg, accountNames, regions,
+		FanOutOptions{Label: "Config Compliance Mode", RoleToAssume: roleToAssume, SessionName: "ConfigCompliance"},
+		collectConfigCompliance)
+
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].NonCompliant != rows[j].NonCompliant {
+			return rows[i].NonCompliant > rows[j].NonCompliant
+		}
+		if rows[i].Account != rows[j].Account {
+			return rows[i].Account < rows[j].Account
+		}
+		if rows[i].Region != rows[j].Region {
+			return rows[i].Region < rows[j].Region
+		}
+		return rows[i].Rule < rows[j].Rule
+	})
+
+	if asJSON {
+		if rows == nil {
+			rows = []configComplianceRow{}
+		}
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(rows); err != nil {
+			return fmt.Errorf("failed to encode Config compliance rows as JSON: %w", err)
+		}
+	} else if len(rows) == 0 {
+		fmt.Fprintf(os.Stderr, "No AWS Config rules found across %d account/region target(s).\n", summary.Succeeded)
+	} else {
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprint(w, pkg.ColorRow(os.Stdout, pkg.ColorPlain, "ACCOUNT\tREGION\tRULE\tCOMPLIANCE\tNON-COMPLIANT\n"))
+		for _, r := range rows {
+			color := pkg.ColorPlain
+			switch configtypes.ComplianceType(r.Compliance) {
+			case configtypes.ComplianceTypeNonCompliant:
+				color = pkg.ColorRed
+			case configtypes.ComplianceTypeInsufficientData:
+				color = pkg.ColorYellow
+			}
+			fmt.Fprint(w, pkg.ColorRow(os.Stdout, color, fmt.Sprintf("%s\t%s\t%s\t%s\t%s\n", r.Account, r.Region, r.Rule, r.Compliance, r.nonCompliantCount())))
+		}
+		w.Flush()
+		printConfigRuleTotals(rows)
+	}
+
+	for _, f := range failures {
+		fmt.Fprintf(os.Stderr, "Warning: Could not read Config compliance for Account: %s, Region: %s: %s\n", f.Target.AccountName, f.Target.Region, f.Err)
+	}
+	if len(failures) > 0 {
+		return fmt.Errorf("%d out of %d target(s) could not be queried", len(failures), summary.Total)
+	}
+	return nil
+}
+
+// printConfigRuleTotals prints the non-compliant resource and target counts
+// per rule name, for rules deployed to several targets (e.g. by a conformance
+// pack or an organization rule) of which at least one is non-compliant.
+func printConfigRuleTotals(rows []configComplianceRow) {
+	type ruleTotal struct {
+		rule                  string
+		targets, nonCompliant int
+		resources             int
+		capped                bool
+	}
+	totals := make(map[string]*ruleTotal)
+	for _, r := range rows {
+		total := totals[r.Rule]
+		if total == nil {
+			total = &ruleTotal{rule: r.Rule}
+			totals[r.Rule] = total
+		}
+		total.targets++
+		if configtypes.ComplianceType(r.Compliance) == configtypes.ComplianceTypeNonCompliant {
+			total.nonCompliant++
+		}
+		total.resources += r.NonCompliant
+		total.capped = total.capped || r.CapExceeded
+	}
+
+	var shared []*ruleTotal
+	for _, total := range totals {
+		if total.targets > 1 && total.nonCompliant > 0 {
+			shared = append(shared, total)
+		}
+	}
+	if len(shared) == 0 {
+		return
+	}
+	sort.Slice(shared, func(i, j int) bool {
+		if shared[i].resources != shared[j].resources {
+			return shared[i].resources > shared[j].resources
+		}
+		return shared[i].rule < shared[j].rule
+	})
+
+	fmt.Println()
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprint(w, pkg.ColorRow(os.Stdout, pkg.ColorPlain, "RULE\tNON-COMPLIANT TARGETS\tNON-COMPLIANT RESOURCES\n"))
+	for _, total := range shared {
+		resources := fmt.Sprintf("%d", total.resources)
+		if total.capped {
+			resources += "+"
+		}
+		fmt.Fprint(w, pkg.ColorRow(os.Stdout, pkg.ColorRed, fmt.Sprintf("%s\t%d/%d\t%s\n", total.rule, total.nonCompliant, total.targets, resources)))
+	}
+	w.Flush()
+}
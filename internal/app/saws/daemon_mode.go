@@ -0,0 +1,218 @@
+package saws
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"sync"
+	"syscall"
+	"time"
+
+	"saws/internal/pkg"
+
+	ststypes "github.com/aws/aws-sdk-go-v2/service/sts/types"
+)
+
+const (
+	daemonSocketName = "saws.sock"
+	// daemonRefreshWindow is how long before expiry cached credentials are renewed.
+	daemonRefreshWindow = 5 * time.Minute
+)
+
+// DefaultDaemonSocket is ~/.aws/saws.sock.
+func DefaultDaemonSocket() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(homeDir, pkg.AWSConfigDir, daemonSocketName), nil
+}
+
+// daemonCredentials is the reply of /v1/credentials. The first five fields
+// follow the credential_process format, so the reply can be used as is.
+type daemonCredentials struct {
+	Version         int    `json:"Version"`
+	AccessKeyID     string `json:"AccessKeyId"`
+	SecretAccessKey string `json:"SecretAccessKey"`
+	SessionToken    string `json:"SessionToken"`
+	Expiration      string `json:"Expiration,omitempty"`
+	Account         string `json:"Account"`
+	AccountID       string `json:"AccountId"`
+	Role            string `json:"Role"`
+	Region          string `json:"Region"`
+}
+
+// credentialDaemon caches assumed-role credentials per account and role. Its
+// mutex guards only the map, so a slow AssumeRole for one role does not hold
+// up requests for others.
+type credentialDaemon struct {
+	// accounts are the configured accounts, the only ones the daemon serves.
+	accounts map[string]string
+	mu       sync.Mutex
+	cache    map[string]*daemonEntry
+}
+
+// daemonEntry is the credentials of one account and role; its mutex keeps
+// concurrent requests for them from assuming the role more than once.
+type daemonEntry struct {
+	mu    sync.Mutex
+	creds *ststypes.Credentials
+}
+
+// credentials returns cached credentials for sCtx, assuming the role again
+// when there are none or they expire within daemonRefreshWindow.
+func (d *credentialDaemon) credentials(ctx context.Context, sCtx *pkg.SelectedContext) (*ststypes.Credentials, error) {
+	key := sCtx.AccountID + "/" + sCtx.RoleName
+	d.mu.Lock()
+	entry, ok := d.cache[key]
+	if !ok {
+		entry = &daemonEntry{}
+		d.cache[key] = entry
+	}
+	d.mu.Unlock()
+
+	entry.mu.Lock()
+	defer entry.mu.Unlock()
+	if creds := entry.creds; creds != nil && (creds.Expiration == nil || time.Until(*creds.Expiration) > daemonRefreshWindow) {
+		return creds, nil
+	}
+	creds, err := pkg.AssumeSelectedRole(ctx, sCtx, "Daemon")
+	pkg.Audit(pkg.AuditResult(pkg.AuditRecord{Event: pkg.AuditAssumeRole, Account: sCtx.AccountName, AccountID: sCtx.AccountID, Role: sCtx.RoleName, Region: sCtx.Region}, err))
+	if err != nil {
+		return nil, err
+	}
+	entry.creds = creds
+	return creds, nil
+}
+
+func writeDaemonJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func (d *credentialDaemon) handleCredentials(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeDaemonJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "use GET"})
+		return
+	}
+	query := r.URL.Query()
+	sCtx, err := pkg.ResolveContext(query.Get("account"), query.Get("role"), query.Get("region"))
+	if err != nil {
+		writeDaemonJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	// Any local process may ask, so ad-hoc account IDs and role ARNs of
+	// accounts outside the config are refused.
+	if id, ok := d.accounts[sCtx.AccountName]; !ok || id != sCtx.AccountID {
+		writeDaemonJSON(w, http.StatusForbidden, map[string]string{"error": fmt.Sprintf("account '%s' is not in the SAWS config", sCtx.AccountName)})
+		return
+	}
+	// Other processes cannot see the warning an interactive run prints, so
+	// roles_by_account is enforced here.
+	if !pkg.RoleAllowedInAccount(sCtx.AccountName, sCtx.RoleName) {
+		writeDaemonJSON(w, http.StatusForbidden, map[string]string{"error": fmt.Sprintf("role '%s' is not listed for account '%s' under roles_by_account", sCtx.RoleName, sCtx.AccountName)})
+		return
+	}
+	creds, err := d.credentials(r.Context(), sCtx)
+	if err != nil {
+		log.Printf("Daemon: credentials for %s/%s failed: %v", sCtx.AccountName, sCtx.RoleName, err)
+		writeDaemonJSON(w, http.StatusBadGateway, map[string]string{"error": pkg.RedactSecrets(err.Error())})
+		return
+	}
+	reply := daemonCredentials{
+		Version: 1, AccessKeyID: *creds.AccessKeyId, SecretAccessKey: *creds.SecretAccessKey, SessionToken: *creds.SessionToken,
+		Account: sCtx.AccountName, AccountID: sCtx.AccountID, Role: sCtx.RoleName, Region: sCtx.Region,
+	}
+	if creds.Expiration != nil {
+		reply.Expiration = creds.Expiration.UTC().Format(time.RFC3339)
+	}
+	fmt.Fprintf(os.Stderr, "%s served %s/%s (%s)\n", time.Now().Format(time.TimeOnly), sCtx.AccountName, sCtx.RoleName, sCtx.Region)
+	writeDaemonJSON(w, http.StatusOK, reply)
+}
+
+func handleDaemonAccounts(appCfg *pkg.AppConfig) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		names := make([]string, 0, len(appCfg.Accounts))
+		for name := range appCfg.Accounts {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		writeDaemonJSON(w, http.StatusOK, map[string]any{"accounts": names, "roles": sortedKeys(appCfg.Roles)})
+	}
+}
+
+// listenDaemonSocket listens on socketPath, which is created accessible to the
+// current user only (see listenPrivateUnix), so no other user can connect in
+// the moment after it is bound. A socket left behind by a daemon that is gone
+// is replaced.
+func listenDaemonSocket(socketPath string) (net.Listener, error) {
+	if err := os.MkdirAll(filepath.Dir(socketPath), 0o700); err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(socketPath); err == nil {
+		if conn, errDial := net.Dial("unix", socketPath); errDial == nil {
+			conn.Close()
+			return nil, fmt.Errorf("a saws daemon is already listening on %s", socketPath)
+		}
+		if err := os.Remove(socketPath); err != nil {
+			return nil, fmt.Errorf("could not remove stale socket %s: %w", socketPath, err)
+		}
+	}
+	return listenPrivateUnix(socketPath)
+}
+
+// HandleDaemon handles the logic for the `daemon` mode. Exported.
+// It serves credentials for configured accounts and roles on a unix socket
+// only the current user can connect to, until interrupted:
+//
+//	GET /v1/credentials?account=<name|alias|id>&role=<role>[&region=<region>]
+//	GET /v1/accounts
+//
+// Only accounts of the config are served. Credentials are cached per account
+// and role and renewed shortly before they expire, so many tools can share one
+// session.
+func HandleDaemon(ctx context.Context, appCfg *pkg.AppConfig, socketPath string) error {
+	if socketPath == "" {
+		var err error
+		if socketPath, err = DefaultDaemonSocket(); err != nil {
+			return fmt.Errorf("could not determine the socket path: %w", err)
+		}
+	}
+	listener, err := listenDaemonSocket(socketPath)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(socketPath)
+
+	daemon := &credentialDaemon{accounts: appCfg.Accounts, cache: make(map[string]*daemonEntry)}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/credentials", daemon.handleCredentials)
+	mux.HandleFunc("/v1/accounts", handleDaemonAccounts(appCfg))
+	server := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+	go func() {
+		<-signals
+		shutdownCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+
+	fmt.Fprintf(os.Stderr, "Serving credentials on %s. Press Ctrl+C to stop.\n", socketPath)
+	fmt.Fprintf(os.Stderr, "Try: curl --unix-socket %s 'http://saws/v1/credentials?account=<account>&role=<role>'\n", socketPath)
+	if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("daemon stopped: %w", err)
+	}
+	return nil
+}
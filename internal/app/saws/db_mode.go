@@ -0,0 +1,159 @@
+package saws
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+
+	"saws/internal/pkg"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	rdsauth "github.com/aws/aws-sdk-go-v2/feature/rds/auth"
+)
+
+// dbClientCommand builds the local psql/mysql invocation for the engine family.
+// An empty password leaves authentication to the client's own prompt.
+func dbClientCommand(engine, host string, port int32, user, dbName, password string) (*exec.Cmd, error) {
+	switch {
+	case strings.Contains(engine, "postgres"):
+		clientPath, err := exec.LookPath("psql")
+		if err != nil {
+			return nil, errors.New("psql not found in PATH")
+		}
+		if dbName == "" {
+			dbName = "postgres"
+		}
+		cmd := exec.Command(clientPath, fmt.Sprintf("host=%s port=%d user=%s dbname=%s sslmode=require", host, port, user, dbName))
+		cmd.Env = os.Environ()
+		if password != "" {
+			cmd.Env = append(cmd.Env, fmt.Sprintf("PGPASSWORD=%s", password))
+		}
+		return cmd, nil
+	case strings.Contains(engine, "mysql"), strings.Contains(engine, "mariadb"):
+		clientPath, err := exec.LookPath("mysql")
+		if err != nil {
+			return nil, errors.New("mysql not found in PATH")
+		}
+		args := []string{fmt.Sprintf("--host=%s", host), fmt.Sprintf("--port=%d", port), fmt.Sprintf("--user=%s", user), "--ssl-mode=REQUIRED"}
+		if password != "" {
+			args = append(args, "--enable-cleartext-plugin")
+		} else {
+			args = append(args, "--password")
+		}
+		if dbName != "" {
+			args = append(args, dbName)
+		}
+		cmd := exec.Command(clientPath, args...)
+		cmd.Env = os.Environ()
+		if password != "" {
+			cmd.Env = append(cmd.Env, fmt.Sprintf("MYSQL_PWD=%s", password))
+		}
+		return cmd, nil
+	default:
+		return nil, fmt.Errorf("no local client known for engine '%s'", engine)
+	}
+}
+
+// HandleDbConnect handles the logic for the -db mode: pick a database endpoint,
+// pick a bastion, open an SSM tunnel, and run the local client through it. Exported.
+func HandleDbConnect(
+	ctx context.Context,
+	instanceFlag, dbUserFlag, dbNameFlag, bastionFlag string, localPortFlag int, // Flags specific to DB mode
+	accountSelectorFlag, roleFlag, regionFlagFromCmd string, // Common context flags
+) error {
+	pkg.LogVerbosef("Preparing for database connect session...")
+	sCtx, creds, err := pkg.EstablishAWSContextAndAssumeRole(ctx, accountSelectorFlag, roleFlag, regionFlagFromCmd, "DBConnectSession")
+	if err != nil {
+		return fmt.Errorf("could not establish AWS context for database session: %w", err)
+	}
+	cfg, err := pkg.LoadAssumedRoleConfig(ctx, creds, sCtx.Region)
+	if err != nil {
+		return err
+	}
+
+	db, err := selectRdsEndpoint(ctx, cfg, instanceFlag)
+	if err != nil {
+		return err
+	}
+
+	bastionID := bastionFlag
+	awsCredentials := aws.Credentials{AccessKeyID: *creds.AccessKeyId, SecretAccessKey: *creds.SecretAccessKey, SessionToken: *creds.SessionToken, Source: "SawsAssumedRoleForDB"}
+	if bastionID == "" {
+		bastionID, err = selectSSMInstance(ctx, awsCredentials, sCtx, "Choose a bastion instance for the tunnel:", SSMInstanceFilter{})
+		if err != nil {
+			return err
+		}
+		if bastionID == "" {
+			return errors.New("no bastion instance available for the tunnel")
+		}
+	} else {
+		pkg.LogVerbosef("Using bastion '%s' provided via --bastion flag.", bastionID)
+		bastionID, err = resolveSSMInstance(ctx, awsCredentials, sCtx.Region, bastionID)
+		if err != nil {
+			return err
+		}
+	}
+
+	localPort := int32(localPortFlag)
+	if localPort == 0 {
+		localPort, err = freeLocalPort()
+		if err != nil {
+			return err
+		}
+	}
+
+	dbUser := dbUserFlag
+	if dbUser == "" {
+		dbUser = db.MasterUser
+		pkg.LogVerbosef("No --db-user given, defaulting to master user '%s'.", dbUser)
+	}
+	if dbUser == "" {
+		return errors.New("could not determine database user; pass --db-user")
+	}
+
+	password := ""
+	if db.IAMAuth {
+		password, err = rdsauth.BuildAuthToken(ctx, fmt.Sprintf("%s:%d", db.Host, db.Port), sCtx.Region, dbUser, cfg.Credentials)
+		if err != nil {
+			return fmt.Errorf("failed to build RDS auth token for %s: %w", db.Identifier, err)
+		}
+		pkg.LogVerbosef("Using an IAM auth token as the password for %s.", dbUser)
+	}
+
+	clientCmd, err := dbClientCommand(db.Engine, "127.0.0.1", localPort, dbUser, dbNameFlag, password)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "Opening tunnel 127.0.0.1:%d -> %s:%d via %s...\n", localPort, db.Host, db.Port, bastionID)
+	tunnel, err := startSSMTunnel(ctx, creds, sCtx.Region, bastionID, db.Host, db.Port, localPort)
+	if err != nil {
+		return err
+	}
+	defer tunnel.Stop()
+
+	// Ctrl+C belongs to the interactive client; keep saws alive so the deferred
+	// tunnel teardown still runs when the client exits.
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt)
+	defer signal.Stop(signals)
+
+	fmt.Fprintf(os.Stderr, "Connecting to %s (%s) as %s in Account=%s(%s), Region=%s. Exit the client to close the tunnel.\n", db.Identifier, db.Engine, dbUser, sCtx.AccountName, sCtx.AccountID, sCtx.Region)
+	clientCmd.Stdin = os.Stdin
+	clientCmd.Stdout = os.Stdout
+	clientCmd.Stderr = os.Stderr
+	err = clientCmd.Run()
+	pkg.LogVerbosef("Database client exited.")
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			pkg.LogVerbosef("Database client exited with status: %s.", exitErr.Error())
+		} else {
+			return fmt.Errorf("failed to run database client: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package saws
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"saws/internal/pkg"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	ststypes "github.com/aws/aws-sdk-go-v2/service/sts/types"
+)
+
+// encodedAuthMessagePattern matches the blob AWS appends to AccessDenied /
+// UnauthorizedOperation errors, as printed by both the CLI and the SDKs.
+var encodedAuthMessagePattern = regexp.MustCompile(`Encoded authorization failure message: ([A-Za-z0-9_-]+)`)
+
+// decodeAuthorizationMessage calls sts:DecodeAuthorizationMessage and returns
+// the decoded policy context as indented JSON.
+func decodeAuthorizationMessage(ctx context.Context, cfg aws.Config, blob string) (string, error) {
+	out, err := sts.NewFromConfig(cfg).DecodeAuthorizationMessage(ctx, &sts.DecodeAuthorizationMessageInput{EncodedMessage: aws.String(blob)})
+	if err != nil {
+		return "", fmt.Errorf("sts:DecodeAuthorizationMessage failed: %w", err)
+	}
+	decoded := aws.ToString(out.DecodedMessage)
+	var pretty bytes.Buffer
+	if json.Indent(&pretty, []byte(decoded), "", "  ") == nil {
+		return pretty.String(), nil
+	}
+	return decoded, nil
+}
+
+// decodeAuthorizationFailures finds encoded authorization messages in a fan-out
+// result and returns a section with the decoded context for each. Decoding
+// needs sts:DecodeAuthorizationMessage, so failures are only logged.
+func decodeAuthorizationFailures(ctx context.Context, creds *ststypes.Credentials, target FanOutTarget, result FanOutResult) []FanOutSection {
+	var blobs []string
+	for _, section := range result.Sections {
+		for _, m := range encodedAuthMessagePattern.FindAllStringSubmatch(section.Body, -1) {
+			blobs = append(blobs, m[1])
+		}
+	}
+	if len(blobs) == 0 || creds == nil {
+		return nil
+	}
+	cfg, err := pkg.LoadAssumedRoleConfig(ctx, creds, target.Region)
+	if err != nil {
+		return nil
+	}
+	var sections []FanOutSection
+	for _, blob := range blobs {
+		decoded, err := decodeAuthorizationMessage(ctx, cfg, blob)
+		if err != nil {
+			pkg.LogVerbosef("Could not decode authorization message for Account: %s, Region: %s: %v", target.AccountName, target.Region, err)
+			continue
+		}
+		sections = append(sections, FanOutSection{Label: "DECODED AUTHORIZATION MESSAGE", Body: decoded})
+	}
+	return sections
+}
+
+// HandleDecode handles the logic for the `decode <blob>` mode. Exported.
+// The blob must be decoded in the account that produced it. A full error line
+// containing "Encoded authorization failure message:" is accepted too.
+func HandleDecode(ctx context.Context, blob, accountSelectorFlag, roleFlag, regionFlagFromCmd string) error {
+	blob = strings.TrimSpace(blob)
+	if m := encodedAuthMessagePattern.FindStringSubmatch(blob); m != nil {
+		blob = m[1]
+	}
+	sCtx, creds, err := pkg.EstablishAWSContextAndAssumeRole(ctx, accountSelectorFlag, roleFlag, regionFlagFromCmd, "DecodeAuthMessage")
+	if err != nil {
+		return fmt.Errorf("could not establish AWS context for decoding: %w", err)
+	}
+	cfg, err := pkg.LoadAssumedRoleConfig(ctx, creds, sCtx.Region)
+	if err != nil {
+		return err
+	}
+	decoded, err := decodeAuthorizationMessage(ctx, cfg, blob)
+	if err != nil {
+		return err
+	}
+	fmt.Println(decoded)
+	return nil
+}
@@ -0,0 +1,69 @@
+package saws
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"saws/internal/pkg"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	ststypes "github.com/aws/aws-sdk-go-v2/service/sts/types"
+)
+
+// Deploy tools `saws cdk` and `saws sam` wrap.
+const (
+	DeployToolCDK = "cdk"
+	DeployToolSAM = "sam"
+)
+
+// DeployTools are the tools of the deploy modes, which are also their command names.
+var DeployTools = []string{DeployToolCDK, DeployToolSAM}
+
+// deployArgv is the command line of tool in target: the user's args plus what
+// points the tool at the target account and region.
+//
+//   - cdk gets --context account=<id> and --context region=<region>, for apps
+//     that read their environment from the context.
+//   - sam gets --region unless args already name one.
+func deployArgv(tool string, target FanOutTarget, args []string) []string {
+	argv := append([]string{tool}, args...)
+	switch tool {
+	case DeployToolCDK:
+		argv = append(argv, "--context", "account="+target.AccountID, "--context", "region="+target.Region)
+	case DeployToolSAM:
+		if !slices.ContainsFunc(args, func(arg string) bool { return arg == "--region" || strings.HasPrefix(arg, "--region=") }) {
+			argv = append(argv, "--region", target.Region)
+		}
+	}
+	return argv
+}
+
+// HandleDeploy handles `saws cdk` and `saws sam`. Exported.
+// It runs the tool with args (e.g. `deploy MyStack`) in every selected account
+// and region through RunSequential, with the assumed credentials in the
+// environment. cdk also gets CDK_DEFAULT_ACCOUNT and CDK_DEFAULT_REGION, so
+// stacks using env: { account: process.env.CDK_DEFAULT_ACCOUNT } deploy to
+// each target in turn.
+func HandleDeploy(ctx context.Context, baseCfg aws.Config, appCfg *pkg.AppConfig, tool string, accountNames, regions []string, role string, args []string) FanOutSummary {
+	opts := FanOutOptions{Label: strings.ToUpper(tool) + " Mode", RoleToAssume: role, SessionName: "Deploy"}
+	task := func(ctx context.Context, target FanOutTarget, creds *ststypes.Credentials) FanOutResult {
+		sCtx := &pkg.SelectedContext{AccountName: target.AccountName, AccountID: target.AccountID, RoleName: role, Region: target.Region}
+		env := sessionEnv(sCtx, creds)
+		if tool == DeployToolCDK {
+			env = append(withoutEnv(env, "CDK_DEFAULT_ACCOUNT", "CDK_DEFAULT_REGION"), "CDK_DEFAULT_ACCOUNT="+target.AccountID, "CDK_DEFAULT_REGION="+target.Region)
+		}
+		argv := deployArgv(tool, target, args)
+		pkg.LogVerbosef("%s: Running %q in %s (%s), %s", opts.Label, argv, target.AccountName, target.AccountID, target.Region)
+		code, err := runWithEnv(argv, env)
+		if err == nil && code != 0 {
+			err = fmt.Errorf("%s exited with status %d", tool, code)
+		}
+		if err != nil {
+			return FanOutResult{Status: StatusFailed, ExitCode: code, Sections: []FanOutSection{{Label: "ERROR", Body: err.Error()}}}
+		}
+		return FanOutResult{Status: StatusSuccess}
+	}
+	return RunSequential(ctx, baseCfg, appCfg, accountNames, regions, opts, task)
+}
@@ -0,0 +1,126 @@
+package saws
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+
+	"saws/internal/pkg"
+
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+)
+
+// DockerCredentialHelperName is the executable name docker looks up for
+// `"credsStore": "saws"` / `"credHelpers": {...: "saws"}`. When saws is invoked
+// under this name (e.g. via a symlink) it behaves as `saws -docker-credential`.
+const DockerCredentialHelperName = "docker-credential-saws"
+
+// errCredentialsNotFound is the message docker expects when a helper has no
+// credentials for a registry; it then falls back to anonymous access.
+var errCredentialsNotFound = errors.New("credentials not found in native keychain")
+
+var ecrRegistryPattern = regexp.MustCompile(`^(\d{12})\.dkr\.ecr(?:-fips)?\.([a-z0-9-]+)\.amazonaws\.com(?:\.cn)?$`)
+
+// dockerCredentials is the JSON document exchanged by the credential-helper protocol.
+type dockerCredentials struct {
+	ServerURL string
+	Username  string
+	Secret    string
+}
+
+// parseEcrRegistry extracts the account ID and region from an ECR registry URL.
+func parseEcrRegistry(serverURL string) (accountID, region string, ok bool) {
+	host := strings.TrimSpace(serverURL)
+	host = strings.TrimPrefix(host, "https://")
+	host = strings.TrimPrefix(host, "http://")
+	host, _, _ = strings.Cut(host, "/")
+	m := ecrRegistryPattern.FindStringSubmatch(host)
+	if m == nil {
+		return "", "", false
+	}
+	return m[1], m[2], true
+}
+
+// HandleDockerCredential implements the docker credential-helper protocol for
+// ECR registries of accounts in the SAWS config. Exported.
+// `get` assumes roleName (falling back to SAWS_ROLE, then docker_credential_role)
+// in the registry's account and returns a fresh ECR token; `store` and `erase`
+// are accepted but ignored because tokens are minted on demand; `list` reports
+// nothing.
+func HandleDockerCredential(ctx context.Context, appCfg *pkg.AppConfig, action, roleName string, in io.Reader, out io.Writer) error {
+	switch action {
+	case "store", "erase":
+		_, _ = io.Copy(io.Discard, in)
+		pkg.LogVerbosef("Docker credential helper: ignoring '%s', ECR tokens are generated on demand.", action)
+		return nil
+	case "list":
+		_, err := fmt.Fprintln(out, "{}")
+		return err
+	case "get":
+	default:
+		return fmt.Errorf("unknown credential helper action '%s' (expected get, store, erase, or list)", action)
+	}
+
+	input, err := io.ReadAll(in)
+	if err != nil {
+		return fmt.Errorf("failed to read server URL from stdin: %w", err)
+	}
+	serverURL := strings.TrimSpace(string(input))
+	accountID, region, ok := parseEcrRegistry(serverURL)
+	if !ok {
+		pkg.LogVerbosef("Docker credential helper: '%s' is not an ECR registry.", serverURL)
+		return errCredentialsNotFound
+	}
+	accountName := ""
+	for name, id := range appCfg.Accounts {
+		if id == accountID {
+			accountName = name
+			break
+		}
+	}
+	if accountName == "" {
+		pkg.LogVerbosef("Docker credential helper: account %s is not in the SAWS config.", accountID)
+		return errCredentialsNotFound
+	}
+	if roleName == "" {
+		roleName = os.Getenv("SAWS_ROLE")
+	}
+	if roleName == "" {
+		roleName = appCfg.DockerCredentialRole
+	}
+	if roleName == "" {
+		return errors.New("no role configured for the docker credential helper (set docker_credential_role in the SAWS config or SAWS_ROLE)")
+	}
+	if actualRole, ok := appCfg.Roles[roleName]; ok {
+		roleName = actualRole
+	}
+
+	baseCfg, err := pkg.LoadAWSConfig(ctx, awsconfig.WithSharedConfigProfile(pkg.BaseProfileForAssume), awsconfig.WithRegion(pkg.FallbackRegion))
+	if err != nil {
+		return fmt.Errorf("error loading base AWS configuration (profile '%s'): %w", pkg.BaseProfileForAssume, err)
+	}
+	if baseCfg, err = pkg.BaseConfigForAccount(ctx, accountName, baseCfg); err != nil {
+		return err
+	}
+	creds, err := pkg.AssumeRole(ctx, baseCfg, accountID, roleName, "DockerCredential")
+	pkg.Audit(pkg.AuditResult(pkg.AuditRecord{Event: pkg.AuditAssumeRole, Account: accountName, AccountID: accountID, Role: roleName, Region: region}, err))
+	if err != nil {
+		return err
+	}
+	cfg, err := pkg.LoadAssumedRoleConfig(ctx, creds, region)
+	if err != nil {
+		return err
+	}
+	ecrCreds, err := fetchEcrCredentials(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	pkg.LogVerbosef("Docker credential helper: issued ECR token for %s (Account=%s(%s), Region=%s)", serverURL, accountName, accountID, region)
+
+	return json.NewEncoder(out).Encode(dockerCredentials{ServerURL: serverURL, Username: ecrCreds.Username, Secret: ecrCreds.Password})
+}
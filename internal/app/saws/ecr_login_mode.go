@@ -0,0 +1,94 @@
+package saws
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	"saws/internal/pkg"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecr"
+	ststypes "github.com/aws/aws-sdk-go-v2/service/sts/types"
+)
+
+// ecrCredentials is a decoded ECR authorization token.
+type ecrCredentials struct {
+	Registry  string
+	Username  string
+	Password  string
+	ExpiresAt time.Time
+}
+
+// fetchEcrCredentials returns docker credentials for the default registry of cfg's account and region.
+func fetchEcrCredentials(ctx context.Context, cfg aws.Config) (*ecrCredentials, error) {
+	out, err := ecr.NewFromConfig(cfg).GetAuthorizationToken(ctx, &ecr.GetAuthorizationTokenInput{})
+	if err != nil {
+		return nil, fmt.Errorf("ecr:GetAuthorizationToken failed: %w", err)
+	}
+	if len(out.AuthorizationData) == 0 {
+		return nil, errors.New("ecr:GetAuthorizationToken returned no authorization data")
+	}
+	authData := out.AuthorizationData[0]
+
+	decoded, err := base64.StdEncoding.DecodeString(aws.ToString(authData.AuthorizationToken))
+	if err != nil {
+		return nil, fmt.Errorf("could not decode ECR authorization token: %w", err)
+	}
+	username, password, ok := strings.Cut(string(decoded), ":")
+	if !ok {
+		return nil, errors.New("unexpected ECR authorization token format")
+	}
+	pkg.RegisterSecret(aws.ToString(authData.AuthorizationToken), password)
+	return &ecrCredentials{
+		Registry:  strings.TrimPrefix(aws.ToString(authData.ProxyEndpoint), "https://"),
+		Username:  username,
+		Password:  password,
+		ExpiresAt: aws.ToTime(authData.ExpiresAt),
+	}, nil
+}
+
+// NewEcrLoginTask returns the fan-out task for -ecr-login, which fetches an ECR
+// authorization token in each target and feeds it to `docker login`.
+func NewEcrLoginTask(dockerPath string) FanOutTask {
+	return func(ctx context.Context, target FanOutTarget, creds *ststypes.Credentials) FanOutResult {
+		cfg, err := pkg.LoadAssumedRoleConfig(ctx, creds, target.Region)
+		if err != nil {
+			return failedResult(err)
+		}
+
+		pkg.LogVerbosef("Fetching ECR authorization token for Account: %s, Region: %s", target.AccountName, target.Region)
+		ecrCreds, err := fetchEcrCredentials(ctx, cfg)
+		if err != nil {
+			return failedResult(err)
+		}
+
+		cmd := exec.CommandContext(ctx, dockerPath, "login", "--username", ecrCreds.Username, "--password-stdin", ecrCreds.Registry)
+		cmd.Stdin = strings.NewReader(ecrCreds.Password)
+		var outb, errb bytes.Buffer
+		cmd.Stdout = &outb
+		cmd.Stderr = &errb
+
+		result := FanOutResult{
+			Status: StatusSuccess,
+			Info:   fmt.Sprintf("Registry: %s, Token Expires: %s", ecrCreds.Registry, ecrCreds.ExpiresAt.Local().Format("15:04")),
+		}
+		if err := cmd.Run(); err != nil {
+			result.Status = StatusFailed
+			result.ExitCode = -1
+			if exitErr, ok := err.(*exec.ExitError); ok {
+				result.ExitCode = exitErr.ExitCode()
+			}
+		}
+		result.Sections = []FanOutSection{
+			{Label: "DOCKER STDOUT", Body: outb.String(), Output: true},
+			{Label: "DOCKER STDERR", Body: errb.String()},
+		}
+		return result
+	}
+}
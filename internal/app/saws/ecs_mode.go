@@ -21,7 +21,7 @@ import (
 
 // listEcsClusters fetches ECS cluster ARNs for the given context.
 func listEcsClusters(ctx context.Context, credsaws aws.Credentials, region string) ([]string, error) {
-	cfg, err := awsconfig.LoadDefaultConfig(ctx,
+	cfg, err := pkg.LoadAWSConfig(ctx,
 		awsconfig.WithCredentialsProvider(aws.CredentialsProviderFunc(func(ctx context.Context) (aws.Credentials, error) { return credsaws, nil })),
 		awsconfig.WithRegion(region),
 	)
@@ -51,7 +51,7 @@ func listEcsClusters(ctx context.Context, credsaws aws.Credentials, region strin
 
 // listEcsTasks fetches running task ARNs for a given cluster.
 func listEcsTasks(ctx context.Context, credsaws aws.Credentials, region, clusterArn string) ([]string, error) {
-	cfg, err := awsconfig.LoadDefaultConfig(ctx,
+	cfg, err := pkg.LoadAWSConfig(ctx,
 		awsconfig.WithCredentialsProvider(aws.CredentialsProviderFunc(func(ctx context.Context) (aws.Credentials, error) { return credsaws, nil })),
 		awsconfig.WithRegion(region),
 	)
@@ -88,7 +88,7 @@ func describeEcsTasks(ctx context.Context, credsaws aws.Credentials, region, clu
 	if len(taskArns) == 0 {
 		return []ecstypes.Task{}, nil
 	}
-	cfg, err := awsconfig.LoadDefaultConfig(ctx,
+	cfg, err := pkg.LoadAWSConfig(ctx,
 		awsconfig.WithCredentialsProvider(aws.CredentialsProviderFunc(func(ctx context.Context) (aws.Credentials, error) { return credsaws, nil })),
 		awsconfig.WithRegion(region),
 	)
@@ -129,12 +129,77 @@ func describeEcsTasks(ctx context.Context, credsaws aws.Credentials, region, clu
 	return describedTasks, nil
 }
 
+// selectEcsCluster prompts for one of the ECS clusters of the context's
+// account and region and returns its ARN, or "" when there are none.
+func selectEcsCluster(ctx context.Context, awsCreds aws.Credentials, sCtx *pkg.SelectedContext) (string, error) {
+	clusters, errList := listEcsClusters(ctx, awsCreds, sCtx.Region)
+	if errList != nil {
+		return "", fmt.Errorf("failed to list ECS clusters: %w", errList)
+	}
+	if len(clusters) == 0 {
+		fmt.Fprintf(os.Stderr, "No ECS clusters found in Account %s, Region %s.\n", sCtx.AccountID, sCtx.Region)
+		return "", nil
+	}
+
+	clusterNames := make([]string, len(clusters))
+	clusterArnToName := make(map[string]string)
+	for i, arn := range clusters {
+		parts := strings.Split(arn, "/")
+		name := parts[len(parts)-1]
+		clusterNames[i] = name
+		clusterArnToName[name] = arn
+	}
+	sort.Strings(clusterNames)
+
+	chosenClusterName := ""
+	prompt := &survey.Select{Message: "Choose ECS Cluster:", Options: clusterNames, PageSize: 15}
+	errSurvey := pkg.AskOne(prompt, &chosenClusterName, survey.WithValidator(survey.Required), pkg.WithFuzzyFilter)
+	if errSurvey != nil {
+		return "", fmt.Errorf("cluster selection failed: %w", errSurvey)
+	}
+	pkg.LogVerbosef("Selected cluster: %s", clusterArnToName[chosenClusterName]) // Use pkg.
+	return clusterArnToName[chosenClusterName], nil
+}
+
+// ecsHostOption is the entry of the container prompt that opens an SSM
+// session to the EC2 container instance running the task instead.
+const ecsHostOption = "Host (SSM session to the container instance)"
+
+// ecsContainerInstanceID returns the EC2 instance ID of the container instance
+// task runs on. Fargate tasks have none.
+func ecsContainerInstanceID(ctx context.Context, credsaws aws.Credentials, region, clusterArn string, task ecstypes.Task) (string, error) {
+	if task.ContainerInstanceArn == nil {
+		return "", fmt.Errorf("task %s runs on %s and has no container instance to connect to", aws.ToString(task.TaskArn), task.LaunchType)
+	}
+	cfg, err := pkg.LoadAWSConfig(ctx,
+		awsconfig.WithCredentialsProvider(aws.CredentialsProviderFunc(func(ctx context.Context) (aws.Credentials, error) { return credsaws, nil })),
+		awsconfig.WithRegion(region),
+	)
+	if err != nil {
+		return "", fmt.Errorf("failed to load SDK config for ECS describe container instances: %w", err)
+	}
+	out, err := ecs.NewFromConfig(cfg).DescribeContainerInstances(ctx, &ecs.DescribeContainerInstancesInput{
+		Cluster:            aws.String(clusterArn),
+		ContainerInstances: []string{*task.ContainerInstanceArn},
+	})
+	if err != nil {
+		return "", fmt.Errorf("ecs:DescribeContainerInstances failed for %s: %w", *task.ContainerInstanceArn, err)
+	}
+	if len(out.ContainerInstances) == 0 || out.ContainerInstances[0].Ec2InstanceId == nil {
+		return "", fmt.Errorf("container instance %s has no EC2 instance ID", *task.ContainerInstanceArn)
+	}
+	return *out.ContainerInstances[0].Ec2InstanceId, nil
+}
+
 // HandleEcsExecSession handles the logic for the -ecs mode. Exported.
+// With hostFlag, or when the host is picked in the container prompt, it opens
+// an SSM session to the EC2 container instance of the task instead.
 func HandleEcsExecSession(
 	ctx context.Context,
 	appCfg *pkg.AppConfig, // Use pkg.AppConfig
 	clusterFlag, taskFlag, containerFlag, commandFlag, // Flags specific to ECS mode
 	accountSelectorFlag, roleFlag, regionFlagFromCmd string, // Common context flags
+	hostFlag bool,
 ) error {
 
 	pkg.LogVerbosef("Preparing for ECS exec session...")                                                                                   // Use pkg.
@@ -156,33 +221,10 @@ func HandleEcsExecSession(
 
 	// --- Cluster Selection ---
 	if targetCluster == "" {
-		clusters, errList := listEcsClusters(ctx, awsCreds, sCtx.Region)
-		if errList != nil {
-			return fmt.Errorf("failed to list ECS clusters: %w", errList)
-		}
-		if len(clusters) == 0 {
-			fmt.Fprintf(os.Stderr, "No ECS clusters found in Account %s, Region %s.\n", sCtx.AccountID, sCtx.Region)
-			return nil
-		}
-
-		clusterNames := make([]string, len(clusters))
-		clusterArnToName := make(map[string]string)
-		for i, arn := range clusters {
-			parts := strings.Split(arn, "/")
-			name := parts[len(parts)-1]
-			clusterNames[i] = name
-			clusterArnToName[name] = arn
+		targetCluster, err = selectEcsCluster(ctx, awsCreds, sCtx)
+		if err != nil || targetCluster == "" {
+			return err
 		}
-		sort.Strings(clusterNames)
-
-		chosenClusterName := ""
-		prompt := &survey.Select{Message: "Choose ECS Cluster:", Options: clusterNames, PageSize: 15}
-		errSurvey := survey.AskOne(prompt, &chosenClusterName, survey.WithValidator(survey.Required))
-		if errSurvey != nil {
-			return fmt.Errorf("cluster selection failed: %w", errSurvey)
-		}
-		targetCluster = clusterArnToName[chosenClusterName]    // Use Name or ARN? API needs name/ARN. Let's use the name for now, assuming it's unique or the API handles it.
-		pkg.LogVerbosef("Selected cluster: %s", targetCluster) // Use pkg.
 	} else {
 		pkg.LogVerbosef("Using cluster '%s' provided via --cluster flag.", targetCluster) // Use pkg.
 	}
@@ -233,7 +275,7 @@ func HandleEcsExecSession(
 
 		chosenDisplayStr := ""
 		prompt := &survey.Select{Message: "Choose Running Task:", Options: taskOptions, PageSize: 15}
-		errSurvey := survey.AskOne(prompt, &chosenDisplayStr, survey.WithValidator(survey.Required))
+		errSurvey := pkg.AskOne(prompt, &chosenDisplayStr, survey.WithValidator(survey.Required), pkg.WithFuzzyFilter)
 		if errSurvey != nil {
 			return fmt.Errorf("task selection failed: %w", errSurvey)
 		}
@@ -243,6 +285,24 @@ func HandleEcsExecSession(
 		pkg.LogVerbosef("Using task '%s' provided via --task flag.", targetTask) // Use pkg.
 	}
 
+	// --- Host Session ---
+	connectToHost := func(task ecstypes.Task) error {
+		instanceID, err := ecsContainerInstanceID(ctx, awsCreds, sCtx.Region, targetCluster, task)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "Task %s runs on container instance %s.\n", targetTask, instanceID)
+		pkg.RecordRecentInstance(sCtx, instanceID)
+		return runSSMSession(sCtx, creds, instanceID)
+	}
+	if hostFlag {
+		describedTasks, errDesc := describeEcsTasks(ctx, awsCreds, sCtx.Region, targetCluster, []string{targetTask})
+		if errDesc != nil || len(describedTasks) == 0 {
+			return fmt.Errorf("failed to describe selected task %s to find its container instance: %w", targetTask, errDesc)
+		}
+		return connectToHost(describedTasks[0])
+	}
+
 	// --- Container Selection ---
 	if targetContainer == "" {
 		var selectedTaskDetails *ecstypes.Task
@@ -287,12 +347,18 @@ func HandleEcsExecSession(
 				targetContainer = strings.Split(containerNames[0], " ")[0]
 				pkg.LogVerbosef("Auto-selected the only running container in the task: %s", targetContainer) // Use pkg.
 			} else {
+				if selectedTaskDetails.ContainerInstanceArn != nil {
+					containerNames = append(containerNames, ecsHostOption)
+				}
 				chosenContainerDisplay := ""
 				prompt := &survey.Select{Message: "Choose Container:", Options: containerNames, PageSize: 10}
-				errSurvey := survey.AskOne(prompt, &chosenContainerDisplay, survey.WithValidator(survey.Required))
+				errSurvey := pkg.AskOne(prompt, &chosenContainerDisplay, survey.WithValidator(survey.Required), pkg.WithFuzzyFilter)
 				if errSurvey != nil {
 					return fmt.Errorf("container selection failed: %w", errSurvey)
 				}
+				if chosenContainerDisplay == ecsHostOption {
+					return connectToHost(*selectedTaskDetails)
+				}
 				targetContainer = strings.Split(chosenContainerDisplay, " ")[0]
 				pkg.LogVerbosef("Selected container: %s", targetContainer) // Use pkg.
 			}
@@ -314,18 +380,8 @@ func HandleEcsExecSession(
 	}
 	pkg.LogVerbosef("Using AWS CLI at: %s", awsCLIPath)              // Use pkg.
 	pkg.LogVerbosef("Preparing environment for ECS exec command...") // Use pkg.
-	currentEnv := os.Environ()
-	newEnv := []string{}
-	for _, e := range currentEnv {
-		if !strings.HasPrefix(e, "AWS_ACCESS_KEY_ID=") && !strings.HasPrefix(e, "AWS_SECRET_ACCESS_KEY=") && !strings.HasPrefix(e, "AWS_SESSION_TOKEN=") && !strings.HasPrefix(e, "AWS_SECURITY_TOKEN=") && !strings.HasPrefix(e, "AWS_REGION=") && !strings.HasPrefix(e, "AWS_DEFAULT_REGION=") && !strings.HasPrefix(e, "AWS_PROFILE=") {
-			newEnv = append(newEnv, e)
-		}
-	}
-	newEnv = append(newEnv, fmt.Sprintf("AWS_ACCESS_KEY_ID=%s", *creds.AccessKeyId))
-	newEnv = append(newEnv, fmt.Sprintf("AWS_SECRET_ACCESS_KEY=%s", *creds.SecretAccessKey))
-	newEnv = append(newEnv, fmt.Sprintf("AWS_SESSION_TOKEN=%s", *creds.SessionToken))
-	newEnv = append(newEnv, fmt.Sprintf("AWS_REGION=%s", sCtx.Region))
-	newEnv = append(newEnv, fmt.Sprintf("AWS_DEFAULT_REGION=%s", sCtx.Region))
+	ensureSessionManagerPlugin()
+	newEnv := assumedRoleEnv(creds, sCtx.Region)
 
 	fmt.Fprintf(os.Stderr, "Starting ECS exec session...\n")
 	fmt.Fprintf(os.Stderr, "  Cluster: %s\n", targetCluster)
@@ -0,0 +1,182 @@
+package saws
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+
+	"saws/internal/pkg"
+
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	ecstypes "github.com/aws/aws-sdk-go-v2/service/ecs/types"
+)
+
+// prefixWriter writes each complete line it receives to out behind prefix.
+// Writers sharing mu never interleave within a line.
+type prefixWriter struct {
+	mu     *sync.Mutex
+	out    io.Writer
+	prefix string
+	buf    []byte
+}
+
+func (w *prefixWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			return len(p), nil
+		}
+		w.mu.Lock()
+		fmt.Fprintf(w.out, "%s%s\n", w.prefix, bytes.TrimRight(w.buf[:i], "\r"))
+		w.mu.Unlock()
+		w.buf = w.buf[i+1:]
+	}
+}
+
+// Flush writes a last line that did not end in a newline.
+func (w *prefixWriter) Flush() {
+	if len(w.buf) > 0 {
+		w.Write([]byte("\n"))
+	}
+}
+
+// selectEcsService prompts for one of the services of clusterArn and returns its name.
+func selectEcsService(ctx context.Context, client *ecs.Client, clusterArn string) (string, error) {
+	var names []string
+	paginator := ecs.NewListServicesPaginator(client, &ecs.ListServicesInput{Cluster: aws.String(clusterArn)})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return "", fmt.Errorf("failed to list ECS services of cluster %s: %w", clusterArn, err)
+		}
+		for _, arn := range page.ServiceArns {
+			names = append(names, arn[strings.LastIndex(arn, "/")+1:])
+		}
+	}
+	if len(names) == 0 {
+		return "", fmt.Errorf("no ECS services found in cluster %s", clusterArn)
+	}
+	sort.Strings(names)
+
+	chosen := ""
+	prompt := &survey.Select{Message: "Choose ECS Service:", Options: names, PageSize: 15}
+	if err := pkg.AskOne(prompt, &chosen, survey.WithValidator(survey.Required), pkg.WithFuzzyFilter); err != nil {
+		return "", fmt.Errorf("service selection failed: %w", err)
+	}
+	return chosen, nil
+}
+
+// HandleEcsServiceExec handles the logic for the `ecs-service-exec` mode. Exported.
+// It runs command with ECS Exec in every running task of a service at once,
+// in containerFlag or each task's first container, and prints their output
+// with a "[task/container]" prefix on every line. It fails when any task does.
+func HandleEcsServiceExec(
+	ctx context.Context,
+	clusterFlag, serviceFlag, containerFlag, command string,
+	accountSelectorFlag, roleFlag, regionFlagFromCmd string,
+) error {
+	sCtx, creds, err := pkg.EstablishAWSContextAndAssumeRole(ctx, accountSelectorFlag, roleFlag, regionFlagFromCmd, "ECSServiceExec")
+	if err != nil {
+		return fmt.Errorf("could not establish AWS context for ECS service exec: %w", err)
+	}
+	awsCreds := aws.Credentials{AccessKeyID: *creds.AccessKeyId, SecretAccessKey: *creds.SecretAccessKey, SessionToken: *creds.SessionToken, Source: "SawsAssumedRoleForECS"}
+	cfg, err := pkg.LoadAWSConfig(ctx,
+		awsconfig.WithCredentialsProvider(aws.CredentialsProviderFunc(func(ctx context.Context) (aws.Credentials, error) { return awsCreds, nil })),
+		awsconfig.WithRegion(sCtx.Region),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to load SDK config for ECS: %w", err)
+	}
+	client := ecs.NewFromConfig(cfg)
+
+	cluster := clusterFlag
+	if cluster == "" {
+		if cluster, err = selectEcsCluster(ctx, awsCreds, sCtx); err != nil || cluster == "" {
+			return err
+		}
+	}
+	service := serviceFlag
+	if service == "" {
+		if service, err = selectEcsService(ctx, client, cluster); err != nil {
+			return err
+		}
+	}
+
+	var taskArns []string
+	paginator := ecs.NewListTasksPaginator(client, &ecs.ListTasksInput{Cluster: aws.String(cluster), ServiceName: aws.String(service), DesiredStatus: ecstypes.DesiredStatusRunning})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to list tasks of service %s: %w", service, err)
+		}
+		taskArns = append(taskArns, page.TaskArns...)
+	}
+	if len(taskArns) == 0 {
+		return fmt.Errorf("service %s has no running tasks", service)
+	}
+	tasks, err := describeEcsTasks(ctx, awsCreds, sCtx.Region, cluster, taskArns)
+	if err != nil {
+		return err
+	}
+
+	awsCLIPath, err := exec.LookPath("aws")
+	if err != nil {
+		return errors.New("AWS CLI ('aws') not found in PATH; required for ECS Exec")
+	}
+	ensureSessionManagerPlugin()
+	env := assumedRoleEnv(creds, sCtx.Region)
+
+	fmt.Fprintf(os.Stderr, "Running '%s' in %d task(s) of service %s (Account: %s, Region: %s)...\n", command, len(tasks), service, sCtx.AccountName, sCtx.Region)
+	var outMu sync.Mutex
+	var wg sync.WaitGroup
+	var failedMu sync.Mutex
+	var failed []string
+	for _, task := range tasks {
+		taskArn := aws.ToString(task.TaskArn)
+		taskID := taskArn[strings.LastIndex(taskArn, "/")+1:]
+		container := containerFlag
+		if container == "" && len(task.Containers) > 0 {
+			container = aws.ToString(task.Containers[0].Name)
+		}
+		wg.Add(1)
+		go func(taskID, container string) {
+			defer wg.Done()
+			prefix := fmt.Sprintf("[%s/%s] ", taskID, container)
+			stdout := &prefixWriter{mu: &outMu, out: os.Stdout, prefix: prefix}
+			stderr := &prefixWriter{mu: &outMu, out: os.Stderr, prefix: prefix}
+			cmd := exec.CommandContext(ctx, awsCLIPath, "ecs", "execute-command", "--cluster", cluster, "--task", taskArn, "--container", container, "--command", command, "--interactive", "--region", sCtx.Region)
+			cmd.Env = env
+			cmd.Stdout, cmd.Stderr = stdout, stderr
+			errRun := cmd.Run()
+			stdout.Flush()
+			stderr.Flush()
+			if errRun != nil {
+				outMu.Lock()
+				fmt.Fprintf(os.Stderr, "%s%s\n", prefix, pkg.Colorize(os.Stderr, pkg.ColorRed, "failed: "+errRun.Error()))
+				outMu.Unlock()
+				failedMu.Lock()
+				failed = append(failed, taskID)
+				failedMu.Unlock()
+			}
+		}(taskID, container)
+	}
+	wg.Wait()
+
+	if len(failed) > 0 {
+		sort.Strings(failed)
+		return fmt.Errorf("%d out of %d task(s) failed: %s", len(failed), len(tasks), strings.Join(failed, ", "))
+	}
+	pkg.LogVerbosef("Command finished in all %d task(s) of service %s.", len(tasks), service)
+	return nil
+}
@@ -0,0 +1,97 @@
+package saws
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"saws/internal/pkg"
+
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+)
+
+const (
+	eksTokenPrefix        = "k8s-aws-v1."
+	eksClusterIDHeader    = "x-k8s-aws-id"
+	eksTokenPresignExpiry = "60"
+	// EKS accepts a presigned token for 15 minutes; report a slightly shorter
+	// lifetime so kubectl asks for a new one before the API server rejects it.
+	eksTokenLifetime         = 14 * time.Minute
+	execCredentialAPIVersion = "client.authentication.k8s.io/v1beta1"
+)
+
+type execCredentialStatus struct {
+	ExpirationTimestamp string `json:"expirationTimestamp"`
+	Token               string `json:"token"`
+}
+
+type execCredential struct {
+	Kind       string               `json:"kind"`
+	APIVersion string               `json:"apiVersion"`
+	Spec       struct{}             `json:"spec"`
+	Status     execCredentialStatus `json:"status"`
+}
+
+// GenerateEksToken presigns an sts:GetCallerIdentity request bound to the given
+// cluster name, which is the bearer token format the EKS API server accepts.
+func GenerateEksToken(ctx context.Context, stsClient *sts.Client, clusterName string) (string, time.Time, error) {
+	presignClient := sts.NewPresignClient(stsClient)
+	presigned, err := presignClient.PresignGetCallerIdentity(ctx, &sts.GetCallerIdentityInput{}, func(po *sts.PresignOptions) {
+		po.ClientOptions = append(po.ClientOptions, func(o *sts.Options) {
+			o.APIOptions = append(o.APIOptions,
+				smithyhttp.SetHeaderValue(eksClusterIDHeader, clusterName),
+				smithyhttp.SetHeaderValue("X-Amz-Expires", eksTokenPresignExpiry),
+			)
+		})
+	})
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to presign sts:GetCallerIdentity for cluster %s: %w", clusterName, err)
+	}
+	token := eksTokenPrefix + base64.RawURLEncoding.EncodeToString([]byte(presigned.URL))
+	pkg.RegisterSecret(token)
+	return token, time.Now().Add(eksTokenLifetime), nil
+}
+
+// HandleEksToken handles the logic for the -eks-token mode. It writes a
+// client.authentication.k8s.io ExecCredential to stdout so kubeconfigs can
+// call saws directly as their exec credential plugin.
+func HandleEksToken(ctx context.Context, clusterName, accountSelectorFlag, roleFlag, regionFlagFromCmd string) error {
+	if clusterName == "" {
+		return errors.New("EKS cluster name is required (--eks-cluster)")
+	}
+
+	pkg.LogVerbosef("Preparing EKS token for cluster %s...", clusterName)
+	sCtx, creds, err := pkg.EstablishAWSContextAndAssumeRole(ctx, accountSelectorFlag, roleFlag, regionFlagFromCmd, "EKSToken")
+	if err != nil {
+		return fmt.Errorf("could not establish AWS context for EKS token: %w", err)
+	}
+
+	cfg, err := pkg.LoadAssumedRoleConfig(ctx, creds, sCtx.Region)
+	if err != nil {
+		return err
+	}
+	token, expiresAt, err := GenerateEksToken(ctx, sts.NewFromConfig(cfg), clusterName)
+	if err != nil {
+		return err
+	}
+	pkg.LogVerbosef("Generated EKS token for cluster %s in Account=%s(%s), Role=%s, Region=%s", clusterName, sCtx.AccountName, sCtx.AccountID, sCtx.RoleName, sCtx.Region)
+
+	cred := execCredential{
+		Kind:       "ExecCredential",
+		APIVersion: execCredentialAPIVersion,
+		Status: execCredentialStatus{
+			ExpirationTimestamp: expiresAt.UTC().Format(time.RFC3339),
+			Token:               token,
+		},
+	}
+	encoder := json.NewEncoder(os.Stdout)
+	if err := encoder.Encode(cred); err != nil {
+		return fmt.Errorf("failed to write ExecCredential: %w", err)
+	}
+	return nil
+}
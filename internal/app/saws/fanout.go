@@ -0,0 +1,414 @@
+package saws
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"text/tabwriter"
+	"time"
+
+	"saws/internal/pkg"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	ststypes "github.com/aws/aws-sdk-go-v2/service/sts/types"
+)
+
+const (
+	StatusSuccess = "SUCCESS"
+	StatusFailed  = "FAILED"
+)
+
+// FanOutTarget is a single account/region pair of a fan-out run.
+type FanOutTarget struct {
+	AccountName string
+	AccountID   string
+	Region      string
+}
+
+// FanOutSection is a labeled block of output printed under a result banner.
+type FanOutSection struct {
+	Label string
+	Body  string
+	// Output marks the target's own output (a command's stdout, a Lambda
+	// payload), the only part -q prints to stdout.
+	Output bool
+	// File holds the full body when it was too large to keep in memory; Body
+	// is then its beginning and a pointer to the file, which is not redacted.
+	File string
+}
+
+// FanOutResult is what a task reports for one target.
+type FanOutResult struct {
+	Status   string
+	ExitCode int
+	// Info holds extra banner fields such as "Status Code: 200".
+	Info     string
+	Sections []FanOutSection
+	Duration time.Duration
+	// Started, Finished, AssumeRoleDuration, and TaskDuration are filled in by
+	// the fan-out engine for the run's metrics, traces, and -timings; Duration
+	// may cover only the task's own work. AssumeRoleDuration is zero when the
+	// account's credentials were reused, TaskDuration when the task never ran.
+	Started, Finished  time.Time
+	AssumeRoleDuration time.Duration
+	TaskDuration       time.Duration
+}
+
+// FanOutTask runs the mode-specific work for one target under the assumed role.
+type FanOutTask func(ctx context.Context, target FanOutTarget, creds *ststypes.Credentials) FanOutResult
+
+// FanOutOptions configures a fan-out run.
+type FanOutOptions struct {
+	// Label prefixes summary lines, e.g. "Cmd Mode".
+	Label        string
+	RoleToAssume string
+	SessionName  string
+}
+
+// FanOutSummary counts the outcomes of a fan-out run.
+type FanOutSummary struct {
+	Total     int
+	Succeeded int64
+	Started   time.Time
+	Duration  time.Duration
+	// Outcomes holds the result of every target that ran, in completion order.
+	Outcomes []FanOutOutcome
+}
+
+// FanOutOutcome is the result of one target of a finished run.
+type FanOutOutcome struct {
+	Target FanOutTarget
+	Result FanOutResult
+}
+
+// Failed returns the number of targets that did not succeed.
+func (s FanOutSummary) Failed() int64 {
+	return int64(s.Total) - s.Succeeded
+}
+
+// failedResult builds a result for errors raised before or instead of the task's own output.
+func failedResult(err error) FanOutResult {
+	return FanOutResult{Status: StatusFailed, ExitCode: -1, Sections: []FanOutSection{{Label: "ERROR", Body: pkg.RedactSecrets(err.Error())}}}
+}
+
+// RunFanOut assumes the role in every account/region pair concurrently, runs
+// task for each, and prints a result block per target as it completes.
+func RunFanOut(ctx context.Context, baseCfg aws.Config, appCfg *pkg.AppConfig, accountNames, regions []string, opts FanOutOptions, task FanOutTask) FanOutSummary {
+	var printMu sync.Mutex
+	return runFanOutTargets(ctx, baseCfg, appCfg, accountNames, regions, opts, task, func(target FanOutTarget, result FanOutResult) {
+		printMu.Lock()
+		printFanOutResult(target, result)
+		printMu.Unlock()
+	})
+}
+
+// FanOutFailure records a target whose collection failed.
+type FanOutFailure struct {
+	Target FanOutTarget
+	Err    string
+}
+
+// CollectFanOut is the table-oriented sibling of RunFanOut: instead of printing
+// a block per target it gathers the rows returned by collect from every target
+// so the caller can render them together. Failed targets are returned separately.
+func CollectFanOut[T any](
+	ctx context.Context, baseCfg aws.Config, appCfg *pkg.AppConfig, accountNames, regions []string, opts FanOutOptions,
+	collect func(ctx context.Context, target FanOutTarget, creds *ststypes.Credentials) ([]T, error),
+) ([]T, []FanOutFailure, FanOutSummary) {
+	var mu sync.Mutex
+	var rows []T
+	var failures []FanOutFailure
+	task := func(ctx context.Context, target FanOutTarget, creds *ststypes.Credentials) FanOutResult {
+		targetRows, err := collect(ctx, target, creds)
+		if err != nil {
+			return failedResult(err)
+		}
+		mu.Lock()
+		rows = append(rows, targetRows...)
+		mu.Unlock()
+		return FanOutResult{Status: StatusSuccess}
+	}
+	summary := runFanOutTargets(ctx, baseCfg, appCfg, accountNames, regions, opts, task, func(target FanOutTarget, result FanOutResult) {
+		if result.Status == StatusSuccess {
+			return
+		}
+		errTexts := make([]string, 0, len(result.Sections))
+		for _, section := range result.Sections {
+			errTexts = append(errTexts, section.Body)
+		}
+		mu.Lock()
+		failures = append(failures, FanOutFailure{Target: target, Err: strings.Join(errTexts, "\n")})
+		mu.Unlock()
+	})
+	sort.Slice(failures, func(i, j int) bool {
+		if failures[i].Target.AccountName != failures[j].Target.AccountName {
+			return failures[i].Target.AccountName < failures[j].Target.AccountName
+		}
+		return failures[i].Target.Region < failures[j].Target.Region
+	})
+	return rows, failures, summary
+}
+
+// fanOutTargets pairs every account with every region, leaving out accounts
+// whose roles_by_account excludes the role and regions outside an account's
+// partition.
+func fanOutTargets(appCfg *pkg.AppConfig, accountNames, regions []string, opts FanOutOptions) []FanOutTarget {
+	var targets []FanOutTarget
+	for _, accountName := range accountNames {
+		if !pkg.RoleAllowedInAccount(accountName, opts.RoleToAssume) {
+			fmt.Fprintf(os.Stderr, "%s: Skipping %s: role '%s' is not listed for it under roles_by_account.\n", opts.Label, accountName, opts.RoleToAssume)
+			continue
+		}
+		partition := pkg.AccountPartition(accountName)
+		for _, region := range regions {
+			if pkg.PartitionForRegion(region) != partition {
+				pkg.LogVerbosef("%s: Skipping %s in %s: the region is not in partition %s.", opts.Label, accountName, region, partition)
+				continue
+			}
+			targets = append(targets, FanOutTarget{AccountName: accountName, AccountID: appCfg.Accounts[accountName], Region: region})
+		}
+	}
+	return targets
+}
+
+// runFanOutTargets drives task over every account/region pair and hands each
+// result to onResult from the worker goroutine. Pairs whose region lies outside
+// the account's partition are skipped.
+func runFanOutTargets(ctx context.Context, baseCfg aws.Config, appCfg *pkg.AppConfig, accountNames, regions []string, opts FanOutOptions, task FanOutTask, onResult func(FanOutTarget, FanOutResult)) FanOutSummary {
+	state, targets := planRun(opts, fanOutTargets(appCfg, accountNames, regions, opts))
+	summary := FanOutSummary{Total: len(targets)}
+	pkg.LogVerbosef("%s: Planning %d executions (%d accounts x %d regions).", opts.Label, summary.Total, len(accountNames), len(regions))
+
+	var wg sync.WaitGroup
+	var outcomesMu sync.Mutex
+	var succeeded atomic.Int64
+	var slots chan struct{}
+	var adaptive *adaptiveLimiter
+	switch {
+	case pkg.AdaptiveParallelism && len(targets) > 0:
+		ceiling := len(targets)
+		if pkg.Parallelism > 0 {
+			ceiling = min(ceiling, pkg.Parallelism)
+		}
+		adaptive = newAdaptiveLimiter(opts.Label, ceiling)
+		pkg.LogVerbosef("%s: Adapting concurrency to throttling, from %d up to %d targets at a time.", opts.Label, adaptive.limit, ceiling)
+	case pkg.Parallelism > 0:
+		slots = make(chan struct{}, pkg.Parallelism)
+		pkg.LogVerbosef("%s: Running at most %d targets at a time.", opts.Label, pkg.Parallelism)
+	}
+	startTime := time.Now()
+	runCreds := newRunCredentials()
+
+	for _, target := range targets {
+		wg.Add(1)
+		go func(target FanOutTarget) {
+			defer wg.Done()
+			if slots != nil {
+				slots <- struct{}{}
+				defer func() { <-slots }()
+			}
+			var started time.Time
+			if adaptive != nil {
+				started = adaptive.acquire()
+			}
+			result := runFanOutTarget(ctx, baseCfg, runCreds, target, opts, task)
+			if adaptive != nil {
+				adaptive.release(started, isThrottled(result))
+			}
+			auditFanOutTarget(target, opts, result)
+			state.record(target, result)
+			if result.Status == StatusSuccess {
+				succeeded.Add(1)
+			}
+			outcomesMu.Lock()
+			summary.Outcomes = append(summary.Outcomes, FanOutOutcome{Target: target, Result: result})
+			outcomesMu.Unlock()
+			onResult(target, result)
+		}(target)
+	}
+	wg.Wait()
+
+	summary.Started, summary.Duration = startTime, time.Since(startTime)
+	summary.Succeeded = succeeded.Load()
+	recordRun(opts, summary)
+	state.announceResume(opts.Label)
+	pkg.LogVerbosef("%s: Finished %d executions in %s.", opts.Label, summary.Total, summary.Duration.Round(time.Second))
+	return summary
+}
+
+// auditFanOutTarget appends the outcome of one target to the audit log.
+func auditFanOutTarget(target FanOutTarget, opts FanOutOptions, result FanOutResult) {
+	rec := pkg.AuditRecord{Event: pkg.AuditTarget, Account: target.AccountName, AccountID: target.AccountID, Role: opts.RoleToAssume, Region: target.Region, Result: "ok", ExitCode: &result.ExitCode}
+	if result.Status != StatusSuccess {
+		rec.Result = "failed"
+		for _, section := range result.Sections {
+			if section.Label == "ERROR" {
+				rec.Error = section.Body
+			}
+		}
+	}
+	pkg.Audit(rec)
+}
+
+// runFanOutTarget runs task for one target with credentials from runCreds.
+func runFanOutTarget(ctx context.Context, baseCfg aws.Config, runCreds *runCredentials, target FanOutTarget, opts FanOutOptions, task FanOutTask) FanOutResult {
+	startTime := time.Now()
+	if target.AccountID == "" {
+		result := failedResult(fmt.Errorf("account ID not found for SAWS config account name '%s'", target.AccountName))
+		result.Started, result.Finished = startTime, time.Now()
+		result.Duration = result.Finished.Sub(startTime)
+		return result
+	}
+
+	creds, assumeDuration, err := runCreds.get(ctx, baseCfg, target, opts)
+	if err != nil {
+		result := failedResult(err)
+		result.Started, result.Finished, result.AssumeRoleDuration = startTime, time.Now(), assumeDuration
+		result.Duration = result.Finished.Sub(startTime)
+		return result
+	}
+
+	taskStart := time.Now()
+	result := task(ctx, target, creds)
+	result.Started, result.Finished, result.AssumeRoleDuration = startTime, time.Now(), assumeDuration
+	result.TaskDuration = result.Finished.Sub(taskStart)
+	runCreds.observe(result.TaskDuration)
+	if result.Status != StatusSuccess {
+		evictRefusedCredentials(creds, result)
+		result.Sections = append(result.Sections, decodeAuthorizationFailures(ctx, creds, target, result)...)
+	}
+	// Commands may echo their environment, which holds the credentials.
+	result.Info = pkg.RedactSecrets(result.Info)
+	for i := range result.Sections {
+		result.Sections[i].Body = pkg.RedactSecrets(result.Sections[i].Body)
+	}
+	if result.Duration == 0 {
+		result.Duration = time.Since(startTime)
+	}
+	return result
+}
+
+// statusColor picks the color of a result word: green for success, red for
+// failure, and yellow for warnings and work in progress. CloudFormation stack
+// statuses and finding severities are covered too.
+func statusColor(status string) pkg.Color {
+	switch {
+	case status == StatusSuccess || status == "PASS" || status == "IN_SYNC" || strings.HasSuffix(status, "_COMPLETE") && !strings.Contains(status, "ROLLBACK"):
+		return pkg.ColorGreen
+	case status == StatusFailed || status == pkg.IssueError || status == "CRITICAL" || status == "DRIFTED" || strings.Contains(status, "FAILED") || strings.Contains(status, "ROLLBACK"):
+		return pkg.ColorRed
+	case status == pkg.IssueWarning || status == "HIGH" || status == "SKIPPED" || strings.HasSuffix(status, "_IN_PROGRESS"):
+		return pkg.ColorYellow
+	}
+	return pkg.ColorPlain
+}
+
+// colorStatus colors status for stdout.
+func colorStatus(status string) string {
+	return pkg.Colorize(os.Stdout, statusColor(status), status)
+}
+
+func printFanOutResult(target FanOutTarget, result FanOutResult) {
+	if pkg.QuietMode {
+		printQuietFanOutResult(target, result)
+		return
+	}
+	banner := fmt.Sprintf("Account: %s, Region: %s, Status: %s, Exit Code: %d, Duration: %s",
+		target.AccountName, target.Region, colorStatus(result.Status), result.ExitCode, result.Duration.Round(time.Millisecond))
+	if result.Info != "" {
+		banner += ", " + result.Info
+	}
+	fmt.Printf("--- Result (%s) ---\n", banner)
+	for _, section := range result.Sections {
+		body := strings.TrimSpace(section.Body)
+		if body == "" {
+			continue
+		}
+		fmt.Printf("[%s]\n", section.Label)
+		fmt.Println(body)
+	}
+	fmt.Println("--- End Result ---")
+}
+
+// printQuietFanOutResult prints a result for -q: output sections go to stdout
+// as they are, everything else, and a status line for failed targets, to stderr.
+func printQuietFanOutResult(target FanOutTarget, result FanOutResult) {
+	if result.Status != StatusSuccess {
+		fmt.Fprintf(os.Stderr, "Account: %s, Region: %s, Status: %s, Exit Code: %d\n",
+			target.AccountName, target.Region, pkg.Colorize(os.Stderr, statusColor(result.Status), result.Status), result.ExitCode)
+	}
+	for _, section := range result.Sections {
+		if section.Body == "" {
+			continue
+		}
+		out := os.Stderr
+		if section.Output {
+			out = os.Stdout
+		}
+		if section.File != "" {
+			if err := copySpilledOutput(out, section); err == nil {
+				continue
+			}
+		}
+		fmt.Fprint(out, section.Body)
+		if !strings.HasSuffix(section.Body, "\n") {
+			fmt.Fprintln(out)
+		}
+	}
+}
+
+// RunSequential runs task for one target at a time, in the order of
+// accountNames and then regions, with the terminal attached so tools can show
+// progress and ask for confirmation. It stops at the first failed target, as a
+// deploy that failed in one account should not go on to the next, and ends
+// with a report of every target; the ones not run count as failed.
+func RunSequential(ctx context.Context, baseCfg aws.Config, appCfg *pkg.AppConfig, accountNames, regions []string, opts FanOutOptions, task FanOutTask) FanOutSummary {
+	state, targets := planRun(opts, fanOutTargets(appCfg, accountNames, regions, opts))
+	summary := FanOutSummary{Total: len(targets)}
+	results := make([]FanOutResult, 0, len(targets))
+	startTime := time.Now()
+	runCreds := newRunCredentials()
+
+	for i, target := range targets {
+		fmt.Fprintf(os.Stderr, "=== [%d/%d] %s (%s) in %s ===\n", i+1, len(targets), target.AccountName, target.AccountID, target.Region)
+		result := runFanOutTarget(ctx, baseCfg, runCreds, target, opts, task)
+		auditFanOutTarget(target, opts, result)
+		state.record(target, result)
+		results = append(results, result)
+		summary.Outcomes = append(summary.Outcomes, FanOutOutcome{Target: target, Result: result})
+		if result.Status == StatusSuccess {
+			summary.Succeeded++
+			continue
+		}
+		for _, section := range result.Sections {
+			fmt.Fprintf(os.Stderr, "[%s] %s\n", section.Label, strings.TrimSpace(section.Body))
+		}
+		if remaining := len(targets) - i - 1; remaining > 0 {
+			fmt.Fprintf(os.Stderr, "%s: Stopping; %d remaining target(s) not run.\n", opts.Label, remaining)
+		}
+		break
+	}
+	summary.Started, summary.Duration = startTime, time.Since(startTime)
+	recordRun(opts, summary)
+	if len(targets) == 0 {
+		fmt.Fprintf(os.Stderr, "%s: No targets to run.\n", opts.Label)
+		return summary
+	}
+
+	fmt.Fprintf(os.Stderr, "\n%s report:\n", opts.Label)
+	w := tabwriter.NewWriter(os.Stderr, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ACCOUNT\tACCOUNT ID\tREGION\tDURATION\tSTATUS")
+	for i, target := range targets {
+		status, duration := "SKIPPED", "-"
+		if i < len(results) {
+			status, duration = results[i].Status, results[i].Duration.Round(time.Second).String()
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", target.AccountName, target.AccountID, target.Region, duration, pkg.Colorize(os.Stderr, statusColor(status), status))
+	}
+	w.Flush()
+	return summary
+}
@@ -0,0 +1,90 @@
+package saws
+
+import (
+	"regexp"
+	"sync"
+	"time"
+
+	"saws/internal/pkg"
+)
+
+// adaptiveInitialLimit is how many targets an adaptive run starts with.
+const adaptiveInitialLimit = 8
+
+// throttlingPattern matches the throttling errors of STS, the AWS APIs, and
+// the AWS CLI in a target's error output.
+var throttlingPattern = regexp.MustCompile(`(?i)throttl|rate exceeded|too many requests|requestlimitexceeded|slow ?down`)
+
+// isThrottled reports whether a target failed on throttling. Only error
+// sections are searched; a command's own output may mention anything.
+func isThrottled(result FanOutResult) bool {
+	if result.Status == StatusSuccess {
+		return false
+	}
+	for _, section := range result.Sections {
+		if !section.Output && throttlingPattern.MatchString(section.Body) {
+			return true
+		}
+	}
+	return false
+}
+
+// adaptiveLimiter bounds the running targets of a fan-out with a limit that
+// grows while targets finish without throttling and halves when one is
+// throttled. The limit grows by one per finished target until the first
+// back-off, and by one per limit's worth of finished targets after it.
+type adaptiveLimiter struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	label   string
+	limit   int
+	ceiling int
+	running int
+	// finished counts targets finished since the limit last grew after a back-off.
+	finished int
+	// backedOffAt is the last back-off; throttling of targets started before
+	// it is already accounted for.
+	backedOffAt time.Time
+}
+
+// newAdaptiveLimiter returns a limiter that never runs more than ceiling targets.
+func newAdaptiveLimiter(label string, ceiling int) *adaptiveLimiter {
+	l := &adaptiveLimiter{label: label, limit: min(adaptiveInitialLimit, ceiling), ceiling: ceiling}
+	l.cond = sync.NewCond(&l.mu)
+	return l
+}
+
+// acquire waits for a free slot and returns when the target started.
+func (l *adaptiveLimiter) acquire() time.Time {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for l.running >= l.limit {
+		l.cond.Wait()
+	}
+	l.running++
+	return time.Now()
+}
+
+// release frees the slot of a target started at started and adjusts the limit.
+func (l *adaptiveLimiter) release(started time.Time, throttled bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.running--
+	switch {
+	case throttled && started.After(l.backedOffAt):
+		l.limit = max(1, l.limit/2)
+		l.backedOffAt, l.finished = time.Now(), 0
+		pkg.LogVerbosef("%s: Throttled; running at most %d targets at a time.", l.label, l.limit)
+	case throttled:
+	case l.backedOffAt.IsZero():
+		l.limit = min(l.ceiling, l.limit+1)
+	default:
+		l.finished++
+		if l.finished >= l.limit && l.limit < l.ceiling {
+			l.limit++
+			l.finished = 0
+			pkg.LogVerbosef("%s: Running at most %d targets at a time.", l.label, l.limit)
+		}
+	}
+	l.cond.Broadcast()
+}
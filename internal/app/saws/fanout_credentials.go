@@ -0,0 +1,120 @@
+package saws
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"sync"
+	"time"
+
+	"saws/internal/pkg"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	ststypes "github.com/aws/aws-sdk-go-v2/service/sts/types"
+)
+
+// minCredentialMargin is the least lifetime credentials must have left when a
+// target is launched with them.
+const minCredentialMargin = 5 * time.Minute
+
+// runCredentials holds the assumed-role credentials of a fan-out run per
+// account, shared by the account's regions. Before handing credentials to a
+// target it checks they outlive the longest target seen so far in the run, and
+// assumes the role again when they would not, so runs longer than the session
+// duration do not fail late targets with ExpiredToken.
+type runCredentials struct {
+	mu       sync.Mutex
+	accounts map[string]*accountCredentials
+	// longest is the longest duration of a finished target of the run.
+	longest          time.Duration
+	warnShortSession sync.Once
+}
+
+// accountCredentials is the current credentials of one account; its mutex
+// keeps the account's regions from assuming the role at the same time.
+type accountCredentials struct {
+	mu    sync.Mutex
+	creds *ststypes.Credentials
+}
+
+func newRunCredentials() *runCredentials {
+	return &runCredentials{accounts: make(map[string]*accountCredentials)}
+}
+
+// margin is the lifetime credentials must have left to launch a target.
+func (c *runCredentials) margin() time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return max(minCredentialMargin, c.longest)
+}
+
+// observe records how long a finished target took.
+func (c *runCredentials) observe(d time.Duration) {
+	c.mu.Lock()
+	c.longest = max(c.longest, d)
+	c.mu.Unlock()
+}
+
+// get returns credentials of opts.RoleToAssume in the target's account that
+// outlive margin, assuming the role when there are none yet or they would
+// expire too soon. The returned duration is that of the sts:AssumeRole call,
+// zero when the held credentials were reused.
+func (c *runCredentials) get(ctx context.Context, baseCfg aws.Config, target FanOutTarget, opts FanOutOptions) (*ststypes.Credentials, time.Duration, error) {
+	c.mu.Lock()
+	account := c.accounts[target.AccountName]
+	if account == nil {
+		account = &accountCredentials{}
+		c.accounts[target.AccountName] = account
+	}
+	c.mu.Unlock()
+
+	account.mu.Lock()
+	defer account.mu.Unlock()
+	margin := c.margin()
+	if account.creds != nil {
+		if account.creds.Expiration == nil {
+			return account.creds, 0, nil
+		}
+		left := time.Until(*account.creds.Expiration)
+		if left > margin {
+			return account.creds, 0, nil
+		}
+		pkg.LogVerbosef("%s: Refreshing credentials of %s; they expire in %s and targets take up to %s.", opts.Label, target.AccountName, left.Round(time.Second), margin.Round(time.Second))
+	}
+
+	accountBaseCfg, err := pkg.BaseConfigForAccount(ctx, target.AccountName, baseCfg)
+	if err != nil {
+		return nil, 0, err
+	}
+	assumeStart := time.Now()
+	creds, err := pkg.AssumeRoleLasting(ctx, accountBaseCfg, target.AccountID, opts.RoleToAssume, opts.SessionName, margin)
+	assumeDuration := time.Since(assumeStart)
+	if err != nil {
+		return nil, assumeDuration, fmt.Errorf("assume role failed for role %s: %w", opts.RoleToAssume, err)
+	}
+	if creds.Expiration != nil && time.Until(*creds.Expiration) <= margin {
+		c.warnShortSession.Do(func() {
+			fmt.Fprintf(os.Stderr, "%s: Warning: Targets take up to %s, but new credentials last only %s; raise defaults.session_duration (and the role's maximum session duration) if targets fail with ExpiredToken.\n",
+				opts.Label, margin.Round(time.Second), time.Until(*creds.Expiration).Round(time.Second))
+		})
+	}
+	account.creds = creds
+	return creds, assumeDuration, nil
+}
+
+// refusedCredentialsPattern matches the AWS CLI's report of a call refused for
+// its credentials, as pkg.RejectsCredentials does for saws's own calls.
+var refusedCredentialsPattern = regexp.MustCompile(`An error occurred \((ExpiredToken(Exception)?|InvalidClientTokenId|UnrecognizedClientException)\)`)
+
+// evictRefusedCredentials drops creds from the credential cache when a failed
+// command's output shows AWS refused them; calls of saws's own SDK clients
+// are covered by pkg.LoadAssumedRoleConfig.
+func evictRefusedCredentials(creds *ststypes.Credentials, result FanOutResult) {
+	for _, section := range result.Sections {
+		if refusedCredentialsPattern.MatchString(section.Body) {
+			pkg.EvictCachedCredentials(creds)
+			return
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package saws
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"saws/internal/pkg"
+)
+
+// maxTimingAccounts is how many accounts the slowest and most failing lists show.
+const maxTimingAccounts = 5
+
+// accountTiming aggregates the targets of one account for PrintFanOutTimings.
+type accountTiming struct {
+	name    string
+	targets int
+	failed  int
+	assume  time.Duration
+	task    time.Duration
+	total   time.Duration
+	slowest time.Duration
+}
+
+// PrintFanOutTimings prints, for -timings, how long each target spent in
+// sts:AssumeRole and in the command, the spread of both across the run, and
+// the accounts that were slowest and failed most, to tell a slow STS or
+// network from slow accounts. Exported.
+func PrintFanOutTimings(label string, summary FanOutSummary) {
+	if len(summary.Outcomes) == 0 {
+		return
+	}
+	outcomes := make([]FanOutOutcome, len(summary.Outcomes))
+	copy(outcomes, summary.Outcomes)
+	sort.Slice(outcomes, func(i, j int) bool { return targetTotal(outcomes[i].Result) > targetTotal(outcomes[j].Result) })
+
+	var assumes, tasks []time.Duration
+	reused := 0
+	accounts := make(map[string]*accountTiming)
+	fmt.Fprintf(os.Stderr, "\n%s timings (slowest first):\n", label)
+	w := tabwriter.NewWriter(os.Stderr, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ACCOUNT\tREGION\tASSUME ROLE\tCOMMAND\tTOTAL\tSTATUS")
+	for _, outcome := range outcomes {
+		result := outcome.Result
+		assume, task := "-", "-"
+		switch {
+		case result.AssumeRoleDuration > 0:
+			assume = result.AssumeRoleDuration.Round(time.Millisecond).String()
+			assumes = append(assumes, result.AssumeRoleDuration)
+		case result.TaskDuration > 0:
+			assume = "reused"
+			reused++
+		}
+		if result.TaskDuration > 0 {
+			task = result.TaskDuration.Round(time.Millisecond).String()
+			tasks = append(tasks, result.TaskDuration)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", outcome.Target.AccountName, outcome.Target.Region, assume, task,
+			targetTotal(result).Round(time.Millisecond), pkg.Colorize(os.Stderr, statusColor(result.Status), result.Status))
+
+		account := accounts[outcome.Target.AccountName]
+		if account == nil {
+			account = &accountTiming{name: outcome.Target.AccountName}
+			accounts[account.name] = account
+		}
+		account.targets++
+		if result.Status != StatusSuccess {
+			account.failed++
+		}
+		account.assume += result.AssumeRoleDuration
+		account.task += result.TaskDuration
+		account.total += targetTotal(result)
+		account.slowest = max(account.slowest, targetTotal(result))
+	}
+	w.Flush()
+
+	fmt.Fprintf(os.Stderr, "\nAssume role: %d call(s)%s, credentials reused for %d target(s).\n", len(assumes), durationSpread(assumes), reused)
+	fmt.Fprintf(os.Stderr, "Command:     %d run(s)%s.\n", len(tasks), durationSpread(tasks))
+
+	byAccount := make([]*accountTiming, 0, len(accounts))
+	for _, account := range accounts {
+		byAccount = append(byAccount, account)
+	}
+	if len(byAccount) > 1 {
+		sort.Slice(byAccount, func(i, j int) bool {
+			ai, aj := byAccount[i].total/time.Duration(byAccount[i].targets), byAccount[j].total/time.Duration(byAccount[j].targets)
+			if ai != aj {
+				return ai > aj
+			}
+			return byAccount[i].name < byAccount[j].name
+		})
+		fmt.Fprintln(os.Stderr, "\nSlowest accounts:")
+		w = tabwriter.NewWriter(os.Stderr, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ACCOUNT\tTARGETS\tAVG ASSUME ROLE\tAVG COMMAND\tAVG TOTAL\tMAX TOTAL")
+		for _, account := range byAccount[:min(maxTimingAccounts, len(byAccount))] {
+			n := time.Duration(account.targets)
+			fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%s\t%s\n", account.name, account.targets, (account.assume / n).Round(time.Millisecond),
+				(account.task / n).Round(time.Millisecond), (account.total / n).Round(time.Millisecond), account.slowest.Round(time.Millisecond))
+		}
+		w.Flush()
+	}
+
+	var failing []*accountTiming
+	for _, account := range byAccount {
+		if account.failed > 0 {
+			failing = append(failing, account)
+		}
+	}
+	if len(failing) == 0 {
+		return
+	}
+	sort.Slice(failing, func(i, j int) bool {
+		if failing[i].failed != failing[j].failed {
+			return failing[i].failed > failing[j].failed
+		}
+		return failing[i].name < failing[j].name
+	})
+	fmt.Fprintln(os.Stderr, "\nMost failing accounts:")
+	w = tabwriter.NewWriter(os.Stderr, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ACCOUNT\tFAILED\tTARGETS")
+	for _, account := range failing[:min(maxTimingAccounts, len(failing))] {
+		fmt.Fprintf(w, "%s\t%d\t%d\n", account.name, account.failed, account.targets)
+	}
+	w.Flush()
+}
+
+// targetTotal is how long a target took from launch to result.
+func targetTotal(result FanOutResult) time.Duration {
+	if result.Finished.IsZero() {
+		return result.Duration
+	}
+	return result.Finished.Sub(result.Started)
+}
+
+// durationSpread formats the median, 95th percentile, and maximum of
+// durations, e.g. ", p50 210ms, p95 1.1s, max 1.4s".
+func durationSpread(durations []time.Duration) string {
+	if len(durations) == 0 {
+		return ""
+	}
+	sorted := make([]time.Duration, len(durations))
+	copy(sorted, durations)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	percentile := func(p int) time.Duration {
+		return sorted[(len(sorted)*p+99)/100-1]
+	}
+	return fmt.Sprintf(", p50 %s, p95 %s, max %s", percentile(50).Round(time.Millisecond), percentile(95).Round(time.Millisecond), sorted[len(sorted)-1].Round(time.Millisecond))
+}
@@ -0,0 +1,147 @@
+package saws
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"text/tabwriter"
+
+	"saws/internal/pkg"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	ststypes "github.com/aws/aws-sdk-go-v2/service/sts/types"
+)
+
+// ipMatch is one ENI or Elastic IP holding the searched-for address.
+type ipMatch struct {
+	AccountName string
+	Region      string
+	VpcID       string
+	SubnetID    string
+	AttachedTo  string
+}
+
+// eniOwner describes what an ENI belongs to, e.g. an instance ID or
+// "nat_gateway: Interface for NAT Gateway nat-0123...".
+func eniOwner(eni ec2types.NetworkInterface) string {
+	if eni.Attachment != nil && eni.Attachment.InstanceId != nil {
+		return fmt.Sprintf("%s (%s)", *eni.Attachment.InstanceId, aws.ToString(eni.NetworkInterfaceId))
+	}
+	owner := string(eni.InterfaceType)
+	if owner == "" {
+		owner = "interface"
+	}
+	if desc := aws.ToString(eni.Description); desc != "" {
+		owner += ": " + desc
+	}
+	return fmt.Sprintf("%s (%s)", owner, aws.ToString(eni.NetworkInterfaceId))
+}
+
+// findIPInRegion searches the ENIs (private, public, and IPv6 addresses) and
+// Elastic IPs of one account/region for ip.
+func findIPInRegion(ctx context.Context, client *ec2.Client, target FanOutTarget, ip net.IP) ([]ipMatch, error) {
+	filterNames := []string{"addresses.private-ip-address", "association.public-ip"}
+	if ip.To4() == nil {
+		filterNames = []string{"ipv6-addresses.ipv6-address"}
+	}
+
+	var matches []ipMatch
+	seenENIs := make(map[string]bool)
+	for _, filterName := range filterNames {
+		paginator := ec2.NewDescribeNetworkInterfacesPaginator(client, &ec2.DescribeNetworkInterfacesInput{
+			Filters: []ec2types.Filter{{Name: aws.String(filterName), Values: []string{ip.String()}}},
+		})
+		for paginator.HasMorePages() {
+			page, err := paginator.NextPage(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("ec2:DescribeNetworkInterfaces failed: %w", err)
+			}
+			for _, eni := range page.NetworkInterfaces {
+				eniID := aws.ToString(eni.NetworkInterfaceId)
+				if seenENIs[eniID] {
+					continue
+				}
+				seenENIs[eniID] = true
+				matches = append(matches, ipMatch{
+					AccountName: target.AccountName,
+					Region:      target.Region,
+					VpcID:       aws.ToString(eni.VpcId),
+					SubnetID:    aws.ToString(eni.SubnetId),
+					AttachedTo:  eniOwner(eni),
+				})
+			}
+		}
+	}
+	if ip.To4() == nil {
+		return matches, nil
+	}
+
+	// Associated Elastic IPs already surfaced through their ENI above.
+	out, err := client.DescribeAddresses(ctx, &ec2.DescribeAddressesInput{
+		Filters: []ec2types.Filter{{Name: aws.String("public-ip"), Values: []string{ip.String()}}},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("ec2:DescribeAddresses failed: %w", err)
+	}
+	for _, addr := range out.Addresses {
+		if seenENIs[aws.ToString(addr.NetworkInterfaceId)] {
+			continue
+		}
+		matches = append(matches, ipMatch{
+			AccountName: target.AccountName,
+			Region:      target.Region,
+			VpcID:       "-",
+			SubnetID:    "-",
+			AttachedTo:  fmt.Sprintf("unassociated Elastic IP (%s)", aws.ToString(addr.AllocationId)),
+		})
+	}
+	return matches, nil
+}
+
+// HandleFindIP handles the logic for the `find-ip <address>` mode. Exported.
+func HandleFindIP(ctx context.Context, baseCfg aws.Config, appCfg *pkg.AppConfig, accountNames, regions []string, roleToAssume, address string) error {
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return fmt.Errorf("'%s' is not a valid IP address", address)
+	}
+
+	matches, failures, summary := CollectFanOut(ctx, baseCfg, appCfg, accountNames, regions,
+		FanOutOptions{Label: "Find IP Mode", RoleToAssume: roleToAssume, SessionName: "FindIP"},
+		func(ctx context.Context, target FanOutTarget, creds *ststypes.Credentials) ([]ipMatch, error) {
+			cfg, err := pkg.LoadAssumedRoleConfig(ctx, creds, target.Region)
+			if err != nil {
+				return nil, err
+			}
+			return findIPInRegion(ctx, ec2.NewFromConfig(cfg), target, ip)
+		})
+
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].AccountName != matches[j].AccountName {
+			return matches[i].AccountName < matches[j].AccountName
+		}
+		return matches[i].Region < matches[j].Region
+	})
+
+	if len(matches) == 0 {
+		fmt.Fprintf(os.Stderr, "%s not found in %d searched account/region target(s).\n", ip, summary.Succeeded)
+	} else {
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ACCOUNT\tACCOUNT ID\tREGION\tVPC\tSUBNET\tATTACHED TO")
+		for _, m := range matches {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", m.AccountName, appCfg.Accounts[m.AccountName], m.Region, m.VpcID, m.SubnetID, m.AttachedTo)
+		}
+		w.Flush()
+	}
+
+	for _, f := range failures {
+		fmt.Fprintf(os.Stderr, "Warning: Could not search Account: %s, Region: %s: %s\n", f.Target.AccountName, f.Target.Region, f.Err)
+	}
+	if len(matches) == 0 && len(failures) > 0 {
+		return fmt.Errorf("%d out of %d target(s) could not be searched", len(failures), summary.Total)
+	}
+	return nil
+}
@@ -0,0 +1,197 @@
+package saws
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"saws/internal/pkg"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	ststypes "github.com/aws/aws-sdk-go-v2/service/sts/types"
+	"github.com/aws/smithy-go"
+)
+
+// foundResource is one place a searched-for resource was located.
+type foundResource struct {
+	AccountName string
+	Region      string
+	Details     string
+}
+
+// ec2NameTag returns the value of the Name tag, or "-".
+func ec2NameTag(tags []ec2types.Tag) string {
+	for _, tag := range tags {
+		if aws.ToString(tag.Key) == "Name" {
+			return aws.ToString(tag.Value)
+		}
+	}
+	return "-"
+}
+
+// isNotFoundError reports whether err is an EC2 "does not exist" error such as
+// InvalidInstanceID.NotFound or InvalidGroup.NotFound. Malformed IDs are also
+// reported here so an ID from another partition does not fail every target.
+func isNotFoundError(err error) bool {
+	var apiErr smithy.APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	code := apiErr.ErrorCode()
+	return strings.HasSuffix(code, ".NotFound") || strings.HasSuffix(code, ".Malformed")
+}
+
+// describeEC2Resource looks resourceID up in one region and returns a one-line
+// description, or "" when it does not exist there.
+func describeEC2Resource(ctx context.Context, client *ec2.Client, resourceID string) (string, error) {
+	switch {
+	case strings.HasPrefix(resourceID, "i-"):
+		out, err := client.DescribeInstances(ctx, &ec2.DescribeInstancesInput{InstanceIds: []string{resourceID}})
+		if err != nil {
+			return "", err
+		}
+		for _, r := range out.Reservations {
+			for _, inst := range r.Instances {
+				state := "-"
+				if inst.State != nil {
+					state = string(inst.State.Name)
+				}
+				return fmt.Sprintf("EC2 instance %q (%s, %s) in %s, private IP %s",
+					ec2NameTag(inst.Tags), inst.InstanceType, state, aws.ToString(inst.VpcId), aws.ToString(inst.PrivateIpAddress)), nil
+			}
+		}
+	case strings.HasPrefix(resourceID, "eni-"):
+		out, err := client.DescribeNetworkInterfaces(ctx, &ec2.DescribeNetworkInterfacesInput{NetworkInterfaceIds: []string{resourceID}})
+		if err != nil {
+			return "", err
+		}
+		for _, eni := range out.NetworkInterfaces {
+			attachedTo := "unattached"
+			if eni.Attachment != nil && eni.Attachment.InstanceId != nil {
+				attachedTo = "attached to " + *eni.Attachment.InstanceId
+			} else if eni.Description != nil && *eni.Description != "" {
+				attachedTo = *eni.Description
+			}
+			return fmt.Sprintf("ENI %s in %s/%s, %s", aws.ToString(eni.PrivateIpAddress), aws.ToString(eni.VpcId), aws.ToString(eni.SubnetId), attachedTo), nil
+		}
+	case strings.HasPrefix(resourceID, "vol-"):
+		out, err := client.DescribeVolumes(ctx, &ec2.DescribeVolumesInput{VolumeIds: []string{resourceID}})
+		if err != nil {
+			return "", err
+		}
+		for _, vol := range out.Volumes {
+			attachedTo := "unattached"
+			if len(vol.Attachments) > 0 {
+				attachedTo = "attached to " + aws.ToString(vol.Attachments[0].InstanceId)
+			}
+			return fmt.Sprintf("EBS volume %q (%d GiB %s, %s) in %s, %s",
+				ec2NameTag(vol.Tags), aws.ToInt32(vol.Size), vol.VolumeType, vol.State, aws.ToString(vol.AvailabilityZone), attachedTo), nil
+		}
+	case strings.HasPrefix(resourceID, "sg-"):
+		out, err := client.DescribeSecurityGroups(ctx, &ec2.DescribeSecurityGroupsInput{GroupIds: []string{resourceID}})
+		if err != nil {
+			return "", err
+		}
+		for _, sg := range out.SecurityGroups {
+			return fmt.Sprintf("Security group %q in %s: %s", aws.ToString(sg.GroupName), aws.ToString(sg.VpcId), aws.ToString(sg.Description)), nil
+		}
+	default:
+		return "", fmt.Errorf("unsupported resource ID '%s'", resourceID)
+	}
+	return "", nil
+}
+
+// findByArn reports where an ARN lives without calling AWS: the account and
+// region are part of the ARN itself.
+func findByArn(appCfg *pkg.AppConfig, arn string) error {
+	parts := strings.SplitN(arn, ":", 6)
+	if len(parts) != 6 {
+		return fmt.Errorf("malformed ARN '%s'", arn)
+	}
+	service, region, accountID, resource := parts[2], parts[3], parts[4], parts[5]
+	accountName := "(not in SAWS config)"
+	for name, id := range appCfg.Accounts {
+		if id == accountID {
+			accountName = name
+			break
+		}
+	}
+	if accountID == "" {
+		accountName, accountID = "-", "-"
+	}
+	if region == "" {
+		region = "global"
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ACCOUNT\tACCOUNT ID\tREGION\tSERVICE\tRESOURCE")
+	fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", accountName, accountID, region, service, resource)
+	return w.Flush()
+}
+
+// HandleFind handles the logic for the `find <id>` mode. Exported.
+// Instance, ENI, volume, and security group IDs are searched in every target in
+// parallel; ARNs are resolved from their own account and region fields.
+func HandleFind(ctx context.Context, baseCfg aws.Config, appCfg *pkg.AppConfig, accountNames, regions []string, roleToAssume, resourceID string) error {
+	if strings.HasPrefix(resourceID, "arn:") {
+		return findByArn(appCfg, resourceID)
+	}
+	knownPrefix := false
+	for _, prefix := range []string{"i-", "eni-", "vol-", "sg-"} {
+		knownPrefix = knownPrefix || strings.HasPrefix(resourceID, prefix)
+	}
+	if !knownPrefix {
+		return fmt.Errorf("unsupported resource ID '%s' (expected i-, eni-, vol-, sg-, or an ARN)", resourceID)
+	}
+
+	matches, failures, summary := CollectFanOut(ctx, baseCfg, appCfg, accountNames, regions,
+		FanOutOptions{Label: "Find Mode", RoleToAssume: roleToAssume, SessionName: "FindResource"},
+		func(ctx context.Context, target FanOutTarget, creds *ststypes.Credentials) ([]foundResource, error) {
+			cfg, err := pkg.LoadAssumedRoleConfig(ctx, creds, target.Region)
+			if err != nil {
+				return nil, err
+			}
+			details, err := describeEC2Resource(ctx, ec2.NewFromConfig(cfg), resourceID)
+			if err != nil {
+				if isNotFoundError(err) {
+					return nil, nil
+				}
+				return nil, fmt.Errorf("lookup of %s failed: %w", resourceID, err)
+			}
+			if details == "" {
+				return nil, nil
+			}
+			return []foundResource{{AccountName: target.AccountName, Region: target.Region, Details: details}}, nil
+		})
+
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].AccountName != matches[j].AccountName {
+			return matches[i].AccountName < matches[j].AccountName
+		}
+		return matches[i].Region < matches[j].Region
+	})
+
+	if len(matches) == 0 {
+		fmt.Fprintf(os.Stderr, "%s not found in %d searched account/region target(s).\n", resourceID, summary.Succeeded)
+	} else {
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ACCOUNT\tACCOUNT ID\tREGION\tDETAILS")
+		for _, m := range matches {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", m.AccountName, appCfg.Accounts[m.AccountName], m.Region, m.Details)
+		}
+		w.Flush()
+	}
+
+	for _, f := range failures {
+		fmt.Fprintf(os.Stderr, "Warning: Could not search Account: %s, Region: %s: %s\n", f.Target.AccountName, f.Target.Region, f.Err)
+	}
+	if len(matches) == 0 && len(failures) > 0 {
+		return fmt.Errorf("%d out of %d target(s) could not be searched", len(failures), summary.Total)
+	}
+	return nil
+}
@@ -0,0 +1,250 @@
+package saws
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"saws/internal/pkg"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/guardduty"
+	gdtypes "github.com/aws/aws-sdk-go-v2/service/guardduty/types"
+	"github.com/aws/aws-sdk-go-v2/service/securityhub"
+	shtypes "github.com/aws/aws-sdk-go-v2/service/securityhub/types"
+	ststypes "github.com/aws/aws-sdk-go-v2/service/sts/types"
+	"github.com/aws/smithy-go"
+)
+
+const (
+	FindingsSourceAll         = "all"
+	FindingsSourceSecurityHub = "securityhub"
+	FindingsSourceGuardDuty   = "guardduty"
+
+	// guardDutyHighSeverity is the lower bound of GuardDuty's High band (7.0-8.9);
+	// Critical starts at 9.0.
+	guardDutyHighSeverity = 7
+	guardDutyBatchSize    = 50
+)
+
+// securityFinding is one row of the -findings output.
+type securityFinding struct {
+	Account   string `json:"account"`
+	Region    string `json:"region"`
+	Source    string `json:"source"`
+	Severity  string `json:"severity"`
+	Title     string `json:"title"`
+	Resource  string `json:"resource"`
+	UpdatedAt string `json:"updated_at"`
+}
+
+func securityHubFindings(ctx context.Context, cfg aws.Config, target FanOutTarget) ([]securityFinding, error) {
+	stringFilters := func(values ...string) []shtypes.StringFilter {
+		filters := make([]shtypes.StringFilter, len(values))
+		for i, v := range values {
+			filters[i] = shtypes.StringFilter{Comparison: shtypes.StringFilterComparisonEquals, Value: aws.String(v)}
+		}
+		return filters
+	}
+	paginator := securityhub.NewGetFindingsPaginator(securityhub.NewFromConfig(cfg), &securityhub.GetFindingsInput{
+		Filters: &shtypes.AwsSecurityFindingFilters{
+			SeverityLabel:  stringFilters("HIGH", "CRITICAL"),
+			RecordState:    stringFilters("ACTIVE"),
+			WorkflowStatus: stringFilters("NEW", "NOTIFIED"),
+		},
+	})
+
+	var findings []securityFinding
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			var apiErr smithy.APIError
+			if errors.As(err, &apiErr) && apiErr.ErrorCode() == "InvalidAccessException" {
+				pkg.LogVerbosef("Security Hub is not enabled for Account: %s, Region: %s; skipping.", target.AccountName, target.Region)
+				return nil, nil
+			}
+			return nil, fmt.Errorf("securityhub:GetFindings failed: %w", err)
+		}
+		for _, f := range page.Findings {
+			finding := securityFinding{
+				Account:   target.AccountName,
+				Region:    target.Region,
+				Source:    "SecurityHub",
+				Title:     aws.ToString(f.Title),
+				Resource:  "-",
+				UpdatedAt: aws.ToString(f.UpdatedAt),
+			}
+			if f.Severity != nil {
+				finding.Severity = string(f.Severity.Label)
+			}
+			if len(f.Resources) > 0 {
+				finding.Resource = aws.ToString(f.Resources[0].Id)
+			}
+			findings = append(findings, finding)
+		}
+	}
+	return findings, nil
+}
+
+// guardDutyResource picks the most useful identifier GuardDuty reports for a finding.
+func guardDutyResource(r *gdtypes.Resource) string {
+	switch {
+	case r == nil:
+		return "-"
+	case r.InstanceDetails != nil && r.InstanceDetails.InstanceId != nil:
+		return *r.InstanceDetails.InstanceId
+	case r.AccessKeyDetails != nil && r.AccessKeyDetails.UserName != nil:
+		return fmt.Sprintf("%s (%s)", *r.AccessKeyDetails.UserName, aws.ToString(r.AccessKeyDetails.AccessKeyId))
+	case len(r.S3BucketDetails) > 0 && r.S3BucketDetails[0].Name != nil:
+		return *r.S3BucketDetails[0].Name
+	case r.EksClusterDetails != nil && r.EksClusterDetails.Name != nil:
+		return *r.EksClusterDetails.Name
+	case r.LambdaDetails != nil && r.LambdaDetails.FunctionName != nil:
+		return *r.LambdaDetails.FunctionName
+	case r.RdsDbInstanceDetails != nil && r.RdsDbInstanceDetails.DbInstanceIdentifier != nil:
+		return *r.RdsDbInstanceDetails.DbInstanceIdentifier
+	}
+	return aws.ToString(r.ResourceType)
+}
+
+func guardDutySeverityLabel(severity float64) string {
+	if severity >= 9 {
+		return "CRITICAL"
+	}
+	return "HIGH"
+}
+
+func guardDutyFindings(ctx context.Context, cfg aws.Config, target FanOutTarget) ([]securityFinding, error) {
+	client := guardduty.NewFromConfig(cfg)
+	var detectorIDs []string
+	detectors := guardduty.NewListDetectorsPaginator(client, &guardduty.ListDetectorsInput{})
+	for detectors.HasMorePages() {
+		page, err := detectors.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("guardduty:ListDetectors failed: %w", err)
+		}
+		detectorIDs = append(detectorIDs, page.DetectorIds...)
+	}
+	if len(detectorIDs) == 0 {
+		pkg.LogVerbosef("GuardDuty has no detector in Account: %s, Region: %s; skipping.", target.AccountName, target.Region)
+		return nil, nil
+	}
+
+	var findings []securityFinding
+	for _, detectorID := range detectorIDs {
+		var findingIDs []string
+		paginator := guardduty.NewListFindingsPaginator(client, &guardduty.ListFindingsInput{
+			DetectorId: aws.String(detectorID),
+			FindingCriteria: &gdtypes.FindingCriteria{Criterion: map[string]gdtypes.Condition{
+				"severity":         {GreaterThanOrEqual: aws.Int64(guardDutyHighSeverity)},
+				"service.archived": {Equals: []string{"false"}},
+			}},
+		})
+		for paginator.HasMorePages() {
+			page, err := paginator.NextPage(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("guardduty:ListFindings failed for detector %s: %w", detectorID, err)
+			}
+			findingIDs = append(findingIDs, page.FindingIds...)
+		}
+
+		for i := 0; i < len(findingIDs); i += guardDutyBatchSize {
+			end := min(i+guardDutyBatchSize, len(findingIDs))
+			out, err := client.GetFindings(ctx, &guardduty.GetFindingsInput{DetectorId: aws.String(detectorID), FindingIds: findingIDs[i:end]})
+			if err != nil {
+				return nil, fmt.Errorf("guardduty:GetFindings failed for detector %s: %w", detectorID, err)
+			}
+			for _, f := range out.Findings {
+				findings = append(findings, securityFinding{
+					Account:   target.AccountName,
+					Region:    target.Region,
+					Source:    "GuardDuty",
+					Severity:  guardDutySeverityLabel(aws.ToFloat64(f.Severity)),
+					Title:     aws.ToString(f.Title),
+					Resource:  guardDutyResource(f.Resource),
+					UpdatedAt: aws.ToString(f.UpdatedAt),
+				})
+			}
+		}
+	}
+	return findings, nil
+}
+
+// HandleFindings handles the logic for the -findings mode. Exported.
+// It gathers active HIGH and CRITICAL findings from Security Hub and/or
+// GuardDuty in every target and prints them as one table, or as JSON.
+func HandleFindings(ctx context.Context, baseCfg aws.Config, appCfg *pkg.AppConfig, accountNames, regions []string, roleToAssume, source string, asJSON bool) error {
+	source = strings.ToLower(source)
+	if source != FindingsSourceAll && source != FindingsSourceSecurityHub && source != FindingsSourceGuardDuty {
+		return fmt.Errorf("unknown findings source '%s' (expected %s, %s, or %s)", source, FindingsSourceAll, FindingsSourceSecurityHub, FindingsSourceGuardDuty)
+	}
+
+	findings, failures, summary := CollectFanOut(ctx, baseCfg, appCfg, accountNames, regions,
+		FanOutOptions{Label: "Findings Mode", RoleToAssume: roleToAssume, SessionName: "Findings"},
+		func(ctx context.Context, target FanOutTarget, creds *ststypes.Credentials) ([]securityFinding, error) {
+			cfg, err := pkg.LoadAssumedRoleConfig(ctx, creds, target.Region)
+			if err != nil {
+				return nil, err
+			}
+			var all []securityFinding
+			if source != FindingsSourceGuardDuty {
+				shFindings, err := securityHubFindings(ctx, cfg, target)
+				if err != nil {
+					return nil, err
+				}
+				all = append(all, shFindings...)
+			}
+			if source != FindingsSourceSecurityHub {
+				gdFindings, err := guardDutyFindings(ctx, cfg, target)
+				if err != nil {
+					return nil, err
+				}
+				all = append(all, gdFindings...)
+			}
+			return all, nil
+		})
+
+	severityRank := map[string]int{"CRITICAL": 0, "HIGH": 1}
+	sort.Slice(findings, func(i, j int) bool {
+		if findings[i].Severity != findings[j].Severity {
+			return severityRank[findings[i].Severity] < severityRank[findings[j].Severity]
+		}
+		if findings[i].Account != findings[j].Account {
+			return findings[i].Account < findings[j].Account
+		}
+		return findings[i].UpdatedAt > findings[j].UpdatedAt
+	})
+
+	if asJSON {
+		if findings == nil {
+			findings = []securityFinding{}
+		}
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(findings); err != nil {
+			return fmt.Errorf("failed to encode findings as JSON: %w", err)
+		}
+	} else if len(findings) == 0 {
+		fmt.Fprintf(os.Stderr, "No active HIGH or CRITICAL findings across %d account/region target(s).\n", summary.Succeeded)
+	} else {
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprint(w, pkg.ColorRow(os.Stdout, pkg.ColorPlain, "SEVERITY\tACCOUNT\tREGION\tSOURCE\tRESOURCE\tTITLE\n"))
+		for _, f := range findings {
+			fmt.Fprint(w, pkg.ColorRow(os.Stdout, statusColor(f.Severity), fmt.Sprintf("%s\t%s\t%s\t%s\t%s\t%s\n", f.Severity, f.Account, f.Region, f.Source, f.Resource, f.Title)))
+		}
+		w.Flush()
+	}
+
+	for _, f := range failures {
+		fmt.Fprintf(os.Stderr, "Warning: Could not fetch findings for Account: %s, Region: %s: %s\n", f.Target.AccountName, f.Target.Region, f.Err)
+	}
+	if len(failures) > 0 {
+		return fmt.Errorf("%d out of %d target(s) could not be queried", len(failures), summary.Total)
+	}
+	return nil
+}
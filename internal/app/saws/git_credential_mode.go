@@ -0,0 +1,163 @@
+package saws
+
+import (
+	"bufio"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"saws/internal/pkg"
+
+	ststypes "github.com/aws/aws-sdk-go-v2/service/sts/types"
+)
+
+// GitCredentialHelperName is the executable name git runs for
+// `credential.helper = saws`. Invoked under this name (e.g. via a symlink),
+// saws behaves as `saws git-credential`.
+const GitCredentialHelperName = "git-credential-saws"
+
+var codeCommitHostPattern = regexp.MustCompile(`^git-codecommit(?:-fips)?\.([a-z0-9-]+)\.amazonaws\.com(?:\.cn)?$`)
+
+// readGitCredentialRequest parses the key=value lines git writes to a helper.
+func readGitCredentialRequest(in io.Reader) (map[string]string, error) {
+	request := make(map[string]string)
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			break
+		}
+		if key, value, ok := strings.Cut(line, "="); ok {
+			request[key] = value
+		}
+	}
+	return request, scanner.Err()
+}
+
+// codeCommitRepoFor returns the codecommit_repos entry for repo: an exact name
+// first, then the longest matching wildcard.
+func codeCommitRepoFor(appCfg *pkg.AppConfig, repo string) (pkg.CodeCommitRepoConfig, bool) {
+	if entry, ok := appCfg.CodeCommitRepos[repo]; ok {
+		return entry, true
+	}
+	patterns := make([]string, 0, len(appCfg.CodeCommitRepos))
+	for pattern := range appCfg.CodeCommitRepos {
+		patterns = append(patterns, pattern)
+	}
+	sort.Slice(patterns, func(i, j int) bool {
+		if len(patterns[i]) != len(patterns[j]) {
+			return len(patterns[i]) > len(patterns[j])
+		}
+		return patterns[i] < patterns[j]
+	})
+	for _, pattern := range patterns {
+		if match, _ := filepath.Match(pattern, repo); match {
+			return appCfg.CodeCommitRepos[pattern], true
+		}
+	}
+	return pkg.CodeCommitRepoConfig{}, false
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// signCodeCommitRequest builds the git username and password of a CodeCommit
+// HTTPS request, the way `aws codecommit credential-helper` does: a SigV4
+// signature over the pseudo-request "GIT <path>" for the host.
+func signCodeCommitRequest(creds *ststypes.Credentials, region, host, path string, now time.Time) (username, password string) {
+	now = now.UTC()
+	timestamp := now.Format("20060102T150405")
+	date := now.Format("20060102")
+	canonicalRequest := fmt.Sprintf("GIT\n%s\n\nhost:%s\n\nhost\n", path, host)
+	canonicalHash := sha256.Sum256([]byte(canonicalRequest))
+	scope := date + "/" + region + "/codecommit/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + timestamp + "\n" + scope + "\n" + hex.EncodeToString(canonicalHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+*creds.SecretAccessKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, "codecommit")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	username = *creds.AccessKeyId
+	if creds.SessionToken != nil && *creds.SessionToken != "" {
+		username += "%" + *creds.SessionToken
+	}
+	return username, timestamp + "Z" + signature
+}
+
+// HandleGitCredential implements the git credential-helper protocol for
+// CodeCommit HTTPS remotes. Exported.
+// `get` finds the account and role of the repository under codecommit_repos
+// (else -s and -r), assumes the role, and answers with a signed username and
+// password; other hosts get no answer, so git falls back to its other helpers.
+// `store` and `erase` are ignored because the password is signed on demand.
+// git must send the repository path: set credential.UseHttpPath to true.
+func HandleGitCredential(ctx context.Context, appCfg *pkg.AppConfig, action, accountSelector, roleName string, in io.Reader, out io.Writer) error {
+	switch action {
+	case "store", "erase":
+		_, _ = io.Copy(io.Discard, in)
+		return nil
+	case "get":
+	default:
+		return fmt.Errorf("unknown credential helper action '%s' (expected get, store, or erase)", action)
+	}
+
+	request, err := readGitCredentialRequest(in)
+	if err != nil {
+		return fmt.Errorf("failed to read the credential request: %w", err)
+	}
+	host, _, _ := strings.Cut(request["host"], ":")
+	m := codeCommitHostPattern.FindStringSubmatch(host)
+	if request["protocol"] != "https" || m == nil {
+		pkg.LogVerbosef("Git credential helper: '%s' is not a CodeCommit HTTPS remote.", request["host"])
+		return nil
+	}
+	region := m[1]
+	path := "/" + strings.TrimPrefix(request["path"], "/")
+	repo, ok := strings.CutPrefix(path, "/v1/repos/")
+	if !ok || repo == "" {
+		return errors.New("git did not send the repository path; run 'git config --global credential.UseHttpPath true'")
+	}
+
+	if entry, ok := codeCommitRepoFor(appCfg, repo); ok {
+		accountSelector = entry.Account
+		if entry.Role != "" {
+			roleName = entry.Role
+		}
+	}
+	if roleName == "" {
+		roleName = os.Getenv("SAWS_ROLE")
+	}
+	if accountSelector == "" || roleName == "" {
+		return fmt.Errorf("no account and role for CodeCommit repository '%s' (add it to codecommit_repos in the SAWS config, or pass -s and -r)", repo)
+	}
+	sCtx, err := pkg.ResolveContext(accountSelector, roleName, region)
+	if err != nil {
+		return err
+	}
+	creds, err := pkg.AssumeSelectedRole(ctx, sCtx, "GitCredential")
+	pkg.Audit(pkg.AuditResult(pkg.AuditRecord{Event: pkg.AuditAssumeRole, Account: sCtx.AccountName, AccountID: sCtx.AccountID, Role: sCtx.RoleName, Region: region}, err))
+	if err != nil {
+		return err
+	}
+
+	username, password := signCodeCommitRequest(creds, region, host, path, time.Now())
+	pkg.RegisterSecret(password)
+	pkg.LogVerbosef("Git credential helper: signed %s%s (Account=%s(%s), Role=%s)", host, path, sCtx.AccountName, sCtx.AccountID, sCtx.RoleName)
+	_, err = fmt.Fprintf(out, "protocol=https\nhost=%s\nusername=%s\npassword=%s\n\n", request["host"], username, password)
+	return err
+}
@@ -0,0 +1,108 @@
+package saws
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"saws/internal/pkg"
+)
+
+// historyTargets summarizes the accounts and regions of an entry for the listing.
+func historyTargets(entry pkg.HistoryEntry) string {
+	accounts := slices.Compact(slices.Sorted(slices.Values(entry.Accounts)))
+	regions := slices.Compact(slices.Sorted(slices.Values(entry.Regions)))
+	switch {
+	case len(accounts) == 0:
+		return "-"
+	case len(accounts) == 1 && len(regions) == 1:
+		return accounts[0] + "/" + regions[0]
+	default:
+		return fmt.Sprintf("%d account(s) x %d region(s)", len(accounts), len(regions))
+	}
+}
+
+// quoteArg quotes a command-line argument for display when the shell would split it.
+func quoteArg(arg string) string {
+	if arg == "" || strings.ContainsAny(arg, " \t\"'$`\\*?[]{}()<>|&;!#") {
+		return "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+	}
+	return arg
+}
+
+// HandleHistory handles the logic for the `history` mode. Exported.
+// It lists the recorded invocations, oldest first, with their outcome.
+func HandleHistory() error {
+	entries, err := pkg.LoadHistory()
+	if err != nil {
+		return fmt.Errorf("could not read history: %w", err)
+	}
+	if len(entries) == 0 {
+		fmt.Fprintln(os.Stderr, "No history yet; saws invocations are recorded as you run them.")
+		return nil
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprint(w, pkg.ColorRow(os.Stdout, pkg.ColorPlain, "ID\tSTARTED\tEXIT\tDURATION\tMODE\tTARGETS\tCOMMAND\n"))
+	for _, entry := range entries {
+		args := make([]string, len(entry.Args))
+		for i, arg := range entry.Args {
+			args[i] = quoteArg(arg)
+		}
+		color := pkg.ColorPlain
+		if entry.ExitCode != 0 {
+			color = pkg.ColorRed
+		}
+		fmt.Fprint(w, pkg.ColorRow(os.Stdout, color, fmt.Sprintf("%d\t%s\t%d\t%s\t%s\t%s\tsaws %s\n", entry.ID, entry.StartedAt.Local().Format(time.DateTime),
+			entry.ExitCode, entry.Duration, entry.Mode, historyTargets(entry), strings.Join(args, " "))))
+	}
+	return w.Flush()
+}
+
+// HandleReplay handles the logic for the `replay <n>` mode. Exported.
+// It re-runs history entry n with the same arguments, SAWS_* environment, and
+// working directory, and returns the exit code of the re-run.
+func HandleReplay(id int) (int, error) {
+	entry, err := pkg.FindHistoryEntry(id)
+	if err != nil {
+		return 1, err
+	}
+	if slices.ContainsFunc(entry.Args, func(arg string) bool { return strings.Contains(arg, pkg.HistoryRedacted) }) {
+		return 1, fmt.Errorf("history entry %d had a secret argument redacted and cannot be replayed", id)
+	}
+	executable, err := os.Executable()
+	if err != nil {
+		return 1, fmt.Errorf("could not locate the saws executable: %w", err)
+	}
+
+	cmd := exec.Command(executable, entry.Args...)
+	cmd.Dir = entry.Dir
+	if _, errStat := os.Stat(entry.Dir); entry.Dir == "" || errStat != nil {
+		cmd.Dir = ""
+		pkg.LogVerbosef("Directory '%s' of entry %d is gone; replaying in the current directory.", entry.Dir, id)
+	}
+	for _, kv := range os.Environ() {
+		if name, _, _ := strings.Cut(kv, "="); !slices.Contains(pkg.HistoryEnvVars, name) {
+			cmd.Env = append(cmd.Env, kv)
+		}
+	}
+	for name, value := range entry.Env {
+		cmd.Env = append(cmd.Env, name+"="+value)
+	}
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	fmt.Fprintf(os.Stderr, "Replaying #%d: saws %s\n", id, strings.Join(entry.Args, " "))
+
+	err = cmd.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode(), nil
+	}
+	if err != nil {
+		return 1, fmt.Errorf("failed to re-run entry %d: %w", id, err)
+	}
+	return 0, nil
+}
@@ -0,0 +1,194 @@
+package saws
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"saws/internal/pkg"
+
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+)
+
+// awsConfigProfile is the part of an ~/.aws/config profile `init --from-aws-config` reads.
+type awsConfigProfile struct {
+	Name          string
+	RoleArn       string
+	SourceProfile string
+	AccountID     string // sso_account_id or granted_sso_account_id
+	RoleName      string // sso_role_name or granted_sso_role_name
+	Region        string
+}
+
+// readAWSConfigProfiles parses the profiles of the shared AWS config file at
+// path. sso-session and other non-profile sections are skipped.
+func readAWSConfigProfiles(path string) ([]awsConfigProfile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not open AWS config file: %w", err)
+	}
+	defer f.Close()
+
+	var profiles []awsConfigProfile
+	var current *awsConfigProfile
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			current = nil
+			section := strings.TrimSpace(line[1 : len(line)-1])
+			name, isProfile := strings.CutPrefix(section, "profile ")
+			if section == "default" {
+				name, isProfile = section, true
+			}
+			if isProfile {
+				profiles = append(profiles, awsConfigProfile{Name: strings.TrimSpace(name)})
+				current = &profiles[len(profiles)-1]
+			}
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok || current == nil {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		switch strings.TrimSpace(key) {
+		case "role_arn":
+			current.RoleArn = value
+		case "source_profile":
+			current.SourceProfile = value
+		case "sso_account_id", "granted_sso_account_id":
+			current.AccountID = value
+		case "sso_role_name", "granted_sso_role_name":
+			current.RoleName = value
+		case "region":
+			current.Region = value
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read AWS config file: %w", err)
+	}
+	return profiles, nil
+}
+
+// importAWSConfigProfiles converts profiles into config accounts, roles, and
+// roles_by_account. Each account is named after the shortest profile that
+// targets it; the base profile is the most used source_profile, else an SSO
+// profile; the regions are those the profiles set. role_arn roles with a path
+// or outside the aws partition keep their full ARN; SSO profiles become
+// "sso:<PermissionSet>" roles, as their roles trust only the SSO provider.
+func importAWSConfigProfiles(profiles []awsConfigProfile) initConfig {
+	cfg := initConfig{Accounts: make(map[string]string), Roles: make(map[string]string)}
+	accountNames := make(map[string]string)
+	accountRoles := make(map[string]map[string]bool)
+	sourceUses := make(map[string]int)
+	var ssoProfiles []string
+	regions := make(map[string]bool)
+
+	for _, p := range profiles {
+		var accountID, roleName, role string
+		switch {
+		case p.RoleArn != "":
+			parsed, ok := pkg.ParseRoleARN(p.RoleArn)
+			if !ok {
+				pkg.LogVerbosef("Skipping profile '%s': '%s' is not an IAM role ARN.", p.Name, p.RoleArn)
+				continue
+			}
+			accountID, roleName, role = parsed.AccountID, parsed.Name, parsed.Name
+			if parsed.ARN != fmt.Sprintf("arn:%s:iam::%s:role/%s", pkg.PartitionAWS, parsed.AccountID, parsed.Name) {
+				role = parsed.ARN
+			}
+			if p.SourceProfile != "" {
+				sourceUses[p.SourceProfile]++
+			}
+		case p.AccountID != "" && p.RoleName != "":
+			accountID, roleName, role = p.AccountID, p.RoleName, pkg.SSORolePrefix+p.RoleName
+			ssoProfiles = append(ssoProfiles, p.Name)
+		default:
+			pkg.LogVerbosef("Skipping profile '%s': it names no account and role.", p.Name)
+			continue
+		}
+		name := configAccountName(p.Name)
+		if existing, ok := accountNames[accountID]; !ok || len(name) < len(existing) || (len(name) == len(existing) && name < existing) {
+			accountNames[accountID] = name
+		}
+		if accountRoles[accountID] == nil {
+			accountRoles[accountID] = make(map[string]bool)
+		}
+		// Roles named alike but assumed differently (another path, partition,
+		// or an SSO permission set) get the account as a suffix.
+		if existing, taken := cfg.Roles[roleName]; taken && existing != role {
+			roleName = fmt.Sprintf("%s-%s", roleName, accountID[len(accountID)-4:])
+		}
+		accountRoles[accountID][roleName] = true
+		cfg.Roles[roleName] = role
+		if p.Region != "" {
+			regions[p.Region] = true
+		}
+	}
+
+	for _, accountID := range sortedKeys(accountNames) {
+		name := accountNames[accountID]
+		if otherID, taken := cfg.Accounts[name]; taken && otherID != accountID {
+			name = fmt.Sprintf("%s-%s", name, accountID[len(accountID)-4:])
+		}
+		cfg.Accounts[name] = accountID
+	}
+	for name, accountID := range cfg.Accounts {
+		if len(accountRoles[accountID]) == len(cfg.Roles) {
+			continue
+		}
+		if cfg.RolesByAccount == nil {
+			cfg.RolesByAccount = make(map[string][]string)
+		}
+		for roleName := range accountRoles[accountID] {
+			cfg.RolesByAccount[name] = append(cfg.RolesByAccount[name], roleName)
+		}
+		sort.Strings(cfg.RolesByAccount[name])
+	}
+	for profile, uses := range sourceUses {
+		if uses > sourceUses[cfg.BaseProfile] || (uses == sourceUses[cfg.BaseProfile] && profile < cfg.BaseProfile) {
+			cfg.BaseProfile = profile
+		}
+	}
+	if len(ssoProfiles) > 0 {
+		sort.Strings(ssoProfiles)
+		if cfg.BaseProfile == "" {
+			cfg.BaseProfile = ssoProfiles[0]
+		} else {
+			fmt.Fprintf(os.Stderr, "Note: the sso: roles of %d SSO profile(s) need an IAM Identity Center base profile such as '%s'; '%s' is suggested for the role_arn profiles.\n",
+				len(ssoProfiles), ssoProfiles[0], cfg.BaseProfile)
+		}
+	}
+	for region := range regions {
+		cfg.CommonRegions = append(cfg.CommonRegions, region)
+	}
+	sort.Strings(cfg.CommonRegions)
+	return cfg
+}
+
+// importAWSConfig reads the shared AWS config file (AWS_CONFIG_FILE or
+// ~/.aws/config) for `saws init --from-aws-config`.
+func importAWSConfig() (initConfig, error) {
+	path := os.Getenv("AWS_CONFIG_FILE")
+	if path == "" {
+		path = awsconfig.DefaultSharedConfigFilename()
+	}
+	profiles, err := readAWSConfigProfiles(path)
+	if err != nil {
+		return initConfig{}, err
+	}
+	imported := importAWSConfigProfiles(profiles)
+	if len(imported.Accounts) == 0 {
+		return initConfig{}, fmt.Errorf("no profile in %s has a role_arn or sso_account_id/sso_role_name", path)
+	}
+	fmt.Fprintf(os.Stderr, "Imported %d account(s) and %d role(s) from %d profile(s) in %s: %s\n",
+		len(imported.Accounts), len(imported.Roles), len(profiles), path, strings.Join(sortedKeys(imported.Roles), ", "))
+	pkg.LogVerbosef("Imported accounts: %v", sortedKeys(imported.Accounts))
+	return imported, nil
+}
@@ -0,0 +1,232 @@
+package saws
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"saws/internal/pkg"
+
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"gopkg.in/yaml.v3"
+)
+
+// initRegionChoices are offered by `saws init`; any other region can be added later.
+var initRegionChoices = []string{
+	"us-east-1", "us-east-2", "us-west-1", "us-west-2",
+	"ca-central-1", "sa-east-1",
+	"eu-west-1", "eu-west-2", "eu-west-3", "eu-central-1", "eu-north-1", "eu-south-1",
+	"ap-south-1", "ap-southeast-1", "ap-southeast-2", "ap-northeast-1", "ap-northeast-2",
+	"me-south-1", "af-south-1",
+}
+
+var initAccountIDPattern = regexp.MustCompile(`^\d{12}$`)
+
+// initConfig is the subset of the config written by `saws init`.
+type initConfig struct {
+	BaseProfile    string              `yaml:"base_profile,omitempty"`
+	Accounts       map[string]string   `yaml:"accounts"`
+	CommonRegions  []string            `yaml:"common_regions"`
+	Roles          map[string]string   `yaml:"roles,omitempty"`
+	RolesByAccount map[string][]string `yaml:"roles_by_account,omitempty"`
+}
+
+// verifyBaseProfile calls sts:GetCallerIdentity with the profile and returns the caller ARN.
+func verifyBaseProfile(ctx context.Context, profile string) (string, error) {
+	cfg, err := pkg.LoadAWSConfig(ctx, awsconfig.WithSharedConfigProfile(profile), awsconfig.WithRegion(pkg.FallbackRegion))
+	if err != nil {
+		return "", fmt.Errorf("could not load AWS profile '%s': %w", profile, err)
+	}
+	out, err := sts.NewFromConfig(cfg).GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+	if err != nil && pkg.IsExpiredCredentials(err) {
+		// An SSO profile that was never logged in to gets a login here.
+		if errReauth := pkg.ReauthenticateBase(ctx, cfg); errReauth != nil {
+			return "", fmt.Errorf("credentials of profile '%s' do not work: %w; %v", profile, err, errReauth)
+		}
+		out, err = sts.NewFromConfig(cfg).GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+	}
+	if err != nil {
+		return "", fmt.Errorf("credentials of profile '%s' do not work: %w", profile, err)
+	}
+	return aws.ToString(out.Arn), nil
+}
+
+func askAccounts() (map[string]string, error) {
+	accounts := make(map[string]string)
+	for {
+		name, id := "", ""
+		if err := pkg.AskOne(&survey.Input{Message: "Account name (e.g. prod-web; empty to name it after its IAM account alias):"}, &name); err != nil {
+			return nil, err
+		}
+		validateID := func(ans interface{}) error {
+			if !initAccountIDPattern.MatchString(strings.TrimSpace(ans.(string))) {
+				return errors.New("account IDs are exactly 12 digits")
+			}
+			return nil
+		}
+		message := fmt.Sprintf("Account ID for %s:", name)
+		if strings.TrimSpace(name) == "" {
+			message = "Account ID:"
+		}
+		if err := pkg.AskOne(&survey.Input{Message: message}, &id, survey.WithValidator(validateID)); err != nil {
+			return nil, err
+		}
+		// Accounts named by their ID are offered their alias later.
+		if name = strings.TrimSpace(name); name == "" {
+			name = strings.TrimSpace(id)
+		}
+		accounts[name] = strings.TrimSpace(id)
+
+		more := false
+		if err := pkg.AskOne(&survey.Confirm{Message: "Add another account?", Default: true}, &more); err != nil {
+			return nil, err
+		}
+		if !more {
+			return accounts, nil
+		}
+	}
+}
+
+func askRoles() (map[string]string, error) {
+	roles := make(map[string]string)
+	for {
+		more := false
+		message := "Add a friendly role name (e.g. Admin -> OrganizationAccountAccessRole)?"
+		if len(roles) > 0 {
+			message = "Add another role?"
+		}
+		if err := pkg.AskOne(&survey.Confirm{Message: message, Default: len(roles) == 0}, &more); err != nil {
+			return nil, err
+		}
+		if !more {
+			return roles, nil
+		}
+		friendly, actual := "", ""
+		if err := pkg.AskOne(&survey.Input{Message: "Friendly role name:"}, &friendly, survey.WithValidator(survey.Required)); err != nil {
+			return nil, err
+		}
+		if err := pkg.AskOne(&survey.Input{Message: fmt.Sprintf("IAM role name for %s:", friendly)}, &actual, survey.WithValidator(survey.Required)); err != nil {
+			return nil, err
+		}
+		roles[strings.TrimSpace(friendly)] = strings.TrimSpace(actual)
+	}
+}
+
+// HandleInit handles the logic for the `init` mode. Exported.
+// It interactively builds a saws-config.yaml, checks the base credentials, and
+// writes the file to one of the locations saws searches. With fromSSO the
+// accounts and roles come from the IAM Identity Center assignments of the base
+// profile instead of prompts; with fromAWSConfig they come from the role_arn and
+// SSO profiles of ~/.aws/config. Accounts named by their ID may be named after
+// their IAM account alias instead.
+func HandleInit(ctx context.Context, fromSSO, fromAWSConfig bool) error {
+	if fromSSO && fromAWSConfig {
+		return errors.New("--from-sso and --from-aws-config cannot be combined")
+	}
+	fmt.Fprintln(os.Stderr, "This wizard creates a SAWS config. Press Ctrl+C to abort at any time.")
+
+	var imported initConfig
+	profile := pkg.BaseProfileForAssume
+	if fromAWSConfig {
+		var err error
+		if imported, err = importAWSConfig(); err != nil {
+			return fmt.Errorf("could not import AWS config profiles: %w", err)
+		}
+		if imported.BaseProfile != "" {
+			profile = imported.BaseProfile
+		}
+	}
+	if err := pkg.AskOne(&survey.Input{Message: "AWS profile whose credentials assume roles (base profile):", Default: profile}, &profile, survey.WithValidator(survey.Required)); err != nil {
+		return fmt.Errorf("base profile prompt failed: %w", err)
+	}
+	callerArn, err := verifyBaseProfile(ctx, profile)
+	if err != nil {
+		proceed := false
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		if errAsk := pkg.AskOne(&survey.Confirm{Message: "Continue anyway (e.g. to log in later)?", Default: false}, &proceed); errAsk != nil || !proceed {
+			return errors.New("aborted: base credentials could not be verified")
+		}
+	} else {
+		fmt.Fprintf(os.Stderr, "Base credentials OK: %s\n", callerArn)
+	}
+
+	var accounts, roles map[string]string
+	if fromSSO {
+		if accounts, roles, err = discoverSSOAssignments(ctx, profile); err != nil {
+			return fmt.Errorf("could not read IAM Identity Center assignments: %w", err)
+		}
+		printSSOAssignments(accounts, roles)
+	} else if fromAWSConfig {
+		accounts, roles = imported.Accounts, imported.Roles
+	} else if accounts, err = askAccounts(); err != nil {
+		return fmt.Errorf("account prompt failed: %w", err)
+	}
+
+	var regions []string
+	regionChoices, regionDefaults := initRegionChoices, []string{pkg.FallbackRegion}
+	if len(imported.CommonRegions) > 0 {
+		regionDefaults = imported.CommonRegions
+		for _, region := range imported.CommonRegions {
+			if !slices.Contains(regionChoices, region) {
+				regionChoices = append(slices.Clone(regionChoices), region)
+			}
+		}
+	}
+	regionPrompt := &survey.MultiSelect{Message: "Regions you commonly work in:", Options: regionChoices, Default: regionDefaults, PageSize: 15}
+	if err := pkg.AskOne(regionPrompt, &regions, survey.WithValidator(survey.MinItems(1)), pkg.WithFuzzyFilter); err != nil {
+		return fmt.Errorf("region prompt failed: %w", err)
+	}
+
+	if !fromSSO && !fromAWSConfig {
+		if roles, err = askRoles(); err != nil {
+			return fmt.Errorf("role prompt failed: %w", err)
+		}
+	}
+
+	cfg := initConfig{Accounts: accounts, CommonRegions: regions, Roles: roles, RolesByAccount: imported.RolesByAccount}
+	if err := nameAccountsByAlias(ctx, profile, &cfg); err != nil {
+		return fmt.Errorf("account alias lookup failed: %w", err)
+	}
+
+	candidates := pkg.CandidateConfigPaths()
+	location := ""
+	if err := pkg.AskOne(&survey.Select{Message: "Where should the config be written?", Options: candidates, Default: candidates[0]}, &location, pkg.WithFuzzyFilter); err != nil {
+		return fmt.Errorf("location prompt failed: %w", err)
+	}
+	if _, errStat := os.Stat(location); errStat == nil {
+		overwrite := false
+		if err := pkg.AskOne(&survey.Confirm{Message: fmt.Sprintf("%s exists. Overwrite it?", location), Default: false}, &overwrite); err != nil || !overwrite {
+			return errors.New("aborted: existing config left untouched")
+		}
+	}
+
+	if profile != "default" {
+		cfg.BaseProfile = profile
+	}
+	data, err := yaml.Marshal(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to encode config: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(location), 0o755); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+	if err := pkg.WriteFileAtomic(location, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write config: %w", err)
+	}
+
+	_, issues := pkg.ValidateConfig(location)
+	for _, issue := range issues {
+		fmt.Fprintf(os.Stderr, "[%s] %s\n", issue.Level, issue.Message)
+	}
+	fmt.Fprintf(os.Stderr, "Wrote %s with %d account(s), %d region(s), and %d role(s).\n", location, len(cfg.Accounts), len(regions), len(roles))
+	fmt.Fprintln(os.Stderr, "Next: run 'saws validate-config --probe' to confirm every role can be assumed.")
+	return nil
+}
@@ -0,0 +1,135 @@
+package saws
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"saws/internal/pkg"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials/ssocreds"
+	"github.com/aws/aws-sdk-go-v2/service/sso"
+)
+
+var accountNameUnsafeChars = regexp.MustCompile(`[^a-z0-9_-]+`)
+
+// ssoCachedToken is the part of an `aws sso login` cache file saws needs.
+type ssoCachedToken struct {
+	AccessToken string    `json:"accessToken"`
+	ExpiresAt   time.Time `json:"expiresAt"`
+}
+
+// ssoAccessToken reads the cached IAM Identity Center access token of profile
+// and returns it with the SSO region.
+func ssoAccessToken(ctx context.Context, profile string) (string, string, error) {
+	shared, err := awsconfig.LoadSharedConfigProfile(ctx, profile)
+	if err != nil {
+		return "", "", fmt.Errorf("could not read AWS profile '%s': %w", profile, err)
+	}
+	cacheKey, region := shared.SSOStartURL, shared.SSORegion
+	if shared.SSOSession != nil {
+		cacheKey, region = shared.SSOSessionName, shared.SSOSession.SSORegion
+	}
+	if cacheKey == "" {
+		return "", "", fmt.Errorf("profile '%s' has no sso_session or sso_start_url", profile)
+	}
+	tokenPath, err := ssocreds.StandardCachedTokenFilepath(cacheKey)
+	if err != nil {
+		return "", "", err
+	}
+	data, err := os.ReadFile(tokenPath)
+	if err != nil {
+		return "", "", fmt.Errorf("no cached SSO token for profile '%s' (run 'saws sso-login %s'): %w", profile, profile, err)
+	}
+	var token ssoCachedToken
+	if err := json.Unmarshal(data, &token); err != nil {
+		return "", "", fmt.Errorf("failed to parse SSO token cache '%s': %w", tokenPath, err)
+	}
+	if token.AccessToken == "" || time.Now().After(token.ExpiresAt) {
+		return "", "", fmt.Errorf("the SSO token of profile '%s' has expired (run 'saws sso-login %s')", profile, profile)
+	}
+	pkg.RegisterSecret(token.AccessToken)
+	return token.AccessToken, region, nil
+}
+
+// configAccountName turns an account name such as "Payments Prod" into "payments-prod".
+func configAccountName(name string) string {
+	return strings.Trim(accountNameUnsafeChars.ReplaceAllString(strings.ToLower(name), "-"), "-")
+}
+
+// discoverSSOAssignments lists the accounts and permission sets the SSO user of
+// profile can access, as config accounts (name -> ID) and roles (name ->
+// "sso:name"). Permission set roles only trust the SSO SAML provider, so they
+// are written as permission sets, whose credentials come from sso:GetRoleCredentials.
+func discoverSSOAssignments(ctx context.Context, profile string) (map[string]string, map[string]string, error) {
+	accessToken, region, err := ssoAccessToken(ctx, profile)
+	if err != nil {
+		return nil, nil, err
+	}
+	cfg, err := pkg.LoadAWSConfig(ctx, awsconfig.WithRegion(region), awsconfig.WithCredentialsProvider(aws.AnonymousCredentials{}))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load AWS configuration for the SSO portal: %w", err)
+	}
+	client := sso.NewFromConfig(cfg)
+
+	accounts := make(map[string]string)
+	roles := make(map[string]string)
+	accountPages := sso.NewListAccountsPaginator(client, &sso.ListAccountsInput{AccessToken: aws.String(accessToken)})
+	for accountPages.HasMorePages() {
+		page, err := accountPages.NextPage(ctx)
+		if err != nil {
+			return nil, nil, fmt.Errorf("sso:ListAccounts failed: %w", err)
+		}
+		for _, account := range page.AccountList {
+			accountID := aws.ToString(account.AccountId)
+			name := configAccountName(aws.ToString(account.AccountName))
+			if name == "" {
+				name = accountID
+			}
+			if _, taken := accounts[name]; taken {
+				name = fmt.Sprintf("%s-%s", name, accountID[len(accountID)-4:])
+			}
+			accounts[name] = accountID
+
+			rolePages := sso.NewListAccountRolesPaginator(client, &sso.ListAccountRolesInput{AccessToken: aws.String(accessToken), AccountId: account.AccountId})
+			for rolePages.HasMorePages() {
+				rolePage, err := rolePages.NextPage(ctx)
+				if err != nil {
+					return nil, nil, fmt.Errorf("sso:ListAccountRoles failed for %s: %w", accountID, err)
+				}
+				for _, role := range rolePage.RoleList {
+					roleName := aws.ToString(role.RoleName)
+					roles[roleName] = pkg.SSORolePrefix + roleName
+				}
+			}
+		}
+	}
+	if len(accounts) == 0 {
+		return nil, nil, errors.New("the SSO user has no account assignments")
+	}
+	return accounts, roles, nil
+}
+
+// sortedKeys returns the keys of m in order, for summaries.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// printSSOAssignments summarizes what `saws init --from-sso` found.
+func printSSOAssignments(accounts, roles map[string]string) {
+	pkg.LogVerbosef("SSO accounts: %v", sortedKeys(accounts))
+	fmt.Fprintf(os.Stderr, "Found %d account(s) and %d permission set(s): %s\n", len(accounts), len(roles), strings.Join(sortedKeys(roles), ", "))
+}
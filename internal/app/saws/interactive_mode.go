@@ -1,10 +1,11 @@
 package saws
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
-	"strings"
+	"os/signal"
 	"time"
 
 	"saws/internal/pkg"
@@ -12,23 +13,11 @@ import (
 	ststypes "github.com/aws/aws-sdk-go-v2/service/sts/types"
 )
 
-func StartInteractiveSubShell(sCtx *pkg.SelectedContext, creds *ststypes.Credentials) error {
-	pkg.LogVerbosef("Preparing interactive sub-shell environment...")
-	currentEnv := os.Environ()
-	newEnv := []string{}
-
-	for _, e := range currentEnv {
-		if !strings.HasPrefix(e, "AWS_ACCESS_KEY_ID=") &&
-			!strings.HasPrefix(e, "AWS_SECRET_ACCESS_KEY=") &&
-			!strings.HasPrefix(e, "AWS_SESSION_TOKEN=") &&
-			!strings.HasPrefix(e, "AWS_SECURITY_TOKEN=") &&
-			!strings.HasPrefix(e, "AWS_REGION=") &&
-			!strings.HasPrefix(e, "AWS_DEFAULT_REGION=") &&
-			!strings.HasPrefix(e, "AWS_PROFILE=") &&
-			!strings.HasPrefix(e, "SAWS_INFO_") {
-			newEnv = append(newEnv, e)
-		}
-	}
+// sessionEnv is the current environment with any AWS credentials, region,
+// profile, and SAWS_INFO_* replaced by those of the established context,
+// including when its credentials expire.
+func sessionEnv(sCtx *pkg.SelectedContext, creds *ststypes.Credentials) []string {
+	newEnv := withoutEnv(os.Environ(), append(inheritedAWSEnv, "SAWS_INFO_")...)
 
 	newEnv = append(newEnv, fmt.Sprintf("AWS_ACCESS_KEY_ID=%s", *creds.AccessKeyId))
 	newEnv = append(newEnv, fmt.Sprintf("AWS_SECRET_ACCESS_KEY=%s", *creds.SecretAccessKey))
@@ -40,10 +29,85 @@ func StartInteractiveSubShell(sCtx *pkg.SelectedContext, creds *ststypes.Credent
 	newEnv = append(newEnv, fmt.Sprintf("SAWS_INFO_ACCOUNT_ID=%s", sCtx.AccountID))
 	newEnv = append(newEnv, fmt.Sprintf("SAWS_INFO_ROLE_NAME=%s", sCtx.RoleName))
 	newEnv = append(newEnv, fmt.Sprintf("SAWS_INFO_REGION=%s", sCtx.Region))
+	return append(newEnv, expiryEnv(creds)...)
+}
+
+// RunInSessionContext runs one command (argv, not a shell line) with the
+// context's credentials in its environment, like 'aws-vault exec'. It returns
+// the command's exit code.
+func RunInSessionContext(sCtx *pkg.SelectedContext, creds *ststypes.Credentials, argv []string) (int, error) {
+	pkg.LogVerbosef("Running %q in Account=%s(%s), Role=%s, Region=%s", argv, sCtx.AccountName, sCtx.AccountID, sCtx.RoleName, sCtx.Region)
+	return runWithEnv(argv, sessionEnv(sCtx, creds))
+}
+
+// runWithEnv runs argv in the foreground with env and returns its exit code.
+func runWithEnv(argv, env []string) (int, error) {
+	cmd := exec.Command(argv[0], argv[1:]...)
+	cmd.Env = env
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	code, err := runForeground(cmd)
+	if err != nil {
+		return code, fmt.Errorf("failed to run '%s': %w", argv[0], err)
+	}
+	return code, nil
+}
+
+// runForeground runs cmd and returns its exit code, 128+n when signal n
+// killed it, as shells report it. Ctrl+C reaches the command through the
+// terminal; SIGTERM and SIGHUP sent to saws alone, e.g. by a wrapper script
+// or a supervisor, are passed on to the command's process group, and saws
+// waits for it to exit either way.
+func runForeground(cmd *exec.Cmd) (int, error) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, append([]os.Signal{os.Interrupt}, forwardedSignals...)...)
+	defer signal.Stop(signals)
+
+	if err := cmd.Start(); err != nil {
+		return 1, err
+	}
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			select {
+			case sig := <-signals:
+				if sig != os.Interrupt {
+					pkg.LogVerbosef("Passing %v on to the command.", sig)
+					forwardSignal(cmd, sig)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	err := cmd.Wait()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		pkg.LogVerbosef("Command exited with status: %s", exitErr.String())
+		return exitStatus(exitErr), nil
+	}
+	if err != nil {
+		return 1, err
+	}
+	return 0, nil
+}
 
-	shell := os.Getenv("SHELL")
+// StartInteractiveSubShell runs the user's shell with the context's
+// credentials and returns its exit code, e.g. that of its last command or the
+// status given to 'exit', so wrapper scripts see how the session ended.
+func StartInteractiveSubShell(sCtx *pkg.SelectedContext, creds *ststypes.Credentials) (int, error) {
+	pkg.LogVerbosef("Preparing interactive sub-shell environment...")
+	newEnv := sessionEnv(sCtx, creds)
+
+	shell := pkg.SubShell
+	if shell == "" {
+		shell = os.Getenv("SHELL")
+	}
 	if shell == "" {
-		shell = "bash"
+		shell = defaultShell()
 		pkg.LogVerbosef("SHELL environment variable not set, defaulting to %s for sub-shell", shell)
 	}
 
@@ -54,19 +118,21 @@ func StartInteractiveSubShell(sCtx *pkg.SelectedContext, creds *ststypes.Credent
 	}
 	fmt.Fprintln(os.Stderr, "Type 'exit' or press Ctrl+D to end this session.")
 
-	cmd := exec.Command(shell)
-	cmd.Env = newEnv
+	prompt, err := prepareSubShellPrompt(shell)
+	if err != nil {
+		pkg.LogVerbosef("Warning: Could not set up the sub-shell prompt: %v", err)
+	}
+	defer prompt.cleanup()
+
+	cmd := exec.Command(shell, prompt.args...)
+	cmd.Env = append(newEnv, prompt.env...)
 	cmd.Stdin = os.Stdin
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
-	err := cmd.Run()
+	code, err := runForeground(cmd)
 	pkg.LogVerbosef("Interactive sub-shell session ended.")
 	if err != nil {
-		if exitErr, ok := err.(*exec.ExitError); ok {
-			pkg.LogVerbosef("Sub-shell exited with status: %s", exitErr.String())
-		} else {
-			return fmt.Errorf("failed to run interactive sub-shell '%s': %w", shell, err)
-		}
+		return 1, fmt.Errorf("failed to run interactive sub-shell '%s': %w", shell, err)
 	}
-	return nil
+	return code, nil
 }
@@ -0,0 +1,98 @@
+package saws
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"saws/internal/pkg"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+)
+
+// readKmsInput reads the operation input from inputPath, or from stdin when it is empty or "-".
+func readKmsInput(inputPath string) ([]byte, error) {
+	if inputPath == "" || inputPath == "-" {
+		pkg.LogVerbosef("Reading KMS input from stdin...")
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read stdin: %w", err)
+		}
+		return data, nil
+	}
+	data, err := os.ReadFile(inputPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read input file: %w", err)
+	}
+	return data, nil
+}
+
+// decodeCiphertext accepts base64 text as found in logs and CLI output, and
+// falls back to treating the input as a raw ciphertext blob.
+func decodeCiphertext(input []byte) []byte {
+	trimmed := strings.Join(strings.Fields(string(input)), "")
+	if decoded, err := base64.StdEncoding.DecodeString(trimmed); err == nil {
+		return decoded
+	}
+	pkg.LogVerbosef("KMS input is not base64, treating it as a raw ciphertext blob.")
+	return input
+}
+
+// HandleKms handles the logic for the -kms-encrypt and -kms-decrypt modes. Exported.
+// Encryption prints base64 ciphertext; decryption writes the raw plaintext to stdout.
+func HandleKms(
+	ctx context.Context,
+	encrypt bool, keyFlag, inputPath string, // Flags specific to KMS mode
+	accountSelectorFlag, roleFlag, regionFlagFromCmd string, // Common context flags
+) error {
+	if encrypt && keyFlag == "" {
+		return errors.New("a KMS key ID, ARN, or alias is required for encryption (--kms-key)")
+	}
+
+	sCtx, creds, err := pkg.EstablishAWSContextAndAssumeRole(ctx, accountSelectorFlag, roleFlag, regionFlagFromCmd, "KMSHelper")
+	if err != nil {
+		return fmt.Errorf("could not establish AWS context for KMS: %w", err)
+	}
+
+	input, err := readKmsInput(inputPath)
+	if err != nil {
+		return err
+	}
+	if len(input) == 0 {
+		return errors.New("no input provided on stdin or via --in")
+	}
+
+	cfg, err := pkg.LoadAssumedRoleConfig(ctx, creds, sCtx.Region)
+	if err != nil {
+		return err
+	}
+	client := kms.NewFromConfig(cfg)
+
+	if encrypt {
+		pkg.LogVerbosef("Encrypting %d bytes with key %s in Account=%s(%s), Region=%s", len(input), keyFlag, sCtx.AccountName, sCtx.AccountID, sCtx.Region)
+		out, err := client.Encrypt(ctx, &kms.EncryptInput{KeyId: aws.String(keyFlag), Plaintext: input})
+		if err != nil {
+			return fmt.Errorf("kms:Encrypt failed: %w", err)
+		}
+		fmt.Println(base64.StdEncoding.EncodeToString(out.CiphertextBlob))
+		return nil
+	}
+
+	decryptInput := &kms.DecryptInput{CiphertextBlob: decodeCiphertext(input)}
+	if keyFlag != "" {
+		decryptInput.KeyId = aws.String(keyFlag)
+	}
+	pkg.LogVerbosef("Decrypting ciphertext in Account=%s(%s), Region=%s", sCtx.AccountName, sCtx.AccountID, sCtx.Region)
+	out, err := client.Decrypt(ctx, decryptInput)
+	if err != nil {
+		return fmt.Errorf("kms:Decrypt failed: %w", err)
+	}
+	pkg.LogVerbosef("Decrypted with key %s", aws.ToString(out.KeyId))
+	_, err = os.Stdout.Write(out.Plaintext)
+	return err
+}
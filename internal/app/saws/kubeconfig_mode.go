@@ -0,0 +1,255 @@
+package saws
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"saws/internal/pkg"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	ststypes "github.com/aws/aws-sdk-go-v2/service/sts/types"
+	"gopkg.in/yaml.v3"
+)
+
+// kubeconfigUserPrefix names the kubeconfig users saws writes.
+const kubeconfigUserPrefix = "saws-"
+
+// eksCluster is the part of the EKS DescribeCluster reply a kubeconfig needs.
+type eksCluster struct {
+	Arn                  string `json:"arn"`
+	Endpoint             string `json:"endpoint"`
+	CertificateAuthority struct {
+		Data string `json:"data"`
+	} `json:"certificateAuthority"`
+}
+
+// describeEKSCluster calls the EKS DescribeCluster API (GET /clusters/<name>)
+// with a SigV4-signed request. AWS_ENDPOINT_URL_EKS overrides the endpoint, as
+// it does for the SDK's own clients.
+func describeEKSCluster(ctx context.Context, creds *ststypes.Credentials, region, clusterName string) (*eksCluster, error) {
+	endpoint := os.Getenv("AWS_ENDPOINT_URL_EKS")
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://eks.%s.%s", region, pkg.PartitionDNSSuffix(pkg.PartitionForRegion(region)))
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(endpoint, "/")+"/clusters/"+url.PathEscape(clusterName), nil)
+	if err != nil {
+		return nil, err
+	}
+	emptyPayload := sha256.Sum256(nil)
+	signingCreds := aws.Credentials{AccessKeyID: *creds.AccessKeyId, SecretAccessKey: *creds.SecretAccessKey, SessionToken: *creds.SessionToken}
+	if err := v4.NewSigner().SignHTTP(ctx, signingCreds, req, hex.EncodeToString(emptyPayload[:]), "eks", region, time.Now()); err != nil {
+		return nil, fmt.Errorf("could not sign eks:DescribeCluster: %w", err)
+	}
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("eks:DescribeCluster for %s failed: %w", clusterName, err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		var apiErr struct {
+			Message string `json:"message"`
+		}
+		json.Unmarshal(body, &apiErr)
+		return nil, fmt.Errorf("eks:DescribeCluster for %s failed (%s): %s", clusterName, resp.Status, apiErr.Message)
+	}
+	var reply struct {
+		Cluster eksCluster `json:"cluster"`
+	}
+	if err := json.Unmarshal(body, &reply); err != nil {
+		return nil, fmt.Errorf("could not parse eks:DescribeCluster reply for %s: %w", clusterName, err)
+	}
+	if reply.Cluster.Endpoint == "" {
+		return nil, fmt.Errorf("cluster %s has no endpoint yet (is it still being created?)", clusterName)
+	}
+	return &reply.Cluster, nil
+}
+
+// kubeconfigEntry is a named entry of a kubeconfig list; its other fields are kept as read.
+type kubeconfigEntry struct {
+	Name string         `yaml:"name"`
+	Rest map[string]any `yaml:",inline"`
+}
+
+// kubeconfigFile is a kubeconfig, with keys saws does not touch kept as read.
+type kubeconfigFile struct {
+	APIVersion     string            `yaml:"apiVersion"`
+	Kind           string            `yaml:"kind"`
+	Clusters       []kubeconfigEntry `yaml:"clusters"`
+	Contexts       []kubeconfigEntry `yaml:"contexts"`
+	Users          []kubeconfigEntry `yaml:"users"`
+	CurrentContext string            `yaml:"current-context"`
+	Rest           map[string]any    `yaml:",inline"`
+}
+
+// upsertKubeconfigEntry replaces the entry named name in entries, or appends it.
+func upsertKubeconfigEntry(entries []kubeconfigEntry, name string, rest map[string]any) []kubeconfigEntry {
+	for i := range entries {
+		if entries[i].Name == name {
+			entries[i].Rest = rest
+			return entries
+		}
+	}
+	return append(entries, kubeconfigEntry{Name: name, Rest: rest})
+}
+
+// DefaultKubeconfigPath is the first file of KUBECONFIG, else ~/.kube/config.
+func DefaultKubeconfigPath() (string, error) {
+	if paths := filepath.SplitList(os.Getenv("KUBECONFIG")); len(paths) > 0 && paths[0] != "" {
+		return paths[0], nil
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(homeDir, ".kube", "config"), nil
+}
+
+// sawsCommandForKubeconfig is how kubectl should call saws: plain "saws" when
+// that is the binary on PATH, else this executable's absolute path.
+func sawsCommandForKubeconfig() string {
+	self, err := os.Executable()
+	if err != nil {
+		return "saws"
+	}
+	if onPath, err := exec.LookPath("saws"); err == nil {
+		a, errA := filepath.EvalSymlinks(onPath)
+		b, errB := filepath.EvalSymlinks(self)
+		if errA == nil && errB == nil && a == b {
+			return "saws"
+		}
+	}
+	return self
+}
+
+// HandleKubeconfig handles `saws kubeconfig`. Exported.
+// For each eks_clusters entry in names (all of them when names is empty) it
+// looks up the cluster's endpoint and CA under the entry's role and writes a
+// cluster, a context named after the entry, and a user to the kubeconfig at
+// path. The user runs `saws eks-token --eks-cluster <entry>` as its exec
+// credential plugin, so no credentials are stored in the kubeconfig and the
+// role mapping stays in the SAWS config. Entries of the same names are
+// replaced; the rest of the file is left as it was.
+func HandleKubeconfig(ctx context.Context, appCfg *pkg.AppConfig, configFlag, contextFlag, path string, names []string) error {
+	if len(appCfg.EKSClusters) == 0 {
+		return errors.New("no clusters are defined under eks_clusters: in the SAWS config")
+	}
+	if len(names) == 0 {
+		names = sortedKeys(appCfg.EKSClusters)
+	}
+	if path == "" {
+		var err error
+		if path, err = DefaultKubeconfigPath(); err != nil {
+			return fmt.Errorf("could not determine the kubeconfig path: %w", err)
+		}
+	}
+
+	// kubectl may run saws from anywhere, so -config is passed on absolutely.
+	execArgs := []string{"eks-token"}
+	if configFlag != "" {
+		if abs, err := filepath.Abs(configFlag); err == nil && !strings.Contains(configFlag, "://") {
+			configFlag = abs
+		}
+		execArgs = append(execArgs, "-config", configFlag)
+	}
+	if contextFlag != "" {
+		execArgs = append(execArgs, "-context", contextFlag)
+	}
+	command := sawsCommandForKubeconfig()
+
+	// The clusters are looked up before the kubeconfig is locked, so a slow
+	// role or API call does not hold up other saws runs writing it.
+	type clusterContext struct {
+		name, clusterName, role string
+		sCtx                    *pkg.SelectedContext
+		cluster                 *eksCluster
+	}
+	var found []clusterContext
+	for _, name := range names {
+		entry, ok := appCfg.EKSClusters[name]
+		if !ok {
+			return fmt.Errorf("cluster '%s' is not defined under eks_clusters: (available: %s)", name, strings.Join(sortedKeys(appCfg.EKSClusters), ", "))
+		}
+		clusterName := entry.ClusterName(name)
+		sCtx, creds, err := pkg.EstablishAWSContextAndAssumeRole(ctx, entry.Account, entry.Role, entry.Region, "Kubeconfig")
+		if err != nil {
+			return fmt.Errorf("could not establish AWS context for cluster '%s': %w", name, err)
+		}
+		cluster, err := describeEKSCluster(ctx, creds, sCtx.Region, clusterName)
+		if err != nil {
+			return err
+		}
+		found = append(found, clusterContext{name: name, clusterName: clusterName, role: entry.Role, sCtx: sCtx, cluster: cluster})
+	}
+
+	unlock, err := pkg.LockState(path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	kubeconfig := kubeconfigFile{APIVersion: "v1", Kind: "Config"}
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("could not read kubeconfig '%s': %w", path, err)
+	}
+	if err == nil {
+		if err := yaml.Unmarshal(data, &kubeconfig); err != nil {
+			return fmt.Errorf("could not parse kubeconfig '%s': %w", path, err)
+		}
+	}
+
+	for _, c := range found {
+		name, cluster := c.name, c.cluster
+		clusterKey := cluster.Arn
+		if clusterKey == "" {
+			clusterKey = name
+		}
+		userKey := kubeconfigUserPrefix + name
+		kubeconfig.Clusters = upsertKubeconfigEntry(kubeconfig.Clusters, clusterKey, map[string]any{
+			"cluster": map[string]any{"server": cluster.Endpoint, "certificate-authority-data": cluster.CertificateAuthority.Data},
+		})
+		kubeconfig.Users = upsertKubeconfigEntry(kubeconfig.Users, userKey, map[string]any{
+			"user": map[string]any{"exec": map[string]any{
+				"apiVersion":      execCredentialAPIVersion,
+				"command":         command,
+				"args":            append(append([]string(nil), execArgs...), "--eks-cluster", name),
+				"interactiveMode": "Never",
+			}},
+		})
+		kubeconfig.Contexts = upsertKubeconfigEntry(kubeconfig.Contexts, name, map[string]any{
+			"context": map[string]any{"cluster": clusterKey, "user": userKey},
+		})
+		fmt.Fprintf(os.Stderr, "Wrote context '%s' for cluster %s (%s, %s, role %s).\n", name, c.clusterName, c.sCtx.AccountName, c.sCtx.Region, c.role)
+	}
+
+	var out bytes.Buffer
+	encoder := yaml.NewEncoder(&out)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&kubeconfig); err != nil {
+		return err
+	}
+	if err := pkg.WriteFileAtomic(path, out.Bytes(), 0o600); err != nil {
+		return fmt.Errorf("could not write kubeconfig '%s': %w", path, err)
+	}
+	fmt.Fprintf(os.Stderr, "Updated %s. Switch with: kubectl config use-context %s\n", path, names[0])
+	return nil
+}
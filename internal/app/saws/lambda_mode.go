@@ -0,0 +1,58 @@
+package saws
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+
+	"saws/internal/pkg"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	lambdatypes "github.com/aws/aws-sdk-go-v2/service/lambda/types"
+	ststypes "github.com/aws/aws-sdk-go-v2/service/sts/types"
+)
+
+// NewLambdaInvokeTask returns the fan-out task for -lambda, which synchronously
+// invokes functionName with payload in each target and reports the response
+// payload and the tail of the execution log.
+func NewLambdaInvokeTask(functionName string, payload []byte) FanOutTask {
+	return func(ctx context.Context, target FanOutTarget, creds *ststypes.Credentials) FanOutResult {
+		cfg, err := pkg.LoadAssumedRoleConfig(ctx, creds, target.Region)
+		if err != nil {
+			return failedResult(err)
+		}
+		lambdaClient := lambda.NewFromConfig(cfg)
+
+		pkg.LogVerbosef("Invoking Lambda function %s in Account: %s, Region: %s", functionName, target.AccountName, target.Region)
+		out, err := lambdaClient.Invoke(ctx, &lambda.InvokeInput{
+			FunctionName:   aws.String(functionName),
+			InvocationType: lambdatypes.InvocationTypeRequestResponse,
+			LogType:        lambdatypes.LogTypeTail,
+			Payload:        payload,
+		})
+		if err != nil {
+			return failedResult(fmt.Errorf("lambda:Invoke failed for function %s: %w", functionName, err))
+		}
+
+		result := FanOutResult{
+			Status: StatusSuccess,
+			Info:   fmt.Sprintf("Status Code: %d", out.StatusCode),
+		}
+		if out.FunctionError != nil {
+			result.Status = StatusFailed
+			result.ExitCode = 1
+			result.Sections = append(result.Sections, FanOutSection{Label: "FUNCTION ERROR", Body: *out.FunctionError})
+		}
+		result.Sections = append(result.Sections, FanOutSection{Label: "PAYLOAD", Body: string(out.Payload), Output: true})
+		if out.LogResult != nil {
+			logTail, errDecode := base64.StdEncoding.DecodeString(*out.LogResult)
+			if errDecode != nil {
+				pkg.LogVerbosef("Warning: could not decode LogResult for Account: %s, Region: %s: %v", target.AccountName, target.Region, errDecode)
+			} else {
+				result.Sections = append(result.Sections, FanOutSection{Label: "LOG TAIL", Body: string(logTail)})
+			}
+		}
+		return result
+	}
+}
@@ -0,0 +1,118 @@
+package saws
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"saws/internal/pkg"
+)
+
+// Entities `saws list` prints.
+const (
+	ListAccounts = "accounts"
+	ListRoles    = "roles"
+	ListRegions  = "regions"
+	ListGroups   = "groups"
+)
+
+// ListKinds are the operands of `saws list`.
+var ListKinds = []string{ListAccounts, ListRoles, ListRegions, ListGroups}
+
+type listedAccount struct {
+	Name        string   `json:"name"`
+	ID          string   `json:"id"`
+	Partition   string   `json:"partition"`
+	Description string   `json:"description,omitempty"`
+	Aliases     []string `json:"aliases,omitempty"`
+}
+
+type listedRole struct {
+	Name string `json:"name"`
+	Role string `json:"role"`
+}
+
+type listedRegion struct {
+	Region string `json:"region"`
+	Name   string `json:"name,omitempty"`
+}
+
+type listedGroup struct {
+	Name     string   `json:"name"`
+	Accounts []string `json:"accounts"`
+}
+
+// HandleList handles the logic for the `list` mode. Exported.
+// It prints the accounts, roles, common regions, or groups of the loaded
+// config (after includes, the active context, and Organizations discovery),
+// as a table or, with asJSON, as a JSON array.
+func HandleList(appCfg *pkg.AppConfig, kind string, asJSON bool) error {
+	var rows []any
+	var header string
+	var line func(any) string
+	switch kind {
+	case ListAccounts:
+		header = "NAME\tID\tPARTITION\tALIASES\tDESCRIPTION\n"
+		for _, name := range sortedKeys(appCfg.Accounts) {
+			detail := appCfg.AccountDetails[name]
+			rows = append(rows, listedAccount{Name: name, ID: appCfg.Accounts[name], Partition: pkg.AccountPartition(name), Description: detail.Description, Aliases: detail.Aliases})
+		}
+		line = func(row any) string {
+			a := row.(listedAccount)
+			return fmt.Sprintf("%s\t%s\t%s\t%s\t%s\n", a.Name, a.ID, a.Partition, strings.Join(a.Aliases, ","), a.Description)
+		}
+	case ListRoles:
+		header = "NAME\tROLE\n"
+		for _, name := range sortedKeys(appCfg.Roles) {
+			rows = append(rows, listedRole{Name: name, Role: appCfg.Roles[name]})
+		}
+		line = func(row any) string {
+			r := row.(listedRole)
+			return fmt.Sprintf("%s\t%s\n", r.Name, r.Role)
+		}
+	case ListRegions:
+		header = "REGION\tNAME\n"
+		for _, region := range appCfg.CommonRegions {
+			rows = append(rows, listedRegion{Region: region, Name: pkg.RegionName(region)})
+		}
+		line = func(row any) string {
+			r := row.(listedRegion)
+			return fmt.Sprintf("%s\t%s\n", r.Region, r.Name)
+		}
+	case ListGroups:
+		header = "GROUP\tACCOUNTS\n"
+		for _, name := range sortedKeys(appCfg.Groups) {
+			rows = append(rows, listedGroup{Name: name, Accounts: appCfg.Groups[name]})
+		}
+		line = func(row any) string {
+			g := row.(listedGroup)
+			return fmt.Sprintf("%s\t%s\n", g.Name, strings.Join(g.Accounts, ","))
+		}
+	default:
+		return fmt.Errorf("unknown list '%s'; use one of %s", kind, strings.Join(ListKinds, ", "))
+	}
+
+	if asJSON {
+		if rows == nil {
+			rows = []any{}
+		}
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(rows); err != nil {
+			return fmt.Errorf("failed to encode %s as JSON: %w", kind, err)
+		}
+		return nil
+	}
+	if len(rows) == 0 {
+		fmt.Fprintf(os.Stderr, "No %s in the SAWS config.\n", kind)
+		return nil
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprint(w, pkg.ColorRow(os.Stdout, pkg.ColorPlain, header))
+	for _, row := range rows {
+		fmt.Fprint(w, pkg.ColorRow(os.Stdout, pkg.ColorPlain, line(row)))
+	}
+	return w.Flush()
+}
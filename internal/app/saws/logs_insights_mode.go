@@ -0,0 +1,154 @@
+package saws
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"saws/internal/pkg"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
+	logstypes "github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs/types"
+	ststypes "github.com/aws/aws-sdk-go-v2/service/sts/types"
+)
+
+// DefaultLogsQuerySince is how far back `saws logs-insights` queries by default.
+const DefaultLogsQuerySince = time.Hour
+
+// logsQueryPollInterval is how often a running query is checked.
+const logsQueryPollInterval = time.Second
+
+// logsQueryRow is one result row of a Logs Insights query, tagged with its target.
+type logsQueryRow struct {
+	Account string            `json:"account"`
+	Region  string            `json:"region"`
+	Fields  map[string]string `json:"fields"`
+	// order keeps the field order of the query's results.
+	order []string
+}
+
+// runLogsQuery runs query against logGroup in one target and waits for its results.
+func runLogsQuery(ctx context.Context, target FanOutTarget, creds *ststypes.Credentials, logGroup, query string, since time.Duration) ([]logsQueryRow, error) {
+	cfg, err := pkg.LoadAssumedRoleConfig(ctx, creds, target.Region)
+	if err != nil {
+		return nil, err
+	}
+	client := cloudwatchlogs.NewFromConfig(cfg)
+
+	end := time.Now()
+	pkg.LogVerbosef("Starting Logs Insights query on %s in Account: %s, Region: %s", logGroup, target.AccountName, target.Region)
+	started, err := client.StartQuery(ctx, &cloudwatchlogs.StartQueryInput{
+		LogGroupName: aws.String(logGroup),
+		QueryString:  aws.String(query),
+		StartTime:    aws.Int64(end.Add(-since).Unix()),
+		EndTime:      aws.Int64(end.Unix()),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("logs:StartQuery failed for %s: %w", logGroup, err)
+	}
+
+	for {
+		out, err := client.GetQueryResults(ctx, &cloudwatchlogs.GetQueryResultsInput{QueryId: started.QueryId})
+		if err != nil {
+			return nil, fmt.Errorf("logs:GetQueryResults failed: %w", err)
+		}
+		switch out.Status {
+		case logstypes.QueryStatusScheduled, logstypes.QueryStatusRunning:
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(logsQueryPollInterval):
+			}
+			continue
+		case logstypes.QueryStatusComplete:
+		default:
+			return nil, fmt.Errorf("query on %s ended with status %s", logGroup, out.Status)
+		}
+
+		rows := make([]logsQueryRow, 0, len(out.Results))
+		for _, result := range out.Results {
+			row := logsQueryRow{Account: target.AccountName, Region: target.Region, Fields: make(map[string]string)}
+			for _, field := range result {
+				name := aws.ToString(field.Field)
+				if name == "@ptr" {
+					continue
+				}
+				row.Fields[name] = aws.ToString(field.Value)
+				row.order = append(row.order, name)
+			}
+			rows = append(rows, row)
+		}
+		return rows, nil
+	}
+}
+
+// HandleLogsInsights handles the logic for the `logs-insights` mode. Exported.
+// It runs query against logGroup over the last since in every target, waits
+// for the results, and prints them as one table (or JSON) tagged with the
+// account and region, newest first when the query returns @timestamp.
+func HandleLogsInsights(ctx context.Context, baseCfg aws.Config, appCfg *pkg.AppConfig, accountNames, regions []string, roleToAssume, logGroup, query string, since time.Duration, asJSON bool) error {
+	rows, failures, summary := CollectFanOut(ctx, baseCfg, appCfg, accountNames, regions,
+		FanOutOptions{Label: "Logs Insights Mode", RoleToAssume: roleToAssume, SessionName: "LogsInsights"},
+		func(ctx context.Context, target FanOutTarget, creds *ststypes.Credentials) ([]logsQueryRow, error) {
+			return runLogsQuery(ctx, target, creds, logGroup, query, since)
+		})
+
+	sort.SliceStable(rows, func(i, j int) bool {
+		if ti, tj := rows[i].Fields["@timestamp"], rows[j].Fields["@timestamp"]; ti != tj {
+			return ti > tj
+		}
+		if rows[i].Account != rows[j].Account {
+			return rows[i].Account < rows[j].Account
+		}
+		return rows[i].Region < rows[j].Region
+	})
+
+	if asJSON {
+		if rows == nil {
+			rows = []logsQueryRow{}
+		}
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(rows); err != nil {
+			return fmt.Errorf("failed to encode query results as JSON: %w", err)
+		}
+	} else if len(rows) == 0 {
+		fmt.Fprintf(os.Stderr, "No results in %s across %d account/region target(s).\n", logGroup, summary.Succeeded)
+	} else {
+		var columns []string
+		seen := make(map[string]bool)
+		for _, row := range rows {
+			for _, name := range row.order {
+				if !seen[name] {
+					seen[name] = true
+					columns = append(columns, name)
+				}
+			}
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ACCOUNT\tREGION\t"+strings.ToUpper(strings.Join(columns, "\t")))
+		for _, row := range rows {
+			values := make([]string, len(columns))
+			for i, name := range columns {
+				// Multi-line messages would break the table.
+				values[i] = strings.ReplaceAll(strings.TrimSpace(row.Fields[name]), "\n", " ")
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\n", row.Account, row.Region, strings.Join(values, "\t"))
+		}
+		w.Flush()
+	}
+
+	for _, f := range failures {
+		fmt.Fprintf(os.Stderr, "Warning: Could not query Account: %s, Region: %s: %s\n", f.Target.AccountName, f.Target.Region, f.Err)
+	}
+	if len(failures) > 0 {
+		return fmt.Errorf("%d out of %d target(s) could not be queried", len(failures), summary.Total)
+	}
+	return nil
+}
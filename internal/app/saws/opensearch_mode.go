@@ -0,0 +1,191 @@
+package saws
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+
+	"saws/internal/pkg"
+
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/opensearch"
+)
+
+const opensearchDescribeBatchSize = 5
+
+// opensearchDomain is a domain endpoint that Dashboards can be tunneled to.
+type opensearchDomain struct {
+	Name          string
+	Host          string
+	EngineVersion string
+	InVPC         bool
+}
+
+// dashboardsPath returns the Dashboards (or legacy Kibana) path for the engine.
+func (d opensearchDomain) dashboardsPath() string {
+	if strings.HasPrefix(d.EngineVersion, "Elasticsearch_") {
+		return "/_plugin/kibana/"
+	}
+	return "/_dashboards/"
+}
+
+// listOpensearchDomains fetches the endpoints of all domains in the given region.
+func listOpensearchDomains(ctx context.Context, cfg aws.Config) ([]opensearchDomain, error) {
+	client := opensearch.NewFromConfig(cfg)
+	pkg.LogVerbosef("Fetching OpenSearch domains in region %s...", cfg.Region)
+	names, err := client.ListDomainNames(ctx, &opensearch.ListDomainNamesInput{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list OpenSearch domains: %w", err)
+	}
+	domainNames := []string{}
+	for _, info := range names.DomainNames {
+		if info.DomainName != nil {
+			domainNames = append(domainNames, *info.DomainName)
+		}
+	}
+
+	var domains []opensearchDomain
+	for i := 0; i < len(domainNames); i += opensearchDescribeBatchSize {
+		end := i + opensearchDescribeBatchSize
+		if end > len(domainNames) {
+			end = len(domainNames)
+		}
+		out, err := client.DescribeDomains(ctx, &opensearch.DescribeDomainsInput{DomainNames: domainNames[i:end]})
+		if err != nil {
+			return nil, fmt.Errorf("failed to describe OpenSearch domains (starting index %d): %w", i, err)
+		}
+		for _, status := range out.DomainStatusList {
+			if status.DomainName == nil || aws.ToBool(status.Deleted) {
+				continue
+			}
+			d := opensearchDomain{Name: *status.DomainName, EngineVersion: aws.ToString(status.EngineVersion)}
+			if vpcEndpoint, ok := status.Endpoints["vpc"]; ok {
+				d.Host = vpcEndpoint
+				d.InVPC = true
+			} else {
+				d.Host = aws.ToString(status.Endpoint)
+			}
+			if d.Host == "" {
+				pkg.LogVerbosef("Skipping OpenSearch domain %s: no endpoint yet.", d.Name)
+				continue
+			}
+			domains = append(domains, d)
+		}
+	}
+	pkg.LogVerbosef("Finished fetching OpenSearch domains. Total with endpoints: %d", len(domains))
+	sort.Slice(domains, func(i, j int) bool { return domains[i].Name < domains[j].Name })
+	return domains, nil
+}
+
+// HandleOpensearchTunnel handles the logic for the -opensearch mode. Exported.
+func HandleOpensearchTunnel(
+	ctx context.Context,
+	domainFlag, bastionFlag string, localPortFlag int, openFlag bool, // Flags specific to OpenSearch mode
+	accountSelectorFlag, roleFlag, regionFlagFromCmd string, // Common context flags
+) error {
+	pkg.LogVerbosef("Preparing for OpenSearch Dashboards tunnel...")
+	sCtx, creds, err := pkg.EstablishAWSContextAndAssumeRole(ctx, accountSelectorFlag, roleFlag, regionFlagFromCmd, "OpenSearchTunnel")
+	if err != nil {
+		return fmt.Errorf("could not establish AWS context for OpenSearch tunnel: %w", err)
+	}
+	cfg, err := pkg.LoadAssumedRoleConfig(ctx, creds, sCtx.Region)
+	if err != nil {
+		return err
+	}
+
+	domains, err := listOpensearchDomains(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	if len(domains) == 0 {
+		fmt.Fprintf(os.Stderr, "No OpenSearch domains found in Account %s, Region %s.\n", sCtx.AccountID, sCtx.Region)
+		return nil
+	}
+
+	var domain *opensearchDomain
+	if domainFlag != "" {
+		for i := range domains {
+			if domains[i].Name == domainFlag {
+				domain = &domains[i]
+				break
+			}
+		}
+		if domain == nil {
+			return fmt.Errorf("OpenSearch domain '%s' not found in region %s", domainFlag, sCtx.Region)
+		}
+	} else {
+		options := make([]string, len(domains))
+		optionToDomain := make(map[string]*opensearchDomain)
+		for i := range domains {
+			access := "public"
+			if domains[i].InVPC {
+				access = "vpc"
+			}
+			displayStr := fmt.Sprintf("%-28s | %-18s | %s", domains[i].Name, domains[i].EngineVersion, access)
+			options[i] = displayStr
+			optionToDomain[displayStr] = &domains[i]
+		}
+		chosenDisplayStr := ""
+		prompt := &survey.Select{Message: "Choose OpenSearch Domain:", Options: options, PageSize: 15}
+		if err := pkg.AskOne(prompt, &chosenDisplayStr, survey.WithValidator(survey.Required), pkg.WithFuzzyFilter); err != nil {
+			return fmt.Errorf("domain selection failed: %w", err)
+		}
+		domain = optionToDomain[chosenDisplayStr]
+	}
+
+	bastionID := bastionFlag
+	awsCredentials := aws.Credentials{AccessKeyID: *creds.AccessKeyId, SecretAccessKey: *creds.SecretAccessKey, SessionToken: *creds.SessionToken, Source: "SawsAssumedRoleForOpenSearch"}
+	if bastionID == "" {
+		bastionID, err = selectSSMInstance(ctx, awsCredentials, sCtx, "Choose a bastion instance for the tunnel:", SSMInstanceFilter{})
+		if err != nil {
+			return err
+		}
+		if bastionID == "" {
+			return errors.New("no bastion instance available for the tunnel")
+		}
+	} else {
+		bastionID, err = resolveSSMInstance(ctx, awsCredentials, sCtx.Region, bastionID)
+		if err != nil {
+			return err
+		}
+	}
+
+	localPort := int32(localPortFlag)
+	if localPort == 0 {
+		localPort, err = freeLocalPort()
+		if err != nil {
+			return err
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "Opening tunnel 127.0.0.1:%d -> %s:443 via %s...\n", localPort, domain.Host, bastionID)
+	tunnel, err := startSSMTunnel(ctx, creds, sCtx.Region, bastionID, domain.Host, 443, localPort)
+	if err != nil {
+		return err
+	}
+	defer tunnel.Stop()
+
+	dashboardsURL := fmt.Sprintf("https://localhost:%d%s", localPort, domain.dashboardsPath())
+	fmt.Fprintf(os.Stderr, "Dashboards for %s (Account=%s(%s), Region=%s) are available at:\n", domain.Name, sCtx.AccountName, sCtx.AccountID, sCtx.Region)
+	fmt.Println(dashboardsURL)
+	fmt.Fprintln(os.Stderr, "The certificate is issued for the domain endpoint, so expect a browser warning. Press Ctrl+C to close the tunnel.")
+	if openFlag {
+		pkg.OpenBrowser(dashboardsURL)
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt)
+	defer signal.Stop(signals)
+	select {
+	case <-signals:
+		pkg.LogVerbosef("Interrupt received, closing OpenSearch tunnel.")
+	case errExit := <-tunnel.exited:
+		return fmt.Errorf("SSM tunnel exited unexpectedly: %v", errExit)
+	}
+	return nil
+}
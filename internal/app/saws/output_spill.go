@@ -0,0 +1,117 @@
+package saws
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"saws/internal/pkg"
+)
+
+const (
+	// outputSpillBytes is how much output of a target is kept in memory before
+	// the rest goes to a temporary file.
+	outputSpillBytes = 4 << 20
+	// outputPreviewBytes is how much of spilled output is still shown in the
+	// result block.
+	outputPreviewBytes = 64 << 10
+)
+
+// spillWriter collects a target's output in memory up to outputSpillBytes and
+// streams all of it to a temporary file beyond that, keeping only the first
+// outputPreviewBytes in memory. If the file cannot be created it keeps
+// buffering, as before.
+type spillWriter struct {
+	// pattern names the temporary file, e.g. "saws-dev-eu-west-1-stdout-*.log".
+	pattern string
+	buf     bytes.Buffer
+	file    *os.File
+	size    int64
+	err     error
+}
+
+func newSpillWriter(target FanOutTarget, stream string) *spillWriter {
+	return &spillWriter{pattern: fmt.Sprintf("saws-%s-%s-%s-*.log", target.AccountName, target.Region, stream)}
+}
+
+func (w *spillWriter) Write(p []byte) (int, error) {
+	w.size += int64(len(p))
+	if w.file == nil && (w.err != nil || w.buf.Len()+len(p) <= outputSpillBytes) {
+		return w.buf.Write(p)
+	}
+	if w.file == nil {
+		f, err := os.CreateTemp("", strings.ReplaceAll(w.pattern, string(os.PathSeparator), "_"))
+		if err == nil {
+			_, err = f.Write(w.buf.Bytes())
+		}
+		if err != nil {
+			pkg.LogVerbosef("Warning: Could not spill output to a temporary file, keeping it in memory: %v", err)
+			w.err = err
+			if f != nil {
+				f.Close()
+				os.Remove(f.Name())
+			}
+			return w.buf.Write(p)
+		}
+		w.file = f
+		w.buf.Truncate(outputPreviewBytes)
+	}
+	if _, err := w.file.Write(p); err != nil {
+		return 0, fmt.Errorf("could not write output to '%s': %w", w.file.Name(), err)
+	}
+	return len(p), nil
+}
+
+// section closes the file, if any, and returns the collected output as a
+// result section: the output itself, or its beginning with a pointer to the file.
+func (w *spillWriter) section(label string, output bool) FanOutSection {
+	if w.file == nil {
+		return FanOutSection{Label: label, Body: w.buf.String(), Output: output}
+	}
+	w.file.Close()
+	body := fmt.Sprintf("%s\n[saws: %s of output, the first %s shown; the full output is in %s]\n",
+		w.buf.String(), formatBytes(w.size), formatBytes(outputPreviewBytes), w.file.Name())
+	return FanOutSection{Label: label, Body: body, Output: output, File: w.file.Name()}
+}
+
+// formatBytes formats a size as B, KiB, MiB, or GiB.
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit && exp < 2; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMG"[exp])
+}
+
+// copySpilledOutput writes the full spilled output of section to out for -q,
+// redacting it line by line like the in-memory output.
+func copySpilledOutput(out io.Writer, section FanOutSection) error {
+	f, err := os.Open(section.File)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	r := bufio.NewReader(f)
+	for {
+		line, err := r.ReadString('\n')
+		if line != "" {
+			if _, errWrite := io.WriteString(out, pkg.RedactSecrets(line)); errWrite != nil {
+				return errWrite
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
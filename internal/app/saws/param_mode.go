@@ -0,0 +1,135 @@
+package saws
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"text/tabwriter"
+
+	"saws/internal/pkg"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	ssmtypes "github.com/aws/aws-sdk-go-v2/service/ssm/types"
+	ststypes "github.com/aws/aws-sdk-go-v2/service/sts/types"
+)
+
+const (
+	ParamOpGet = "get"
+	ParamOpPut = "put"
+)
+
+// paramRow is one line of the -param result table.
+type paramRow struct {
+	AccountName string
+	Region      string
+	Version     string
+	Type        string
+	// Value is the decrypted value for get and the write status for put.
+	Value string
+}
+
+func getParameterRow(ctx context.Context, client *ssm.Client, target FanOutTarget, name string) (paramRow, error) {
+	row := paramRow{AccountName: target.AccountName, Region: target.Region, Version: "-", Type: "-"}
+	out, err := client.GetParameter(ctx, &ssm.GetParameterInput{Name: aws.String(name), WithDecryption: aws.Bool(true)})
+	if err != nil {
+		var notFound *ssmtypes.ParameterNotFound
+		if errors.As(err, &notFound) {
+			row.Value = "(not found)"
+			return row, nil
+		}
+		return row, fmt.Errorf("ssm:GetParameter failed for %s: %w", name, err)
+	}
+	row.Version = strconv.FormatInt(out.Parameter.Version, 10)
+	row.Type = string(out.Parameter.Type)
+	row.Value = aws.ToString(out.Parameter.Value)
+	return row, nil
+}
+
+func putParameterRow(ctx context.Context, client *ssm.Client, target FanOutTarget, name, value, paramType string) (paramRow, error) {
+	row := paramRow{AccountName: target.AccountName, Region: target.Region, Version: "-", Type: paramType}
+	if row.Type == "" {
+		// Keep the existing type when overwriting; new parameters default to String.
+		existing, err := getParameterRow(ctx, client, target, name)
+		if err != nil {
+			return row, err
+		}
+		row.Type = string(ssmtypes.ParameterTypeString)
+		if existing.Type != "-" {
+			row.Type = existing.Type
+		}
+	}
+	out, err := client.PutParameter(ctx, &ssm.PutParameterInput{
+		Name:      aws.String(name),
+		Value:     aws.String(value),
+		Type:      ssmtypes.ParameterType(row.Type),
+		Overwrite: aws.Bool(true),
+	})
+	if err != nil {
+		return row, fmt.Errorf("ssm:PutParameter failed for %s: %w", name, err)
+	}
+	row.Version = strconv.FormatInt(out.Version, 10)
+	row.Value = "written"
+	return row, nil
+}
+
+// HandleParam handles the logic for the -param mode. Exported.
+// op is ParamOpGet or ParamOpPut; value and paramType are only used by put.
+func HandleParam(
+	ctx context.Context, baseCfg aws.Config, appCfg *pkg.AppConfig, accountNames, regions []string, roleToAssume string,
+	op, name, value, paramType string,
+) error {
+	if op != ParamOpGet && op != ParamOpPut {
+		return fmt.Errorf("unknown -param operation '%s' (expected '%s' or '%s')", op, ParamOpGet, ParamOpPut)
+	}
+
+	rows, failures, summary := CollectFanOut(ctx, baseCfg, appCfg, accountNames, regions,
+		FanOutOptions{Label: "Param Mode", RoleToAssume: roleToAssume, SessionName: "ParamStore"},
+		func(ctx context.Context, target FanOutTarget, creds *ststypes.Credentials) ([]paramRow, error) {
+			cfg, err := pkg.LoadAssumedRoleConfig(ctx, creds, target.Region)
+			if err != nil {
+				return nil, err
+			}
+			client := ssm.NewFromConfig(cfg)
+			var row paramRow
+			if op == ParamOpGet {
+				row, err = getParameterRow(ctx, client, target, name)
+			} else {
+				row, err = putParameterRow(ctx, client, target, name, value, paramType)
+			}
+			if err != nil {
+				return nil, err
+			}
+			return []paramRow{row}, nil
+		})
+
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].AccountName != rows[j].AccountName {
+			return rows[i].AccountName < rows[j].AccountName
+		}
+		return rows[i].Region < rows[j].Region
+	})
+
+	valueHeader := "VALUE"
+	if op == ParamOpPut {
+		valueHeader = "STATUS"
+	}
+	fmt.Fprintf(os.Stderr, "Parameter: %s\n", name)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "ACCOUNT\tREGION\tVERSION\tTYPE\t%s\n", valueHeader)
+	for _, r := range rows {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", r.AccountName, r.Region, r.Version, r.Type, r.Value)
+	}
+	w.Flush()
+
+	for _, f := range failures {
+		fmt.Fprintf(os.Stderr, "Warning: Parameter %s failed for Account: %s, Region: %s: %s\n", op, f.Target.AccountName, f.Target.Region, f.Err)
+	}
+	if len(failures) > 0 {
+		return fmt.Errorf("%d out of %d target(s) failed", len(failures), summary.Total)
+	}
+	return nil
+}
@@ -0,0 +1,163 @@
+package saws
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+
+	"saws/internal/pkg"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	ssmtypes "github.com/aws/aws-sdk-go-v2/service/ssm/types"
+	ststypes "github.com/aws/aws-sdk-go-v2/service/sts/types"
+)
+
+// patchComplianceRow is one line of the patch-compliance table.
+type patchComplianceRow struct {
+	Account string `json:"account"`
+	Region  string `json:"region"`
+	// Managed counts the instances SSM knows, Offline those whose agent stopped
+	// checking in, and MissingAgent running EC2 instances SSM does not know.
+	Managed                 int `json:"managed"`
+	Offline                 int `json:"offline"`
+	MissingAgent            int `json:"missing_agent"`
+	PatchCompliant          int `json:"patch_compliant"`
+	PatchNonCompliant       int `json:"patch_non_compliant"`
+	AssociationCompliant    int `json:"association_compliant"`
+	AssociationNonCompliant int `json:"association_non_compliant"`
+}
+
+// collectPatchCompliance builds the patch-compliance row of one target.
+func collectPatchCompliance(ctx context.Context, target FanOutTarget, creds *ststypes.Credentials) ([]patchComplianceRow, error) {
+	cfg, err := pkg.LoadAssumedRoleConfig(ctx, creds, target.Region)
+	if err != nil {
+		return nil, err
+	}
+	ssmClient := ssm.NewFromConfig(cfg)
+	row := patchComplianceRow{Account: target.AccountName, Region: target.Region}
+
+	summaries := ssm.NewListComplianceSummariesPaginator(ssmClient, &ssm.ListComplianceSummariesInput{})
+	for summaries.HasMorePages() {
+		page, err := summaries.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("ssm:ListComplianceSummaries failed: %w", err)
+		}
+		for _, item := range page.ComplianceSummaryItems {
+			compliant, nonCompliant := 0, 0
+			if item.CompliantSummary != nil {
+				compliant = int(item.CompliantSummary.CompliantCount)
+			}
+			if item.NonCompliantSummary != nil {
+				nonCompliant = int(item.NonCompliantSummary.NonCompliantCount)
+			}
+			switch aws.ToString(item.ComplianceType) {
+			case "Patch":
+				row.PatchCompliant, row.PatchNonCompliant = compliant, nonCompliant
+			case "Association":
+				row.AssociationCompliant, row.AssociationNonCompliant = compliant, nonCompliant
+			}
+		}
+	}
+
+	managed := make(map[string]bool)
+	instances := ssm.NewDescribeInstanceInformationPaginator(ssmClient, &ssm.DescribeInstanceInformationInput{})
+	for instances.HasMorePages() {
+		page, err := instances.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("ssm:DescribeInstanceInformation failed: %w", err)
+		}
+		for _, info := range page.InstanceInformationList {
+			managed[aws.ToString(info.InstanceId)] = true
+			if info.PingStatus != ssmtypes.PingStatusOnline {
+				row.Offline++
+			}
+		}
+	}
+	row.Managed = len(managed)
+
+	running := ec2.NewDescribeInstancesPaginator(ec2.NewFromConfig(cfg), &ec2.DescribeInstancesInput{
+		Filters: []ec2types.Filter{{Name: aws.String("instance-state-name"), Values: []string{"running"}}},
+	})
+	for running.HasMorePages() {
+		page, err := running.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("ec2:DescribeInstances failed: %w", err)
+		}
+		for _, reservation := range page.Reservations {
+			for _, instance := range reservation.Instances {
+				if !managed[aws.ToString(instance.InstanceId)] {
+					row.MissingAgent++
+				}
+			}
+		}
+	}
+	return []patchComplianceRow{row}, nil
+}
+
+// HandlePatchCompliance handles the logic for the `patch-compliance` mode. Exported.
+// It prints one table of patch and association compliance counts, offline SSM
+// agents, and running instances without an agent for every target.
+func HandlePatchCompliance(ctx context.Context, baseCfg aws.Config, appCfg *pkg.AppConfig, accountNames, regions []string, roleToAssume string, asJSON bool) error {
+	rows, failures, summary := CollectFanOut(ctx, baseCfg, appCfg, accountNames, regions,
+		FanOutOptions{Label: "Patch Compliance Mode", RoleToAssume: roleToAssume, SessionName: "PatchCompliance"},
+		collectPatchCompliance)
+
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].Account != rows[j].Account {
+			return rows[i].Account < rows[j].Account
+		}
+		return rows[i].Region < rows[j].Region
+	})
+
+	if asJSON {
+		if rows == nil {
+			rows = []patchComplianceRow{}
+		}
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(rows); err != nil {
+			return fmt.Errorf("failed to encode compliance rows as JSON: %w", err)
+		}
+	} else {
+		var total patchComplianceRow
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprint(w, pkg.ColorRow(os.Stdout, pkg.ColorPlain, "ACCOUNT\tREGION\tMANAGED\tOFFLINE\tNO AGENT\tPATCH OK\tPATCH NON-COMPLIANT\tASSOC OK\tASSOC NON-COMPLIANT\n"))
+		for _, r := range rows {
+			color := pkg.ColorPlain
+			switch {
+			case r.PatchNonCompliant > 0 || r.AssociationNonCompliant > 0:
+				color = pkg.ColorRed
+			case r.MissingAgent > 0 || r.Offline > 0:
+				color = pkg.ColorYellow
+			}
+			fmt.Fprint(w, pkg.ColorRow(os.Stdout, color, fmt.Sprintf("%s\t%s\t%d\t%d\t%d\t%d\t%d\t%d\t%d\n",
+				r.Account, r.Region, r.Managed, r.Offline, r.MissingAgent, r.PatchCompliant, r.PatchNonCompliant, r.AssociationCompliant, r.AssociationNonCompliant)))
+			total.Managed += r.Managed
+			total.Offline += r.Offline
+			total.MissingAgent += r.MissingAgent
+			total.PatchCompliant += r.PatchCompliant
+			total.PatchNonCompliant += r.PatchNonCompliant
+			total.AssociationCompliant += r.AssociationCompliant
+			total.AssociationNonCompliant += r.AssociationNonCompliant
+		}
+		if len(rows) > 1 {
+			fmt.Fprint(w, pkg.ColorRow(os.Stdout, pkg.ColorPlain, fmt.Sprintf("TOTAL\t\t%d\t%d\t%d\t%d\t%d\t%d\t%d\n",
+				total.Managed, total.Offline, total.MissingAgent, total.PatchCompliant, total.PatchNonCompliant, total.AssociationCompliant, total.AssociationNonCompliant)))
+		}
+		w.Flush()
+	}
+
+	for _, f := range failures {
+		fmt.Fprintf(os.Stderr, "Warning: Could not summarize compliance for Account: %s, Region: %s: %s\n", f.Target.AccountName, f.Target.Region, f.Err)
+	}
+	if len(failures) > 0 {
+		return fmt.Errorf("%d out of %d target(s) could not be queried", len(failures), summary.Total)
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package saws
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+
+	"saws/internal/pkg"
+)
+
+// pluginPrefix names plugin executables: `saws deploy` runs saws-deploy from PATH.
+const pluginPrefix = "saws-"
+
+// FindPlugin returns the path of the plugin executable for `saws <name>`.
+func FindPlugin(name string) (string, bool) {
+	if name == "" || strings.ContainsAny(name, `/\`) || strings.HasPrefix(name, "-") {
+		return "", false
+	}
+	path, err := exec.LookPath(pluginPrefix + name)
+	return path, err == nil
+}
+
+// PluginNames lists the plugins on PATH, for the usage text.
+func PluginNames() []string {
+	seen := make(map[string]bool)
+	var names []string
+	for _, dir := range filepath.SplitList(os.Getenv("PATH")) {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			name, ok := strings.CutPrefix(entry.Name(), pluginPrefix)
+			if !ok || entry.IsDir() {
+				continue
+			}
+			if runtime.GOOS == "windows" {
+				name = strings.TrimSuffix(name, filepath.Ext(name))
+			}
+			if _, found := FindPlugin(name); found && !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// HandlePlugin handles `saws <plugin>`. Exported.
+// It establishes the account/role/region context like -e and runs the plugin
+// with args. The plugin gets the credentials and SAWS_INFO_* as in the -e
+// sub-shell, including SAWS_INFO_EXPIRES_AT, plus SAWS_PLUGIN and SAWS_CONFIG,
+// and keeps the terminal for its own prompts. It returns the plugin's exit code.
+func HandlePlugin(ctx context.Context, pluginPath, name string, args []string, configPath, accountSelectorFlag, roleFlag, regionFlagFromCmd string) (int, error) {
+	sCtx, creds, err := pkg.EstablishAWSContextAndAssumeRole(ctx, accountSelectorFlag, roleFlag, regionFlagFromCmd, "Plugin")
+	if err != nil {
+		return 1, fmt.Errorf("could not establish AWS context for plugin '%s': %w", name, err)
+	}
+	env := append(sessionEnv(sCtx, creds), "SAWS_PLUGIN="+name)
+	if configPath != "" && !strings.Contains(configPath, "://") {
+		if abs, err := filepath.Abs(configPath); err == nil {
+			configPath = abs
+		}
+	}
+	env = append(withoutEnv(env, "SAWS_CONFIG"), "SAWS_CONFIG="+configPath)
+
+	pkg.LogVerbosef("Running plugin %s (%s) in Account=%s(%s), Role=%s, Region=%s", name, pluginPath, sCtx.AccountName, sCtx.AccountID, sCtx.RoleName, sCtx.Region)
+	return runWithEnv(append([]string{pluginPath}, args...), env)
+}
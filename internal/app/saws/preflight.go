@@ -0,0 +1,263 @@
+package saws
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"saws/internal/pkg"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	iamtypes "github.com/aws/aws-sdk-go-v2/service/iam/types"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	ststypes "github.com/aws/aws-sdk-go-v2/service/sts/types"
+)
+
+// iamActionPattern is the shape of an IAM action such as ec2:DescribeInstances.
+var iamActionPattern = regexp.MustCompile(`^[a-z0-9-]+:[A-Za-z0-9*]+$`)
+
+// cliServicePrefixes maps AWS CLI service names to IAM action prefixes where
+// they differ.
+var cliServicePrefixes = map[string]string{
+	"s3api":                    "s3",
+	"s3control":                "s3",
+	"elbv2":                    "elasticloadbalancing",
+	"elb":                      "elasticloadbalancing",
+	"configservice":            "config",
+	"stepfunctions":            "states",
+	"opensearch":               "es",
+	"emr":                      "elasticmapreduce",
+	"apigatewayv2":             "apigateway",
+	"resourcegroupstaggingapi": "tag",
+	"sesv2":                    "ses",
+	"dynamodbstreams":          "dynamodb",
+	"sso-admin":                "sso",
+}
+
+// cliActionOverrides maps API operations to the IAM action that authorizes
+// them where the names differ.
+var cliActionOverrides = map[string][]string{
+	"s3:ListObjects":   {"s3:ListBucket"},
+	"s3:ListObjectsV2": {"s3:ListBucket"},
+	"s3:HeadObject":    {"s3:GetObject"},
+	"s3:HeadBucket":    {"s3:ListBucket"},
+	// The high-level `aws s3` commands.
+	"s3:Ls":   {"s3:ListAllMyBuckets", "s3:ListBucket"},
+	"s3:Cp":   {"s3:GetObject", "s3:PutObject"},
+	"s3:Mv":   {"s3:GetObject", "s3:PutObject", "s3:DeleteObject"},
+	"s3:Rm":   {"s3:DeleteObject"},
+	"s3:Sync": {"s3:ListBucket", "s3:GetObject", "s3:PutObject"},
+	"s3:Mb":   {"s3:CreateBucket"},
+	"s3:Rb":   {"s3:DeleteBucket"},
+	// Callable by every principal; simulating it only reports an implicit deny.
+	"sts:GetCallerIdentity": nil,
+}
+
+// cliValueOptions are AWS CLI global options that take a separate value.
+var cliValueOptions = map[string]bool{
+	"--region": true, "--profile": true, "--output": true, "--query": true, "--endpoint-url": true,
+	"--color": true, "--ca-bundle": true, "--cli-read-timeout": true, "--cli-connect-timeout": true,
+}
+
+// CommandActions extracts the IAM actions implied by the `aws <service>
+// <operation>` calls of a shell command, e.g. "aws ec2 describe-instances"
+// gives ec2:DescribeInstances. Calls hidden in scripts or variables are not
+// seen; -preflight-actions names those.
+func CommandActions(command string) []string {
+	tokens := strings.FieldsFunc(command, func(r rune) bool {
+		return r == ' ' || r == '\t' || r == '\n' || r == ';' || r == '|' || r == '&' || r == '(' || r == ')' || r == '`' || r == '"' || r == '\''
+	})
+	var actions []string
+	for i := 0; i < len(tokens); i++ {
+		if tokens[i] != "aws" && !strings.HasSuffix(tokens[i], "/aws") {
+			continue
+		}
+		var words []string
+		for j := i + 1; j < len(tokens) && len(words) < 2; j++ {
+			if strings.HasPrefix(tokens[j], "-") {
+				if cliValueOptions[tokens[j]] {
+					j++
+				}
+				continue
+			}
+			words = append(words, tokens[j])
+		}
+		if len(words) < 2 || words[1] == "wait" || words[1] == "help" {
+			continue
+		}
+		prefix := words[0]
+		if mapped, ok := cliServicePrefixes[prefix]; ok {
+			prefix = mapped
+		}
+		var operation strings.Builder
+		for _, part := range strings.Split(words[1], "-") {
+			if part != "" {
+				operation.WriteString(strings.ToUpper(part[:1]) + part[1:])
+			}
+		}
+		action := prefix + ":" + operation.String()
+		mapped, ok := cliActionOverrides[action]
+		if !ok {
+			mapped = []string{action}
+		}
+		for _, a := range mapped {
+			if !slices.Contains(actions, a) {
+				actions = append(actions, a)
+			}
+		}
+	}
+	return actions
+}
+
+// ParseActionList splits a -preflight-actions value into IAM actions.
+func ParseActionList(value string) ([]string, error) {
+	var actions []string
+	for _, action := range strings.Split(value, ",") {
+		action = strings.TrimSpace(action)
+		if action == "" {
+			continue
+		}
+		if !iamActionPattern.MatchString(action) {
+			return nil, fmt.Errorf("'%s' is not an IAM action like ec2:DescribeInstances", action)
+		}
+		if !slices.Contains(actions, action) {
+			actions = append(actions, action)
+		}
+	}
+	return actions, nil
+}
+
+// preflightRow is the simulation outcome for the role in one account.
+type preflightRow struct {
+	Account string
+	// Denied maps each denied action to its decision (implicitDeny, explicitDeny).
+	Denied map[string]string
+	// SimulationErr is set when the role may not call iam:SimulatePrincipalPolicy.
+	SimulationErr string
+}
+
+// simulateRoleActions simulates actions for roleToAssume in one account with
+// the role's own credentials.
+func simulateRoleActions(ctx context.Context, target FanOutTarget, creds *ststypes.Credentials, roleToAssume string, actions []string) ([]preflightRow, error) {
+	cfg, err := pkg.LoadAssumedRoleConfig(ctx, creds, target.Region)
+	if err != nil {
+		return nil, err
+	}
+	roleArn := fmt.Sprintf("arn:%s:iam::%s:role/%s", pkg.AccountPartition(target.AccountName), target.AccountID, roleToAssume)
+	if parsed, ok := pkg.ParseRoleARN(roleToAssume); ok {
+		roleArn = parsed.ARN
+	}
+
+	row := preflightRow{Account: target.AccountName, Denied: make(map[string]string)}
+	if _, ok := pkg.SSOPermissionSet(roleToAssume); ok {
+		if roleArn, err = assumedRoleARN(ctx, cfg); err != nil {
+			row.SimulationErr = pkg.RedactSecrets(err.Error())
+			return []preflightRow{row}, nil
+		}
+	}
+	paginator := iam.NewSimulatePrincipalPolicyPaginator(iam.NewFromConfig(cfg), &iam.SimulatePrincipalPolicyInput{
+		PolicySourceArn: aws.String(roleArn),
+		ActionNames:     actions,
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			pkg.LogVerbosef("Preflight: iam:SimulatePrincipalPolicy failed in %s: %v", target.AccountName, err)
+			row.SimulationErr = pkg.RedactSecrets(err.Error())
+			return []preflightRow{row}, nil
+		}
+		for _, result := range page.EvaluationResults {
+			if result.EvalDecision != iamtypes.PolicyEvaluationDecisionTypeAllowed {
+				row.Denied[aws.ToString(result.EvalActionName)] = string(result.EvalDecision)
+			}
+		}
+	}
+	return []preflightRow{row}, nil
+}
+
+// assumedRoleARN returns the ARN, with its path, of the role whose session cfg
+// holds, e.g. the AWSReservedSSO_<name>_<hash> role of a permission set.
+func assumedRoleARN(ctx context.Context, cfg aws.Config) (string, error) {
+	identity, err := sts.NewFromConfig(cfg).GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+	if err != nil {
+		return "", fmt.Errorf("sts:GetCallerIdentity failed: %w", err)
+	}
+	// arn:<partition>:sts::<account>:assumed-role/<role name>/<session name>
+	parts := strings.Split(aws.ToString(identity.Arn), "/")
+	if len(parts) != 3 || !strings.HasSuffix(parts[0], ":assumed-role") {
+		return "", fmt.Errorf("%s is not a role session", aws.ToString(identity.Arn))
+	}
+	role, err := iam.NewFromConfig(cfg).GetRole(ctx, &iam.GetRoleInput{RoleName: aws.String(parts[1])})
+	if err != nil {
+		return "", fmt.Errorf("iam:GetRole failed for %s: %w", parts[1], err)
+	}
+	return aws.ToString(role.Role.Arn), nil
+}
+
+// preflightRegions picks one region per partition among regions; IAM is
+// global, so each account needs to be simulated once.
+func preflightRegions(regions []string) []string {
+	var picked []string
+	seen := make(map[string]bool)
+	for _, region := range regions {
+		if partition := pkg.PartitionForRegion(region); !seen[partition] {
+			seen[partition] = true
+			picked = append(picked, region)
+		}
+	}
+	return picked
+}
+
+// RunPreflight simulates actions for roleToAssume in every account with
+// iam:SimulatePrincipalPolicy before a fan-out, and prints the accounts where
+// any of them would be denied. It fails when any account denies an action or
+// the role cannot be assumed there; accounts where the role may not run the
+// simulation are only warned about.
+func RunPreflight(ctx context.Context, baseCfg aws.Config, appCfg *pkg.AppConfig, accountNames, regions []string, roleToAssume string, actions []string) error {
+	fmt.Fprintf(os.Stderr, "Preflight: Simulating %s for role %s in %d account(s)...\n", strings.Join(actions, ", "), roleToAssume, len(accountNames))
+	rows, failures, summary := CollectFanOut(ctx, baseCfg, appCfg, accountNames, preflightRegions(regions),
+		FanOutOptions{Label: "Preflight", RoleToAssume: roleToAssume, SessionName: "Preflight"},
+		func(ctx context.Context, target FanOutTarget, creds *ststypes.Credentials) ([]preflightRow, error) {
+			return simulateRoleActions(ctx, target, creds, roleToAssume, actions)
+		})
+	sort.Slice(rows, func(i, j int) bool { return rows[i].Account < rows[j].Account })
+
+	denied := 0
+	w := tabwriter.NewWriter(os.Stderr, 0, 0, 2, ' ', 0)
+	for _, row := range rows {
+		if row.SimulationErr != "" {
+			fmt.Fprintf(os.Stderr, "Warning: Preflight could not simulate in %s: %s\n", row.Account, row.SimulationErr)
+			continue
+		}
+		if len(row.Denied) == 0 {
+			continue
+		}
+		if denied == 0 {
+			fmt.Fprint(w, pkg.ColorRow(os.Stderr, pkg.ColorPlain, "ACCOUNT\tDENIED ACTIONS\n"))
+		}
+		denied++
+		var entries []string
+		for _, action := range actions {
+			if decision, ok := row.Denied[action]; ok {
+				entries = append(entries, fmt.Sprintf("%s (%s)", action, decision))
+			}
+		}
+		fmt.Fprint(w, pkg.ColorRow(os.Stderr, pkg.ColorRed, fmt.Sprintf("%s\t%s\n", row.Account, strings.Join(entries, ", "))))
+	}
+	w.Flush()
+
+	for _, f := range failures {
+		fmt.Fprintf(os.Stderr, "Preflight: Could not check %s: %s\n", f.Target.AccountName, f.Err)
+	}
+	if denied > 0 || len(failures) > 0 {
+		return fmt.Errorf("%d out of %d account(s) would deny the command and %d could not be checked; fix the role's policies, narrow -s, or run without -preflight", denied, summary.Total, len(failures))
+	}
+	fmt.Fprintf(os.Stderr, "Preflight: All %d account(s) allow the simulated actions.\n", summary.Total)
+	return nil
+}
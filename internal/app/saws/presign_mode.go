@@ -0,0 +1,83 @@
+package saws
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"saws/internal/pkg"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// DefaultPresignExpires is how long a `saws presign` URL is valid by default.
+const DefaultPresignExpires = time.Hour
+
+// maxPresignExpires is the longest validity SigV4 allows for a presigned URL.
+const maxPresignExpires = 7 * 24 * time.Hour
+
+// HandlePresign handles the logic for the `presign` mode. Exported.
+// It signs a GET (or, with put, a PUT) URL for an S3 object with the role
+// assumed in the resolved account and prints it on stdout. A presigned URL
+// stops working when the credentials that signed it expire, so the user is
+// warned when expires outlasts the assumed-role session.
+func HandlePresign(
+	ctx context.Context,
+	s3URL string, expires time.Duration, put bool, // Arguments specific to presign
+	accountSelectorFlag, roleFlag, regionFlagFromCmd string, // Common context flags
+) error {
+	loc, err := parseS3URL(s3URL)
+	if err != nil {
+		return err
+	}
+	if loc.Key == "" || strings.HasSuffix(loc.Key, "/") {
+		return fmt.Errorf("'%s' must name an object", s3URL)
+	}
+	if expires <= 0 || expires > maxPresignExpires {
+		return fmt.Errorf("-expires must be between 1s and %s, got %s", maxPresignExpires, expires)
+	}
+
+	sCtx, creds, err := pkg.EstablishAWSContextAndAssumeRole(ctx, accountSelectorFlag, roleFlag, regionFlagFromCmd, "Presign")
+	if err != nil {
+		return fmt.Errorf("could not establish AWS context for presigning: %w", err)
+	}
+	client, err := s3ClientFor(ctx, creds, pkg.ContextPartition(sCtx), loc.Bucket)
+	if err != nil {
+		return err
+	}
+	presigner := s3.NewPresignClient(client, s3.WithPresignExpires(expires))
+
+	method := "GET"
+	var url string
+	if put {
+		method = "PUT"
+		req, err := presigner.PresignPutObject(ctx, &s3.PutObjectInput{Bucket: aws.String(loc.Bucket), Key: aws.String(loc.Key)})
+		if err != nil {
+			return fmt.Errorf("failed to presign PUT for %s: %w", loc, err)
+		}
+		url = req.URL
+	} else {
+		if _, err := client.HeadObject(ctx, &s3.HeadObjectInput{Bucket: aws.String(loc.Bucket), Key: aws.String(loc.Key)}); err != nil {
+			return fmt.Errorf("s3:HeadObject failed for %s: %w", loc, err)
+		}
+		req, err := presigner.PresignGetObject(ctx, &s3.GetObjectInput{Bucket: aws.String(loc.Bucket), Key: aws.String(loc.Key)})
+		if err != nil {
+			return fmt.Errorf("failed to presign GET for %s: %w", loc, err)
+		}
+		url = req.URL
+	}
+
+	validUntil := time.Now().Add(expires)
+	if creds.Expiration != nil && creds.Expiration.Before(validUntil) {
+		fmt.Fprintf(os.Stderr, "Warning: The %s session expires at %s, so the URL stops working then rather than after %s.\n",
+			sCtx.RoleName, creds.Expiration.Local().Format(time.RFC3339), expires)
+		validUntil = *creds.Expiration
+	}
+	fmt.Fprintf(os.Stderr, "Presigned %s for %s (Account: %s, Role: %s), valid until %s:\n",
+		method, loc, sCtx.AccountName, sCtx.RoleName, validUntil.Local().Format(time.RFC3339))
+	fmt.Println(url)
+	return nil
+}
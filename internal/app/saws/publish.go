@@ -0,0 +1,256 @@
+package saws
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"saws/internal/pkg"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+	"github.com/aws/aws-sdk-go-v2/service/eventbridge"
+	ebtypes "github.com/aws/aws-sdk-go-v2/service/eventbridge/types"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	snstypes "github.com/aws/aws-sdk-go-v2/service/sns/types"
+)
+
+const (
+	// RunEventSource and RunEventDetailType identify saws run events on an
+	// EventBridge bus, for rules such as {"source": ["saws"]}.
+	RunEventSource     = "saws"
+	RunEventDetailType = "saws Run Completed"
+
+	// maxRunEventError bounds each target's error text, and maxRunEventCommand
+	// the command, as SNS messages and EventBridge events are limited to 256 KB.
+	maxRunEventError   = 1024
+	maxRunEventCommand = 4096
+	// maxRunEventBytes is the size NewRunEvent keeps the JSON of an event
+	// under, leaving room for the SNS subject and attributes or the
+	// EventBridge source and detail-type within the 256 KB.
+	maxRunEventBytes = 240 * 1024
+)
+
+// RunEvent is what -publish sends after a run: who ran what, where, and how it went.
+type RunEvent struct {
+	Mode            string           `json:"mode"`
+	Command         string           `json:"command,omitempty"`
+	Role            string           `json:"role"`
+	User            string           `json:"user"`
+	Host            string           `json:"host"`
+	Started         time.Time        `json:"started"`
+	DurationSeconds float64          `json:"duration_seconds"`
+	Status          string           `json:"status"`
+	Total           int              `json:"total"`
+	Succeeded       int64            `json:"succeeded"`
+	Failed          int64            `json:"failed"`
+	Targets         []RunEventTarget `json:"targets,omitempty"`
+	// OmittedSucceeded and OmittedFailed count the targets left out of
+	// Targets to keep the event within maxRunEventBytes.
+	OmittedSucceeded int `json:"omitted_succeeded,omitempty"`
+	OmittedFailed    int `json:"omitted_failed,omitempty"`
+}
+
+// RunEventTarget is the result of one account/region in a RunEvent.
+type RunEventTarget struct {
+	Account         string  `json:"account"`
+	AccountID       string  `json:"account_id"`
+	Region          string  `json:"region"`
+	Status          string  `json:"status"`
+	ExitCode        int     `json:"exit_code"`
+	DurationSeconds float64 `json:"duration_seconds"`
+	Error           string  `json:"error,omitempty"`
+}
+
+// NewRunEvent describes a finished run of mode. With withTargets, the result of
+// every target is included, sorted by account and region, as far as the event
+// stays within maxRunEventBytes: failures are kept before successes, and the
+// targets left out are counted instead. The command is redacted like the history.
+func NewRunEvent(mode, command, role string, summary FanOutSummary, withTargets bool) RunEvent {
+	event := RunEvent{
+		Mode:            mode,
+		Command:         truncate(pkg.RedactSecrets(command), maxRunEventCommand),
+		Role:            role,
+		User:            pkg.CurrentUser(),
+		Started:         summary.Started.UTC().Truncate(time.Second),
+		DurationSeconds: summary.Duration.Round(time.Millisecond).Seconds(),
+		Status:          StatusSuccess,
+		Total:           summary.Total,
+		Succeeded:       summary.Succeeded,
+		Failed:          summary.Failed(),
+	}
+	event.Host, _ = os.Hostname()
+	if event.Failed > 0 {
+		event.Status = StatusFailed
+	}
+	if !withTargets {
+		return event
+	}
+	for _, outcome := range summary.Outcomes {
+		target := RunEventTarget{
+			Account:         outcome.Target.AccountName,
+			AccountID:       outcome.Target.AccountID,
+			Region:          outcome.Target.Region,
+			Status:          outcome.Result.Status,
+			ExitCode:        outcome.Result.ExitCode,
+			DurationSeconds: outcome.Result.Duration.Round(time.Millisecond).Seconds(),
+		}
+		for _, section := range outcome.Result.Sections {
+			if section.Label == "ERROR" {
+				target.Error = truncate(strings.TrimSpace(section.Body), maxRunEventError)
+			}
+		}
+		event.Targets = append(event.Targets, target)
+	}
+	sort.Slice(event.Targets, func(i, j int) bool {
+		if event.Targets[i].Account != event.Targets[j].Account {
+			return event.Targets[i].Account < event.Targets[j].Account
+		}
+		return event.Targets[i].Region < event.Targets[j].Region
+	})
+	fitRunEvent(&event)
+	return event
+}
+
+// fitRunEvent drops targets from event until its JSON fits maxRunEventBytes,
+// keeping failed targets first and the rest in their order.
+func fitRunEvent(event *RunEvent) {
+	if body, err := json.Marshal(event); err != nil || len(body) <= maxRunEventBytes {
+		return
+	}
+	targets := event.Targets
+	event.Targets = nil
+	// Room for the omitted counts, written after the targets are picked.
+	base, _ := json.Marshal(event)
+	budget := maxRunEventBytes - len(base) - len(`,"targets":[],"omitted_succeeded":000000,"omitted_failed":000000`)
+
+	keep := make([]bool, len(targets))
+	for _, failed := range []bool{true, false} {
+		for i, target := range targets {
+			if (target.Status != StatusSuccess) != failed {
+				continue
+			}
+			entry, err := json.Marshal(target)
+			if err != nil || len(entry)+1 > budget {
+				continue
+			}
+			budget -= len(entry) + 1
+			keep[i] = true
+		}
+	}
+	for i, target := range targets {
+		switch {
+		case keep[i]:
+			event.Targets = append(event.Targets, target)
+		case target.Status == StatusSuccess:
+			event.OmittedSucceeded++
+		default:
+			event.OmittedFailed++
+		}
+	}
+	pkg.LogVerbosef("Left %d succeeded and %d failed targets out of the run event to keep it under %d KB.", event.OmittedSucceeded, event.OmittedFailed, maxRunEventBytes/1024)
+}
+
+// truncate shortens s to at most n bytes, cut at a character boundary, marking
+// the cut.
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n] + "... (truncated)"
+}
+
+// publishConfig returns the config -publish sends with. A destination in an
+// account of the SAWS config is reached through role in that account; any
+// other uses the base credentials, which the topic or bus policy must allow.
+func publishConfig(ctx context.Context, baseCfg aws.Config, appCfg *pkg.AppConfig, destination arn.ARN, role string) (aws.Config, error) {
+	for accountName, accountID := range appCfg.Accounts {
+		if accountID != destination.AccountID {
+			continue
+		}
+		accountBaseCfg, err := pkg.BaseConfigForAccount(ctx, accountName, baseCfg)
+		if err != nil {
+			return aws.Config{}, err
+		}
+		creds, err := pkg.AssumeRole(ctx, accountBaseCfg, accountID, role, "Publish")
+		if err != nil {
+			return aws.Config{}, fmt.Errorf("assume role %s in %s to publish failed: %w", role, accountName, err)
+		}
+		return pkg.LoadAssumedRoleConfig(ctx, creds, destination.Region)
+	}
+	cfg := baseCfg.Copy()
+	cfg.Region = destination.Region
+	return cfg, nil
+}
+
+// ParsePublishDestination checks that destination is an SNS topic ARN or an
+// EventBridge event bus ARN, so a typo is caught before the run rather than after it.
+func ParsePublishDestination(destination string) (arn.ARN, error) {
+	parsed, err := arn.Parse(destination)
+	if err != nil || (parsed.Service != "sns" && !(parsed.Service == "events" && strings.HasPrefix(parsed.Resource, "event-bus/"))) {
+		return arn.ARN{}, fmt.Errorf("'%s' is neither an SNS topic ARN nor an EventBridge event bus ARN", destination)
+	}
+	return parsed, nil
+}
+
+// PublishRunEvent sends event to destination, an SNS topic ARN or an
+// EventBridge event bus ARN. Exported.
+// SNS receives the event as a JSON message with a one-line subject and a
+// "status" message attribute for subscription filters; EventBridge receives it
+// as the detail of a RunEventSource/RunEventDetailType event.
+func PublishRunEvent(ctx context.Context, baseCfg aws.Config, appCfg *pkg.AppConfig, destination, role string, event RunEvent) error {
+	parsed, err := ParsePublishDestination(destination)
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	cfg, err := publishConfig(ctx, baseCfg, appCfg, parsed, role)
+	if err != nil {
+		return err
+	}
+
+	if parsed.Service == "sns" {
+		subject := fmt.Sprintf("saws %s: %d of %d targets succeeded", event.Mode, event.Succeeded, event.Total)
+		_, err := sns.NewFromConfig(cfg).Publish(ctx, &sns.PublishInput{
+			TopicArn: aws.String(destination),
+			Subject:  aws.String(subject),
+			Message:  aws.String(string(body)),
+			MessageAttributes: map[string]snstypes.MessageAttributeValue{
+				"status": {DataType: aws.String("String"), StringValue: aws.String(event.Status)},
+			},
+		})
+		if err != nil {
+			return fmt.Errorf("sns:Publish to %s failed: %w", destination, err)
+		}
+		pkg.LogVerbosef("Published the run summary to SNS topic %s.", destination)
+		return nil
+	}
+
+	out, err := eventbridge.NewFromConfig(cfg).PutEvents(ctx, &eventbridge.PutEventsInput{
+		Entries: []ebtypes.PutEventsRequestEntry{{
+			EventBusName: aws.String(destination),
+			Source:       aws.String(RunEventSource),
+			DetailType:   aws.String(RunEventDetailType),
+			Detail:       aws.String(string(body)),
+		}},
+	})
+	if err != nil {
+		return fmt.Errorf("events:PutEvents to %s failed: %w", destination, err)
+	}
+	if out.FailedEntryCount > 0 && len(out.Entries) > 0 {
+		return fmt.Errorf("events:PutEvents to %s rejected the event: %s", destination, aws.ToString(out.Entries[0].ErrorMessage))
+	}
+	pkg.LogVerbosef("Published the run summary to event bus %s.", destination)
+	return nil
+}
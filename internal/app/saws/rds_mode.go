@@ -0,0 +1,219 @@
+package saws
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"saws/internal/pkg"
+
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	rdsauth "github.com/aws/aws-sdk-go-v2/feature/rds/auth"
+	"github.com/aws/aws-sdk-go-v2/service/rds"
+	rdstypes "github.com/aws/aws-sdk-go-v2/service/rds/types"
+)
+
+// rdsEndpoint is the subset of a DB instance or cluster needed to connect to it.
+type rdsEndpoint struct {
+	Identifier string
+	Engine     string
+	Host       string
+	Port       int32
+	MasterUser string
+	IAMAuth    bool
+}
+
+// listRdsEndpoints fetches all DB instances and Aurora cluster writer endpoints in the given region.
+func listRdsEndpoints(ctx context.Context, cfg aws.Config) ([]rdsEndpoint, error) {
+	rdsClient := rds.NewFromConfig(cfg)
+	paginator := rds.NewDescribeDBInstancesPaginator(rdsClient, &rds.DescribeDBInstancesInput{MaxRecords: aws.Int32(100)})
+
+	var endpoints []rdsEndpoint
+	pkg.LogVerbosef("Fetching RDS DB instances in region %s...", cfg.Region)
+	pageNum := 0
+	for paginator.HasMorePages() {
+		pageNum++
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to describe RDS DB instances (page %d): %w", pageNum, err)
+		}
+		for _, db := range page.DBInstances {
+			if ep, ok := rdsEndpointFromInstance(db); ok {
+				endpoints = append(endpoints, ep)
+			}
+		}
+	}
+
+	clusterPaginator := rds.NewDescribeDBClustersPaginator(rdsClient, &rds.DescribeDBClustersInput{MaxRecords: aws.Int32(100)})
+	pkg.LogVerbosef("Fetching RDS DB clusters in region %s...", cfg.Region)
+	pageNum = 0
+	for clusterPaginator.HasMorePages() {
+		pageNum++
+		page, err := clusterPaginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to describe RDS DB clusters (page %d): %w", pageNum, err)
+		}
+		for _, cluster := range page.DBClusters {
+			if ep, ok := rdsEndpointFromCluster(cluster); ok {
+				endpoints = append(endpoints, ep)
+			}
+		}
+	}
+	pkg.LogVerbosef("Finished fetching RDS endpoints. Total with endpoints: %d", len(endpoints))
+	sort.Slice(endpoints, func(i, j int) bool { return endpoints[i].Identifier < endpoints[j].Identifier })
+	return endpoints, nil
+}
+
+func rdsEndpointFromInstance(db rdstypes.DBInstance) (rdsEndpoint, bool) {
+	if db.DBInstanceIdentifier == nil || db.Endpoint == nil || db.Endpoint.Address == nil || db.Endpoint.Port == nil {
+		return rdsEndpoint{}, false
+	}
+	ep := rdsEndpoint{
+		Identifier: *db.DBInstanceIdentifier,
+		Host:       *db.Endpoint.Address,
+		Port:       *db.Endpoint.Port,
+		IAMAuth:    aws.ToBool(db.IAMDatabaseAuthenticationEnabled),
+	}
+	ep.Engine = aws.ToString(db.Engine)
+	ep.MasterUser = aws.ToString(db.MasterUsername)
+	return ep, true
+}
+
+func rdsEndpointFromCluster(cluster rdstypes.DBCluster) (rdsEndpoint, bool) {
+	if cluster.DBClusterIdentifier == nil || cluster.Endpoint == nil || cluster.Port == nil {
+		return rdsEndpoint{}, false
+	}
+	return rdsEndpoint{
+		Identifier: *cluster.DBClusterIdentifier,
+		Engine:     aws.ToString(cluster.Engine),
+		Host:       *cluster.Endpoint,
+		Port:       *cluster.Port,
+		MasterUser: aws.ToString(cluster.MasterUsername),
+		IAMAuth:    aws.ToBool(cluster.IAMDatabaseAuthenticationEnabled),
+	}, true
+}
+
+// selectRdsEndpoint resolves the DB instance given via flag, or prompts for one.
+func selectRdsEndpoint(ctx context.Context, cfg aws.Config, instanceFlag string) (*rdsEndpoint, error) {
+	endpoints, err := listRdsEndpoints(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	if len(endpoints) == 0 {
+		return nil, fmt.Errorf("no RDS DB instances or clusters with endpoints found in region %s", cfg.Region)
+	}
+
+	if instanceFlag != "" {
+		for i := range endpoints {
+			if endpoints[i].Identifier == instanceFlag {
+				pkg.LogVerbosef("Using DB instance '%s' provided via --rds-instance flag.", instanceFlag)
+				return &endpoints[i], nil
+			}
+		}
+		return nil, fmt.Errorf("DB instance or cluster '%s' not found in region %s", instanceFlag, cfg.Region)
+	}
+
+	options := make([]string, len(endpoints))
+	optionToEndpoint := make(map[string]*rdsEndpoint)
+	for i := range endpoints {
+		iamAuth := "iam-auth:off"
+		if endpoints[i].IAMAuth {
+			iamAuth = "iam-auth:on"
+		}
+		displayStr := fmt.Sprintf("%-30s | %-17s | %s", endpoints[i].Identifier, endpoints[i].Engine, iamAuth)
+		options[i] = displayStr
+		optionToEndpoint[displayStr] = &endpoints[i]
+	}
+
+	chosenDisplayStr := ""
+	prompt := &survey.Select{Message: "Choose RDS DB Instance or Cluster:", Options: options, PageSize: 15}
+	if err := pkg.AskOne(prompt, &chosenDisplayStr, survey.WithValidator(survey.Required), pkg.WithFuzzyFilter); err != nil {
+		return nil, fmt.Errorf("DB instance selection failed: %w", err)
+	}
+	selected := optionToEndpoint[chosenDisplayStr]
+	pkg.LogVerbosef("Selected DB instance: %s", selected.Identifier)
+	return selected, nil
+}
+
+// rdsConnectLine renders a ready-to-paste client invocation for the engine family.
+func rdsConnectLine(engine, host string, port int32, user, token string) string {
+	switch {
+	case strings.Contains(engine, "postgres"):
+		return fmt.Sprintf("PGPASSWORD='%s' psql \"host=%s port=%d user=%s dbname=postgres sslmode=require\"", token, host, port, user)
+	case strings.Contains(engine, "mysql"), strings.Contains(engine, "mariadb"):
+		return fmt.Sprintf("mysql --host=%s --port=%d --user=%s --password='%s' --enable-cleartext-plugin --ssl-mode=REQUIRED", host, port, user, token)
+	default:
+		return fmt.Sprintf("# Engine '%s' has no known client template. Host=%s Port=%d User=%s Password=%s", engine, host, port, user, token)
+	}
+}
+
+// HandleRdsToken handles the logic for the -rds-token mode. Exported.
+func HandleRdsToken(
+	ctx context.Context,
+	instanceFlag, dbUserFlag string, tunnel bool, localPortFlag int, // Flags specific to RDS token mode
+	accountSelectorFlag, roleFlag, regionFlagFromCmd string, // Common context flags
+) error {
+	pkg.LogVerbosef("Preparing RDS IAM auth token...")
+	sCtx, creds, err := pkg.EstablishAWSContextAndAssumeRole(ctx, accountSelectorFlag, roleFlag, regionFlagFromCmd, "RDSToken")
+	if err != nil {
+		return fmt.Errorf("could not establish AWS context for RDS token: %w", err)
+	}
+	cfg, err := pkg.LoadAssumedRoleConfig(ctx, creds, sCtx.Region)
+	if err != nil {
+		return err
+	}
+
+	db, err := selectRdsEndpoint(ctx, cfg, instanceFlag)
+	if err != nil {
+		return err
+	}
+	if !db.IAMAuth {
+		fmt.Fprintf(os.Stderr, "Warning: IAM database authentication is not enabled on %s; the token will be rejected.\n", db.Identifier)
+	}
+
+	dbUser := dbUserFlag
+	if dbUser == "" {
+		prompt := &survey.Input{Message: "Enter the database user to authenticate as:", Default: db.MasterUser}
+		if err := pkg.AskOne(prompt, &dbUser, survey.WithValidator(survey.Required)); err != nil {
+			return fmt.Errorf("database user input failed: %w", err)
+		}
+	}
+
+	// The token is signed for the real endpoint even when connecting through a tunnel.
+	token, err := rdsauth.BuildAuthToken(ctx, fmt.Sprintf("%s:%d", db.Host, db.Port), sCtx.Region, dbUser, cfg.Credentials)
+	if err != nil {
+		return fmt.Errorf("failed to build RDS auth token for %s: %w", db.Identifier, err)
+	}
+
+	connectHost := db.Host
+	connectPort := db.Port
+	if tunnel {
+		connectHost = "127.0.0.1"
+		connectPort = db.Port
+		if localPortFlag > 0 {
+			connectPort = int32(localPortFlag)
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "RDS IAM auth token for %s (user %s) in Account=%s(%s), Region=%s. Valid for 15 minutes.\n", db.Identifier, dbUser, sCtx.AccountName, sCtx.AccountID, sCtx.Region)
+	fmt.Println(rdsConnectLine(db.Engine, connectHost, connectPort, dbUser, token))
+
+	if !tunnel {
+		return nil
+	}
+
+	awsCredentials := aws.Credentials{AccessKeyID: *creds.AccessKeyId, SecretAccessKey: *creds.SecretAccessKey, SessionToken: *creds.SessionToken, Source: "SawsAssumedRoleForRDS"}
+	bastionID, err := selectSSMInstance(ctx, awsCredentials, sCtx, "Choose a bastion instance for the tunnel:", SSMInstanceFilter{})
+	if err != nil {
+		return err
+	}
+	if bastionID == "" {
+		return errors.New("no bastion instance available for the tunnel")
+	}
+
+	return runSSMTunnel(ctx, creds, sCtx.Region, bastionID, db.Host, db.Port, connectPort)
+}
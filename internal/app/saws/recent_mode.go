@@ -0,0 +1,50 @@
+package saws
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"saws/internal/pkg"
+
+	"github.com/AlecAivazis/survey/v2"
+)
+
+// RecentSSMSession is the session type EstablishAWSContextAndAssumeRole records
+// for -ssm; `saws recent` resumes those as SSM sessions.
+const RecentSSMSession = "SSMSessionSetup"
+
+// HandleRecent handles the logic for the `recent` mode. Exported.
+// It lists the remembered account/role/region/instance combinations, newest
+// first, and returns the one picked so main can resume it.
+func HandleRecent() (pkg.RecentSelection, error) {
+	recent := pkg.LoadRecentSelections()
+	if len(recent) == 0 {
+		return pkg.RecentSelection{}, errors.New("no recent selections yet; they are remembered as you use saws")
+	}
+	options := make([]string, len(recent))
+	optionToSelection := make(map[string]pkg.RecentSelection, len(recent))
+	for i, r := range recent {
+		options[i] = pkg.FormatRecentSelection(r)
+		optionToSelection[options[i]] = r
+	}
+	chosen := ""
+	fmt.Fprintln(os.Stderr, "Recent selections (account / role / region / instance):")
+	prompt := &survey.Select{Message: "Resume:", Options: options, PageSize: 15}
+	if err := pkg.AskOne(prompt, &chosen, survey.WithValidator(survey.Required), pkg.WithFuzzyFilter); err != nil {
+		return pkg.RecentSelection{}, fmt.Errorf("recent selection failed: %w", err)
+	}
+	return optionToSelection[chosen], nil
+}
+
+// HandleLast handles the logic for the `last` mode. Exported.
+// It returns the newest remembered selection without a picker, for quick
+// reconnects after the session token expired.
+func HandleLast() (pkg.RecentSelection, error) {
+	recent := pkg.LoadRecentSelections()
+	if len(recent) == 0 {
+		return pkg.RecentSelection{}, errors.New("no recent selections yet; they are remembered as you use saws")
+	}
+	fmt.Fprintf(os.Stderr, "Resuming %s\n", pkg.FormatRecentSelection(recent[0]))
+	return recent[0], nil
+}
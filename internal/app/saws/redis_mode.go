@@ -0,0 +1,215 @@
+package saws
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"sort"
+	"strconv"
+
+	"saws/internal/pkg"
+
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/elasticache"
+	"github.com/aws/aws-sdk-go-v2/service/memorydb"
+)
+
+// cacheEndpoint is a Redis/Valkey compatible endpoint reachable through a tunnel.
+type cacheEndpoint struct {
+	Name    string
+	Service string
+	Host    string
+	Port    int32
+	TLS     bool
+}
+
+// listCacheEndpoints fetches ElastiCache replication groups, ElastiCache
+// serverless caches, and MemoryDB clusters in the given region.
+func listCacheEndpoints(ctx context.Context, cfg aws.Config) ([]cacheEndpoint, error) {
+	var endpoints []cacheEndpoint
+
+	ecClient := elasticache.NewFromConfig(cfg)
+	pkg.LogVerbosef("Fetching ElastiCache replication groups in region %s...", cfg.Region)
+	rgPaginator := elasticache.NewDescribeReplicationGroupsPaginator(ecClient, &elasticache.DescribeReplicationGroupsInput{})
+	for rgPaginator.HasMorePages() {
+		page, err := rgPaginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to describe ElastiCache replication groups: %w", err)
+		}
+		for _, rg := range page.ReplicationGroups {
+			ep := rg.ConfigurationEndpoint
+			if ep == nil && len(rg.NodeGroups) > 0 {
+				ep = rg.NodeGroups[0].PrimaryEndpoint
+			}
+			if rg.ReplicationGroupId == nil || ep == nil || ep.Address == nil || ep.Port == nil {
+				continue
+			}
+			endpoints = append(endpoints, cacheEndpoint{Name: *rg.ReplicationGroupId, Service: "elasticache", Host: *ep.Address, Port: *ep.Port, TLS: aws.ToBool(rg.TransitEncryptionEnabled)})
+		}
+	}
+
+	pkg.LogVerbosef("Fetching ElastiCache serverless caches in region %s...", cfg.Region)
+	slPaginator := elasticache.NewDescribeServerlessCachesPaginator(ecClient, &elasticache.DescribeServerlessCachesInput{})
+	for slPaginator.HasMorePages() {
+		page, err := slPaginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to describe ElastiCache serverless caches: %w", err)
+		}
+		for _, sc := range page.ServerlessCaches {
+			if sc.ServerlessCacheName == nil || sc.Endpoint == nil || sc.Endpoint.Address == nil || sc.Endpoint.Port == nil {
+				continue
+			}
+			// Serverless caches only accept TLS connections.
+			endpoints = append(endpoints, cacheEndpoint{Name: *sc.ServerlessCacheName, Service: "elasticache-serverless", Host: *sc.Endpoint.Address, Port: *sc.Endpoint.Port, TLS: true})
+		}
+	}
+
+	mdbClient := memorydb.NewFromConfig(cfg)
+	pkg.LogVerbosef("Fetching MemoryDB clusters in region %s...", cfg.Region)
+	mdbPaginator := memorydb.NewDescribeClustersPaginator(mdbClient, &memorydb.DescribeClustersInput{})
+	for mdbPaginator.HasMorePages() {
+		page, err := mdbPaginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to describe MemoryDB clusters: %w", err)
+		}
+		for _, c := range page.Clusters {
+			if c.Name == nil || c.ClusterEndpoint == nil || c.ClusterEndpoint.Address == nil {
+				continue
+			}
+			endpoints = append(endpoints, cacheEndpoint{Name: *c.Name, Service: "memorydb", Host: *c.ClusterEndpoint.Address, Port: c.ClusterEndpoint.Port, TLS: aws.ToBool(c.TLSEnabled)})
+		}
+	}
+
+	pkg.LogVerbosef("Finished fetching cache endpoints. Total found: %d", len(endpoints))
+	sort.Slice(endpoints, func(i, j int) bool { return endpoints[i].Name < endpoints[j].Name })
+	return endpoints, nil
+}
+
+// selectCacheEndpoint resolves the cache given via flag, or prompts for one.
+func selectCacheEndpoint(ctx context.Context, cfg aws.Config, nameFlag string) (*cacheEndpoint, error) {
+	endpoints, err := listCacheEndpoints(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	if len(endpoints) == 0 {
+		return nil, fmt.Errorf("no ElastiCache or MemoryDB endpoints found in region %s", cfg.Region)
+	}
+	if nameFlag != "" {
+		for i := range endpoints {
+			if endpoints[i].Name == nameFlag {
+				pkg.LogVerbosef("Using cache '%s' provided via --cache flag.", nameFlag)
+				return &endpoints[i], nil
+			}
+		}
+		return nil, fmt.Errorf("cache '%s' not found in region %s", nameFlag, cfg.Region)
+	}
+
+	options := make([]string, len(endpoints))
+	optionToEndpoint := make(map[string]*cacheEndpoint)
+	for i := range endpoints {
+		tls := "plain"
+		if endpoints[i].TLS {
+			tls = "tls"
+		}
+		displayStr := fmt.Sprintf("%-30s | %-22s | %s", endpoints[i].Name, endpoints[i].Service, tls)
+		options[i] = displayStr
+		optionToEndpoint[displayStr] = &endpoints[i]
+	}
+	chosenDisplayStr := ""
+	prompt := &survey.Select{Message: "Choose Cache:", Options: options, PageSize: 15}
+	if err := pkg.AskOne(prompt, &chosenDisplayStr, survey.WithValidator(survey.Required), pkg.WithFuzzyFilter); err != nil {
+		return nil, fmt.Errorf("cache selection failed: %w", err)
+	}
+	return optionToEndpoint[chosenDisplayStr], nil
+}
+
+// HandleRedisConnect handles the logic for the -redis mode: pick a cache, pick a
+// bastion, open an SSM tunnel, and run redis-cli through it. Exported.
+func HandleRedisConnect(
+	ctx context.Context,
+	cacheFlag, bastionFlag string, localPortFlag int, // Flags specific to Redis mode
+	accountSelectorFlag, roleFlag, regionFlagFromCmd string, // Common context flags
+) error {
+	redisCLIPath, err := exec.LookPath("redis-cli")
+	if err != nil {
+		return errors.New("redis-cli not found in PATH")
+	}
+
+	pkg.LogVerbosef("Preparing for cache connect session...")
+	sCtx, creds, err := pkg.EstablishAWSContextAndAssumeRole(ctx, accountSelectorFlag, roleFlag, regionFlagFromCmd, "RedisConnectSession")
+	if err != nil {
+		return fmt.Errorf("could not establish AWS context for cache session: %w", err)
+	}
+	cfg, err := pkg.LoadAssumedRoleConfig(ctx, creds, sCtx.Region)
+	if err != nil {
+		return err
+	}
+
+	cache, err := selectCacheEndpoint(ctx, cfg, cacheFlag)
+	if err != nil {
+		return err
+	}
+
+	bastionID := bastionFlag
+	awsCredentials := aws.Credentials{AccessKeyID: *creds.AccessKeyId, SecretAccessKey: *creds.SecretAccessKey, SessionToken: *creds.SessionToken, Source: "SawsAssumedRoleForRedis"}
+	if bastionID == "" {
+		bastionID, err = selectSSMInstance(ctx, awsCredentials, sCtx, "Choose a bastion instance for the tunnel:", SSMInstanceFilter{})
+		if err != nil {
+			return err
+		}
+		if bastionID == "" {
+			return errors.New("no bastion instance available for the tunnel")
+		}
+	} else {
+		bastionID, err = resolveSSMInstance(ctx, awsCredentials, sCtx.Region, bastionID)
+		if err != nil {
+			return err
+		}
+	}
+
+	localPort := int32(localPortFlag)
+	if localPort == 0 {
+		localPort, err = freeLocalPort()
+		if err != nil {
+			return err
+		}
+	}
+
+	args := []string{"-h", "127.0.0.1", "-p", strconv.Itoa(int(localPort))}
+	if cache.TLS {
+		// The certificate names the cache endpoint, not the local tunnel address.
+		args = append(args, "--tls", "--insecure", "--sni", cache.Host)
+	}
+
+	fmt.Fprintf(os.Stderr, "Opening tunnel 127.0.0.1:%d -> %s:%d via %s...\n", localPort, cache.Host, cache.Port, bastionID)
+	tunnel, err := startSSMTunnel(ctx, creds, sCtx.Region, bastionID, cache.Host, cache.Port, localPort)
+	if err != nil {
+		return err
+	}
+	defer tunnel.Stop()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt)
+	defer signal.Stop(signals)
+
+	fmt.Fprintf(os.Stderr, "Connecting to %s (%s) in Account=%s(%s), Region=%s. Exit redis-cli to close the tunnel.\n", cache.Name, cache.Service, sCtx.AccountName, sCtx.AccountID, sCtx.Region)
+	fmt.Fprintln(os.Stderr, "Note: cluster-mode caches redirect to node addresses that are not tunneled; use single-node commands.")
+	redisCmd := exec.Command(redisCLIPath, args...)
+	redisCmd.Stdin = os.Stdin
+	redisCmd.Stdout = os.Stdout
+	redisCmd.Stderr = os.Stderr
+	err = redisCmd.Run()
+	pkg.LogVerbosef("redis-cli exited.")
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			pkg.LogVerbosef("redis-cli exited with status: %s.", exitErr.Error())
+		} else {
+			return fmt.Errorf("failed to run redis-cli: %w", err)
+		}
+	}
+	return nil
+}
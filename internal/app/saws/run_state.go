@@ -0,0 +1,277 @@
+package saws
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"saws/internal/pkg"
+)
+
+const (
+	runStateDirName = "saws-runs"
+	// maxRunStates is how many run-state files are kept; older ones are removed.
+	maxRunStates = 50
+)
+
+// Outcomes of a target in a run-state file.
+const (
+	runTargetPending   = "pending"
+	runTargetSucceeded = "succeeded"
+	runTargetFailed    = "failed"
+)
+
+// ResumeOptions selects the saved run whose unfinished targets a fan-out
+// re-executes instead of its whole plan.
+type ResumeOptions struct {
+	// RunID is a run to resume, from -resume.
+	RunID string
+	// RetryFailed resumes the most recent run of the same command, from -retry-failed.
+	RetryFailed bool
+}
+
+// Resume is set by main from -resume and -retry-failed.
+var Resume ResumeOptions
+
+// runState is the plan and per-target outcomes of one fan-out run, kept in
+// ~/.aws/saws-runs/<id>.json and rewritten as targets finish, so an
+// interrupted or partly failed run can be resumed.
+type runState struct {
+	ID string `json:"id"`
+	// Mode is the command, e.g. "exec"; Label tells apart the fan-outs of one
+	// invocation, e.g. a -preflight and the run it guards.
+	Mode      string           `json:"mode"`
+	Label     string           `json:"label"`
+	Role      string           `json:"role"`
+	StartedAt time.Time        `json:"started_at"`
+	Targets   []runStateTarget `json:"targets"`
+
+	mu   sync.Mutex
+	path string
+}
+
+// runStateTarget is one target of a run-state file.
+type runStateTarget struct {
+	Account   string `json:"account"`
+	AccountID string `json:"account_id"`
+	Region    string `json:"region"`
+	Status    string `json:"status"`
+	ExitCode  int    `json:"exit_code,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+func runStateDir() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(homeDir, pkg.AWSConfigDir, runStateDirName), nil
+}
+
+func loadRunState(path string) (*runState, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	state := &runState{path: path}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("could not parse run state '%s': %w", path, err)
+	}
+	return state, nil
+}
+
+// loadRunStates returns the saved runs, newest first.
+func loadRunStates() ([]*runState, error) {
+	dir, err := runStateDir()
+	if err != nil {
+		return nil, err
+	}
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	var states []*runState
+	for _, path := range paths {
+		state, err := loadRunState(path)
+		if err != nil {
+			pkg.LogVerbosef("Warning: Skipping run state '%s': %v", path, err)
+			continue
+		}
+		states = append(states, state)
+	}
+	sort.Slice(states, func(i, j int) bool { return states[i].StartedAt.After(states[j].StartedAt) })
+	return states, nil
+}
+
+// unfinished reports whether a target of the run failed or never started.
+func (s *runState) unfinished() bool {
+	for _, t := range s.Targets {
+		if t.Status != runTargetSucceeded {
+			return true
+		}
+	}
+	return false
+}
+
+// findResumedRun returns the run Resume selects among the runs of mode with
+// label, or nil when none is selected.
+func findResumedRun(mode, label string) (*runState, error) {
+	if Resume.RunID == "" && !Resume.RetryFailed {
+		return nil, nil
+	}
+	states, err := loadRunStates()
+	if err != nil {
+		return nil, fmt.Errorf("could not read saved runs: %w", err)
+	}
+	for _, state := range states {
+		if state.Mode != mode || (label != "" && state.Label != label) {
+			continue
+		}
+		if Resume.RunID != "" && state.ID == Resume.RunID {
+			return state, nil
+		}
+		if Resume.RunID == "" && state.unfinished() {
+			return state, nil
+		}
+	}
+	switch {
+	case Resume.RunID != "":
+		return nil, fmt.Errorf("no saved '%s' run with ID %s", mode, Resume.RunID)
+	default:
+		return nil, fmt.Errorf("no saved '%s' run has failed or unstarted targets", mode)
+	}
+}
+
+// CheckResume fails when -resume or -retry-failed selects no saved run of
+// mode, so a typo is reported before any role is assumed. Exported.
+func CheckResume(mode string) error {
+	state, err := findResumedRun(mode, "")
+	if err != nil || state == nil {
+		return err
+	}
+	if !state.unfinished() {
+		return fmt.Errorf("every target of run %s succeeded; nothing to resume", state.ID)
+	}
+	return nil
+}
+
+// planRun saves the plan of a fan-out run and returns the targets to run.
+// When it resumes a saved run, only the targets that failed or never started
+// there are returned, and their outcomes update that run.
+// Failing to save the state only loses the ability to resume the run.
+func planRun(opts FanOutOptions, targets []FanOutTarget) (*runState, []FanOutTarget) {
+	resumed, err := findResumedRun(Telemetry.Mode, opts.Label)
+	if err != nil {
+		pkg.LogVerbosef("%s: Not resuming: %v", opts.Label, err)
+	}
+	if resumed != nil {
+		unfinished := make(map[string]bool)
+		for _, t := range resumed.Targets {
+			if t.Status != runTargetSucceeded {
+				unfinished[t.Account+"/"+t.Region] = true
+			}
+		}
+		var remaining []FanOutTarget
+		for _, target := range targets {
+			if unfinished[target.AccountName+"/"+target.Region] {
+				remaining = append(remaining, target)
+			}
+		}
+		fmt.Fprintf(os.Stderr, "%s: Resuming run %s with %d of its %d target(s) that failed or never started.\n", opts.Label, resumed.ID, len(remaining), len(resumed.Targets))
+		return resumed, remaining
+	}
+
+	dir, err := runStateDir()
+	if err != nil {
+		pkg.LogVerbosef("Warning: Not saving the run state: %v", err)
+		return nil, targets
+	}
+	startedAt := time.Now()
+	suffix := make([]byte, 2)
+	rand.Read(suffix)
+	state := &runState{
+		ID:        startedAt.Format("20060102-150405") + "-" + hex.EncodeToString(suffix),
+		Mode:      Telemetry.Mode,
+		Label:     opts.Label,
+		Role:      opts.RoleToAssume,
+		StartedAt: startedAt,
+	}
+	state.path = filepath.Join(dir, state.ID+".json")
+	for _, target := range targets {
+		state.Targets = append(state.Targets, runStateTarget{Account: target.AccountName, AccountID: target.AccountID, Region: target.Region, Status: runTargetPending})
+	}
+	state.mu.Lock()
+	state.save()
+	state.mu.Unlock()
+	pruneRunStates()
+	return state, targets
+}
+
+// record stores the outcome of target and rewrites the state file.
+func (s *runState) record(target FanOutTarget, result FanOutResult) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range s.Targets {
+		t := &s.Targets[i]
+		if t.Account != target.AccountName || t.Region != target.Region {
+			continue
+		}
+		t.Status, t.ExitCode, t.Error = runTargetSucceeded, result.ExitCode, ""
+		if result.Status != StatusSuccess {
+			t.Status = runTargetFailed
+			for _, section := range result.Sections {
+				if section.Label == "ERROR" {
+					t.Error = section.Body
+				}
+			}
+		}
+	}
+	s.save()
+}
+
+// save writes the state file; the caller holds s.mu.
+func (s *runState) save() {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err == nil {
+		err = pkg.WriteFileAtomic(s.path, data, 0o600)
+	}
+	if err != nil {
+		pkg.LogVerbosef("Warning: Could not save run state '%s': %v", s.path, err)
+	}
+}
+
+// announceResume tells how to re-run the targets of the run that did not succeed.
+func (s *runState) announceResume(label string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.unfinished() {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "%s: Saved as run %s; repeat the command with -resume %s (or -retry-failed) to re-run only the targets that failed or never started.\n", label, s.ID, s.ID)
+}
+
+// pruneRunStates removes all but the newest maxRunStates run-state files.
+func pruneRunStates() {
+	states, err := loadRunStates()
+	if err != nil || len(states) <= maxRunStates {
+		return
+	}
+	for _, state := range states[maxRunStates:] {
+		if err := os.Remove(state.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			pkg.LogVerbosef("Warning: Could not remove old run state '%s': %v", state.path, err)
+		}
+	}
+}
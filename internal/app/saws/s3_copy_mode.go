@@ -0,0 +1,186 @@
+package saws
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+
+	"saws/internal/pkg"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	ststypes "github.com/aws/aws-sdk-go-v2/service/sts/types"
+	"github.com/aws/smithy-go"
+)
+
+// s3Location is a parsed s3://bucket/key URL.
+type s3Location struct {
+	Bucket string
+	Key    string
+}
+
+func (l s3Location) String() string {
+	return fmt.Sprintf("s3://%s/%s", l.Bucket, l.Key)
+}
+
+// parseS3URL parses s3://bucket/key; the key may be empty or end in "/".
+func parseS3URL(raw string) (s3Location, error) {
+	rest, ok := strings.CutPrefix(raw, "s3://")
+	if !ok {
+		return s3Location{}, fmt.Errorf("'%s' is not an s3:// URL", raw)
+	}
+	bucket, key, _ := strings.Cut(rest, "/")
+	if bucket == "" {
+		return s3Location{}, fmt.Errorf("'%s' has no bucket name", raw)
+	}
+	return s3Location{Bucket: bucket, Key: key}, nil
+}
+
+// ParseS3CopyDestination splits "<account>:s3://bucket/key" into the account name and location.
+func ParseS3CopyDestination(raw string) (string, s3Location, error) {
+	accountName, s3URL, ok := strings.Cut(raw, ":s3://")
+	if !ok || accountName == "" {
+		return "", s3Location{}, fmt.Errorf("destination '%s' must look like <account>:s3://bucket/key", raw)
+	}
+	loc, err := parseS3URL("s3://" + s3URL)
+	return accountName, loc, err
+}
+
+// bucketRegion returns the region a bucket in partition lives in, so clients
+// can be pointed at it regardless of -region.
+func bucketRegion(ctx context.Context, creds *ststypes.Credentials, partition, bucket string) (string, error) {
+	cfg, err := pkg.LoadAssumedRoleConfig(ctx, creds, pkg.PartitionDefaultRegion(partition))
+	if err != nil {
+		return "", err
+	}
+	out, err := s3.NewFromConfig(cfg).GetBucketLocation(ctx, &s3.GetBucketLocationInput{Bucket: aws.String(bucket)})
+	if err != nil {
+		return "", fmt.Errorf("s3:GetBucketLocation failed for %s: %w", bucket, err)
+	}
+	switch region := string(out.LocationConstraint); region {
+	case "":
+		// Only the commercial partition has buckets without a location constraint.
+		return "us-east-1", nil
+	case "EU":
+		return "eu-west-1", nil
+	default:
+		return region, nil
+	}
+}
+
+func s3ClientFor(ctx context.Context, creds *ststypes.Credentials, partition, bucket string) (*s3.Client, error) {
+	region, err := bucketRegion(ctx, creds, partition, bucket)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := pkg.LoadAssumedRoleConfig(ctx, creds, region)
+	if err != nil {
+		return nil, err
+	}
+	return s3.NewFromConfig(cfg), nil
+}
+
+func isAccessDenied(err error) bool {
+	var apiErr smithy.APIError
+	return errors.As(err, &apiErr) && apiErr.ErrorCode() == "AccessDenied"
+}
+
+// HandleS3Copy handles the logic for the `s3-copy` mode. Exported.
+// The copy is attempted server-side with the destination role, which works when
+// the source bucket policy grants it read access. Otherwise the object is
+// streamed from the source role to the destination role without touching disk.
+func HandleS3Copy(
+	ctx context.Context, appCfg *pkg.AppConfig,
+	srcURL, destSpec, destRoleFlag string, // Arguments specific to s3-copy
+	accountSelectorFlag, roleFlag, regionFlagFromCmd string, // Common context flags
+) error {
+	src, err := parseS3URL(srcURL)
+	if err != nil {
+		return err
+	}
+	if src.Key == "" || strings.HasSuffix(src.Key, "/") {
+		return fmt.Errorf("source '%s' must name an object", srcURL)
+	}
+	destAccountName, dst, err := ParseS3CopyDestination(destSpec)
+	if err != nil {
+		return err
+	}
+	destAccountID, ok := appCfg.Accounts[destAccountName]
+	if !ok {
+		return fmt.Errorf("destination account '%s' not found in SAWS config", destAccountName)
+	}
+	if dst.Key == "" || strings.HasSuffix(dst.Key, "/") {
+		dst.Key += path.Base(src.Key)
+	}
+
+	sCtx, srcCreds, err := pkg.EstablishAWSContextAndAssumeRole(ctx, accountSelectorFlag, roleFlag, regionFlagFromCmd, "S3CopySource")
+	if err != nil {
+		return fmt.Errorf("could not establish AWS context for the source account: %w", err)
+	}
+	destRole := destRoleFlag
+	if destRole == "" {
+		destRole = sCtx.RoleName
+	} else if actualRole, ok := appCfg.Roles[destRole]; ok {
+		destRole = actualRole
+	}
+	baseCfg, err := pkg.LoadAWSConfig(ctx, awsconfig.WithSharedConfigProfile(pkg.BaseProfileForAssume), awsconfig.WithRegion(pkg.FallbackRegion))
+	if err != nil {
+		return fmt.Errorf("error loading base AWS configuration (profile '%s'): %w", pkg.BaseProfileForAssume, err)
+	}
+	if baseCfg, err = pkg.BaseConfigForAccount(ctx, destAccountName, baseCfg); err != nil {
+		return err
+	}
+	destCreds, err := pkg.AssumeRole(ctx, baseCfg, destAccountID, destRole, "S3CopyDest")
+	pkg.Audit(pkg.AuditResult(pkg.AuditRecord{Event: pkg.AuditAssumeRole, Account: destAccountName, AccountID: destAccountID, Role: destRole}, err))
+	if err != nil {
+		return fmt.Errorf("could not assume role %s in destination account %s: %w", destRole, destAccountName, err)
+	}
+
+	destClient, err := s3ClientFor(ctx, destCreds, pkg.AccountPartition(destAccountName), dst.Bucket)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Copying %s (%s) -> %s (%s)...\n", src, sCtx.AccountName, dst, destAccountName)
+
+	_, err = destClient.CopyObject(ctx, &s3.CopyObjectInput{
+		Bucket:     aws.String(dst.Bucket),
+		Key:        aws.String(dst.Key),
+		CopySource: aws.String(src.Bucket + "/" + url.PathEscape(src.Key)),
+	})
+	if err == nil {
+		fmt.Fprintln(os.Stderr, "Copied server-side.")
+		return nil
+	}
+	if !isAccessDenied(err) {
+		return fmt.Errorf("s3:CopyObject failed: %w", err)
+	}
+	pkg.LogVerbosef("Server-side copy denied (%v); streaming through saws instead.", err)
+
+	srcClient, err := s3ClientFor(ctx, srcCreds, pkg.ContextPartition(sCtx), src.Bucket)
+	if err != nil {
+		return err
+	}
+	obj, err := srcClient.GetObject(ctx, &s3.GetObjectInput{Bucket: aws.String(src.Bucket), Key: aws.String(src.Key)})
+	if err != nil {
+		return fmt.Errorf("s3:GetObject failed for %s: %w", src, err)
+	}
+	defer obj.Body.Close()
+	_, err = destClient.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:        aws.String(dst.Bucket),
+		Key:           aws.String(dst.Key),
+		Body:          obj.Body,
+		ContentLength: obj.ContentLength,
+		ContentType:   obj.ContentType,
+		Metadata:      obj.Metadata,
+	})
+	if err != nil {
+		return fmt.Errorf("s3:PutObject failed for %s: %w", dst, err)
+	}
+	fmt.Fprintf(os.Stderr, "Copied %d bytes via saws (source bucket policy does not grant the destination role read access).\n", aws.ToInt64(obj.ContentLength))
+	return nil
+}
@@ -0,0 +1,84 @@
+package saws
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"saws/internal/pkg"
+
+	ststypes "github.com/aws/aws-sdk-go-v2/service/sts/types"
+)
+
+// inheritedAWSEnv are the variables replaced by those of an assumed role.
+var inheritedAWSEnv = []string{"AWS_ACCESS_KEY_ID", "AWS_SECRET_ACCESS_KEY", "AWS_SESSION_TOKEN", "AWS_SECURITY_TOKEN", "AWS_REGION", "AWS_DEFAULT_REGION", "AWS_PROFILE"}
+
+// withoutEnv returns env without the variables named in names. A name ending
+// in "_" drops every variable with that prefix. Names are compared the way the
+// OS does, so case-insensitively on Windows.
+func withoutEnv(env []string, names ...string) []string {
+	kept := []string{}
+	for _, e := range env {
+		name, _, _ := strings.Cut(e, "=")
+		key := envNameKey(name)
+		drop := false
+		for _, n := range names {
+			n = envNameKey(n)
+			if key == n || (strings.HasSuffix(n, "_") && strings.HasPrefix(key, n)) {
+				drop = true
+				break
+			}
+		}
+		if !drop {
+			kept = append(kept, e)
+		}
+	}
+	return kept
+}
+
+// assumedRoleEnv returns the current process environment with any inherited AWS
+// credentials, profile, and region replaced by the assumed-role credentials.
+func assumedRoleEnv(creds *ststypes.Credentials, region string) []string {
+	newEnv := withoutEnv(os.Environ(), append(inheritedAWSEnv, "SAWS_INFO_EXPIRES_")...)
+	newEnv = append(newEnv, fmt.Sprintf("AWS_ACCESS_KEY_ID=%s", *creds.AccessKeyId))
+	newEnv = append(newEnv, fmt.Sprintf("AWS_SECRET_ACCESS_KEY=%s", *creds.SecretAccessKey))
+	newEnv = append(newEnv, fmt.Sprintf("AWS_SESSION_TOKEN=%s", *creds.SessionToken))
+	newEnv = append(newEnv, fmt.Sprintf("AWS_REGION=%s", region))
+	newEnv = append(newEnv, fmt.Sprintf("AWS_DEFAULT_REGION=%s", region))
+	return append(newEnv, expiryEnv(creds)...)
+}
+
+// expiryEnv is SAWS_INFO_EXPIRES_AT (RFC3339, UTC) and SAWS_INFO_EXPIRES_IN
+// (seconds left when the environment was built) for creds, or nothing when
+// they carry no expiration. `saws time-left` reads the former.
+func expiryEnv(creds *ststypes.Credentials) []string {
+	if creds.Expiration == nil {
+		return nil
+	}
+	return []string{
+		fmt.Sprintf("SAWS_INFO_EXPIRES_AT=%s", creds.Expiration.UTC().Format(time.RFC3339)),
+		fmt.Sprintf("SAWS_INFO_EXPIRES_IN=%d", int(time.Until(*creds.Expiration).Seconds())),
+	}
+}
+
+// ensureSessionManagerPlugin makes session-manager-plugin findable by the aws
+// CLI. When it is not on PATH but in its default install location, that
+// directory is added to saws' PATH, which the CLI inherits; otherwise saws
+// warns with the install instructions.
+func ensureSessionManagerPlugin() {
+	if path, err := exec.LookPath("session-manager-plugin"); err == nil {
+		pkg.LogVerbosef("Using Session Manager plugin at: %s", path)
+		return
+	}
+	for _, dir := range sessionManagerPluginDirs() {
+		if path, err := exec.LookPath(filepath.Join(dir, "session-manager-plugin")); err == nil {
+			pkg.LogVerbosef("Using Session Manager plugin at: %s (not on PATH)", path)
+			os.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+			return
+		}
+	}
+	fmt.Fprintln(os.Stderr, "Warning: Session Manager plugin ('session-manager-plugin') not found. Install it: https://docs.aws.amazon.com/systems-manager/latest/userguide/session-manager-working-with-install-plugin.html")
+}
@@ -0,0 +1,195 @@
+package saws
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"saws/internal/pkg"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	kmstypes "github.com/aws/aws-sdk-go-v2/service/kms/types"
+)
+
+// sharedKMSOperations are the grant operations a destination account needs to
+// launch from, or copy, an image or snapshot encrypted with the source key.
+var sharedKMSOperations = []kmstypes.GrantOperation{
+	kmstypes.GrantOperationDecrypt,
+	kmstypes.GrantOperationDescribeKey,
+	kmstypes.GrantOperationCreateGrant,
+	kmstypes.GrantOperationReEncryptFrom,
+	kmstypes.GrantOperationGenerateDataKeyWithoutPlaintext,
+}
+
+// shareSource is the AMI or snapshot being shared and what it depends on.
+type shareSource struct {
+	ImageID     string
+	SnapshotIDs []string
+	KMSKeyIDs   []string
+}
+
+// resolveShareSource describes resourceID and collects its snapshots and their
+// customer-managed KMS keys.
+func resolveShareSource(ctx context.Context, ec2Client *ec2.Client, resourceID string) (*shareSource, error) {
+	src := &shareSource{}
+	switch {
+	case strings.HasPrefix(resourceID, "ami-"):
+		out, err := ec2Client.DescribeImages(ctx, &ec2.DescribeImagesInput{ImageIds: []string{resourceID}})
+		if err != nil {
+			return nil, fmt.Errorf("ec2:DescribeImages failed for %s: %w", resourceID, err)
+		}
+		if len(out.Images) == 0 {
+			return nil, fmt.Errorf("AMI %s not found", resourceID)
+		}
+		src.ImageID = resourceID
+		for _, mapping := range out.Images[0].BlockDeviceMappings {
+			if mapping.Ebs != nil && mapping.Ebs.SnapshotId != nil {
+				src.SnapshotIDs = append(src.SnapshotIDs, *mapping.Ebs.SnapshotId)
+			}
+		}
+	case strings.HasPrefix(resourceID, "snap-"):
+		src.SnapshotIDs = []string{resourceID}
+	default:
+		return nil, fmt.Errorf("unsupported resource '%s' (expected an ami- or snap- ID)", resourceID)
+	}
+
+	if len(src.SnapshotIDs) == 0 {
+		return src, nil
+	}
+	out, err := ec2Client.DescribeSnapshots(ctx, &ec2.DescribeSnapshotsInput{SnapshotIds: src.SnapshotIDs})
+	if err != nil {
+		return nil, fmt.Errorf("ec2:DescribeSnapshots failed: %w", err)
+	}
+	seenKeys := make(map[string]bool)
+	for _, snap := range out.Snapshots {
+		keyID := aws.ToString(snap.KmsKeyId)
+		if aws.ToBool(snap.Encrypted) && keyID != "" && !seenKeys[keyID] {
+			seenKeys[keyID] = true
+			src.KMSKeyIDs = append(src.KMSKeyIDs, keyID)
+		}
+	}
+	return src, nil
+}
+
+// checkShareableKeys rejects AWS-managed keys, which cannot be used from another account.
+func checkShareableKeys(ctx context.Context, kmsClient *kms.Client, keyIDs []string) error {
+	for _, keyID := range keyIDs {
+		out, err := kmsClient.DescribeKey(ctx, &kms.DescribeKeyInput{KeyId: aws.String(keyID)})
+		if err != nil {
+			return fmt.Errorf("kms:DescribeKey failed for %s: %w", keyID, err)
+		}
+		if out.KeyMetadata != nil && out.KeyMetadata.KeyManager == kmstypes.KeyManagerTypeAws {
+			return fmt.Errorf("snapshots are encrypted with the AWS-managed key %s, which cannot be shared; re-encrypt with a customer-managed key first", keyID)
+		}
+	}
+	return nil
+}
+
+// shareWithAccount grants destAccountID launch/create-volume permission and KMS access.
+func shareWithAccount(ctx context.Context, ec2Client *ec2.Client, kmsClient *kms.Client, src *shareSource, destAccountName, destAccountID string) (string, error) {
+	if src.ImageID != "" {
+		_, err := ec2Client.ModifyImageAttribute(ctx, &ec2.ModifyImageAttributeInput{
+			ImageId:          aws.String(src.ImageID),
+			LaunchPermission: &ec2types.LaunchPermissionModifications{Add: []ec2types.LaunchPermission{{UserId: aws.String(destAccountID)}}},
+		})
+		if err != nil {
+			return "", fmt.Errorf("ec2:ModifyImageAttribute failed: %w", err)
+		}
+	}
+	for _, snapshotID := range src.SnapshotIDs {
+		_, err := ec2Client.ModifySnapshotAttribute(ctx, &ec2.ModifySnapshotAttributeInput{
+			SnapshotId:             aws.String(snapshotID),
+			CreateVolumePermission: &ec2types.CreateVolumePermissionModifications{Add: []ec2types.CreateVolumePermission{{UserId: aws.String(destAccountID)}}},
+		})
+		if err != nil {
+			return "", fmt.Errorf("ec2:ModifySnapshotAttribute failed for %s: %w", snapshotID, err)
+		}
+	}
+	for _, keyID := range src.KMSKeyIDs {
+		_, err := kmsClient.CreateGrant(ctx, &kms.CreateGrantInput{
+			KeyId:            aws.String(keyID),
+			GranteePrincipal: aws.String(fmt.Sprintf("arn:%s:iam::%s:root", pkg.AccountPartition(destAccountName), destAccountID)),
+			Operations:       sharedKMSOperations,
+			Name:             aws.String("saws-share-" + destAccountID),
+		})
+		if err != nil {
+			return "", fmt.Errorf("kms:CreateGrant failed for key %s: %w", keyID, err)
+		}
+	}
+
+	parts := []string{}
+	if src.ImageID != "" {
+		parts = append(parts, "launch permission")
+	}
+	if len(src.SnapshotIDs) > 0 {
+		parts = append(parts, fmt.Sprintf("%d snapshot(s)", len(src.SnapshotIDs)))
+	}
+	if len(src.KMSKeyIDs) > 0 {
+		parts = append(parts, fmt.Sprintf("%d KMS grant(s)", len(src.KMSKeyIDs)))
+	}
+	return strings.Join(parts, ", "), nil
+}
+
+// HandleShare handles the logic for the -share mode. Exported.
+// It shares an AMI (with its backing snapshots) or a snapshot from the selected
+// source account to every account named in destAccountNames, creating KMS
+// grants for customer-managed keys, and reports the outcome per destination.
+func HandleShare(
+	ctx context.Context, appCfg *pkg.AppConfig,
+	resourceID string, destAccountNames []string, // Flags specific to share mode
+	accountSelectorFlag, roleFlag, regionFlagFromCmd string, // Common context flags
+) error {
+	sCtx, creds, err := pkg.EstablishAWSContextAndAssumeRole(ctx, accountSelectorFlag, roleFlag, regionFlagFromCmd, "ShareImage")
+	if err != nil {
+		return fmt.Errorf("could not establish AWS context for sharing: %w", err)
+	}
+	cfg, err := pkg.LoadAssumedRoleConfig(ctx, creds, sCtx.Region)
+	if err != nil {
+		return err
+	}
+	ec2Client := ec2.NewFromConfig(cfg)
+	kmsClient := kms.NewFromConfig(cfg)
+
+	src, err := resolveShareSource(ctx, ec2Client, resourceID)
+	if err != nil {
+		return err
+	}
+	if err := checkShareableKeys(ctx, kmsClient, src.KMSKeyIDs); err != nil {
+		return err
+	}
+	pkg.LogVerbosef("Sharing %s (snapshots: %v, KMS keys: %v) from Account=%s(%s), Region=%s", resourceID, src.SnapshotIDs, src.KMSKeyIDs, sCtx.AccountName, sCtx.AccountID, sCtx.Region)
+
+	sort.Strings(destAccountNames)
+	failed := 0
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprint(w, pkg.ColorRow(os.Stdout, pkg.ColorPlain, "DESTINATION\tACCOUNT ID\tSTATUS\tDETAILS\n"))
+	for _, destName := range destAccountNames {
+		destID := appCfg.Accounts[destName]
+		if destID == sCtx.AccountID {
+			fmt.Fprint(w, pkg.ColorRow(os.Stdout, statusColor("SKIPPED"), fmt.Sprintf("%s\t%s\t%s\t%s\n", destName, destID, "SKIPPED", "source account")))
+			continue
+		}
+		details, errShare := shareWithAccount(ctx, ec2Client, kmsClient, src, destName, destID)
+		status := StatusSuccess
+		if errShare != nil {
+			status, details = StatusFailed, errShare.Error()
+			failed++
+		}
+		fmt.Fprint(w, pkg.ColorRow(os.Stdout, statusColor(status), fmt.Sprintf("%s\t%s\t%s\t%s\n", destName, destID, status, details)))
+	}
+	w.Flush()
+
+	if len(src.KMSKeyIDs) > 0 {
+		fmt.Fprintln(os.Stderr, "Note: principals in the destination accounts still need IAM permissions to use the shared KMS key(s).")
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d out of %d destination(s) failed", failed, len(destAccountNames))
+	}
+	return nil
+}
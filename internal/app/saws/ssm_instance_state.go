@@ -0,0 +1,149 @@
+package saws
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"saws/internal/pkg"
+
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	ssmtypes "github.com/aws/aws-sdk-go-v2/service/ssm/types"
+)
+
+// instanceStartTimeout bounds the wait for a started instance to run and for
+// its SSM agent to come online.
+const instanceStartTimeout = 5 * time.Minute
+
+// SSMInstanceFilter narrows the instance picker of `saws ssm`.
+type SSMInstanceFilter struct {
+	// States keeps only instances in one of these EC2 states (running,
+	// stopped, ...) or SSM ping statuses (online, connectionlost), lower case.
+	States []string
+	// IncludeStopped also lists stopped instances SSM no longer reports.
+	IncludeStopped bool
+}
+
+// ParseInstanceStates splits an -instance-state value into lower-case states.
+func ParseInstanceStates(value string) []string {
+	var states []string
+	for _, state := range strings.Split(value, ",") {
+		if state = strings.ToLower(strings.TrimSpace(state)); state != "" {
+			states = append(states, state)
+		}
+	}
+	return states
+}
+
+// needsEC2 reports whether the filter needs the EC2 view of the instances.
+func (f SSMInstanceFilter) needsEC2() bool {
+	return f.IncludeStopped || len(f.States) > 0
+}
+
+// matches reports whether an instance in EC2 state and SSM ping status passes.
+func (f SSMInstanceFilter) matches(state, ping string) bool {
+	if len(f.States) == 0 {
+		return true
+	}
+	return slices.Contains(f.States, strings.ToLower(state)) || slices.Contains(f.States, strings.ToLower(ping))
+}
+
+// ec2InstanceInfo is what the picker shows of an instance from EC2.
+type ec2InstanceInfo struct {
+	State     string
+	Name      string
+	PrivateIP string
+	Platform  string
+}
+
+func ec2ConfigForCredentials(ctx context.Context, awsCreds aws.Credentials, region string) (aws.Config, error) {
+	return pkg.LoadAWSConfig(ctx,
+		awsconfig.WithCredentialsProvider(aws.CredentialsProviderFunc(func(ctx context.Context) (aws.Credentials, error) {
+			return awsCreds, nil
+		})),
+		awsconfig.WithRegion(region),
+	)
+}
+
+// describeEC2Instances returns the instances of the region that are not
+// terminated, keyed by instance ID.
+func describeEC2Instances(ctx context.Context, cfg aws.Config) (map[string]ec2InstanceInfo, error) {
+	instances := make(map[string]ec2InstanceInfo)
+	paginator := ec2.NewDescribeInstancesPaginator(ec2.NewFromConfig(cfg), &ec2.DescribeInstancesInput{
+		Filters: []ec2types.Filter{{Name: aws.String("instance-state-name"), Values: []string{"pending", "running", "stopping", "stopped"}}},
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("ec2:DescribeInstances failed: %w", err)
+		}
+		for _, reservation := range page.Reservations {
+			for _, instance := range reservation.Instances {
+				info := ec2InstanceInfo{PrivateIP: aws.ToString(instance.PrivateIpAddress), Platform: aws.ToString(instance.PlatformDetails)}
+				if instance.State != nil {
+					info.State = string(instance.State.Name)
+				}
+				for _, tag := range instance.Tags {
+					if aws.ToString(tag.Key) == "Name" {
+						info.Name = aws.ToString(tag.Value)
+					}
+				}
+				instances[aws.ToString(instance.InstanceId)] = info
+			}
+		}
+	}
+	return instances, nil
+}
+
+// offerToStartInstance asks whether to start a stopped instance and, if so,
+// starts it and waits until its SSM agent is online. It returns false when the
+// user declines.
+func offerToStartInstance(ctx context.Context, awsCreds aws.Credentials, region, instanceID string) (bool, error) {
+	start := false
+	prompt := &survey.Confirm{Message: fmt.Sprintf("Instance %s is stopped. Start it and connect once SSM can reach it?", instanceID), Default: true}
+	if err := pkg.AskOne(prompt, &start); err != nil || !start {
+		return false, err
+	}
+	cfg, err := ec2ConfigForCredentials(ctx, awsCreds, region)
+	if err != nil {
+		return false, fmt.Errorf("failed to load AWS SDK config for EC2 client: %w", err)
+	}
+	ec2Client := ec2.NewFromConfig(cfg)
+	if _, err := ec2Client.StartInstances(ctx, &ec2.StartInstancesInput{InstanceIds: []string{instanceID}}); err != nil {
+		return false, fmt.Errorf("ec2:StartInstances failed for %s: %w", instanceID, err)
+	}
+	fmt.Fprintf(os.Stderr, "Starting %s; waiting for it to run...\n", instanceID)
+	waiter := ec2.NewInstanceRunningWaiter(ec2Client)
+	if err := waiter.Wait(ctx, &ec2.DescribeInstancesInput{InstanceIds: []string{instanceID}}, instanceStartTimeout); err != nil {
+		return false, fmt.Errorf("instance %s did not reach running: %w", instanceID, err)
+	}
+
+	fmt.Fprintf(os.Stderr, "Waiting for the SSM agent of %s to come online...\n", instanceID)
+	ssmClient := ssm.NewFromConfig(cfg)
+	deadline := time.Now().Add(instanceStartTimeout)
+	for time.Now().Before(deadline) {
+		out, err := ssmClient.DescribeInstanceInformation(ctx, &ssm.DescribeInstanceInformationInput{
+			Filters: []ssmtypes.InstanceInformationStringFilter{{Key: aws.String("InstanceIds"), Values: []string{instanceID}}},
+		})
+		if err != nil {
+			return false, fmt.Errorf("ssm:DescribeInstanceInformation failed for %s: %w", instanceID, err)
+		}
+		if len(out.InstanceInformationList) > 0 && out.InstanceInformationList[0].PingStatus == ssmtypes.PingStatusOnline {
+			return true, nil
+		}
+		select {
+		case <-ctx.Done():
+			return false, ctx.Err()
+		case <-time.After(5 * time.Second):
+		}
+	}
+	return false, fmt.Errorf("the SSM agent of %s did not come online within %s", instanceID, instanceStartTimeout)
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"sort"
 	"strings"
 	"time"
@@ -15,12 +16,14 @@ import (
 	"github.com/AlecAivazis/survey/v2"
 	"github.com/aws/aws-sdk-go-v2/aws"
 	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
 	"github.com/aws/aws-sdk-go-v2/service/ssm"
 	ssmtypes "github.com/aws/aws-sdk-go-v2/service/ssm/types"
+	ststypes "github.com/aws/aws-sdk-go-v2/service/sts/types"
 )
 
 func GetSSMInstanceInfoList(ctx context.Context, credsaws aws.Credentials, region string) ([]ssmtypes.InstanceInformation, error) {
-	awsSDKConfig, err := awsconfig.LoadDefaultConfig(ctx,
+	awsSDKConfig, err := pkg.LoadAWSConfig(ctx,
 		awsconfig.WithCredentialsProvider(aws.CredentialsProviderFunc(func(ctx context.Context) (aws.Credentials, error) {
 			return credsaws, nil
 		})),
@@ -57,7 +60,125 @@ func GetSSMInstanceInfoList(ctx context.Context, credsaws aws.Credentials, regio
 	return allInstanceInfo, nil
 }
 
-func HandleSSMSession(ctx context.Context, instanceIDFromFlag, accountSelectorFlag, roleFlag, regionFlagFromCmd string) error {
+// ssmPickerEntry is one instance offered by selectSSMInstance.
+type ssmPickerEntry struct {
+	ID, Name, Platform, IP, Ping string
+	// State is the EC2 state, known only when the filter needed EC2 data.
+	State string
+}
+
+// selectSSMInstance lists the SSM-managed instances in the context's account and
+// region that pass filter and prompts for one. With filter.IncludeStopped,
+// stopped instances are listed from EC2 as well, and picking one offers to
+// start it. It returns an empty ID when there is nothing to pick.
+func selectSSMInstance(ctx context.Context, awsCreds aws.Credentials, sCtx *pkg.SelectedContext, promptMessage string, filter SSMInstanceFilter) (string, error) {
+	pkg.LogVerbosef("Listing available SSM-managed instances for selection...")
+	instanceList, errList := GetSSMInstanceInfoList(ctx, awsCreds, sCtx.Region)
+	if errList != nil {
+		return "", fmt.Errorf("failed to list SSM instances for selection: %w", errList)
+	}
+
+	var ec2Instances map[string]ec2InstanceInfo
+	if filter.needsEC2() {
+		cfg, err := ec2ConfigForCredentials(ctx, awsCreds, sCtx.Region)
+		if err != nil {
+			return "", fmt.Errorf("failed to load AWS SDK config for EC2 client: %w", err)
+		}
+		if ec2Instances, err = describeEC2Instances(ctx, cfg); err != nil {
+			return "", fmt.Errorf("failed to list EC2 instances for selection: %w", err)
+		}
+	}
+
+	var entries []ssmPickerEntry
+	listed := make(map[string]bool)
+	for _, info := range instanceList {
+		entry := ssmPickerEntry{ID: "N/A", Name: "N/A", Platform: "N/A", IP: "N/A", Ping: "N/A"}
+		if info.InstanceId != nil {
+			entry.ID = *info.InstanceId
+		}
+		if info.ComputerName != nil {
+			entry.Name = *info.ComputerName
+		}
+		if info.PlatformType != "" {
+			entry.Platform = string(info.PlatformType)
+		}
+		if info.IPAddress != nil {
+			entry.IP = *info.IPAddress
+		}
+		if info.PingStatus != "" {
+			entry.Ping = string(info.PingStatus)
+		}
+		if ec2Instances != nil {
+			entry.State = ec2Instances[entry.ID].State
+		}
+		listed[entry.ID] = true
+		if filter.matches(entry.State, entry.Ping) {
+			entries = append(entries, entry)
+		}
+	}
+	if filter.needsEC2() {
+		// Instances stopped long enough drop out of SSM; EC2 still knows them.
+		for id, info := range ec2Instances {
+			if listed[id] || (info.State != string(ec2types.InstanceStateNameStopped) && info.State != string(ec2types.InstanceStateNameStopping)) {
+				continue
+			}
+			entry := ssmPickerEntry{ID: id, Name: info.Name, Platform: info.Platform, IP: info.PrivateIP, Ping: "-", State: info.State}
+			if entry.Name == "" {
+				entry.Name = "N/A"
+			}
+			if filter.matches(entry.State, entry.Ping) {
+				entries = append(entries, entry)
+			}
+		}
+	}
+	if len(entries) == 0 {
+		fmt.Fprintf(os.Stderr, "No SSM-managed instances found in Account: %s (%s), Region: %s to select from.\n", sCtx.AccountName, sCtx.AccountID, sCtx.Region)
+		return "", nil // Not an error, just nothing to do
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].Name != entries[j].Name {
+			return entries[i].Name < entries[j].Name
+		}
+		return entries[i].ID < entries[j].ID
+	})
+
+	instanceOptions := make([]string, len(entries))
+	optionToEntry := make(map[string]ssmPickerEntry)
+	for i, entry := range entries {
+		displayStr := fmt.Sprintf("%-19s | %-20s | %-7s | %-15s | %s", entry.ID, entry.Name, entry.Platform, entry.IP, entry.Ping)
+		if entry.State != "" {
+			displayStr += " | " + entry.State
+		}
+		instanceOptions[i] = displayStr
+		optionToEntry[displayStr] = entry
+	}
+
+	instanceOptions = pkg.RecentInstancesFirst(sCtx, instanceOptions, func(option string) string { return optionToEntry[option].ID })
+
+	chosenDisplayStr := ""
+	prompt := &survey.Select{Message: promptMessage, Options: instanceOptions, PageSize: 15}
+	errSurvey := pkg.AskOne(prompt, &chosenDisplayStr, survey.WithValidator(survey.Required), pkg.WithFuzzyFilter)
+	if errSurvey != nil {
+		return "", fmt.Errorf("instance selection failed: %w", errSurvey)
+	}
+	chosen := optionToEntry[chosenDisplayStr]
+	if chosen.State == string(ec2types.InstanceStateNameStopped) {
+		started, err := offerToStartInstance(ctx, awsCreds, sCtx.Region, chosen.ID)
+		if err != nil {
+			return "", err
+		}
+		if !started {
+			return "", nil
+		}
+	}
+	pkg.RecordRecentInstance(sCtx, chosen.ID)
+	return chosen.ID, nil
+}
+
+// HandleSSMSession handles the logic for the -ssm mode. Exported.
+// filter narrows the instance picker shown when instanceIDFromFlag is empty.
+func HandleSSMSession(ctx context.Context, instanceIDFromFlag, accountSelectorFlag, roleFlag, regionFlagFromCmd string, filter SSMInstanceFilter) error {
 	pkg.LogVerbosef("Preparing for SSM session...")
 	sCtx, creds, err := pkg.EstablishAWSContextAndAssumeRole(ctx, accountSelectorFlag, roleFlag, regionFlagFromCmd, "SSMSessionSetup")
 	if err != nil {
@@ -68,84 +189,29 @@ func HandleSSMSession(ctx context.Context, instanceIDFromFlag, accountSelectorFl
 	awsCreds := aws.Credentials{AccessKeyID: *creds.AccessKeyId, SecretAccessKey: *creds.SecretAccessKey, SessionToken: *creds.SessionToken, Source: "SawsAssumedRoleForSSM"}
 
 	if targetInstanceID == "" {
-		pkg.LogVerbosef("No instance ID provided via -i flag. Listing available SSM-managed instances for selection...")
-		instanceList, errList := GetSSMInstanceInfoList(ctx, awsCreds, sCtx.Region)
-		if errList != nil {
-			return fmt.Errorf("failed to list SSM instances for selection: %w", errList)
-		}
-		if len(instanceList) == 0 {
-			fmt.Fprintf(os.Stderr, "No SSM-managed instances found in Account: %s (%s), Region: %s to select from.\n", sCtx.AccountName, sCtx.AccountID, sCtx.Region)
-			return nil // Not an error, just nothing to do
-		}
-
-		instanceOptions := make([]string, len(instanceList))
-		optionToInstanceID := make(map[string]string)
-		sort.SliceStable(instanceList, func(i, j int) bool {
-			nameI := ""
-			if instanceList[i].ComputerName != nil {
-				nameI = *instanceList[i].ComputerName
-			}
-			nameJ := ""
-			if instanceList[j].ComputerName != nil {
-				nameJ = *instanceList[j].ComputerName
-			}
-			if nameI != nameJ {
-				return nameI < nameJ
-			}
-			idI := ""
-			if instanceList[i].InstanceId != nil {
-				idI = *instanceList[i].InstanceId
-			}
-			idJ := ""
-			if instanceList[j].InstanceId != nil {
-				idJ = *instanceList[j].InstanceId
-			}
-			return idI < idJ
-		})
-
-		for i, info := range instanceList {
-			instID := "N/A"
-			if info.InstanceId != nil {
-				instID = *info.InstanceId
-			}
-			compName := "N/A"
-			if info.ComputerName != nil {
-				compName = *info.ComputerName
-			}
-			platType := "N/A"
-			if info.PlatformType != "" {
-				platType = string(info.PlatformType)
-			}
-			ipAddr := "N/A"
-			if info.IPAddress != nil {
-				ipAddr = *info.IPAddress
-			}
-			pingStat := "N/A"
-			if info.PingStatus != "" {
-				pingStat = string(info.PingStatus)
-			}
-
-			displayStr := fmt.Sprintf("%-19s | %-20s | %-7s | %-15s | %s", instID, compName, platType, ipAddr, pingStat)
-			instanceOptions[i] = displayStr
-			optionToInstanceID[displayStr] = instID
+		pkg.LogVerbosef("No instance ID provided via -i flag.")
+		targetInstanceID, err = selectSSMInstance(ctx, awsCreds, sCtx, "Choose an SSM instance to connect to:", filter)
+		if err != nil {
+			return err
 		}
-
-		chosenDisplayStr := ""
-		prompt := &survey.Select{Message: "Choose an SSM instance to connect to:", Options: instanceOptions, PageSize: 15}
-		errSurvey := survey.AskOne(prompt, &chosenDisplayStr, survey.WithValidator(survey.Required))
-		if errSurvey != nil {
-			return fmt.Errorf("instance selection failed: %w", errSurvey)
+		if targetInstanceID == "" {
+			return nil
 		}
-		targetInstanceID = optionToInstanceID[chosenDisplayStr]
 		pkg.LogVerbosef("Instance '%s' selected for SSM session.", targetInstanceID)
 	} else {
 		pkg.LogVerbosef("Instance ID '%s' provided via -i flag. Attempting direct connection.", targetInstanceID)
+		pkg.RecordRecentInstance(sCtx, targetInstanceID)
 	}
 
 	if targetInstanceID == "" {
 		return errors.New("internal error: target instance ID for SSM session is empty after selection/flag check")
 	}
+	return runSSMSession(sCtx, creds, targetInstanceID)
+}
 
+// runSSMSession runs an interactive 'aws ssm start-session' to targetInstanceID
+// with the assumed role's credentials.
+func runSSMSession(sCtx *pkg.SelectedContext, creds *ststypes.Credentials, targetInstanceID string) error {
 	awsCLIPath, err := exec.LookPath("aws")
 	if err != nil {
 		fmt.Fprintln(os.Stderr, "Error: AWS CLI ('aws') not found in PATH. Required for SSM Session Mode.")
@@ -153,20 +219,10 @@ func HandleSSMSession(ctx context.Context, instanceIDFromFlag, accountSelectorFl
 		return errors.New("aws cli not found")
 	}
 	pkg.LogVerbosef("Using AWS CLI at: %s", awsCLIPath)
+	ensureSessionManagerPlugin()
 
 	pkg.LogVerbosef("Preparing environment for SSM session command...")
-	currentEnv := os.Environ()
-	newEnv := []string{}
-	for _, e := range currentEnv {
-		if !strings.HasPrefix(e, "AWS_ACCESS_KEY_ID=") && !strings.HasPrefix(e, "AWS_SECRET_ACCESS_KEY=") && !strings.HasPrefix(e, "AWS_SESSION_TOKEN=") && !strings.HasPrefix(e, "AWS_SECURITY_TOKEN=") && !strings.HasPrefix(e, "AWS_REGION=") && !strings.HasPrefix(e, "AWS_DEFAULT_REGION=") && !strings.HasPrefix(e, "AWS_PROFILE=") {
-			newEnv = append(newEnv, e)
-		}
-	}
-	newEnv = append(newEnv, fmt.Sprintf("AWS_ACCESS_KEY_ID=%s", *creds.AccessKeyId))
-	newEnv = append(newEnv, fmt.Sprintf("AWS_SECRET_ACCESS_KEY=%s", *creds.SecretAccessKey))
-	newEnv = append(newEnv, fmt.Sprintf("AWS_SESSION_TOKEN=%s", *creds.SessionToken))
-	newEnv = append(newEnv, fmt.Sprintf("AWS_REGION=%s", sCtx.Region))
-	newEnv = append(newEnv, fmt.Sprintf("AWS_DEFAULT_REGION=%s", sCtx.Region))
+	newEnv := assumedRoleEnv(creds, sCtx.Region)
 
 	fmt.Fprintf(os.Stderr, "Starting SSM session to instance '%s' in region '%s'...\n", targetInstanceID, sCtx.Region)
 	if creds.Expiration != nil {
@@ -174,7 +230,7 @@ func HandleSSMSession(ctx context.Context, instanceIDFromFlag, accountSelectorFl
 	} else {
 		fmt.Fprintf(os.Stderr, "Context: Account=%s(%s), Role=%s. Session expiration time not available.\n", sCtx.AccountName, sCtx.AccountID, sCtx.RoleName)
 	}
-	fmt.Fprintln(os.Stderr, "Ensure the Session Manager plugin for AWS CLI is installed. Type 'exit' or Ctrl+D to end session.")
+	fmt.Fprintln(os.Stderr, "Type 'exit' to end session.")
 
 	ssmCmd := exec.Command(awsCLIPath, "ssm", "start-session", "--target", targetInstanceID, "--region", sCtx.Region)
 	ssmCmd.Env = newEnv
@@ -192,3 +248,55 @@ func HandleSSMSession(ctx context.Context, instanceIDFromFlag, accountSelectorFl
 	}
 	return nil
 }
+
+// newSSMPortForwardCommand builds an 'aws ssm start-session' command that forwards
+// localPort through the target instance to remoteHost:remotePort.
+func newSSMPortForwardCommand(ctx context.Context, awsCLIPath string, env []string, targetInstanceID, region, remoteHost string, remotePort, localPort int32) *exec.Cmd {
+	parameters := fmt.Sprintf(`{"host":["%s"],"portNumber":["%d"],"localPortNumber":["%d"]}`, remoteHost, remotePort, localPort)
+	pkg.LogVerbosef("Port forwarding parameters for instance %s: %s", targetInstanceID, parameters)
+	cmd := exec.CommandContext(ctx, awsCLIPath, "ssm", "start-session",
+		"--target", targetInstanceID,
+		"--document-name", "AWS-StartPortForwardingSessionToRemoteHost",
+		"--parameters", parameters,
+		"--region", region)
+	cmd.Env = env
+	return cmd
+}
+
+// resolveSSMInstance maps a bastion selector to an instance ID. The selector is
+// either an instance ID or a wildcard matched against SSM computer names, in
+// which case the first Online match wins.
+func resolveSSMInstance(ctx context.Context, awsCreds aws.Credentials, region, selector string) (string, error) {
+	if strings.HasPrefix(selector, "i-") || strings.HasPrefix(selector, "mi-") {
+		return selector, nil
+	}
+	instanceList, err := GetSSMInstanceInfoList(ctx, awsCreds, region)
+	if err != nil {
+		return "", fmt.Errorf("failed to list SSM instances to resolve '%s': %w", selector, err)
+	}
+	var fallback string
+	for _, info := range instanceList {
+		if info.InstanceId == nil || info.ComputerName == nil {
+			continue
+		}
+		match, errMatch := filepath.Match(selector, *info.ComputerName)
+		if errMatch != nil {
+			return "", fmt.Errorf("invalid instance selector '%s': %w", selector, errMatch)
+		}
+		if !match {
+			continue
+		}
+		if info.PingStatus == ssmtypes.PingStatusOnline {
+			pkg.LogVerbosef("Resolved instance selector '%s' to %s (%s).", selector, *info.InstanceId, *info.ComputerName)
+			return *info.InstanceId, nil
+		}
+		if fallback == "" {
+			fallback = *info.InstanceId
+		}
+	}
+	if fallback != "" {
+		pkg.LogVerbosef("Warning: no Online instance matches '%s'; using %s.", selector, fallback)
+		return fallback, nil
+	}
+	return "", fmt.Errorf("no SSM-managed instance matches selector '%s' in region %s", selector, region)
+}
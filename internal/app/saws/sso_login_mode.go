@@ -0,0 +1,27 @@
+package saws
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"saws/internal/pkg"
+)
+
+// HandleSSOLogin handles the logic for the `sso-login` mode. Exported.
+// It signs in to IAM Identity Center for profile, the base profile when empty,
+// without the AWS CLI, and checks the profile's credentials afterwards.
+func HandleSSOLogin(ctx context.Context, profile string) error {
+	if profile == "" {
+		profile = pkg.BaseProfileForAssume
+	}
+	if err := pkg.SSOLogin(ctx, profile); err != nil {
+		return err
+	}
+	callerArn, err := verifyBaseProfile(ctx, profile)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Base credentials OK: %s\n", callerArn)
+	return nil
+}
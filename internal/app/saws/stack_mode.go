@@ -0,0 +1,104 @@
+package saws
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"saws/internal/pkg"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+	ststypes "github.com/aws/aws-sdk-go-v2/service/sts/types"
+	"github.com/aws/smithy-go"
+)
+
+// stackRow is one line of the -stack table.
+type stackRow struct {
+	AccountName string
+	Region      string
+	Status      string
+	Drift       string
+	LastUpdated string
+}
+
+// describeStackRow reports the status of stackName in one target; a missing
+// stack is a row, not an error, so the table shows where it was never deployed.
+func describeStackRow(ctx context.Context, target FanOutTarget, creds *ststypes.Credentials, stackName string) ([]stackRow, error) {
+	cfg, err := pkg.LoadAssumedRoleConfig(ctx, creds, target.Region)
+	if err != nil {
+		return nil, err
+	}
+	row := stackRow{AccountName: target.AccountName, Region: target.Region, Drift: "-", LastUpdated: "-"}
+
+	pkg.LogVerbosef("Describing stack %s in Account: %s, Region: %s", stackName, target.AccountName, target.Region)
+	out, err := cloudformation.NewFromConfig(cfg).DescribeStacks(ctx, &cloudformation.DescribeStacksInput{StackName: aws.String(stackName)})
+	if err != nil {
+		var apiErr smithy.APIError
+		if errors.As(err, &apiErr) && apiErr.ErrorCode() == "ValidationError" && strings.Contains(apiErr.ErrorMessage(), "does not exist") {
+			row.Status = "(not found)"
+			return []stackRow{row}, nil
+		}
+		return nil, fmt.Errorf("cloudformation:DescribeStacks failed for %s: %w", stackName, err)
+	}
+	if len(out.Stacks) == 0 {
+		row.Status = "(not found)"
+		return []stackRow{row}, nil
+	}
+
+	stack := out.Stacks[0]
+	row.Status = string(stack.StackStatus)
+	if stack.DriftInformation != nil {
+		row.Drift = string(stack.DriftInformation.StackDriftStatus)
+	}
+	lastUpdated := stack.LastUpdatedTime
+	if lastUpdated == nil {
+		lastUpdated = stack.CreationTime
+	}
+	if lastUpdated != nil {
+		row.LastUpdated = lastUpdated.Local().Format("2006-01-02 15:04:05")
+	}
+	return []stackRow{row}, nil
+}
+
+// HandleStackStatus handles the logic for the -stack mode. Exported.
+// It prints the status, last drift detection result, and last update time of
+// stackName in every target as one table.
+func HandleStackStatus(ctx context.Context, baseCfg aws.Config, appCfg *pkg.AppConfig, accountNames, regions []string, roleToAssume, stackName string) error {
+	rows, failures, summary := CollectFanOut(ctx, baseCfg, appCfg, accountNames, regions,
+		FanOutOptions{Label: "Stack Mode", RoleToAssume: roleToAssume, SessionName: "StackStatus"},
+		func(ctx context.Context, target FanOutTarget, creds *ststypes.Credentials) ([]stackRow, error) {
+			return describeStackRow(ctx, target, creds, stackName)
+		})
+
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].AccountName != rows[j].AccountName {
+			return rows[i].AccountName < rows[j].AccountName
+		}
+		return rows[i].Region < rows[j].Region
+	})
+
+	fmt.Fprintf(os.Stderr, "Stack: %s\n", stackName)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprint(w, pkg.ColorRow(os.Stdout, pkg.ColorPlain, "ACCOUNT\tREGION\tSTATUS\tDRIFT\tLAST UPDATED\n"))
+	for _, r := range rows {
+		color := statusColor(r.Status)
+		if color != pkg.ColorRed && r.Drift == "DRIFTED" {
+			color = pkg.ColorRed
+		}
+		fmt.Fprint(w, pkg.ColorRow(os.Stdout, color, fmt.Sprintf("%s\t%s\t%s\t%s\t%s\n", r.AccountName, r.Region, r.Status, r.Drift, r.LastUpdated)))
+	}
+	w.Flush()
+
+	for _, f := range failures {
+		fmt.Fprintf(os.Stderr, "Warning: Could not describe stack for Account: %s, Region: %s: %s\n", f.Target.AccountName, f.Target.Region, f.Err)
+	}
+	if len(failures) > 0 {
+		return fmt.Errorf("%d out of %d target(s) could not be queried", len(failures), summary.Total)
+	}
+	return nil
+}
@@ -0,0 +1,210 @@
+package saws
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"saws/internal/pkg"
+)
+
+// statsCount accumulates runs, failures, and durations for one key of the report.
+type statsCount struct {
+	runs, failed  int
+	attempts      int // targets and assumed roles, from the audit log
+	attemptFailed int
+	timed         int
+	total         time.Duration
+	accounts      map[string]bool
+}
+
+func (c *statsCount) addRun(entry pkg.HistoryEntry) {
+	c.runs++
+	if entry.ExitCode != 0 {
+		c.failed++
+	}
+	if d, err := time.ParseDuration(entry.Duration); err == nil {
+		c.timed++
+		c.total += d
+	}
+}
+
+func (c *statsCount) average() time.Duration {
+	if c.timed == 0 {
+		return 0
+	}
+	return (c.total / time.Duration(c.timed)).Round(100 * time.Millisecond)
+}
+
+func rate(failed, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(failed) / float64(total) * 100
+}
+
+// statsRow is one line of a section of `saws stats`.
+type statsRow struct {
+	Name           string   `json:"name"`
+	Runs           int      `json:"runs"`
+	Failed         int      `json:"failed"`
+	FailureRate    float64  `json:"failure_rate"`
+	AverageSeconds float64  `json:"average_seconds,omitempty"`
+	Accounts       []string `json:"accounts,omitempty"`
+}
+
+// StatsReport is what `saws stats -json` prints.
+type StatsReport struct {
+	Since       time.Time  `json:"since"`
+	Invocations int        `json:"invocations"`
+	AuditLog    string     `json:"audit_log,omitempty"`
+	Commands    []statsRow `json:"commands"`
+	Accounts    []statsRow `json:"accounts"`
+	Roles       []statsRow `json:"roles"`
+}
+
+// statsRows turns counts into rows, most runs first.
+func statsRows(counts map[string]*statsCount, useAttempts bool) []statsRow {
+	rows := make([]statsRow, 0, len(counts))
+	for name, c := range counts {
+		row := statsRow{Name: name, Runs: c.runs, Failed: c.failed, AverageSeconds: c.average().Seconds()}
+		if useAttempts {
+			row.Runs, row.Failed = c.attempts, c.attemptFailed
+		}
+		row.FailureRate = rate(row.Failed, row.Runs)
+		for account := range c.accounts {
+			row.Accounts = append(row.Accounts, account)
+		}
+		slices.Sort(row.Accounts)
+		rows = append(rows, row)
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].Runs != rows[j].Runs {
+			return rows[i].Runs > rows[j].Runs
+		}
+		return rows[i].Name < rows[j].Name
+	})
+	return rows
+}
+
+func countFor(counts map[string]*statsCount, key string) *statsCount {
+	c, ok := counts[key]
+	if !ok {
+		c = &statsCount{accounts: make(map[string]bool)}
+		counts[key] = c
+	}
+	return c
+}
+
+// HandleStats handles the logic for the `stats` mode. Exported.
+// It summarizes the local history: runs, failure rate, and average duration
+// per command; per account; and per role with the accounts it was assumed in.
+// With an audit log (defaults.audit_log or SAWS_AUDIT_LOG), the account
+// failure rates count each fan-out target and assumed role on its own, so an
+// account that fails in every run stands out from the ones that only shared a
+// run with it; without one, they count the runs that used the account.
+func HandleStats(asJSON bool) error {
+	entries, err := pkg.LoadHistory()
+	if err != nil {
+		return fmt.Errorf("could not read history: %w", err)
+	}
+	records, auditPath, err := pkg.LoadAuditLog()
+	if err != nil {
+		return fmt.Errorf("could not read audit log '%s': %w", auditPath, err)
+	}
+	if len(entries) == 0 && len(records) == 0 {
+		fmt.Fprintln(os.Stderr, "No history yet; saws invocations are recorded as you run them.")
+		return nil
+	}
+
+	report := StatsReport{Invocations: len(entries)}
+	commands := make(map[string]*statsCount)
+	accounts := make(map[string]*statsCount)
+	roles := make(map[string]*statsCount)
+	for _, entry := range entries {
+		if report.Since.IsZero() || entry.StartedAt.Before(report.Since) {
+			report.Since = entry.StartedAt
+		}
+		mode := entry.Mode
+		if mode == "" {
+			mode = "(none)"
+		}
+		countFor(commands, mode).addRun(entry)
+		for _, account := range slices.Compact(slices.Sorted(slices.Values(entry.Accounts))) {
+			countFor(accounts, account).addRun(entry)
+		}
+		if entry.Role != "" {
+			c := countFor(roles, entry.Role)
+			c.addRun(entry)
+			for _, account := range entry.Accounts {
+				c.accounts[account] = true
+			}
+		}
+	}
+
+	useAttempts := false
+	for _, rec := range records {
+		if rec.Event != pkg.AuditTarget && rec.Event != pkg.AuditAssumeRole || rec.Account == "" {
+			continue
+		}
+		if len(entries) > 0 && rec.Time.Before(report.Since) {
+			continue
+		}
+		useAttempts = true
+		report.AuditLog = auditPath
+		c := countFor(accounts, rec.Account)
+		c.attempts++
+		if rec.Result != "ok" {
+			c.attemptFailed++
+		}
+	}
+
+	report.Commands = statsRows(commands, false)
+	report.Accounts = statsRows(accounts, useAttempts)
+	report.Roles = statsRows(roles, false)
+	if asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(report)
+	}
+
+	fmt.Printf("%d invocation(s) since %s.\n", report.Invocations, report.Since.Local().Format(time.DateTime))
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	printSection := func(title, header string, rows []statsRow, line func(statsRow) string) {
+		fmt.Fprintf(w, "\n%s\n", title)
+		fmt.Fprint(w, pkg.ColorRow(os.Stdout, pkg.ColorPlain, header))
+		for _, row := range rows {
+			color := pkg.ColorPlain
+			if row.FailureRate >= 50 {
+				color = pkg.ColorRed
+			} else if row.Failed > 0 {
+				color = pkg.ColorYellow
+			}
+			fmt.Fprint(w, pkg.ColorRow(os.Stdout, color, line(row)))
+		}
+	}
+	printSection("Commands", "COMMAND\tRUNS\tFAILED\tFAILURE RATE\tAVG DURATION\n", report.Commands, func(r statsRow) string {
+		return fmt.Sprintf("%s\t%d\t%d\t%.0f%%\t%s\n", r.Name, r.Runs, r.Failed, r.FailureRate, time.Duration(r.AverageSeconds*float64(time.Second)))
+	})
+	accountsHeader := "ACCOUNT\tRUNS\tFAILED\tFAILURE RATE\tAVG DURATION\n"
+	if useAttempts {
+		accountsHeader = "ACCOUNT\tTARGETS\tFAILED\tFAILURE RATE\tAVG RUN DURATION\n"
+	}
+	printSection("Accounts", accountsHeader, report.Accounts, func(r statsRow) string {
+		return fmt.Sprintf("%s\t%d\t%d\t%.0f%%\t%s\n", r.Name, r.Runs, r.Failed, r.FailureRate, time.Duration(r.AverageSeconds*float64(time.Second)))
+	})
+	printSection("Roles", "ROLE\tRUNS\tFAILED\tFAILURE RATE\tACCOUNTS\n", report.Roles, func(r statsRow) string {
+		return fmt.Sprintf("%s\t%d\t%d\t%.0f%%\t%d\n", r.Name, r.Runs, r.Failed, r.FailureRate, len(r.Accounts))
+	})
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if !useAttempts {
+		fmt.Fprintln(os.Stderr, "\nAccount failures count whole runs; set defaults.audit_log (or SAWS_AUDIT_LOG) to count each target on its own.")
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+package saws
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"saws/internal/pkg"
+)
+
+// promptLabel is the prefix the -e sub-shell puts in front of the user's own
+// prompt. It expands the SAWS_INFO_* variables when the rc file runs.
+const promptLabel = "(saws:${SAWS_INFO_ACCOUNT_NAME}/${SAWS_INFO_ROLE_NAME}/${SAWS_INFO_REGION})"
+
+// subShellPrompt holds what makes a shell show the saws context in its prompt
+// after reading the user's own rc files.
+type subShellPrompt struct {
+	args    []string
+	env     []string
+	cleanup func()
+}
+
+// shellName is the lower-case program name of shell, without ".exe".
+func shellName(shell string) string {
+	return strings.TrimSuffix(strings.ToLower(filepath.Base(shell)), ".exe")
+}
+
+// prepareSubShellPrompt sets up the prompt for bash (a temporary --rcfile),
+// zsh (a temporary ZDOTDIR), fish (an init command), and PowerShell and cmd on
+// Windows (a startup command). Other shells, and defaults.shell_prompt: false,
+// get no prompt changes.
+func prepareSubShellPrompt(shell string) (subShellPrompt, error) {
+	prompt := subShellPrompt{cleanup: func() {}}
+	if !pkg.ShellPrompt {
+		return prompt, nil
+	}
+	color := pkg.UseColor(os.Stdout)
+	switch shellName(shell) {
+	case "bash":
+		label := promptLabel
+		if color {
+			label = `\[\033[01;32m\]` + label + `\[\033[00m\]`
+		}
+		dir, err := os.MkdirTemp("", "saws-shell-")
+		if err != nil {
+			return prompt, err
+		}
+		prompt.cleanup = func() { os.RemoveAll(dir) }
+		rcFile := filepath.Join(dir, "bashrc")
+		rc := fmt.Sprintf(`[ -f ~/.bashrc ] && . ~/.bashrc
+PS1="%s ${PS1:-\\$ }"
+`, label)
+		if err := os.WriteFile(rcFile, []byte(rc), 0o600); err != nil {
+			prompt.cleanup()
+			return prompt, err
+		}
+		prompt.args = []string{"--rcfile", rcFile}
+	case "zsh":
+		label := promptLabel
+		if color {
+			label = "%F{green}" + label + "%f"
+		}
+		dir, err := os.MkdirTemp("", "saws-shell-")
+		if err != nil {
+			return prompt, err
+		}
+		prompt.cleanup = func() { os.RemoveAll(dir) }
+		userDir := os.Getenv("ZDOTDIR")
+		if userDir == "" {
+			userDir, _ = os.UserHomeDir()
+		}
+		// zsh reads .zshenv and .zshrc from ZDOTDIR: each sources the user's file
+		// with ZDOTDIR restored, and .zshrc then hands ZDOTDIR back for good.
+		zshenv := `_saws_zdotdir=$ZDOTDIR
+ZDOTDIR=$SAWS_USER_ZDOTDIR
+[ -f "$ZDOTDIR/.zshenv" ] && . "$ZDOTDIR/.zshenv"
+ZDOTDIR=$_saws_zdotdir
+unset _saws_zdotdir
+`
+		zshrc := fmt.Sprintf(`ZDOTDIR=$SAWS_USER_ZDOTDIR
+unset SAWS_USER_ZDOTDIR
+[ -f "$ZDOTDIR/.zshrc" ] && . "$ZDOTDIR/.zshrc"
+PROMPT="%s ${PROMPT:-%%# }"
+`, label)
+		for name, content := range map[string]string{".zshenv": zshenv, ".zshrc": zshrc} {
+			if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+				prompt.cleanup()
+				return prompt, err
+			}
+		}
+		prompt.env = []string{"ZDOTDIR=" + dir, "SAWS_USER_ZDOTDIR=" + userDir}
+	case "fish":
+		label := strings.NewReplacer("${", "$", "}", "").Replace(promptLabel)
+		echo := `echo -n "` + label + ` "`
+		if color {
+			echo = "set_color green; " + echo + "; set_color normal"
+		}
+		prompt.args = []string{"-C", "functions -c fish_prompt _saws_fish_prompt; function fish_prompt; " + echo + "; _saws_fish_prompt; end"}
+	case "pwsh", "powershell":
+		label := strings.NewReplacer("${", "$($env:", "}", ")").Replace(promptLabel)
+		if color {
+			label = "$([char]27)[32m" + label + "$([char]27)[0m"
+		}
+		prompt.args = []string{"-NoExit", "-Command", `$global:_sawsPrompt = $function:prompt; function global:prompt { "` + label + ` " + (& $global:_sawsPrompt) }`}
+	case "cmd":
+		// cmd expands %VAR% in the /K line, so the prompt holds the values.
+		label := strings.NewReplacer("${", "%", "}", "%").Replace(promptLabel)
+		if color {
+			label = "$E[32m" + label + "$E[0m"
+		}
+		prompt.args = []string{"/K", "prompt " + label + " $P$G"}
+	default:
+		pkg.LogVerbosef("No prompt setup for shell '%s'; the SAWS_INFO_* variables hold the context.", shell)
+	}
+	return prompt, nil
+}
@@ -0,0 +1,121 @@
+//go:build !windows
+
+package saws
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"saws/internal/pkg"
+)
+
+// detachFromTerminalSignals puts cmd in its own process group so a Ctrl+C aimed
+// at the foreground client does not also tear down background helpers.
+func detachFromTerminalSignals(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+// forwardedSignals are the signals saws passes on to a foreground command.
+var forwardedSignals = []os.Signal{syscall.SIGTERM, syscall.SIGHUP}
+
+// forwardSignal sends sig to the process group of cmd, so an interactive
+// shell's jobs end with it. A command sharing saws's own group gets it alone,
+// since signaling the group would reach saws again.
+func forwardSignal(cmd *exec.Cmd, sig os.Signal) {
+	pid := cmd.Process.Pid
+	pgid, err := syscall.Getpgid(pid)
+	if err == nil && pgid != syscall.Getpgrp() {
+		pid = -pgid
+	}
+	if err := syscall.Kill(pid, sig.(syscall.Signal)); err != nil && !errors.Is(err, syscall.ESRCH) {
+		pkg.LogVerbosef("Warning: Could not pass %v on to the command: %v", sig, err)
+	}
+}
+
+// exitStatus is the exit code of a finished command, or 128+n when signal n
+// killed it.
+func exitStatus(exitErr *exec.ExitError) int {
+	if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		return 128 + int(status.Signal())
+	}
+	return exitErr.ExitCode()
+}
+
+// defaultShell is the -e shell when neither defaults.shell nor $SHELL is set.
+func defaultShell() string {
+	return "bash"
+}
+
+// shellCommand runs a -c command line with bash.
+func shellCommand(ctx context.Context, commandLine string) *exec.Cmd {
+	return exec.CommandContext(ctx, "bash", "-c", commandLine)
+}
+
+// shellQuote quotes arg for bash when it holds anything beyond plain word
+// characters.
+func shellQuote(arg string) string {
+	if arg != "" && strings.Trim(arg, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789@%+=:,./_-") == "" {
+		return arg
+	}
+	return "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+}
+
+// envNameKey normalizes an environment variable name for comparison; names
+// are case-sensitive outside Windows.
+func envNameKey(name string) string {
+	return name
+}
+
+// sessionManagerPluginDirs are install locations of session-manager-plugin
+// that may be missing from PATH.
+func sessionManagerPluginDirs() []string {
+	return []string{"/usr/local/sessionmanagerplugin/bin"}
+}
+
+// interruptProcess asks cmd to exit, as Ctrl+C would.
+func interruptProcess(cmd *exec.Cmd) error {
+	return cmd.Process.Signal(os.Interrupt)
+}
+
+// killProcessTree kills cmd and, as detachFromTerminalSignals put it in a
+// process group of its own, the helpers it started, such as the Session
+// Manager plugin the AWS CLI runs.
+func killProcessTree(cmd *exec.Cmd) error {
+	if pgid, err := syscall.Getpgid(cmd.Process.Pid); err == nil && pgid == cmd.Process.Pid {
+		return syscall.Kill(-pgid, syscall.SIGKILL)
+	}
+	return cmd.Process.Kill()
+}
+
+// listenPrivateUnix listens on a unix socket at path with mode 0600. The
+// socket is bound and chmodded inside a new 0700 directory, which no other
+// user can reach, and only then renamed to path, so it is never open to them.
+func listenPrivateUnix(path string) (net.Listener, error) {
+	dir, err := os.MkdirTemp(filepath.Dir(path), ".saws-sock-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	bound := filepath.Join(dir, filepath.Base(path))
+	listener, err := net.Listen("unix", bound)
+	if err != nil {
+		return nil, err
+	}
+	// The socket leaves bound; the caller removes it at path.
+	listener.(*net.UnixListener).SetUnlinkOnClose(false)
+	if err := os.Chmod(bound, 0o600); err != nil {
+		listener.Close()
+		return nil, err
+	}
+	if err := os.Rename(bound, path); err != nil {
+		listener.Close()
+		return nil, err
+	}
+	return listener, nil
+}
@@ -0,0 +1,121 @@
+//go:build windows
+
+package saws
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"saws/internal/pkg"
+
+	"golang.org/x/sys/windows"
+)
+
+// detachFromTerminalSignals starts cmd in a new process group so console
+// Ctrl+C events aimed at the foreground client do not reach it.
+func detachFromTerminalSignals(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{CreationFlags: syscall.CREATE_NEW_PROCESS_GROUP}
+}
+
+// forwardedSignals are the signals saws passes on to a foreground command;
+// Windows reports closing the console or logging off as SIGTERM.
+var forwardedSignals = []os.Signal{syscall.SIGTERM}
+
+// forwardSignal ends cmd; Windows cannot deliver other signals to a process.
+func forwardSignal(cmd *exec.Cmd, sig os.Signal) {
+	if err := cmd.Process.Kill(); err != nil && !errors.Is(err, os.ErrProcessDone) {
+		pkg.LogVerbosef("Warning: Could not pass %v on to the command: %v", sig, err)
+	}
+}
+
+// exitStatus is the exit code of a finished command.
+func exitStatus(exitErr *exec.ExitError) int {
+	return exitErr.ExitCode()
+}
+
+// defaultShell is the -e shell when neither defaults.shell nor $SHELL is set:
+// PowerShell 7, then Windows PowerShell, then cmd.exe.
+func defaultShell() string {
+	for _, name := range []string{"pwsh.exe", "powershell.exe"} {
+		if path, err := exec.LookPath(name); err == nil {
+			return path
+		}
+	}
+	if comspec := os.Getenv("ComSpec"); comspec != "" {
+		return comspec
+	}
+	return "cmd.exe"
+}
+
+// shellCommand runs a -c command line with PowerShell, or with cmd.exe when
+// that is the only shell around.
+func shellCommand(ctx context.Context, commandLine string) *exec.Cmd {
+	shell := defaultShell()
+	if shellName(shell) != "cmd" {
+		return exec.CommandContext(ctx, shell, "-NoProfile", "-NonInteractive", "-Command", commandLine)
+	}
+	// cmd.exe does its own parsing of /C, so the line is passed unescaped.
+	cmd := exec.CommandContext(ctx, shell)
+	cmd.SysProcAttr = &syscall.SysProcAttr{CmdLine: fmt.Sprintf(`"%s" /S /C "%s"`, shell, commandLine)}
+	return cmd
+}
+
+// shellQuote quotes arg for the shell of shellCommand: in single quotes for
+// PowerShell, in double quotes for cmd.exe, which still expands %VAR% in them.
+func shellQuote(arg string) string {
+	if arg != "" && strings.Trim(arg, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789+=:,./_-\\") == "" {
+		return arg
+	}
+	if shellName(defaultShell()) == "cmd" {
+		return `"` + strings.ReplaceAll(arg, `"`, `""`) + `"`
+	}
+	return "'" + strings.ReplaceAll(arg, "'", "''") + "'"
+}
+
+// envNameKey normalizes an environment variable name for comparison; Windows
+// treats Path and PATH as the same variable.
+func envNameKey(name string) string {
+	return strings.ToUpper(name)
+}
+
+// sessionManagerPluginDirs are install locations of session-manager-plugin
+// that may be missing from PATH, e.g. in consoles opened before the install.
+func sessionManagerPluginDirs() []string {
+	programFiles := os.Getenv("ProgramFiles")
+	if programFiles == "" {
+		programFiles = `C:\Program Files`
+	}
+	return []string{filepath.Join(programFiles, "Amazon", "SessionManagerPlugin", "bin")}
+}
+
+// interruptProcess asks cmd to exit with a CTRL_BREAK_EVENT, the console event
+// Windows delivers to a process group, which detachFromTerminalSignals gave
+// cmd; os.Interrupt cannot be sent to another process on Windows.
+func interruptProcess(cmd *exec.Cmd) error {
+	return windows.GenerateConsoleCtrlEvent(windows.CTRL_BREAK_EVENT, uint32(cmd.Process.Pid))
+}
+
+// killProcessTree kills cmd and the helpers it started, such as the Session
+// Manager plugin the AWS CLI runs, which Process.Kill would leave running.
+func killProcessTree(cmd *exec.Cmd) error {
+	taskkill := exec.Command("taskkill", "/T", "/F", "/PID", strconv.Itoa(cmd.Process.Pid))
+	if err := taskkill.Run(); err != nil {
+		pkg.LogVerbosef("taskkill of pid %d failed (%v); killing the process alone.", cmd.Process.Pid, err)
+		return cmd.Process.Kill()
+	}
+	return nil
+}
+
+// listenPrivateUnix listens on a unix socket at path; Windows has no mode bits
+// for it, and the socket inherits the ACL of its directory in the user's profile.
+func listenPrivateUnix(path string) (net.Listener, error) {
+	return net.Listen("unix", path)
+}
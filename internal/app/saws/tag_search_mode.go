@@ -0,0 +1,146 @@
+package saws
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"saws/internal/pkg"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/resourcegroupstaggingapi"
+	taggingtypes "github.com/aws/aws-sdk-go-v2/service/resourcegroupstaggingapi/types"
+	ststypes "github.com/aws/aws-sdk-go-v2/service/sts/types"
+)
+
+// taggedResource is one resource returned by -tags.
+type taggedResource struct {
+	Account string            `json:"account"`
+	Region  string            `json:"region"`
+	ARN     string            `json:"arn"`
+	Tags    map[string]string `json:"tags"`
+}
+
+// ParseTagFilters parses "key=value,key2,key=other" into tagging API filters.
+// A bare key matches any value; repeating a key ORs its values.
+func ParseTagFilters(spec string) ([]taggingtypes.TagFilter, error) {
+	valuesByKey := make(map[string][]string)
+	var keys []string
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		key, value, hasValue := strings.Cut(part, "=")
+		key = strings.TrimSpace(key)
+		if key == "" {
+			return nil, fmt.Errorf("tag filter '%s' has an empty key", part)
+		}
+		if _, seen := valuesByKey[key]; !seen {
+			keys = append(keys, key)
+			valuesByKey[key] = nil
+		}
+		if hasValue {
+			valuesByKey[key] = append(valuesByKey[key], strings.TrimSpace(value))
+		}
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no tag filters in '%s' (expected key=value[,key2=value2])", spec)
+	}
+	filters := make([]taggingtypes.TagFilter, len(keys))
+	for i, key := range keys {
+		filters[i] = taggingtypes.TagFilter{Key: aws.String(key), Values: valuesByKey[key]}
+	}
+	return filters, nil
+}
+
+func formatTags(tags map[string]string) string {
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, len(keys))
+	for i, k := range keys {
+		pairs[i] = k + "=" + tags[k]
+	}
+	return strings.Join(pairs, ",")
+}
+
+// HandleTagSearch handles the logic for the -tags mode. Exported.
+// It lists every resource matching the tag filters in each target via the
+// Resource Groups Tagging API and prints them as one table, or as JSON.
+func HandleTagSearch(ctx context.Context, baseCfg aws.Config, appCfg *pkg.AppConfig, accountNames, regions []string, roleToAssume, tagSpec string, asJSON bool) error {
+	filters, err := ParseTagFilters(tagSpec)
+	if err != nil {
+		return err
+	}
+
+	resources, failures, summary := CollectFanOut(ctx, baseCfg, appCfg, accountNames, regions,
+		FanOutOptions{Label: "Tag Search Mode", RoleToAssume: roleToAssume, SessionName: "TagSearch"},
+		func(ctx context.Context, target FanOutTarget, creds *ststypes.Credentials) ([]taggedResource, error) {
+			cfg, err := pkg.LoadAssumedRoleConfig(ctx, creds, target.Region)
+			if err != nil {
+				return nil, err
+			}
+			var found []taggedResource
+			paginator := resourcegroupstaggingapi.NewGetResourcesPaginator(resourcegroupstaggingapi.NewFromConfig(cfg), &resourcegroupstaggingapi.GetResourcesInput{TagFilters: filters})
+			for paginator.HasMorePages() {
+				page, err := paginator.NextPage(ctx)
+				if err != nil {
+					return nil, fmt.Errorf("tag:GetResources failed: %w", err)
+				}
+				for _, mapping := range page.ResourceTagMappingList {
+					tags := make(map[string]string, len(mapping.Tags))
+					for _, tag := range mapping.Tags {
+						tags[aws.ToString(tag.Key)] = aws.ToString(tag.Value)
+					}
+					found = append(found, taggedResource{Account: target.AccountName, Region: target.Region, ARN: aws.ToString(mapping.ResourceARN), Tags: tags})
+				}
+			}
+			return found, nil
+		})
+
+	sort.Slice(resources, func(i, j int) bool {
+		if resources[i].Account != resources[j].Account {
+			return resources[i].Account < resources[j].Account
+		}
+		if resources[i].Region != resources[j].Region {
+			return resources[i].Region < resources[j].Region
+		}
+		return resources[i].ARN < resources[j].ARN
+	})
+
+	if asJSON {
+		if resources == nil {
+			resources = []taggedResource{}
+		}
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(resources); err != nil {
+			return fmt.Errorf("failed to encode resources as JSON: %w", err)
+		}
+	} else if len(resources) == 0 {
+		fmt.Fprintf(os.Stderr, "No resources tagged %s across %d account/region target(s).\n", tagSpec, summary.Succeeded)
+	} else {
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ACCOUNT\tREGION\tARN\tTAGS")
+		for _, r := range resources {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", r.Account, r.Region, r.ARN, formatTags(r.Tags))
+		}
+		w.Flush()
+		fmt.Fprintf(os.Stderr, "%d resource(s) found.\n", len(resources))
+	}
+
+	for _, f := range failures {
+		fmt.Fprintf(os.Stderr, "Warning: Could not search tags for Account: %s, Region: %s: %s\n", f.Target.AccountName, f.Target.Region, f.Err)
+	}
+	if len(failures) > 0 {
+		return fmt.Errorf("%d out of %d target(s) could not be queried", len(failures), summary.Total)
+	}
+	return nil
+}
@@ -0,0 +1,363 @@
+package saws
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"saws/internal/pkg"
+)
+
+// TelemetryOptions says where the metrics and traces of fan-out runs go.
+type TelemetryOptions struct {
+	// Mode labels the metrics and names the root span, e.g. "exec".
+	Mode string
+	// MetricsFile receives the metrics in the Prometheus text format, for the
+	// node_exporter textfile collector.
+	MetricsFile string
+	// Pushgateway is the base URL of a Prometheus Pushgateway.
+	Pushgateway string
+	// OTLPEndpoint is the base URL of an OTLP/HTTP collector, e.g.
+	// http://localhost:4318; traces are posted to <endpoint>/v1/traces.
+	OTLPEndpoint string
+}
+
+// Telemetry is set by main from -metrics-file, -pushgateway, and
+// -otlp-endpoint (or SAWS_METRICS_FILE, SAWS_PUSHGATEWAY, and the standard
+// OTEL_EXPORTER_OTLP_ENDPOINT / OTEL_EXPORTER_OTLP_TRACES_ENDPOINT).
+var Telemetry TelemetryOptions
+
+const telemetryTimeout = 10 * time.Second
+
+// recordedRun is a finished fan-out run awaiting export.
+type recordedRun struct {
+	opts    FanOutOptions
+	summary FanOutSummary
+}
+
+var (
+	recordedRunsMu sync.Mutex
+	recordedRuns   []recordedRun
+)
+
+// recordRun keeps a finished run for ExportTelemetry.
+func recordRun(opts FanOutOptions, summary FanOutSummary) {
+	recordedRunsMu.Lock()
+	defer recordedRunsMu.Unlock()
+	recordedRuns = append(recordedRuns, recordedRun{opts: opts, summary: summary})
+}
+
+// enabled reports whether any exporter is configured.
+func (t TelemetryOptions) enabled() bool {
+	return t.MetricsFile != "" || t.Pushgateway != "" || t.otlpTracesURL() != ""
+}
+
+// ExportTelemetry writes the metrics and traces of the fan-out runs of this
+// invocation to the configured exporters. Exported.
+// Export failures are reported on stderr but never change the exit code: the
+// run itself is what automation acts on.
+func ExportTelemetry() {
+	recordedRunsMu.Lock()
+	runs := recordedRuns
+	recordedRuns = nil
+	recordedRunsMu.Unlock()
+	if len(runs) == 0 || !Telemetry.enabled() {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), telemetryTimeout)
+	defer cancel()
+
+	mode := Telemetry.Mode
+	if mode == "" {
+		mode = runs[0].opts.Label
+	}
+	metrics := prometheusMetrics(mode, runs, time.Now())
+	if Telemetry.MetricsFile != "" {
+		// Replaced atomically, so the textfile collector never reads a half-written file.
+		if err := pkg.WriteFileAtomic(Telemetry.MetricsFile, metrics, 0o644); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: Could not write metrics to '%s': %v\n", Telemetry.MetricsFile, err)
+		}
+	}
+	if Telemetry.Pushgateway != "" {
+		if err := pushMetrics(ctx, Telemetry.Pushgateway, mode, metrics); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: Could not push metrics to '%s': %v\n", Telemetry.Pushgateway, err)
+		}
+	}
+	if tracesURL := Telemetry.otlpTracesURL(); tracesURL != "" {
+		if err := exportTraces(ctx, tracesURL, mode, runs); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: Could not export traces to '%s': %v\n", tracesURL, err)
+		}
+	}
+}
+
+// metricsWriter builds a Prometheus text exposition, one HELP/TYPE header per metric.
+type metricsWriter struct {
+	buf    bytes.Buffer
+	headed map[string]bool
+}
+
+func (w *metricsWriter) gauge(name, help string, labels [][2]string, value float64) {
+	if w.headed == nil {
+		w.headed = make(map[string]bool)
+	}
+	if !w.headed[name] {
+		fmt.Fprintf(&w.buf, "# HELP %s %s\n# TYPE %s gauge\n", name, help, name)
+		w.headed[name] = true
+	}
+	pairs := make([]string, 0, len(labels))
+	for _, label := range labels {
+		value := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(label[1])
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, label[0], value))
+	}
+	fmt.Fprintf(&w.buf, "%s{%s} %s\n", name, strings.Join(pairs, ","), strconv.FormatFloat(value, 'g', -1, 64))
+}
+
+// prometheusMetrics renders the runs as gauges: the totals of the invocation,
+// then the duration, assume-role latency, and outcome of every target.
+func prometheusMetrics(mode string, runs []recordedRun, now time.Time) []byte {
+	var w metricsWriter
+	var total, failed int64
+	var duration time.Duration
+	for _, run := range runs {
+		total += int64(run.summary.Total)
+		failed += run.summary.Failed()
+		duration += run.summary.Duration
+	}
+	modeLabel := [][2]string{{"mode", mode}}
+	w.gauge("saws_run_duration_seconds", "Wall-clock duration of the fan-out run.", modeLabel, duration.Seconds())
+	w.gauge("saws_run_targets", "Account/region targets of the run.", modeLabel, float64(total))
+	w.gauge("saws_run_failed_targets", "Targets of the run that failed.", modeLabel, float64(failed))
+	w.gauge("saws_run_last_completion_timestamp_seconds", "Unix time the run finished.", modeLabel, float64(now.Unix()))
+
+	// Samples of one metric must be contiguous in the exposition.
+	targetMetrics := []struct {
+		name, help string
+		value      func(FanOutResult) float64
+	}{
+		{"saws_target_duration_seconds", "Duration of one target, assume-role included.", func(r FanOutResult) float64 { return r.Finished.Sub(r.Started).Seconds() }},
+		{"saws_target_assume_role_duration_seconds", "sts:AssumeRole latency of one target.", func(r FanOutResult) float64 { return r.AssumeRoleDuration.Seconds() }},
+		{"saws_target_failed", "1 when the target failed, else 0.", func(r FanOutResult) float64 {
+			if r.Status != StatusSuccess {
+				return 1
+			}
+			return 0
+		}},
+	}
+	for _, metric := range targetMetrics {
+		for _, run := range runs {
+			for _, outcome := range run.summary.Outcomes {
+				labels := [][2]string{{"mode", mode}, {"account", outcome.Target.AccountName}, {"account_id", outcome.Target.AccountID}, {"region", outcome.Target.Region}, {"role", run.opts.RoleToAssume}}
+				w.gauge(metric.name, metric.help, labels, metric.value(outcome.Result))
+			}
+		}
+	}
+	return w.buf.Bytes()
+}
+
+// pushMetrics replaces the metrics of the saws job's mode group on a Pushgateway,
+// so targets of an earlier run that are gone this time do not linger.
+func pushMetrics(ctx context.Context, gateway, mode string, metrics []byte) error {
+	endpoint := strings.TrimSuffix(gateway, "/") + "/metrics/job/saws/mode/" + url.PathEscape(mode)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, endpoint, bytes.NewReader(metrics))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; version=0.0.4")
+	return doTelemetryRequest(req)
+}
+
+func doTelemetryRequest(req *http.Request) error {
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("%s %s: %s", req.Method, req.URL.Redacted(), resp.Status)
+	}
+	pkg.LogVerbosef("Telemetry: %s %s: %s", req.Method, req.URL.Redacted(), resp.Status)
+	return nil
+}
+
+// otlpTracesURL is OTEL_EXPORTER_OTLP_TRACES_ENDPOINT as given, else the OTLP
+// base endpoint with /v1/traces appended, per the OpenTelemetry conventions.
+func (t TelemetryOptions) otlpTracesURL() string {
+	if t.OTLPEndpoint == "" {
+		if tracesURL := os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT"); tracesURL != "" {
+			return tracesURL
+		}
+	}
+	base := t.OTLPEndpoint
+	if base == "" {
+		base = os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT")
+	}
+	if base == "" {
+		return ""
+	}
+	return strings.TrimSuffix(base, "/") + "/v1/traces"
+}
+
+// otlpKeyValue and the types below are the OTLP/JSON encoding of a trace.
+type otlpKeyValue struct {
+	Key   string `json:"key"`
+	Value struct {
+		StringValue string `json:"stringValue"`
+	} `json:"value"`
+}
+
+type otlpSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	ParentSpanID      string         `json:"parentSpanId,omitempty"`
+	Name              string         `json:"name"`
+	Kind              int            `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Status            struct {
+		Code    int    `json:"code"`
+		Message string `json:"message,omitempty"`
+	} `json:"status"`
+}
+
+// Span kinds and status codes of OTLP.
+const (
+	otlpSpanKindInternal = 1
+	otlpSpanKindClient   = 3
+	otlpStatusOK         = 1
+	otlpStatusError      = 2
+)
+
+func otlpAttributes(pairs ...string) []otlpKeyValue {
+	attrs := make([]otlpKeyValue, 0, len(pairs)/2)
+	for i := 0; i+1 < len(pairs); i += 2 {
+		var kv otlpKeyValue
+		kv.Key, kv.Value.StringValue = pairs[i], pairs[i+1]
+		attrs = append(attrs, kv)
+	}
+	return attrs
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func unixNano(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
+
+var traceparentPattern = regexp.MustCompile(`^[0-9a-f]{2}-([0-9a-f]{32})-([0-9a-f]{16})-[0-9a-f]{2}$`)
+
+// exportTraces posts one trace for the invocation: a root span for the mode,
+// a span per target, and an sts:AssumeRole span under each. With TRACEPARENT
+// set (W3C trace context, as CI systems export it) the root span joins that
+// trace instead of starting a new one.
+func exportTraces(ctx context.Context, tracesURL, mode string, runs []recordedRun) error {
+	traceID, parentID := randomHex(16), ""
+	if m := traceparentPattern.FindStringSubmatch(os.Getenv("TRACEPARENT")); m != nil {
+		traceID, parentID = m[1], m[2]
+	}
+	rootID := randomHex(8)
+	root := otlpSpan{TraceID: traceID, SpanID: rootID, ParentSpanID: parentID, Name: "saws " + mode, Kind: otlpSpanKindInternal}
+	root.Status.Code = otlpStatusOK
+	var start, end time.Time
+	var total, failed int64
+	spans := []otlpSpan{}
+
+	for _, run := range runs {
+		runEnd := run.summary.Started.Add(run.summary.Duration)
+		if start.IsZero() || run.summary.Started.Before(start) {
+			start = run.summary.Started
+		}
+		if runEnd.After(end) {
+			end = runEnd
+		}
+		total += int64(run.summary.Total)
+		failed += run.summary.Failed()
+		for _, outcome := range run.summary.Outcomes {
+			target, result := outcome.Target, outcome.Result
+			span := otlpSpan{
+				TraceID: traceID, SpanID: randomHex(8), ParentSpanID: rootID,
+				Name: fmt.Sprintf("%s %s", target.AccountName, target.Region), Kind: otlpSpanKindInternal,
+				StartTimeUnixNano: unixNano(result.Started), EndTimeUnixNano: unixNano(result.Finished),
+				Attributes: otlpAttributes("saws.account", target.AccountName, "cloud.account.id", target.AccountID, "cloud.region", target.Region, "saws.role", run.opts.RoleToAssume, "saws.status", result.Status),
+			}
+			span.Status.Code = otlpStatusOK
+			if result.Status != StatusSuccess {
+				span.Status.Code = otlpStatusError
+				for _, section := range result.Sections {
+					if section.Label == "ERROR" {
+						span.Status.Message = truncate(strings.TrimSpace(section.Body), maxRunEventError)
+					}
+				}
+			}
+			spans = append(spans, span)
+			if result.AssumeRoleDuration > 0 {
+				assume := otlpSpan{
+					TraceID: traceID, SpanID: randomHex(8), ParentSpanID: span.SpanID,
+					Name: "sts:AssumeRole", Kind: otlpSpanKindClient,
+					// Resolving the account's base config before the call takes next to no time.
+					StartTimeUnixNano: unixNano(result.Started), EndTimeUnixNano: unixNano(result.Started.Add(result.AssumeRoleDuration)),
+					Attributes: otlpAttributes("rpc.system", "aws-api", "rpc.service", "STS", "rpc.method", "AssumeRole"),
+				}
+				assume.Status.Code = otlpStatusOK
+				spans = append(spans, assume)
+			}
+		}
+	}
+	root.StartTimeUnixNano, root.EndTimeUnixNano = unixNano(start), unixNano(end)
+	root.Attributes = otlpAttributes("saws.mode", mode, "saws.targets", strconv.FormatInt(total, 10), "saws.failed_targets", strconv.FormatInt(failed, 10))
+	if failed > 0 {
+		root.Status.Code = otlpStatusError
+		root.Status.Message = fmt.Sprintf("%d of %d targets failed", failed, total)
+	}
+	spans = append([]otlpSpan{root}, spans...)
+
+	serviceName := os.Getenv("OTEL_SERVICE_NAME")
+	if serviceName == "" {
+		serviceName = "saws"
+	}
+	payload := map[string]any{
+		"resourceSpans": []any{map[string]any{
+			"resource": map[string]any{"attributes": otlpAttributes("service.name", serviceName)},
+			"scopeSpans": []any{map[string]any{
+				"scope": map[string]any{"name": "saws"},
+				"spans": spans,
+			}},
+		}},
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, tracesURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	// OTEL_EXPORTER_OTLP_HEADERS is "key1=value1,key2=value2", values URL-encoded.
+	for _, header := range strings.Split(os.Getenv("OTEL_EXPORTER_OTLP_HEADERS"), ",") {
+		key, value, ok := strings.Cut(header, "=")
+		if !ok {
+			continue
+		}
+		if decoded, err := url.QueryUnescape(value); err == nil {
+			value = decoded
+		}
+		req.Header.Set(strings.TrimSpace(key), strings.TrimSpace(value))
+	}
+	return doTelemetryRequest(req)
+}
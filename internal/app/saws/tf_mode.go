@@ -0,0 +1,94 @@
+package saws
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"saws/internal/pkg"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	ststypes "github.com/aws/aws-sdk-go-v2/service/sts/types"
+)
+
+const (
+	// DefaultTerraformBinary is run unless -tf-bin names another, e.g. terragrunt.
+	DefaultTerraformBinary = "terraform"
+	// DefaultTerraformStateKey is the backend key of each target in a
+	// multi-account run; {account}, {account_id}, and {region} are replaced.
+	DefaultTerraformStateKey = "{account}/{region}/terraform.tfstate"
+)
+
+// TerraformOptions configures `saws tf`.
+type TerraformOptions struct {
+	Binary   string
+	StateKey string
+}
+
+// terraformEnv is the session environment plus the per-account input variables
+// a module can declare: account_id, account_name, and region.
+func terraformEnv(sCtx *pkg.SelectedContext, creds *ststypes.Credentials) []string {
+	env := withoutEnv(sessionEnv(sCtx, creds), "TF_VAR_account_id", "TF_VAR_account_name", "TF_VAR_region")
+	return append(env,
+		"TF_VAR_account_id="+sCtx.AccountID,
+		"TF_VAR_account_name="+sCtx.AccountName,
+		"TF_VAR_region="+sCtx.Region)
+}
+
+// isTerragrunt reports whether binary is terragrunt, which initializes modules
+// and picks state keys from its own configuration.
+func isTerragrunt(binary string) bool {
+	return shellName(binary) == "terragrunt"
+}
+
+// HandleTerraform handles `saws tf` for one account. Exported.
+// It establishes the context like -e and runs terraform (or opts.Binary) with
+// args in the current directory, returning its exit code.
+func HandleTerraform(ctx context.Context, accountSelectorFlag, roleFlag, regionFlagFromCmd string, opts TerraformOptions, args []string) (int, error) {
+	sCtx, creds, err := pkg.EstablishAWSContextAndAssumeRole(ctx, accountSelectorFlag, roleFlag, regionFlagFromCmd, "Terraform")
+	if err != nil {
+		return 1, fmt.Errorf("could not establish AWS context: %w", err)
+	}
+	pkg.LogVerbosef("Running %s %q in Account=%s(%s), Role=%s, Region=%s", opts.Binary, args, sCtx.AccountName, sCtx.AccountID, sCtx.RoleName, sCtx.Region)
+	return runWithEnv(append([]string{opts.Binary}, args...), terraformEnv(sCtx, creds))
+}
+
+// terraformStateKey expands the placeholders of the state key template for target.
+func terraformStateKey(template string, target FanOutTarget) string {
+	return strings.NewReplacer("{account}", target.AccountName, "{account_id}", target.AccountID, "{region}", target.Region).Replace(template)
+}
+
+// HandleTerraformAccounts handles `saws tf` across several accounts and
+// regions. Exported.
+// The targets run one at a time through RunSequential.
+// Each target gets its own TF_DATA_DIR and, for terraform, is initialized with
+// its own backend key from opts.StateKey, so the targets never share state.
+func HandleTerraformAccounts(ctx context.Context, baseCfg aws.Config, appCfg *pkg.AppConfig, accountNames, regions []string, role string, opts TerraformOptions, args []string) FanOutSummary {
+	fanOpts := FanOutOptions{Label: "Terraform Mode", RoleToAssume: role, SessionName: "Terraform"}
+	task := func(ctx context.Context, target FanOutTarget, creds *ststypes.Credentials) FanOutResult {
+		sCtx := &pkg.SelectedContext{AccountName: target.AccountName, AccountID: target.AccountID, RoleName: role, Region: target.Region}
+		env := withoutEnv(terraformEnv(sCtx, creds), "TF_DATA_DIR")
+		env = append(env, "TF_DATA_DIR="+filepath.Join(".terraform", "saws", target.AccountName+"_"+target.Region))
+
+		if !isTerragrunt(opts.Binary) {
+			initArgs := []string{opts.Binary, "init", "-input=false", "-reconfigure", "-backend-config=key=" + terraformStateKey(opts.StateKey, target)}
+			if code, err := runWithEnv(initArgs, env); err != nil || code != 0 {
+				if err == nil {
+					err = fmt.Errorf("%s init exited with status %d", opts.Binary, code)
+				}
+				return FanOutResult{Status: StatusFailed, ExitCode: code, Sections: []FanOutSection{{Label: "ERROR", Body: err.Error()}}}
+			}
+		}
+		code, err := runWithEnv(append([]string{opts.Binary}, args...), env)
+		if err == nil && code != 0 {
+			err = fmt.Errorf("%s exited with status %d", opts.Binary, code)
+		}
+		if err != nil {
+			return FanOutResult{Status: StatusFailed, ExitCode: code, Sections: []FanOutSection{{Label: "ERROR", Body: err.Error()}}}
+		}
+		return FanOutResult{Status: StatusSuccess}
+	}
+
+	return RunSequential(ctx, baseCfg, appCfg, accountNames, regions, fanOpts, task)
+}
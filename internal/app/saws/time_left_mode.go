@@ -0,0 +1,30 @@
+package saws
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// HandleTimeLeft handles the logic for the `time-left` mode. Exported.
+// It prints how long the credentials of the saws session it runs in remain
+// valid, read from SAWS_INFO_EXPIRES_AT, e.g. "42m10s", for shell prompts and
+// scripts. It fails outside a saws session and once the session has expired.
+func HandleTimeLeft() error {
+	value := os.Getenv("SAWS_INFO_EXPIRES_AT")
+	if value == "" {
+		return errors.New("not in a saws session (SAWS_INFO_EXPIRES_AT is not set)")
+	}
+	expiresAt, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return fmt.Errorf("SAWS_INFO_EXPIRES_AT '%s' is not an RFC3339 time: %w", value, err)
+	}
+	left := time.Until(expiresAt).Round(time.Second)
+	if left <= 0 {
+		fmt.Println("expired")
+		return fmt.Errorf("the session expired at %s", expiresAt.Local().Format(time.RFC1123))
+	}
+	fmt.Println(left)
+	return nil
+}
@@ -0,0 +1,133 @@
+package saws
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"time"
+
+	"saws/internal/pkg"
+
+	ststypes "github.com/aws/aws-sdk-go-v2/service/sts/types"
+)
+
+const (
+	tunnelReadyTimeout = 30 * time.Second
+	tunnelStopTimeout  = 5 * time.Second
+)
+
+// ssmTunnel is a running background SSM port forwarding session.
+type ssmTunnel struct {
+	cmd    *exec.Cmd
+	exited chan error
+}
+
+// freeLocalPort asks the OS for an unused TCP port on the loopback interface.
+func freeLocalPort() (int32, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, fmt.Errorf("failed to find a free local port: %w", err)
+	}
+	defer listener.Close()
+	return int32(listener.Addr().(*net.TCPAddr).Port), nil
+}
+
+// startSSMTunnel starts an SSM remote-host port forward in the background and
+// waits until the local end accepts connections. The caller must stop the
+// returned tunnel with Stop.
+func startSSMTunnel(ctx context.Context, creds *ststypes.Credentials, region, bastionID, remoteHost string, remotePort, localPort int32) (*ssmTunnel, error) {
+	awsCLIPath, err := exec.LookPath("aws")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error: AWS CLI ('aws') not found in PATH. Required for the SSM tunnel.")
+		fmt.Fprintln(os.Stderr, "Please install AWS CLI and Session Manager plugin.")
+		return nil, errors.New("aws cli not found")
+	}
+	ensureSessionManagerPlugin()
+
+	tunnelCmd := newSSMPortForwardCommand(ctx, awsCLIPath, assumedRoleEnv(creds, region), bastionID, region, remoteHost, remotePort, localPort)
+	if pkg.VerboseMode {
+		tunnelCmd.Stdout = os.Stderr
+		tunnelCmd.Stderr = os.Stderr
+	} else {
+		tunnelCmd.Stdout = io.Discard
+		tunnelCmd.Stderr = io.Discard
+	}
+	detachFromTerminalSignals(tunnelCmd)
+	if err := tunnelCmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start SSM port forwarding session: %w", err)
+	}
+
+	tunnel := &ssmTunnel{cmd: tunnelCmd, exited: make(chan error, 1)}
+	go func() { tunnel.exited <- tunnelCmd.Wait() }()
+
+	localAddr := fmt.Sprintf("127.0.0.1:%d", localPort)
+	deadline := time.Now().Add(tunnelReadyTimeout)
+	pkg.LogVerbosef("Waiting for tunnel on %s to accept connections...", localAddr)
+	for time.Now().Before(deadline) {
+		select {
+		case errExit := <-tunnel.exited:
+			return nil, fmt.Errorf("SSM port forwarding session exited before the tunnel was ready: %v", errExit)
+		default:
+		}
+		conn, errDial := net.DialTimeout("tcp", localAddr, time.Second)
+		if errDial == nil {
+			conn.Close()
+			pkg.LogVerbosef("Tunnel on %s is ready.", localAddr)
+			return tunnel, nil
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+	tunnel.Stop()
+	return nil, fmt.Errorf("timed out after %s waiting for the tunnel on %s", tunnelReadyTimeout, localAddr)
+}
+
+// Stop interrupts the session-manager process (see interruptProcess) and kills
+// it with its helpers if it does not exit promptly.
+func (t *ssmTunnel) Stop() {
+	if t == nil || t.cmd.Process == nil {
+		return
+	}
+	pkg.LogVerbosef("Closing SSM tunnel (pid %d)...", t.cmd.Process.Pid)
+	if err := interruptProcess(t.cmd); err != nil {
+		pkg.LogVerbosef("Could not interrupt the SSM tunnel: %v", err)
+	}
+	select {
+	case <-t.exited:
+	case <-time.After(tunnelStopTimeout):
+		pkg.LogVerbosef("SSM tunnel did not exit after %s, killing it.", tunnelStopTimeout)
+		_ = killProcessTree(t.cmd)
+		<-t.exited
+	}
+}
+
+// runSSMTunnel runs an SSM remote-host port forward in the foreground until the
+// user closes it with Ctrl+C.
+func runSSMTunnel(ctx context.Context, creds *ststypes.Credentials, region, bastionID, remoteHost string, remotePort, localPort int32) error {
+	awsCLIPath, err := exec.LookPath("aws")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error: AWS CLI ('aws') not found in PATH. Required for the SSM tunnel.")
+		fmt.Fprintln(os.Stderr, "Please install AWS CLI and Session Manager plugin.")
+		return errors.New("aws cli not found")
+	}
+	ensureSessionManagerPlugin()
+
+	fmt.Fprintf(os.Stderr, "Forwarding 127.0.0.1:%d -> %s:%d via %s. Press Ctrl+C to close the tunnel.\n", localPort, remoteHost, remotePort, bastionID)
+	tunnelCmd := newSSMPortForwardCommand(ctx, awsCLIPath, assumedRoleEnv(creds, region), bastionID, region, remoteHost, remotePort, localPort)
+	tunnelCmd.Stdin = os.Stdin
+	tunnelCmd.Stdout = os.Stderr
+	tunnelCmd.Stderr = os.Stderr
+	err = tunnelCmd.Run()
+	pkg.LogVerbosef("SSM tunnel ended.")
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			pkg.LogVerbosef("SSM tunnel exited with status: %s.", exitErr.Error())
+		} else {
+			return fmt.Errorf("failed to run SSM port forwarding session: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package saws
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"saws/internal/pkg"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// HandleNamedTunnel handles the logic for the -tunnel mode, bringing up a
+// port forward defined under 'tunnels:' in the SAWS config. Exported.
+func HandleNamedTunnel(ctx context.Context, appCfg *pkg.AppConfig, tunnelName, roleFlag, regionFlagFromCmd string, localPortFlag int) error {
+	tunnel, ok := appCfg.Tunnels[tunnelName]
+	if !ok {
+		names := make([]string, 0, len(appCfg.Tunnels))
+		for name := range appCfg.Tunnels {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		if len(names) == 0 {
+			return fmt.Errorf("tunnel '%s' not found: no 'tunnels' section in SAWS config", tunnelName)
+		}
+		return fmt.Errorf("tunnel '%s' not found in SAWS config (available: %s)", tunnelName, strings.Join(names, ", "))
+	}
+
+	// Flags win over the tunnel definition so a profile can be reused with another role or region.
+	role := roleFlag
+	if role == "" {
+		role = tunnel.Role
+	}
+	region := regionFlagFromCmd
+	if region == "" {
+		region = tunnel.Region
+	}
+	localPort := tunnel.LocalPort
+	if localPortFlag > 0 {
+		localPort = int32(localPortFlag)
+	}
+	if localPort == 0 {
+		localPort = tunnel.RemotePort
+	}
+
+	pkg.LogVerbosef("Preparing tunnel '%s' (%s:%d via %s)...", tunnelName, tunnel.RemoteHost, tunnel.RemotePort, tunnel.Bastion)
+	sCtx, creds, err := pkg.EstablishAWSContextAndAssumeRole(ctx, tunnel.Account, role, region, "TunnelSession")
+	if err != nil {
+		return fmt.Errorf("could not establish AWS context for tunnel '%s': %w", tunnelName, err)
+	}
+
+	awsCreds := aws.Credentials{AccessKeyID: *creds.AccessKeyId, SecretAccessKey: *creds.SecretAccessKey, SessionToken: *creds.SessionToken, Source: "SawsAssumedRoleForTunnel"}
+	bastionID, err := resolveSSMInstance(ctx, awsCreds, sCtx.Region, tunnel.Bastion)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "Tunnel '%s': Account=%s(%s), Role=%s, Region=%s\n", tunnelName, sCtx.AccountName, sCtx.AccountID, sCtx.RoleName, sCtx.Region)
+	return runSSMTunnel(ctx, creds, sCtx.Region, bastionID, tunnel.RemoteHost, tunnel.RemotePort, localPort)
+}
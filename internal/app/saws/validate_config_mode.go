@@ -0,0 +1,184 @@
+package saws
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"text/tabwriter"
+
+	"saws/internal/pkg"
+
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+)
+
+// maxConcurrentProbes bounds the AssumeRole and alias probes running at once
+// when defaults.parallelism does not, so large configs are not throttled by STS.
+const maxConcurrentProbes = 16
+
+// probeSlots returns the semaphore of a probe run: parallelism slots, or
+// maxConcurrentProbes when parallelism is 0 (unlimited for fan-outs).
+func probeSlots(parallelism int) chan struct{} {
+	if parallelism <= 0 {
+		parallelism = maxConcurrentProbes
+	}
+	return make(chan struct{}, parallelism)
+}
+
+// roleProbe is the outcome of one AssumeRole probe.
+type roleProbe struct {
+	Account string
+	Role    string
+	Err     error
+}
+
+// probeRoles calls sts:AssumeRole for every account/role pair, at most
+// parallelism (see probeSlots) at a time.
+// AssumeRole has no side effects beyond a CloudTrail entry, so it is a safe
+// way to confirm the config matches what the base credentials may assume.
+func probeRoles(ctx context.Context, accounts map[string]string, roleNames []string, parallelism int) ([]roleProbe, error) {
+	baseCfg, err := pkg.LoadAWSConfig(ctx, awsconfig.WithSharedConfigProfile(pkg.BaseProfileForAssume), awsconfig.WithRegion(pkg.FallbackRegion))
+	if err != nil {
+		return nil, fmt.Errorf("error loading base AWS configuration (profile '%s'): %w", pkg.BaseProfileForAssume, err)
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	var probes []roleProbe
+	slots := probeSlots(parallelism)
+	for accountName, accountID := range accounts {
+		for _, roleName := range roleNames {
+			wg.Add(1)
+			go func(accountName, accountID, roleName string) {
+				defer wg.Done()
+				slots <- struct{}{}
+				defer func() { <-slots }()
+				accountBaseCfg, errAssume := pkg.BaseConfigForAccount(ctx, accountName, baseCfg)
+				if errAssume == nil {
+					_, errAssume = pkg.AssumeRoleFresh(ctx, accountBaseCfg, accountID, roleName, "ValidateConfig")
+				}
+				mu.Lock()
+				probes = append(probes, roleProbe{Account: accountName, Role: roleName, Err: errAssume})
+				mu.Unlock()
+			}(accountName, accountID, roleName)
+		}
+	}
+	wg.Wait()
+
+	sort.Slice(probes, func(i, j int) bool {
+		if probes[i].Account != probes[j].Account {
+			return probes[i].Account < probes[j].Account
+		}
+		return probes[i].Role < probes[j].Role
+	})
+	return probes, nil
+}
+
+// HandleValidateConfig handles the logic for the `validate-config` mode. Exported.
+// It prints a pass/fail report of the offline checks and, with probe, of an
+// AssumeRole attempt for every account and role (or only roleFlag when set).
+// With aliases it compares every account name with its IAM account alias.
+// The probes cover the accounts and roles of contextName, or of the context
+// SAWS_CONTEXT or default_context selects, like other commands.
+func HandleValidateConfig(ctx context.Context, filePath, contextName string, probe, aliases bool, roleFlag string) error {
+	fmt.Printf("Validating %s\n", filePath)
+	cfg, issues := pkg.ValidateConfig(filePath)
+
+	failures := 0
+	for _, issue := range issues {
+		if issue.Level == pkg.IssueError {
+			failures++
+		}
+		fmt.Printf("[%s] %s\n", colorStatus(issue.Level), issue.Message)
+	}
+	if failures == 0 {
+		fmt.Printf("[%s] Schema, account IDs, regions, roles, groups, tunnels, includes, and contexts\n", colorStatus("PASS"))
+	}
+
+	if cfg != nil {
+		if cfg.BaseProfile == "" {
+			cfg.BaseProfile = cfg.Defaults.BaseProfile
+		}
+		if err := pkg.ApplyContext(cfg, contextName, filePath); err != nil {
+			fmt.Printf("[%s] %v\n", colorStatus(pkg.IssueError), err)
+			return fmt.Errorf("%d check(s) failed", failures+1)
+		}
+		if cfg.ActiveContext != "" && (probe || aliases) {
+			fmt.Printf("Probing context '%s'.\n", cfg.ActiveContext)
+		}
+		pkg.SetAccountDetails(cfg.AccountDetails)
+	}
+
+	if aliases && cfg != nil {
+		roleName := roleFlag
+		if actual, ok := cfg.Roles[roleName]; ok {
+			roleName = actual
+		}
+		if roleName == "" {
+			for _, friendly := range sortedKeys(cfg.Roles) {
+				if roleName = cfg.Roles[friendly]; roleName != "" {
+					break
+				}
+			}
+		}
+		if roleName == "" || len(cfg.Accounts) == 0 {
+			fmt.Printf("[%s] Alias check skipped: no accounts or role to read aliases with (pass -r)\n", colorStatus(pkg.IssueWarning))
+		} else {
+			baseCfg, err := pkg.LoadAWSConfig(ctx, awsconfig.WithSharedConfigProfile(pkg.BaseProfileForAssume), awsconfig.WithRegion(pkg.FallbackRegion))
+			if err != nil {
+				return fmt.Errorf("error loading base AWS configuration (profile '%s'): %w", pkg.BaseProfileForAssume, err)
+			}
+			fmt.Printf("Reading IAM account aliases of %d account(s) as role '%s'...\n", len(cfg.Accounts), roleName)
+			if mismatches := printAliasReport(probeAccountAliases(ctx, baseCfg, cfg.Accounts, roleName, cfg.Defaults.Parallelism)); mismatches > 0 {
+				fmt.Printf("[%s] %d account name(s) differ from their IAM account alias\n", colorStatus(pkg.IssueWarning), mismatches)
+			}
+		}
+	}
+
+	if probe && cfg != nil {
+		roleNames := []string{}
+		if roleFlag != "" {
+			if actual, ok := cfg.Roles[roleFlag]; ok {
+				roleFlag = actual
+			}
+			roleNames = append(roleNames, roleFlag)
+		} else {
+			seen := make(map[string]bool)
+			for _, actual := range cfg.Roles {
+				if actual != "" && !seen[actual] {
+					seen[actual] = true
+					roleNames = append(roleNames, actual)
+				}
+			}
+			sort.Strings(roleNames)
+		}
+
+		if len(roleNames) == 0 || len(cfg.Accounts) == 0 {
+			fmt.Printf("[%s] AssumeRole probe skipped: no accounts or roles to probe (pass -r to probe a specific role)\n", colorStatus(pkg.IssueWarning))
+		} else {
+			fmt.Printf("Probing AssumeRole for %d account(s) x %d role(s) with base profile '%s'...\n", len(cfg.Accounts), len(roleNames), pkg.BaseProfileForAssume)
+			probes, err := probeRoles(ctx, cfg.Accounts, roleNames, cfg.Defaults.Parallelism)
+			if err != nil {
+				return err
+			}
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprint(w, pkg.ColorRow(os.Stdout, pkg.ColorPlain, "RESULT\tACCOUNT\tROLE\tDETAIL\n"))
+			for _, p := range probes {
+				result, detail := "PASS", ""
+				if p.Err != nil {
+					result, detail = pkg.IssueError, p.Err.Error()
+					failures++
+				}
+				fmt.Fprint(w, pkg.ColorRow(os.Stdout, statusColor(result), fmt.Sprintf("[%s]\t%s\t%s\t%s\n", result, p.Account, p.Role, detail)))
+			}
+			w.Flush()
+		}
+	}
+
+	if failures > 0 {
+		return fmt.Errorf("%d check(s) failed", failures)
+	}
+	fmt.Println("Config is valid.")
+	return nil
+}
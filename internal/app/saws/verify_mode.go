@@ -0,0 +1,194 @@
+package saws
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"text/tabwriter"
+
+	"saws/internal/pkg"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	ststypes "github.com/aws/aws-sdk-go-v2/service/sts/types"
+	"github.com/aws/smithy-go"
+)
+
+// Results of one cell of the verify matrix.
+const (
+	VerifyOK      = "ok"
+	VerifyDenied  = "DENIED"
+	VerifyMFA     = "MFA"
+	VerifyError   = "ERROR"
+	VerifySkipped = "-"
+)
+
+// verifyCell is the outcome of assuming one role in one account.
+type verifyCell struct {
+	Account   string `json:"account"`
+	AccountID string `json:"account_id"`
+	Role      string `json:"role"`
+	Result    string `json:"result"`
+	Error     string `json:"error,omitempty"`
+
+	creds *ststypes.Credentials
+}
+
+// classifyAssumeRoleError tells a refused AssumeRole from other failures.
+func classifyAssumeRoleError(err error) string {
+	if strings.Contains(err.Error(), "MultiFactorAuth") {
+		return VerifyMFA
+	}
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) && apiErr.ErrorCode() == "AccessDenied" {
+		return VerifyDenied
+	}
+	return VerifyError
+}
+
+// trustPolicyRequiresMFA reports whether the trust policy of roleName names an
+// aws:MultiFactorAuth* condition key, read with creds of another role in the
+// same account. STS refuses an MFA-less AssumeRole with a plain AccessDenied,
+// so this is the only way to tell the two apart.
+func trustPolicyRequiresMFA(ctx context.Context, creds *ststypes.Credentials, region, roleName string) bool {
+	cfg, err := pkg.LoadAssumedRoleConfig(ctx, creds, region)
+	if err != nil {
+		return false
+	}
+	out, err := iam.NewFromConfig(cfg).GetRole(ctx, &iam.GetRoleInput{RoleName: aws.String(roleName)})
+	if err != nil || out.Role == nil || out.Role.AssumeRolePolicyDocument == nil {
+		pkg.LogVerbosef("Could not read the trust policy of %s: %v", roleName, err)
+		return false
+	}
+	document, err := url.QueryUnescape(*out.Role.AssumeRolePolicyDocument)
+	if err != nil {
+		return false
+	}
+	return strings.Contains(strings.ToLower(document), "aws:multifactorauth")
+}
+
+// HandleVerify handles the logic for the `verify` mode. Exported.
+// It calls sts:AssumeRole, and nothing else, for every account and role and
+// prints the outcomes as a matrix with an account per row and a role per
+// column. Roles excluded by roles_by_account are not tried.
+func HandleVerify(ctx context.Context, baseCfg aws.Config, appCfg *pkg.AppConfig, accountNames, roleNames []string, asJSON bool) error {
+	roles := make([]string, len(roleNames))
+	for i, name := range roleNames {
+		roles[i] = name
+		if actual, ok := appCfg.Roles[name]; ok {
+			roles[i] = actual
+		}
+	}
+
+	cells := make([][]verifyCell, len(accountNames))
+	var wg sync.WaitGroup
+	var slots chan struct{}
+	if pkg.Parallelism > 0 {
+		slots = make(chan struct{}, pkg.Parallelism)
+	}
+	for i, accountName := range accountNames {
+		cells[i] = make([]verifyCell, len(roles))
+		for j, role := range roles {
+			cell := &cells[i][j]
+			*cell = verifyCell{Account: accountName, AccountID: appCfg.Accounts[accountName], Role: roleNames[j], Result: VerifySkipped}
+			if !pkg.RoleAllowedInAccount(accountName, roleNames[j]) {
+				continue
+			}
+			wg.Add(1)
+			go func(role string) {
+				defer wg.Done()
+				if slots != nil {
+					slots <- struct{}{}
+					defer func() { <-slots }()
+				}
+				accountBaseCfg, err := pkg.BaseConfigForAccount(ctx, cell.Account, baseCfg)
+				if err == nil {
+					cell.creds, err = pkg.AssumeRoleFresh(ctx, accountBaseCfg, cell.AccountID, role, "Verify")
+				}
+				cell.Result = VerifyOK
+				if err != nil {
+					cell.Result, cell.Error = classifyAssumeRoleError(err), pkg.RedactSecrets(err.Error())
+				}
+			}(role)
+		}
+	}
+	wg.Wait()
+
+	// A role that was assumed may read the trust policy of a refused one.
+	for i := range cells {
+		for j := range cells[i] {
+			if cells[i][j].Result != VerifyDenied {
+				continue
+			}
+			for _, other := range cells[i] {
+				if other.creds == nil {
+					continue
+				}
+				if trustPolicyRequiresMFA(ctx, other.creds, baseCfg.Region, roles[j]) {
+					cells[i][j].Result = VerifyMFA
+				}
+				break
+			}
+		}
+	}
+
+	failed, total := 0, 0
+	var flat []verifyCell
+	for _, row := range cells {
+		for _, cell := range row {
+			if cell.Result == VerifySkipped {
+				continue
+			}
+			total++
+			if cell.Result != VerifyOK {
+				failed++
+			}
+			flat = append(flat, cell)
+		}
+	}
+
+	if asJSON {
+		if flat == nil {
+			flat = []verifyCell{}
+		}
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(flat); err != nil {
+			return fmt.Errorf("failed to encode the access matrix as JSON: %w", err)
+		}
+	} else {
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprint(w, pkg.ColorRow(os.Stdout, pkg.ColorPlain, "ACCOUNT\tACCOUNT ID\t"+strings.Join(roleNames, "\t")+"\n"))
+		for _, row := range cells {
+			color, results := pkg.ColorGreen, make([]string, len(row))
+			for j, cell := range row {
+				results[j] = cell.Result
+				switch cell.Result {
+				case VerifyDenied, VerifyError:
+					color = pkg.ColorRed
+				case VerifyMFA:
+					if color != pkg.ColorRed {
+						color = pkg.ColorYellow
+					}
+				}
+			}
+			fmt.Fprint(w, pkg.ColorRow(os.Stdout, color, fmt.Sprintf("%s\t%s\t%s\n", row[0].Account, row[0].AccountID, strings.Join(results, "\t"))))
+		}
+		w.Flush()
+		for _, cell := range flat {
+			if cell.Result == VerifyDenied || cell.Result == VerifyError {
+				fmt.Fprintf(os.Stderr, "%s / %s: %s\n", cell.Account, cell.Role, cell.Error)
+			}
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d out of %d account/role combination(s) could not be assumed", failed, total)
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package pkg
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+
+	"github.com/AlecAivazis/survey/v2"
+)
+
+// accountPromptPageSize is the page size of account prompts. Longer lists are
+// narrowed by group first when the config defines groups.
+const accountPromptPageSize = 15
+
+const (
+	allAccountsGroupOption = "All accounts"
+	ungroupedGroupOption   = "Ungrouped accounts"
+)
+
+// chooseAccountGroup asks which group to pick an account from when names does
+// not fit on one page of the account prompt, and returns the names in that
+// group in their original order. Accounts in no group are offered together.
+// Without groups, or for short lists, names is returned as is.
+func chooseAccountGroup(names []string) ([]string, error) {
+	if len(groups) == 0 || len(names) <= accountPromptPageSize {
+		return names, nil
+	}
+	grouped := make(map[string]bool)
+	groupNames := make([]string, 0, len(groups))
+	for name, members := range groups {
+		groupNames = append(groupNames, name)
+		for _, member := range members {
+			grouped[member] = true
+		}
+	}
+	sort.Strings(groupNames)
+
+	var options []string
+	optionMembers := make(map[string][]string)
+	for _, name := range groupNames {
+		members := slices.DeleteFunc(slices.Clone(names), func(n string) bool {
+			return !slices.Contains(groups[name], n)
+		})
+		if len(members) == 0 {
+			continue
+		}
+		option := fmt.Sprintf("%s%s (%d)", GroupSelectorPrefix, name, len(members))
+		options = append(options, option)
+		optionMembers[option] = members
+	}
+	ungrouped := slices.DeleteFunc(slices.Clone(names), func(n string) bool { return grouped[n] })
+	if len(ungrouped) > 0 {
+		option := fmt.Sprintf("%s (%d)", ungroupedGroupOption, len(ungrouped))
+		options = append(options, option)
+		optionMembers[option] = ungrouped
+	}
+	if len(options) < 2 {
+		return names, nil
+	}
+	options = append(options, allAccountsGroupOption)
+	optionMembers[allAccountsGroupOption] = names
+
+	chosen := ""
+	prompt := &survey.Select{Message: "Choose an account group:", Options: options, PageSize: accountPromptPageSize}
+	if err := AskOne(prompt, &chosen, survey.WithValidator(survey.Required), WithFuzzyFilter); err != nil {
+		return nil, fmt.Errorf("account group selection failed: %w", err)
+	}
+	return optionMembers[chosen], nil
+}
@@ -0,0 +1,159 @@
+package pkg
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const envAuditLogVar = "SAWS_AUDIT_LOG"
+
+// Audit events.
+const (
+	AuditAssumeRole = "assume_role" // a single-account context was established (or failed)
+	AuditTarget     = "target"      // one account/region of a fan-out run finished
+	AuditInvocation = "invocation"  // saws exited
+)
+
+// AuditLog is the defaults.audit_log path; empty disables the audit log.
+var AuditLog string
+
+var auditMu sync.Mutex
+
+// AuditRecord is one line of the audit log. Who, when, and the (redacted)
+// command line are filled in by Audit.
+type AuditRecord struct {
+	Time      time.Time `json:"time"`
+	User      string    `json:"user"`
+	Host      string    `json:"host"`
+	Event     string    `json:"event"`
+	Mode      string    `json:"mode,omitempty"`
+	Args      []string  `json:"args,omitempty"`
+	Account   string    `json:"account,omitempty"`
+	AccountID string    `json:"account_id,omitempty"`
+	Role      string    `json:"role,omitempty"`
+	Region    string    `json:"region,omitempty"`
+	Result    string    `json:"result"`
+	Error     string    `json:"error,omitempty"`
+	ExitCode  *int      `json:"exit_code,omitempty"`
+}
+
+// auditLogPath returns the audit log file from SAWS_AUDIT_LOG or
+// defaults.audit_log, with a leading ~ expanded.
+func auditLogPath() string {
+	path := AuditLog
+	if env := os.Getenv(envAuditLogVar); env != "" {
+		path = env
+	}
+	if strings.HasPrefix(path, "~") {
+		if homeDir, err := os.UserHomeDir(); err == nil {
+			path = filepath.Join(homeDir, path[1:])
+		}
+	}
+	return path
+}
+
+// CurrentUser names the OS user running saws, for audit records and published run events.
+func CurrentUser() string {
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	if name := os.Getenv("USER"); name != "" {
+		return name
+	}
+	return os.Getenv("USERNAME")
+}
+
+// Audit appends rec to the audit log when one is configured. The file is only
+// ever opened for appending; failures are only logged.
+func Audit(rec AuditRecord) {
+	path := auditLogPath()
+	if path == "" {
+		return
+	}
+	rec.Time = time.Now().UTC()
+	rec.User = CurrentUser()
+	rec.Host, _ = os.Hostname()
+	if currentInvocation != nil {
+		rec.Mode = currentInvocation.Mode
+		rec.Args = currentInvocation.Args
+	}
+	rec.Error = RedactSecrets(rec.Error)
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return
+	}
+
+	auditMu.Lock()
+	defer auditMu.Unlock()
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		LogVerbosef("Warning: Could not create directory for audit log '%s': %v", path, err)
+		return
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		LogVerbosef("Warning: Could not open audit log '%s': %v", path, err)
+		return
+	}
+	defer f.Close()
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		LogVerbosef("Warning: Could not write audit log '%s': %v", path, err)
+	}
+}
+
+// LoadAuditLog returns the records of the audit log and its path; both are
+// empty when no audit log is configured or it has not been written yet.
+func LoadAuditLog() ([]AuditRecord, string, error) {
+	path := auditLogPath()
+	if path == "" {
+		return nil, "", nil
+	}
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, path, nil
+	}
+	if err != nil {
+		return nil, path, err
+	}
+	defer f.Close()
+	var records []AuditRecord
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var rec AuditRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			LogVerbosef("Warning: Skipping unreadable audit log line in '%s': %v", path, err)
+			continue
+		}
+		records = append(records, rec)
+	}
+	return records, path, scanner.Err()
+}
+
+// AuditResult fills the result of rec from err.
+func AuditResult(rec AuditRecord, err error) AuditRecord {
+	rec.Result = "ok"
+	if err != nil {
+		rec.Result = "failed"
+		rec.Error = err.Error()
+	}
+	return rec
+}
+
+// FinishAudit records that saws exits with exitCode. Only invocations recorded
+// in the history are audited; helper modes are covered by their assume_role events.
+func FinishAudit(exitCode int) {
+	if currentInvocation == nil {
+		return
+	}
+	rec := AuditRecord{Event: AuditInvocation, Result: "ok", ExitCode: &exitCode}
+	if exitCode != 0 {
+		rec.Result = "failed"
+	}
+	Audit(rec)
+}
@@ -6,14 +6,17 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/AlecAivazis/survey/v2"
 	"github.com/aws/aws-sdk-go-v2/aws"
 	awsconfig "github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/sts"
 	ststypes "github.com/aws/aws-sdk-go-v2/service/sts/types"
+	"github.com/aws/smithy-go/middleware"
 )
 
 type SelectedContext struct {
@@ -23,20 +26,86 @@ type SelectedContext struct {
 	Region      string
 }
 
-const (
-	BaseProfileForAssume   = "default"
-	FallbackRegion         = "eu-west-1"
-	SessionDurationSeconds = 3600
-)
+const FallbackRegion = "eu-west-1"
+
+// OtherAccountOption ends the account prompt and asks for an account ID that
+// is not in the config.
+const OtherAccountOption = "Other (enter account ID)…"
+
+// SessionDurationSeconds is the AssumeRole session duration; see defaults.session_duration.
+var SessionDurationSeconds int32 = 3600
+
+// BaseProfileForAssume is the shared-config profile whose credentials call
+// sts:AssumeRole. It is "default" unless the config (or the active context)
+// sets base_profile.
+var BaseProfileForAssume = "default"
 
+// AssumeRole returns credentials of roleToAssume in accountID, reusing cached
+// ones that remain valid for CredentialCacheMinLifetime.
 func AssumeRole(ctx context.Context, baseCfg aws.Config, accountID, roleToAssume, sessionNameSuffix string) (*ststypes.Credentials, error) {
+	return AssumeRoleLasting(ctx, baseCfg, accountID, roleToAssume, sessionNameSuffix, CredentialCacheMinLifetime)
+}
+
+// AssumeRoleLasting is AssumeRole for callers that need the credentials for
+// at least minLifetime, such as fan-outs with long targets; cached credentials
+// that expire sooner are not reused.
+func AssumeRoleLasting(ctx context.Context, baseCfg aws.Config, accountID, roleToAssume, sessionNameSuffix string, minLifetime time.Duration) (*ststypes.Credentials, error) {
+	return assumeRole(ctx, baseCfg, accountID, roleToAssume, sessionNameSuffix, minLifetime, true)
+}
+
+// AssumeRoleFresh is AssumeRole without reusing cached credentials, for checks
+// such as verify whose outcome cached credentials would hide: they do not
+// reflect a trust policy changed since they were issued.
+func AssumeRoleFresh(ctx context.Context, baseCfg aws.Config, accountID, roleToAssume, sessionNameSuffix string) (*ststypes.Credentials, error) {
+	return assumeRole(ctx, baseCfg, accountID, roleToAssume, sessionNameSuffix, CredentialCacheMinLifetime, false)
+}
+
+// assumeRole is AssumeRoleLasting, reusing cached credentials only with reuse.
+func assumeRole(ctx context.Context, baseCfg aws.Config, accountID, roleToAssume, sessionNameSuffix string, minLifetime time.Duration, reuse bool) (*ststypes.Credentials, error) {
 	if baseCfg.Region == "" {
 		LogVerbosef("Warning: base AWS config for STS AssumeRole call had no region, defaulting to %s", FallbackRegion)
 		baseCfg.Region = FallbackRegion
 	}
 
-	stsClient := sts.NewFromConfig(baseCfg)
-	roleArn := fmt.Sprintf("arn:aws:iam::%s:role/%s", accountID, roleToAssume)
+	if permissionSet, ok := SSOPermissionSet(roleToAssume); ok {
+		creds, err := ssoRoleCredentials(ctx, baseCfg, accountID, permissionSet)
+		if err != nil && IsExpiredCredentials(err) {
+			if errReauth := ReauthenticateBase(ctx, baseCfg); errReauth != nil {
+				return nil, fmt.Errorf("%w; %v", err, errReauth)
+			}
+			creds, err = ssoRoleCredentials(ctx, baseCfg, accountID, permissionSet)
+		}
+		return creds, err
+	}
+
+	partition := accountIDPartition(accountID, PartitionForRegion(baseCfg.Region))
+	if PartitionForRegion(baseCfg.Region) != partition && knownPartition(partition) {
+		LogVerbosef("Calling STS in %s for account %s (partition %s).", PartitionDefaultRegion(partition), accountID, partition)
+		baseCfg.Region = PartitionDefaultRegion(partition)
+	}
+	roleArn := fmt.Sprintf("arn:%s:iam::%s:role/%s", partition, accountID, roleToAssume)
+	if parsed, ok := ParseRoleARN(roleToAssume); ok {
+		if parsed.AccountID != accountID {
+			return nil, fmt.Errorf("role ARN %s is not in account %s", roleToAssume, accountID)
+		}
+		// The ARN is used as given; STS is called in its partition.
+		if PartitionForRegion(baseCfg.Region) != parsed.Partition {
+			if region, ok := partitionDefaultRegions[parsed.Partition]; ok {
+				LogVerbosef("Calling STS in %s for role ARN %s (partition %s).", region, roleToAssume, parsed.Partition)
+				baseCfg.Region = region
+			}
+		}
+		roleArn, roleToAssume = parsed.ARN, parsed.Name
+	}
+	baseCfg.Region = stsRegion(baseCfg.Region)
+	externalID := AccountExternalID(accountID)
+	cachePath, cacheable := credentialCachePath(baseCfg, roleArn, sessionNameSuffix, externalID)
+	if cacheable && reuse {
+		if creds, ok := readCachedCredentials(cachePath, max(minLifetime, CredentialCacheMinLifetime)); ok {
+			return creds, nil
+		}
+	}
+	stsClient := newSTSClient(baseCfg)
 
 	safeRolePart := strings.ReplaceAll(roleToAssume, "/", "-")
 	safeRolePart = strings.ReplaceAll(safeRolePart, " ", "_")
@@ -54,9 +123,20 @@ func AssumeRole(ctx context.Context, baseCfg aws.Config, accountID, roleToAssume
 		RoleSessionName: aws.String(sessionName),
 		DurationSeconds: aws.Int32(SessionDurationSeconds),
 	}
+	if externalID != "" {
+		AssumeRoleInput.ExternalId = aws.String(externalID)
+		LogVerbosef("Passing the configured ExternalId for account %s.", accountID)
+	}
 	LogVerbosef("Attempting AssumeRole: ARN=%s, SessionName=%s", roleArn, sessionName)
 
 	AssumeRoleOutput, err := stsClient.AssumeRole(ctx, AssumeRoleInput)
+	if err != nil && IsExpiredCredentials(err) {
+		if errReauth := ReauthenticateBase(ctx, baseCfg); errReauth != nil {
+			return nil, fmt.Errorf("sts:AssumeRole call failed for role ARN %s: %w; %v", roleArn, err, errReauth)
+		}
+		LogVerbosef("Retrying AssumeRole of %s with the refreshed base credentials.", roleArn)
+		AssumeRoleOutput, err = newSTSClient(baseCfg).AssumeRole(ctx, AssumeRoleInput)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("sts:AssumeRole call failed for role ARN %s: %w", roleArn, err)
 	}
@@ -68,7 +148,11 @@ func AssumeRole(ctx context.Context, baseCfg aws.Config, accountID, roleToAssume
 		return nil, fmt.Errorf("assume role response for role ARN %s did not contain valid credentials", roleArn)
 	}
 
+	RegisterSecret(*AssumeRoleOutput.Credentials.AccessKeyId, *AssumeRoleOutput.Credentials.SecretAccessKey, *AssumeRoleOutput.Credentials.SessionToken)
 	LogVerbosef("Successfully assumed role %s", roleArn)
+	if cacheable {
+		writeCachedCredentials(cachePath, roleArn, AssumeRoleOutput.Credentials)
+	}
 	return AssumeRoleOutput.Credentials, nil
 }
 
@@ -79,11 +163,25 @@ func EstablishAWSContextAndAssumeRole(ctx context.Context, accountSelectorFlag,
 
 	sCtx := &SelectedContext{}
 
+	roleSelector := roleFlag
+	if roleSelector == "" {
+		roleSelector = os.Getenv(envRoleVar)
+	}
+	if err := ValidateRoleARN(roleSelector); err != nil {
+		return nil, nil, err
+	}
+	if accountID := roleAccountSelector(roleSelector); accountID != "" && accountSelectorFlag == "" && os.Getenv(envAccountVar) == "" {
+		LogVerbosef("Using account %s of role ARN %s.", accountID, roleSelector)
+		accountSelectorFlag = accountID
+	}
+
 	allAccountNames := make([]string, 0, len(accounts))
 	for name := range accounts {
 		allAccountNames = append(allAccountNames, name)
 	}
 	sort.Strings(allAccountNames)
+	recentAccounts := recentValues(func(r RecentSelection) string { return r.Account }, nil)
+	allAccountNames = recentFirst(allAccountNames, recentAccounts, func(name string) string { return name })
 
 	selectedAccountName := ""
 	currentAccountSelector := accountSelectorFlag
@@ -95,21 +193,36 @@ func EstablishAWSContextAndAssumeRole(ctx context.Context, accountSelectorFlag,
 	} else {
 		LogVerbosef("Using account selector '%s' from -s flag.", currentAccountSelector)
 	}
+	currentAccountSelector = normalizeAccountIDSelector(currentAccountSelector)
 
-	if currentAccountSelector != "" {
+	if accountIDPattern.MatchString(currentAccountSelector) {
+		selectedAccountName = accountNameForID(currentAccountSelector)
+	} else if currentAccountSelector != "" {
 		matchedAccountNames := []string{}
-		for _, accName := range allAccountNames {
-			if currentAccountSelector == accName {
-				matchedAccountNames = []string{accName}
-				break
-			}
-			match, err := filepath.Match(currentAccountSelector, accName)
-			if err != nil {
-				LogVerbosef("Warning: Invalid pattern '%s' in selector: %v. Skipping this pattern for account '%s'.", currentAccountSelector, err, accName)
-				continue
+		members, isGroup, errGroup := expandGroupSelector(groups, currentAccountSelector)
+		if errGroup != nil {
+			return nil, nil, errGroup
+		}
+		if isGroup {
+			matchedAccountNames = dedupeAccountsByID(accounts, members)
+		} else {
+			for _, accName := range allAccountNames {
+				if currentAccountSelector == accName || accountMatchesAlias(accountDetails, accName, currentAccountSelector) {
+					matchedAccountNames = []string{accName}
+					break
+				}
+				match, err := filepath.Match(currentAccountSelector, accName)
+				if err != nil {
+					LogVerbosef("Warning: Invalid pattern '%s' in selector: %v. Skipping this pattern for account '%s'.", currentAccountSelector, err, accName)
+					continue
+				}
+				if match {
+					matchedAccountNames = append(matchedAccountNames, accName)
+				}
 			}
-			if match {
-				matchedAccountNames = append(matchedAccountNames, accName)
+			if len(matchedAccountNames) == 0 {
+				// An account ID cut short, e.g. pasted from an alert.
+				matchedAccountNames = accountsWithIDPrefix(accounts, currentAccountSelector)
 			}
 		}
 		if len(matchedAccountNames) == 1 {
@@ -120,14 +233,15 @@ func EstablishAWSContextAndAssumeRole(ctx context.Context, accountSelectorFlag,
 			displayOptions := make([]string, len(matchedAccountNames))
 			optionToAccountNameMap := make(map[string]string)
 			sort.Strings(matchedAccountNames)
+			matchedAccountNames = recentFirst(matchedAccountNames, recentAccounts, func(name string) string { return name })
 			for i, name := range matchedAccountNames {
-				displayStr := fmt.Sprintf("%s (%s)", name, accounts[name])
+				displayStr := AccountOption(name, accounts[name], accountDetails[name])
 				displayOptions[i] = displayStr
 				optionToAccountNameMap[displayStr] = name
 			}
 			chosenDisplayStr := ""
-			promptAccount := &survey.Select{Message: "Choose an AWS Account:", Options: displayOptions, PageSize: 15}
-			err := survey.AskOne(promptAccount, &chosenDisplayStr, survey.WithValidator(survey.Required))
+			promptAccount := &survey.Select{Message: "Choose an AWS Account:", Options: displayOptions, PageSize: accountPromptPageSize}
+			err := AskOne(promptAccount, &chosenDisplayStr, survey.WithValidator(survey.Required), WithFuzzyFilter)
 			if err != nil {
 				return nil, nil, fmt.Errorf("account selection from multiple matches failed: %w", err)
 			}
@@ -139,23 +253,56 @@ func EstablishAWSContextAndAssumeRole(ctx context.Context, accountSelectorFlag,
 
 	if selectedAccountName == "" {
 		fmt.Fprintln(os.Stderr, "Please select an account:")
-		displayOptions := make([]string, len(allAccountNames))
+		promptAccountNames := allAccountNames
+		if len(projectAccounts) > 0 {
+			// The .saws.yaml of the working directory names the accounts it uses.
+			promptAccountNames = slices.DeleteFunc(slices.Clone(allAccountNames), func(name string) bool {
+				return !slices.Contains(projectAccounts, name)
+			})
+		}
+		promptAccountNames, err := chooseAccountGroup(promptAccountNames)
+		if err != nil {
+			return nil, nil, err
+		}
+		displayOptions := make([]string, len(promptAccountNames))
 		optionToAccountNameMap := make(map[string]string)
-		for i, name := range allAccountNames {
-			displayStr := fmt.Sprintf("%s (%s)", name, accounts[name])
+		for i, name := range promptAccountNames {
+			displayStr := AccountOption(name, accounts[name], accountDetails[name])
 			displayOptions[i] = displayStr
 			optionToAccountNameMap[displayStr] = name
 		}
+		displayOptions = append(displayOptions, OtherAccountOption)
 		chosenDisplayStr := ""
-		promptAccount := &survey.Select{Message: "Choose an AWS Account:", Options: displayOptions, PageSize: 15}
-		err := survey.AskOne(promptAccount, &chosenDisplayStr, survey.WithValidator(survey.Required))
+		promptAccount := &survey.Select{Message: "Choose an AWS Account:", Options: displayOptions, PageSize: accountPromptPageSize}
+		err = AskOne(promptAccount, &chosenDisplayStr, survey.WithValidator(survey.Required), WithFuzzyFilter)
 		if err != nil {
 			return nil, nil, fmt.Errorf("interactive account selection failed: %w", err)
 		}
 		selectedAccountName = optionToAccountNameMap[chosenDisplayStr]
+		if chosenDisplayStr == OtherAccountOption {
+			accountID := ""
+			promptAccountID := &survey.Input{Message: "Enter the 12-digit AWS account ID:"}
+			err := AskOne(promptAccountID, &accountID, survey.WithValidator(func(ans any) error {
+				if !accountIDPattern.MatchString(strings.TrimSpace(fmt.Sprint(ans))) {
+					return errors.New("account IDs are exactly 12 digits")
+				}
+				return nil
+			}))
+			if err != nil {
+				return nil, nil, fmt.Errorf("manual account ID input failed: %w", err)
+			}
+			selectedAccountName = accountNameForID(strings.TrimSpace(accountID))
+		}
 	}
 	sCtx.AccountName = selectedAccountName
 	sCtx.AccountID = accounts[selectedAccountName]
+	if sCtx.AccountID == "" {
+		// An ad-hoc account is named by its ID.
+		sCtx.AccountID = selectedAccountName
+	}
+	if err := checkRoleARNAccount(roleSelector, sCtx.AccountName, sCtx.AccountID); err != nil {
+		return nil, nil, err
+	}
 
 	selectedRoleName := ""
 	currentRoleName := roleFlag
@@ -174,17 +321,30 @@ func EstablishAWSContextAndAssumeRole(ctx context.Context, accountSelectorFlag,
 			LogVerbosef("Interpreted non-interactive role '%s' as friendly name for actual role '%s'.", currentRoleName, friendlyRole)
 			selectedRoleName = friendlyRole
 		}
+		if !RoleAllowedInAccount(selectedAccountName, currentRoleName) {
+			fmt.Fprintf(os.Stderr, "Warning: role '%s' is not listed for account '%s' under roles_by_account; assuming it anyway.\n", currentRoleName, selectedAccountName)
+		}
 	} else {
 		if len(roles) > 0 {
 			fmt.Fprintln(os.Stderr, "Please select a role:")
 			friendlyRoleNames := make([]string, 0, len(roles))
 			for friendlyName := range roles {
-				friendlyRoleNames = append(friendlyRoleNames, friendlyName)
+				if RoleAllowedInAccount(selectedAccountName, friendlyName) {
+					friendlyRoleNames = append(friendlyRoleNames, friendlyName)
+				}
+			}
+			if len(friendlyRoleNames) == 0 {
+				fmt.Fprintf(os.Stderr, "Warning: no configured role is listed for account '%s' under roles_by_account; offering all roles.\n", selectedAccountName)
+				for friendlyName := range roles {
+					friendlyRoleNames = append(friendlyRoleNames, friendlyName)
+				}
 			}
 			sort.Strings(friendlyRoleNames)
+			recentRoles := recentValues(func(r RecentSelection) string { return r.Role }, func(r RecentSelection) bool { return r.Account == selectedAccountName })
+			friendlyRoleNames = recentFirst(friendlyRoleNames, recentRoles, func(friendly string) string { return roles[friendly] })
 			chosenFriendlyName := ""
 			promptRoleSelect := &survey.Select{Message: "Choose Role to Assume:", Options: friendlyRoleNames, PageSize: 15}
-			err := survey.AskOne(promptRoleSelect, &chosenFriendlyName, survey.WithValidator(survey.Required))
+			err := AskOne(promptRoleSelect, &chosenFriendlyName, survey.WithValidator(survey.Required), WithFuzzyFilter)
 			if err != nil {
 				return nil, nil, fmt.Errorf("interactive role selection failed: %w", err)
 			}
@@ -193,7 +353,7 @@ func EstablishAWSContextAndAssumeRole(ctx context.Context, accountSelectorFlag,
 		} else {
 			fmt.Fprintln(os.Stderr, "No 'roles' section in config. Please provide role name:")
 			promptManualRole := &survey.Input{Message: "Enter the exact IAM Role Name to Assume:"}
-			err := survey.AskOne(promptManualRole, &selectedRoleName, survey.WithValidator(survey.Required))
+			err := AskOne(promptManualRole, &selectedRoleName, survey.WithValidator(survey.Required))
 			if err != nil {
 				return nil, nil, fmt.Errorf("manual role input failed: %w", err)
 			}
@@ -205,6 +365,7 @@ func EstablishAWSContextAndAssumeRole(ctx context.Context, accountSelectorFlag,
 	sCtx.RoleName = selectedRoleName
 
 	selectedRegion := ""
+	var finalCreds *ststypes.Credentials
 	currentRegion := regionFlagFromCmd
 	if currentRegion == "" {
 		currentRegion = os.Getenv(envRegionVar)
@@ -214,6 +375,11 @@ func EstablishAWSContextAndAssumeRole(ctx context.Context, accountSelectorFlag,
 	} else {
 		LogVerbosef("Using region '%s' from -region flag.", currentRegion)
 	}
+	if currentRegion != "" {
+		if err := ValidateRegions("region", currentRegion); err != nil {
+			return nil, nil, err
+		}
+	}
 
 	if currentRegion != "" {
 		selectedRegion = currentRegion
@@ -221,7 +387,7 @@ func EstablishAWSContextAndAssumeRole(ctx context.Context, accountSelectorFlag,
 		availablePromptRegions := commonRegions
 		if len(availablePromptRegions) == 0 {
 			LogVerbosef("No 'common_regions' defined in SAWS config. Trying to detect default AWS region from your environment...")
-			tempCfg, err := awsconfig.LoadDefaultConfig(ctx, awsconfig.WithSharedConfigProfile(BaseProfileForAssume))
+			tempCfg, err := LoadAWSConfig(ctx, awsconfig.WithSharedConfigProfile(BaseProfileForAssume))
 			if err == nil && tempCfg.Region != "" {
 				LogVerbosef("Detected default AWS region: %s. Using it as the only option for selection.", tempCfg.Region)
 				availablePromptRegions = []string{tempCfg.Region}
@@ -229,9 +395,34 @@ func EstablishAWSContextAndAssumeRole(ctx context.Context, accountSelectorFlag,
 				LogVerbosef("Could not detect default AWS region from environment. Please provide the region manually.")
 			}
 		}
+		if partition := ContextPartition(sCtx); len(availablePromptRegions) > 0 {
+			if inPartition := PartitionRegions(partition, availablePromptRegions); len(inPartition) > 0 {
+				availablePromptRegions = inPartition
+			} else if knownPartition(partition) {
+				LogVerbosef("None of the regions is in partition %s of account %s; offering %s.", partition, sCtx.AccountName, partitionDefaultRegions[partition])
+				availablePromptRegions = []string{partitionDefaultRegions[partition]}
+			}
+		}
 		if len(availablePromptRegions) > 0 {
+			// The role is assumed before the region is known so the prompt can
+			// show which regions the account has enabled; the credentials are reused.
+			// The nested session is confirmed and the assume audited right away.
+			var enabled map[string]bool
+			if !NoInput {
+				if err := ConfirmNestedSession(fmt.Sprintf("Account=%s(%s), Role=%s", sCtx.AccountName, sCtx.AccountID, sCtx.RoleName)); err != nil {
+					return nil, nil, err
+				}
+				creds, err := AssumeSelectedRole(ctx, sCtx, sessionType)
+				Audit(AuditResult(AuditRecord{Event: AuditAssumeRole, Account: sCtx.AccountName, AccountID: sCtx.AccountID, Role: sCtx.RoleName}, err))
+				if err == nil {
+					finalCreds = creds
+					enabled = enabledRegions(ctx, finalCreds, ContextPartition(sCtx))
+				} else {
+					LogVerbosef("Could not assume the role ahead of the region prompt: %v", err)
+				}
+			}
 			defaultRegionChoice := FallbackRegion
-			tempCfg, err := awsconfig.LoadDefaultConfig(ctx, awsconfig.WithSharedConfigProfile(BaseProfileForAssume))
+			tempCfg, err := LoadAWSConfig(ctx, awsconfig.WithSharedConfigProfile(BaseProfileForAssume))
 			if err == nil && tempCfg.Region != "" {
 				defaultRegionChoice = tempCfg.Region
 			}
@@ -245,16 +436,33 @@ func EstablishAWSContextAndAssumeRole(ctx context.Context, accountSelectorFlag,
 			if !foundDefaultInList && len(availablePromptRegions) > 0 {
 				defaultRegionChoice = availablePromptRegions[0]
 			}
+			recentRegions := recentValues(func(r RecentSelection) string { return r.Region }, func(r RecentSelection) bool { return r.Account == selectedAccountName })
+			availablePromptRegions = recentFirst(availablePromptRegions, recentRegions, func(region string) string { return region })
+			if len(recentRegions) > 0 && availablePromptRegions[0] == recentRegions[0] {
+				defaultRegionChoice = recentRegions[0]
+			}
+			regionOptions := make([]string, len(availablePromptRegions))
+			optionToRegion := make(map[string]string, len(availablePromptRegions))
+			defaultOption := ""
+			for i, region := range availablePromptRegions {
+				regionOptions[i] = RegionOption(region, enabled)
+				optionToRegion[regionOptions[i]] = region
+				if region == defaultRegionChoice {
+					defaultOption = regionOptions[i]
+				}
+			}
 			fmt.Fprintln(os.Stderr, "Please select a region:")
-			promptRegion := &survey.Select{Message: "Choose AWS Region:", Options: availablePromptRegions, Default: defaultRegionChoice, PageSize: 10}
-			err = survey.AskOne(promptRegion, &selectedRegion, survey.WithValidator(survey.Required))
+			chosenOption := ""
+			promptRegion := &survey.Select{Message: "Choose AWS Region:", Options: regionOptions, Default: defaultOption, PageSize: 10}
+			err = AskOne(promptRegion, &chosenOption, survey.WithValidator(survey.Required), WithFuzzyFilter)
 			if err != nil {
 				return nil, nil, fmt.Errorf("interactive region selection failed: %w", err)
 			}
+			selectedRegion = optionToRegion[chosenOption]
 		} else {
 			fmt.Fprintln(os.Stderr, "Please provide region manually:")
 			promptManualRegion := &survey.Input{Message: "Enter the AWS Region:"}
-			err := survey.AskOne(promptManualRegion, &selectedRegion, survey.WithValidator(survey.Required))
+			err := AskOne(promptManualRegion, &selectedRegion, survey.WithValidator(survey.Required))
 			if err != nil {
 				return nil, nil, fmt.Errorf("manual region input failed: %w", err)
 			}
@@ -266,14 +474,131 @@ func EstablishAWSContextAndAssumeRole(ctx context.Context, accountSelectorFlag,
 	sCtx.Region = selectedRegion
 
 	LogVerbosef("Context established: Account=%s(%s), Role=%s, Region=%s. Assuming role for session type: %s", sCtx.AccountName, sCtx.AccountID, sCtx.RoleName, sCtx.Region, sessionType)
-	baseCfg, err := awsconfig.LoadDefaultConfig(ctx, awsconfig.WithSharedConfigProfile(BaseProfileForAssume), awsconfig.WithRegion(FallbackRegion))
-	if err != nil {
-		return nil, nil, fmt.Errorf("failed to load base AWS configuration for STS AssumeRole call: %w", err)
+	if partition := ContextPartition(sCtx); PartitionForRegion(sCtx.Region) != partition {
+		return nil, nil, fmt.Errorf("region %s is not in partition %s of account %s", sCtx.Region, partition, sCtx.AccountName)
 	}
-	finalCreds, err := AssumeRole(ctx, baseCfg, sCtx.AccountID, sCtx.RoleName, sessionType)
-	if err != nil {
-		return nil, nil, fmt.Errorf("failed to assume role '%s' in account %s (%s) for region %s: %w", sCtx.RoleName, sCtx.AccountName, sCtx.AccountID, sCtx.Region, err)
+	if err := ConfirmNestedSession(fmt.Sprintf("Account=%s(%s), Role=%s, Region=%s", sCtx.AccountName, sCtx.AccountID, sCtx.RoleName, sCtx.Region)); err != nil {
+		return nil, nil, err
+	}
+	if finalCreds == nil {
+		var err error
+		finalCreds, err = AssumeSelectedRole(ctx, sCtx, sessionType)
+		Audit(AuditResult(AuditRecord{Event: AuditAssumeRole, Account: sCtx.AccountName, AccountID: sCtx.AccountID, Role: sCtx.RoleName, Region: sCtx.Region}, err))
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to assume role '%s' in account %s (%s) for region %s: %w", sCtx.RoleName, sCtx.AccountName, sCtx.AccountID, sCtx.Region, err)
+		}
 	}
+	RecordRecentSelection(RecentSelection{Account: sCtx.AccountName, Role: sCtx.RoleName, Region: sCtx.Region, Session: sessionType})
+	NoteHistoryTargets([]string{sCtx.AccountName}, []string{sCtx.Region})
+	NoteHistoryRole(sCtx.RoleName)
 
 	return sCtx, finalCreds, nil
 }
+
+// accountNameForID returns the configured account with the given ID, or the ID
+// itself for an account that is not (yet) in the config.
+func accountNameForID(accountID string) string {
+	names := make([]string, 0, 1)
+	for name, id := range accounts {
+		if id == accountID {
+			names = append(names, name)
+		}
+	}
+	if len(names) > 0 {
+		sort.Strings(names)
+		LogVerbosef("Account ID %s is '%s' in the SAWS config.", accountID, names[0])
+		return names[0]
+	}
+	fmt.Fprintf(os.Stderr, "Note: account %s is not in the SAWS config; using it ad hoc.\n", accountID)
+	return accountID
+}
+
+// ResolveContext resolves an account (name, alias, account ID or the start of
+// one, or a wildcard matching exactly one account), a role (friendly or actual
+// name, or an ARN, whose account is used when accountSelector is empty), and a
+// region without prompting, for callers such as the daemon that cannot ask.
+func ResolveContext(accountSelector, role, region string) (*SelectedContext, error) {
+	if err := ValidateRoleARN(role); err != nil {
+		return nil, err
+	}
+	if accountSelector == "" {
+		accountSelector = roleAccountSelector(role)
+	}
+	if accountSelector == "" || role == "" {
+		return nil, errors.New("account and role are required")
+	}
+	sCtx := &SelectedContext{RoleName: role, Region: region}
+	if actualRole, ok := roles[role]; ok {
+		sCtx.RoleName = actualRole
+	}
+	if sCtx.Region == "" {
+		sCtx.Region = FallbackRegion
+	}
+	if err := ValidateRegions("region", sCtx.Region); err != nil {
+		return nil, err
+	}
+
+	accountSelector = normalizeAccountIDSelector(accountSelector)
+	var matches []string
+	for name, id := range accounts {
+		if accountSelector == name || accountSelector == id || accountMatchesAlias(accountDetails, name, accountSelector) {
+			matches = []string{name}
+			break
+		}
+		if match, _ := filepath.Match(accountSelector, name); match {
+			matches = append(matches, name)
+		}
+	}
+	if len(matches) == 0 {
+		matches = accountsWithIDPrefix(accounts, accountSelector)
+	}
+	switch {
+	case len(matches) == 1:
+		sCtx.AccountName, sCtx.AccountID = matches[0], accounts[matches[0]]
+	case len(matches) > 1:
+		sort.Strings(matches)
+		return nil, fmt.Errorf("account selector '%s' matches several accounts: %s", accountSelector, strings.Join(matches, ", "))
+	case accountIDPattern.MatchString(accountSelector):
+		sCtx.AccountName, sCtx.AccountID = accountSelector, accountSelector
+	default:
+		return nil, fmt.Errorf("account selector '%s' did not match any accounts in SAWS config", accountSelector)
+	}
+	if err := checkRoleARNAccount(role, sCtx.AccountName, sCtx.AccountID); err != nil {
+		return nil, err
+	}
+	if partition := ContextPartition(sCtx); PartitionForRegion(sCtx.Region) != partition {
+		return nil, fmt.Errorf("region %s is not in partition %s of account %s", sCtx.Region, partition, sCtx.AccountName)
+	}
+	return sCtx, nil
+}
+
+// AssumeSelectedRole assumes the role of sCtx in its account from the base profile.
+func AssumeSelectedRole(ctx context.Context, sCtx *SelectedContext, sessionType string) (*ststypes.Credentials, error) {
+	baseCfg, err := LoadAWSConfig(ctx, awsconfig.WithSharedConfigProfile(BaseProfileForAssume), awsconfig.WithRegion(FallbackRegion))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load base AWS configuration for STS AssumeRole call: %w", err)
+	}
+	if baseCfg, err = BaseConfigForAccount(ctx, sCtx.AccountName, baseCfg); err != nil {
+		return nil, err
+	}
+	return AssumeRole(ctx, baseCfg, sCtx.AccountID, sCtx.RoleName, sessionType)
+}
+
+// LoadAssumedRoleConfig builds an SDK config that signs requests with the given
+// assumed-role credentials in the given region.
+func LoadAssumedRoleConfig(ctx context.Context, creds *ststypes.Credentials, region string) (aws.Config, error) {
+	staticCreds := aws.Credentials{AccessKeyID: *creds.AccessKeyId, SecretAccessKey: *creds.SecretAccessKey, SessionToken: *creds.SessionToken, Source: "SawsAssumedRole"}
+	if creds.Expiration != nil {
+		staticCreds.CanExpire = true
+		staticCreds.Expires = *creds.Expiration
+	}
+	cfg, err := LoadAWSConfig(ctx,
+		awsconfig.WithCredentialsProvider(aws.CredentialsProviderFunc(func(ctx context.Context) (aws.Credentials, error) { return staticCreds, nil })),
+		awsconfig.WithRegion(region),
+		awsconfig.WithAPIOptions([]func(*middleware.Stack) error{evictRejectedCredentials(creds)}),
+	)
+	if err != nil {
+		return aws.Config{}, fmt.Errorf("failed to load SDK config for assumed role in region %s: %w", region, err)
+	}
+	return cfg, nil
+}
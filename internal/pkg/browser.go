@@ -0,0 +1,22 @@
+package pkg
+
+import (
+	"os/exec"
+	"runtime"
+)
+
+// OpenBrowser asks the desktop environment to open url; failures are only logged.
+func OpenBrowser(url string) {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", url)
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", url)
+	default:
+		cmd = exec.Command("xdg-open", url)
+	}
+	if err := cmd.Start(); err != nil {
+		LogVerbosef("Warning: could not open a browser for %s: %v", url, err)
+	}
+}
@@ -0,0 +1,49 @@
+package pkg
+
+import (
+	"os"
+	"strings"
+)
+
+// Color is an ANSI foreground color code.
+type Color string
+
+const (
+	ColorPlain  Color = "39"
+	ColorRed    Color = "31"
+	ColorGreen  Color = "32"
+	ColorYellow Color = "33"
+
+	ansiReset = "\033[0m"
+)
+
+// NoColor turns color off regardless of defaults.color (--no-color).
+var NoColor bool
+
+func (c Color) escape() string {
+	return "\033[" + string(c) + "m"
+}
+
+// Colorize wraps s in c when output to f is colored.
+func Colorize(f *os.File, c Color, s string) string {
+	if !UseColor(f) {
+		return s
+	}
+	return c.escape() + s + ansiReset
+}
+
+// ColorRow colors one line of a tabwriter table written to f; line may end in
+// a newline. The escape a line starts with has the same width for every color,
+// so as long as every line of the table goes through ColorRow (ColorPlain for
+// the header and uncolored rows) the columns stay aligned.
+func ColorRow(f *os.File, c Color, line string) string {
+	if !UseColor(f) {
+		return line
+	}
+	body, hasNewline := strings.CutSuffix(line, "\n")
+	line = c.escape() + body + ansiReset
+	if hasNewline {
+		line += "\n"
+	}
+	return line
+}
@@ -5,52 +5,249 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
-
-	"gopkg.in/yaml.v3"
 )
 
 type AppConfig struct {
-	Accounts      map[string]string `yaml:"accounts"`
-	CommonRegions []string          `yaml:"common_regions"`
-	Roles         map[string]string `yaml:"roles"`
+	// Include lists further config files, or conf.d-style directories of
+	// *.yaml files, merged into this one. See mergeIncludes.
+	Include       []string                `yaml:"include"`
+	Accounts      map[string]string       `yaml:"accounts"`
+	CommonRegions []string                `yaml:"common_regions"`
+	Roles         map[string]string       `yaml:"roles"`
+	Tunnels       map[string]TunnelConfig `yaml:"tunnels"`
+	// EKSClusters maps a cluster name of your choosing to the account, role,
+	// and region its kubeconfig entry gets tokens with (saws kubeconfig).
+	EKSClusters map[string]EKSClusterConfig `yaml:"eks_clusters"`
+	// AccountDetails holds the description and aliases of accounts written in
+	// the long form (see AccountDetail); Accounts still maps every name to its ID.
+	AccountDetails map[string]AccountDetail `yaml:"-"`
+	// Groups name sets of accounts, selected with -s "@group".
+	Groups map[string][]string `yaml:"groups"`
+	// RolesByAccount maps account name patterns to the roles (friendly or
+	// actual names) that exist there; unmatched accounts allow every role.
+	RolesByAccount map[string][]string `yaml:"roles_by_account"`
+	// DockerCredentialRole is the role docker-credential-saws assumes when
+	// neither -r nor SAWS_ROLE is set.
+	DockerCredentialRole string `yaml:"docker_credential_role"`
+	// CodeCommitRepos maps CodeCommit repository names (or wildcards) to the
+	// account and role git-credential-saws signs their HTTPS requests with.
+	CodeCommitRepos map[string]CodeCommitRepoConfig `yaml:"codecommit_repos"`
+	// AccountsSource is "static" (default) or "organizations", which fetches the
+	// active accounts at startup; static accounts are merged on top.
+	AccountsSource string              `yaml:"accounts_source"`
+	Organizations  OrganizationsConfig `yaml:"organizations"`
+	// Remote points a local config at a remote one (see RemoteConfigRef).
+	Remote RemoteConfigRef `yaml:"remote"`
+	// BaseProfile overrides the AWS profile used to call sts:AssumeRole.
+	BaseProfile string `yaml:"base_profile"`
+	// Partition is the partition (aws, aws-us-gov, or aws-cn) of accounts that
+	// do not set their own; "aws" when empty.
+	Partition string `yaml:"partition"`
+	// Defaults holds team-wide tool behavior (parallelism, output, shell, ...).
+	Defaults DefaultsConfig `yaml:"defaults"`
+	// Contexts are named alternative setups (e.g. one per client organization)
+	// selected with -context, SAWS_CONTEXT, or default_context.
+	Contexts       map[string]ContextConfig `yaml:"contexts"`
+	DefaultContext string                   `yaml:"default_context"`
+	// ActiveContext is the name of the context applied by LoadConfig, if any.
+	ActiveContext string `yaml:"-"`
+}
+
+// ContextConfig is a named context. When active, its accounts, roles, groups,
+// roles_by_account, and tunnels replace the top-level ones; base_profile, partition, and
+// common_regions do so only when set.
+type ContextConfig struct {
+	BaseProfile    string                  `yaml:"base_profile"`
+	Partition      string                  `yaml:"partition"`
+	Accounts       map[string]string       `yaml:"accounts"`
+	CommonRegions  []string                `yaml:"common_regions"`
+	Roles          map[string]string       `yaml:"roles"`
+	Tunnels        map[string]TunnelConfig `yaml:"tunnels"`
+	Groups         map[string][]string     `yaml:"groups"`
+	RolesByAccount map[string][]string     `yaml:"roles_by_account"`
+	// AccountDetails is filled from long-form account entries, as on AppConfig.
+	AccountDetails map[string]AccountDetail `yaml:"-"`
+}
+
+// TunnelConfig describes a named SSM port forward brought up by -tunnel.
+type TunnelConfig struct {
+	Account    string `yaml:"account"`
+	Role       string `yaml:"role"`
+	Region     string `yaml:"region"`
+	Bastion    string `yaml:"bastion"`
+	RemoteHost string `yaml:"remote_host"`
+	RemotePort int32  `yaml:"remote_port"`
+	LocalPort  int32  `yaml:"local_port"`
+}
+
+// EKSClusterConfig is an entry of eks_clusters. Cluster is the EKS cluster
+// name, which defaults to the entry's name.
+type EKSClusterConfig struct {
+	Cluster string `yaml:"cluster"`
+	Account string `yaml:"account"`
+	Role    string `yaml:"role"`
+	Region  string `yaml:"region"`
+}
+
+// CodeCommitRepoConfig is an entry of codecommit_repos.
+type CodeCommitRepoConfig struct {
+	Account string `yaml:"account"`
+	Role    string `yaml:"role"`
+}
+
+// ClusterName is the EKS name of the cluster configured as name.
+func (c EKSClusterConfig) ClusterName(name string) string {
+	if c.Cluster != "" {
+		return c.Cluster
+	}
+	return name
 }
 
 var accounts map[string]string
 var commonRegions []string
 var roles map[string]string
+var groups map[string][]string
+var accountDetails map[string]AccountDetail
+var rolesByAccount map[string][]string
 var VerboseMode bool
 
+// QuietMode (-q) drops the result banners of fan-out modes so stdout carries
+// only the targets' own output.
+var QuietMode bool
+
 const (
-	ConfigFileName = "saws-config.yaml"
-	AWSConfigDir   = ".aws"
+	ConfigFileName     = "saws-config.yaml"
+	ConfigFileNameJSON = "saws-config.json"
+	AWSConfigDir       = ".aws"
 )
 
 const (
 	envRoleVar    = "SAWS_ROLE"
 	envRegionVar  = "SAWS_REGION"
 	envAccountVar = "SAWS_ACCOUNT"
+	envContextVar = "SAWS_CONTEXT"
+	envConfigVar  = "SAWS_CONFIG"
 )
 
 func LogVerbosef(format string, v ...any) {
 	if VerboseMode {
-		log.Printf(format, v...)
+		log.Print(RedactSecrets(fmt.Sprintf(format, v...)))
 	}
 }
 
-func LoadConfig(filePath string) (*AppConfig, error) {
+// ApplyContext switches cfg to the named context, falling back to SAWS_CONTEXT
+// and then default_context. With no context selected the top-level settings apply.
+func ApplyContext(cfg *AppConfig, contextName, filePath string) error {
+	if contextName == "" {
+		contextName = os.Getenv(envContextVar)
+		if contextName != "" {
+			LogVerbosef("Using context '%s' from %s environment variable.", contextName, envContextVar)
+		}
+	}
+	if contextName == "" {
+		contextName = cfg.DefaultContext
+	}
+	if contextName == "" {
+		if cfg.BaseProfile != "" {
+			BaseProfileForAssume = cfg.BaseProfile
+		}
+		return nil
+	}
+
+	selected, ok := cfg.Contexts[contextName]
+	if !ok {
+		names := make([]string, 0, len(cfg.Contexts))
+		for name := range cfg.Contexts {
+			names = append(names, name)
+		}
+		if len(names) == 0 {
+			return fmt.Errorf("context '%s' requested but no contexts are defined in '%s'", contextName, filePath)
+		}
+		sort.Strings(names)
+		return fmt.Errorf("context '%s' not found in '%s' (available: %s)", contextName, filePath, strings.Join(names, ", "))
+	}
+	cfg.ActiveContext = contextName
+	cfg.Accounts = selected.Accounts
+	if cfg.Accounts == nil {
+		cfg.Accounts = make(map[string]string)
+	}
+	cfg.Roles = selected.Roles
+	if cfg.Roles == nil {
+		cfg.Roles = make(map[string]string)
+	}
+	cfg.Tunnels = selected.Tunnels
+	cfg.Groups = selected.Groups
+	cfg.RolesByAccount = selected.RolesByAccount
+	cfg.AccountDetails = selected.AccountDetails
+	if len(selected.CommonRegions) > 0 {
+		cfg.CommonRegions = selected.CommonRegions
+	}
+	if selected.Partition != "" {
+		cfg.Partition = selected.Partition
+	}
+	switch {
+	case selected.BaseProfile != "":
+		BaseProfileForAssume = selected.BaseProfile
+	case cfg.BaseProfile != "":
+		BaseProfileForAssume = cfg.BaseProfile
+	}
+	LogVerbosef("Active SAWS context: %s (base profile '%s').", contextName, BaseProfileForAssume)
+	return nil
+}
+
+// LoadConfig reads the SAWS config at filePath. contextName selects a named
+// context (see ApplyContext) and may be empty.
+func LoadConfig(filePath, contextName string) (*AppConfig, error) {
 	data, err := os.ReadFile(filePath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read SAWS config file '%s': %w", filePath, err)
 	}
+	if filePath, data, err = followRemoteConfig(filePath, data); err != nil {
+		return nil, fmt.Errorf("SAWS remote config failed: %w", err)
+	}
 	var loadedAppConfig AppConfig
 	loadedAppConfig.Accounts = make(map[string]string)
 	loadedAppConfig.Roles = make(map[string]string)
 	loadedAppConfig.CommonRegions = []string{}
 
-	err = yaml.Unmarshal(data, &loadedAppConfig)
+	err = decodeConfigFile(filePath, data, &loadedAppConfig)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse YAML from SAWS config file '%s': %w", filePath, err)
+		return nil, fmt.Errorf("failed to parse SAWS config file '%s': %w", filePath, err)
+	}
+
+	if err := mergeIncludes(&loadedAppConfig, filePath); err != nil {
+		return nil, fmt.Errorf("SAWS config include failed for '%s': %w", filePath, err)
+	}
+	if err := reportLintIssues(validateDefaults(loadedAppConfig.Defaults), filePath); err != nil {
+		return nil, err
+	}
+	if keys := remoteOnlyDefaults(loadedAppConfig.Defaults); len(keys) > 0 && isRemoteConfigCopy(filePath) {
+		return nil, fmt.Errorf("SAWS config validation failed: remote config '%s' sets %s, which only a local config may set", filePath, strings.Join(keys, ", "))
+	}
+	applyDefaults(loadedAppConfig.Defaults)
+	if loadedAppConfig.BaseProfile == "" {
+		loadedAppConfig.BaseProfile = loadedAppConfig.Defaults.BaseProfile
+	}
+	if err := ApplyContext(&loadedAppConfig, contextName, filePath); err != nil {
+		return nil, fmt.Errorf("SAWS config context selection failed: %w", err)
+	}
+
+	switch loadedAppConfig.AccountsSource {
+	case "", AccountsSourceStatic:
+	case AccountsSourceOrganizations:
+		discovered, errOrg := discoverOrganizationAccounts(loadedAppConfig.Organizations)
+		if errOrg != nil {
+			return nil, fmt.Errorf("failed to discover accounts from AWS Organizations: %w", errOrg)
+		}
+		staticAccounts := loadedAppConfig.Accounts
+		loadedAppConfig.Accounts = discovered
+		for name, id := range staticAccounts {
+			loadedAppConfig.Accounts[name] = id
+		}
+	default:
+		return nil, fmt.Errorf("SAWS config validation failed: unknown accounts_source '%s' in '%s' (expected '%s' or '%s')", loadedAppConfig.AccountsSource, filePath, AccountsSourceStatic, AccountsSourceOrganizations)
 	}
 
 	if len(loadedAppConfig.Accounts) == 0 {
@@ -59,6 +256,34 @@ func LoadConfig(filePath string) (*AppConfig, error) {
 	if len(loadedAppConfig.CommonRegions) == 0 {
 		LogVerbosef("Warning: 'common_regions' list is empty in SAWS config '%s'. Region selection might be limited.", filePath)
 	}
+	for name, tunnel := range loadedAppConfig.Tunnels {
+		if tunnel.Account == "" || tunnel.Bastion == "" || tunnel.RemoteHost == "" || tunnel.RemotePort == 0 {
+			return nil, fmt.Errorf("SAWS config validation failed: tunnel '%s' in '%s' must set account, bastion, remote_host, and remote_port", name, filePath)
+		}
+		if _, ok := loadedAppConfig.Accounts[tunnel.Account]; !ok {
+			return nil, fmt.Errorf("SAWS config validation failed: tunnel '%s' in '%s' references unknown account '%s'", name, filePath, tunnel.Account)
+		}
+	}
+	for name, cluster := range loadedAppConfig.EKSClusters {
+		if cluster.Account == "" || cluster.Role == "" || cluster.Region == "" {
+			return nil, fmt.Errorf("SAWS config validation failed: eks_clusters entry '%s' in '%s' must set account, role, and region", name, filePath)
+		}
+		if _, ok := loadedAppConfig.Accounts[cluster.Account]; !ok {
+			return nil, fmt.Errorf("SAWS config validation failed: eks_clusters entry '%s' in '%s' references unknown account '%s'", name, filePath, cluster.Account)
+		}
+	}
+	for pattern, repo := range loadedAppConfig.CodeCommitRepos {
+		if _, ok := loadedAppConfig.Accounts[repo.Account]; !ok {
+			return nil, fmt.Errorf("SAWS config validation failed: codecommit_repos entry '%s' in '%s' references unknown account '%s'", pattern, filePath, repo.Account)
+		}
+	}
+	lintIssues := lintAccountSet("", loadedAppConfig.Accounts, loadedAppConfig.AccountDetails, loadedAppConfig.Roles, loadedAppConfig.Groups)
+	lintIssues = append(lintIssues, lintRegions("", loadedAppConfig.CommonRegions, loadedAppConfig.Tunnels)...)
+	lintIssues = append(lintIssues, lintPartitions("", loadedAppConfig.Partition, loadedAppConfig.Accounts, loadedAppConfig.AccountDetails, loadedAppConfig.CommonRegions)...)
+	lintIssues = append(lintIssues, lintRolesByAccount("", loadedAppConfig.RolesByAccount, loadedAppConfig.Accounts, loadedAppConfig.Roles)...)
+	if err := reportLintIssues(lintIssues, filePath); err != nil {
+		return nil, err
+	}
 	if len(loadedAppConfig.Roles) == 0 {
 		LogVerbosef("Info: 'roles' map is empty or missing in SAWS config '%s'. Roles must be provided via -r flag or %s env var for session modes, or selected manually.", filePath, envRoleVar)
 	}
@@ -66,12 +291,62 @@ func LoadConfig(filePath string) (*AppConfig, error) {
 	accounts = loadedAppConfig.Accounts
 	commonRegions = loadedAppConfig.CommonRegions
 	roles = loadedAppConfig.Roles
+	groups = loadedAppConfig.Groups
+	accountDetails = loadedAppConfig.AccountDetails
+	rolesByAccount = loadedAppConfig.RolesByAccount
+	DefaultPartition = PartitionAWS
+	if loadedAppConfig.Partition != "" {
+		DefaultPartition = loadedAppConfig.Partition
+	}
 
 	LogVerbosef("Loaded SAWS config: %d accounts, %d regions, %d roles from %s", len(accounts), len(commonRegions), len(roles), filePath)
 	return &loadedAppConfig, nil
 }
 
+// userConfigDirPath returns the saws config path under the OS user config
+// directory: $XDG_CONFIG_HOME/saws (or ~/.config/saws) on Linux,
+// ~/Library/Application Support/saws on macOS, and %AppData%\saws on Windows.
+func userConfigDirPath() (string, error) {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configDir, "saws", ConfigFileName), nil
+}
+
+// CandidateConfigPaths lists the default locations FindConfigPath searches, in order.
+func CandidateConfigPaths() []string {
+	var paths []string
+	if homeDir, err := os.UserHomeDir(); err == nil {
+		paths = append(paths, filepath.Join(homeDir, AWSConfigDir, ConfigFileName))
+	}
+	if userConfigPath, err := userConfigDirPath(); err == nil {
+		paths = append(paths, userConfigPath)
+	}
+	return append(paths, ConfigFileName)
+}
+
+// findConfigFileIn returns the YAML or, failing that, JSON config file in dir.
+func findConfigFileIn(dir string) (string, bool) {
+	for _, name := range []string{ConfigFileName, ConfigFileNameJSON} {
+		configPath := filepath.Join(dir, name)
+		if _, err := os.Stat(configPath); err == nil {
+			return configPath, true
+		}
+	}
+	return "", false
+}
+
 func FindConfigPath(configFileOverride string) (string, error) {
+	overrideSource := "-config flag"
+	if configFileOverride == "" {
+		configFileOverride = os.Getenv(envConfigVar)
+		overrideSource = envConfigVar + " environment variable"
+	}
+	if IsRemoteConfig(configFileOverride) {
+		LogVerbosef("Using remote SAWS config from %s: %s", overrideSource, configFileOverride)
+		return FetchRemoteConfig(RemoteConfigRef{URL: configFileOverride})
+	}
 	if configFileOverride != "" {
 		expandedPath := configFileOverride
 		if strings.HasPrefix(configFileOverride, "~") {
@@ -83,27 +358,35 @@ func FindConfigPath(configFileOverride string) (string, error) {
 			}
 		}
 		if _, err := os.Stat(expandedPath); err == nil {
-			LogVerbosef("Using specified SAWS config file: %s", expandedPath)
+			LogVerbosef("Using SAWS config file from %s: %s", overrideSource, expandedPath)
 			return expandedPath, nil
 		}
-		return "", fmt.Errorf("specified SAWS config file '%s' (expanded to '%s') not found", configFileOverride, expandedPath)
+		return "", fmt.Errorf("SAWS config file '%s' from %s (expanded to '%s') not found", configFileOverride, overrideSource, expandedPath)
 	}
 
 	homeDir, err := os.UserHomeDir()
 	if err == nil {
-		configPath := filepath.Join(homeDir, AWSConfigDir, ConfigFileName)
-		if _, errStat := os.Stat(configPath); errStat == nil {
+		if configPath, ok := findConfigFileIn(filepath.Join(homeDir, AWSConfigDir)); ok {
 			return configPath, nil
 		}
 	} else {
 		LogVerbosef("Warning: Could not determine home directory: %v. Cannot check default ~/%s/%s location.", err, AWSConfigDir, ConfigFileName)
 	}
 
-	configPathLocal := ConfigFileName
-	if _, err := os.Stat(configPathLocal); err == nil {
-		return configPathLocal, nil
+	userConfigPath, err := userConfigDirPath()
+	if err == nil {
+		if configPath, ok := findConfigFileIn(filepath.Dir(userConfigPath)); ok {
+			return configPath, nil
+		}
+	} else {
+		LogVerbosef("Warning: Could not determine user config directory: %v.", err)
+		userConfigPath = filepath.Join("$XDG_CONFIG_HOME", "saws", ConfigFileName)
+	}
+
+	if configPath, ok := findConfigFileIn("."); ok {
+		return configPath, nil
 	}
 
-	return "", fmt.Errorf("SAWS configuration file ('%s') not found in standard locations (~/%s/%s, ./%s) and no -config flag provided",
-		ConfigFileName, AWSConfigDir, ConfigFileName, ConfigFileName)
+	return "", fmt.Errorf("SAWS configuration file ('%s' or '%s') not found in standard locations (~/%s/, %s, ./) and neither -config nor %s was provided",
+		ConfigFileName, ConfigFileNameJSON, AWSConfigDir, filepath.Dir(userConfigPath), envConfigVar)
 }
@@ -0,0 +1,162 @@
+package pkg
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// AccountDetail is the long form of an accounts: entry:
+//
+//	prod-payments:
+//	  id: "123456789012"
+//	  description: PCI cardholder env
+//	  aliases: [payments, pci]
+//	  base_profile: gov-sso   # optional, see BaseConfigForAccount
+//	  partition: aws-us-gov   # optional: aws (default), aws-us-gov, or aws-cn
+//	  external_id: a1b2c3     # optional: sts:ExternalId the role's trust policy requires
+//
+// The short form `prod-payments: "123456789012"` has no detail.
+type AccountDetail struct {
+	ID          string   `yaml:"id"`
+	Description string   `yaml:"description"`
+	Aliases     []string `yaml:"aliases"`
+	BaseProfile string   `yaml:"base_profile"`
+	Partition   string   `yaml:"partition"`
+	ExternalID  string   `yaml:"external_id"`
+}
+
+// extractAccountDetails rewrites the long-form entries of an accounts: mapping
+// node to plain IDs, so it decodes as map[string]string, and returns their details.
+func extractAccountDetails(accountsNode *yaml.Node, path string) (map[string]AccountDetail, []unknownConfigKey, error) {
+	if accountsNode.Kind != yaml.MappingNode {
+		return nil, nil, nil
+	}
+	details := make(map[string]AccountDetail)
+	var unknown []unknownConfigKey
+	for i := 0; i+1 < len(accountsNode.Content); i += 2 {
+		name, valueNode := accountsNode.Content[i].Value, accountsNode.Content[i+1]
+		if valueNode.Kind != yaml.MappingNode {
+			continue
+		}
+		entryPath := joinKeyPath(path, name)
+		unknown = append(unknown, findUnknownKeys(valueNode, reflect.TypeOf(AccountDetail{}), entryPath)...)
+		var detail AccountDetail
+		if err := valueNode.Decode(&detail); err != nil {
+			return nil, nil, fmt.Errorf("account '%s': %w", name, err)
+		}
+		if detail.ID == "" {
+			return nil, nil, fmt.Errorf("line %d: account '%s' must set id", valueNode.Line, name)
+		}
+		details[name] = detail
+		*valueNode = yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: detail.ID, Line: valueNode.Line, Column: valueNode.Column}
+	}
+	return details, unknown, nil
+}
+
+// mappingValue returns the value node of key in a mapping node, or nil.
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// splitAccountDetails extracts the long-form account entries of the top level
+// and of every context. Details are keyed by context name, "" for the top level.
+func splitAccountDetails(root *yaml.Node) (map[string]map[string]AccountDetail, []unknownConfigKey, error) {
+	doc := root
+	if doc.Kind == yaml.DocumentNode && len(doc.Content) > 0 {
+		doc = doc.Content[0]
+	}
+	all := make(map[string]map[string]AccountDetail)
+	var unknown []unknownConfigKey
+	if accountsNode := mappingValue(doc, "accounts"); accountsNode != nil {
+		details, found, err := extractAccountDetails(accountsNode, "accounts")
+		if err != nil {
+			return nil, nil, err
+		}
+		all[""], unknown = details, append(unknown, found...)
+	}
+	if contextsNode := mappingValue(doc, "contexts"); contextsNode != nil && contextsNode.Kind == yaml.MappingNode {
+		for i := 0; i+1 < len(contextsNode.Content); i += 2 {
+			name := contextsNode.Content[i].Value
+			accountsNode := mappingValue(contextsNode.Content[i+1], "accounts")
+			if accountsNode == nil {
+				continue
+			}
+			details, found, err := extractAccountDetails(accountsNode, joinKeyPath("contexts."+name, "accounts"))
+			if err != nil {
+				return nil, nil, fmt.Errorf("context '%s': %w", name, err)
+			}
+			all[name], unknown = details, append(unknown, found...)
+		}
+	}
+	return all, unknown, nil
+}
+
+// applyAccountDetails stores details from splitAccountDetails on the decoded cfg.
+func applyAccountDetails(cfg *AppConfig, details map[string]map[string]AccountDetail) {
+	cfg.AccountDetails = details[""]
+	for name, c := range cfg.Contexts {
+		c.AccountDetails = details[name]
+		cfg.Contexts[name] = c
+	}
+}
+
+// accountMatchesAlias reports whether selector is one of the aliases of account name.
+func accountMatchesAlias(details map[string]AccountDetail, name, selector string) bool {
+	for _, alias := range details[name].Aliases {
+		if alias == selector {
+			return true
+		}
+	}
+	return false
+}
+
+// accountIDPrefixPattern matches the start of an account ID, with or without
+// the dashes the console shows (1234-5678-9012).
+var accountIDPrefixPattern = regexp.MustCompile(`^\d[\d-]{0,13}$`)
+
+// normalizeAccountIDSelector removes the dashes of a selector that is an
+// account ID or the start of one; other selectors are returned unchanged.
+func normalizeAccountIDSelector(selector string) string {
+	if !accountIDPrefixPattern.MatchString(selector) {
+		return selector
+	}
+	return strings.ReplaceAll(selector, "-", "")
+}
+
+// accountsWithIDPrefix returns the accounts whose ID starts with prefix, one
+// name per ID, sorted.
+func accountsWithIDPrefix(accounts map[string]string, prefix string) []string {
+	if !accountIDPrefixPattern.MatchString(prefix) || strings.Contains(prefix, "-") {
+		return nil
+	}
+	var names []string
+	for name, id := range accounts {
+		if strings.HasPrefix(id, prefix) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return dedupeAccountsByID(accounts, names)
+}
+
+// AccountOption formats an account for survey prompts, e.g.
+// "prod-payments (123456789012) — PCI cardholder env".
+func AccountOption(name, id string, detail AccountDetail) string {
+	if detail.Description == "" {
+		return fmt.Sprintf("%s (%s)", name, id)
+	}
+	return fmt.Sprintf("%s (%s) — %s", name, id, detail.Description)
+}
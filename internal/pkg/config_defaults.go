@@ -0,0 +1,155 @@
+package pkg
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+const (
+	OutputText = "text"
+	OutputJSON = "json"
+
+	ColorAuto   = "auto"
+	ColorAlways = "always"
+	ColorNever  = "never"
+
+	minSessionDuration = 15 * time.Minute
+	maxSessionDuration = 12 * time.Hour
+)
+
+// DefaultsConfig is the defaults: block, holding team-wide tool behavior.
+// Command-line flags still take precedence.
+type DefaultsConfig struct {
+	// Parallelism caps concurrent targets in fan-out modes; 0 means unlimited.
+	Parallelism int `yaml:"parallelism"`
+	// AdaptiveParallelism ramps concurrent targets up and halves them on
+	// throttling errors; Parallelism, if set, stays the ceiling.
+	AdaptiveParallelism bool `yaml:"adaptive_parallelism"`
+	// Output is "text" or "json" for modes that support -json.
+	Output string `yaml:"output"`
+	// SessionDuration is the AssumeRole duration, e.g. "1h" (15m to 12h).
+	SessionDuration string `yaml:"session_duration"`
+	// BaseProfile applies when neither base_profile nor the context sets one.
+	BaseProfile string `yaml:"base_profile"`
+	// Shell is the program started by -e instead of $SHELL.
+	Shell string `yaml:"shell"`
+	// Color is "auto" (when stdout is a terminal and NO_COLOR is unset), "always", or "never".
+	Color string `yaml:"color"`
+	// ShellPrompt shows the account/role/region in the prompt of the -e
+	// sub-shell; set it to false to keep your own prompt untouched.
+	ShellPrompt *bool `yaml:"shell_prompt"`
+	// UpdateCheck opts into a daily check for newer saws releases on GitHub.
+	UpdateCheck bool `yaml:"update_check"`
+	// AuditLog is a file every invocation, assumed role, and fan-out target is
+	// appended to as JSON lines (SAWS_AUDIT_LOG overrides it).
+	AuditLog string `yaml:"audit_log"`
+	// ReauthCommand refreshes expired base credentials before saws retries,
+	// e.g. "aws-mfa --profile default"; SSO profiles need none.
+	ReauthCommand string `yaml:"reauth_command"`
+	// CredentialCache keeps assumed-role credentials on disk for later runs;
+	// set it to false to assume roles afresh every time.
+	CredentialCache *bool `yaml:"credential_cache"`
+	// STSEndpoint is "regional", to never call the global STS endpoint, or the
+	// URL of the endpoint every sts:AssumeRole call goes to.
+	STSEndpoint string `yaml:"sts_endpoint"`
+	// STSRegion is the region whose STS endpoint sts:AssumeRole calls use.
+	STSRegion string `yaml:"sts_region"`
+}
+
+// Settings from the defaults: block, applied by LoadConfig.
+var (
+	Parallelism         int
+	AdaptiveParallelism bool
+	OutputFormat        = OutputText
+	SubShell            string
+	ColorMode           = ColorAuto
+	ShellPrompt         = true
+)
+
+// validateDefaults checks the defaults: block.
+func validateDefaults(d DefaultsConfig) []ConfigIssue {
+	var issues []ConfigIssue
+	add := func(format string, args ...any) {
+		issues = append(issues, ConfigIssue{Level: IssueError, Message: fmt.Sprintf(format, args...)})
+	}
+	if d.Parallelism < 0 {
+		add("defaults.parallelism must be 0 (unlimited) or more, got %d", d.Parallelism)
+	}
+	switch d.Output {
+	case "", OutputText, OutputJSON:
+	default:
+		add("defaults.output '%s' is not '%s' or '%s'", d.Output, OutputText, OutputJSON)
+	}
+	if d.SessionDuration != "" {
+		duration, err := time.ParseDuration(d.SessionDuration)
+		if err != nil {
+			add("defaults.session_duration '%s' is not a duration such as 1h or 45m", d.SessionDuration)
+		} else if duration < minSessionDuration || duration > maxSessionDuration {
+			add("defaults.session_duration '%s' must be between %s and %s", d.SessionDuration, minSessionDuration, maxSessionDuration)
+		}
+	}
+	switch d.Color {
+	case "", ColorAuto, ColorAlways, ColorNever:
+	default:
+		add("defaults.color '%s' is not '%s', '%s', or '%s'", d.Color, ColorAuto, ColorAlways, ColorNever)
+	}
+	if err := ValidateSTSEndpoint(d.STSEndpoint); err != nil {
+		add("defaults.sts_endpoint: %v", err)
+	}
+	if d.STSRegion != "" {
+		if issue := checkRegionName(d.STSRegion); issue != nil {
+			issues = append(issues, ConfigIssue{Level: issue.Level, Message: "defaults.sts_region: " + issue.Message})
+		}
+	}
+	return issues
+}
+
+// applyDefaults sets the package-level settings from a validated defaults: block.
+func applyDefaults(d DefaultsConfig) {
+	Parallelism = d.Parallelism
+	AdaptiveParallelism = d.AdaptiveParallelism
+	if d.Output != "" {
+		OutputFormat = d.Output
+	}
+	if d.SessionDuration != "" {
+		if duration, err := time.ParseDuration(d.SessionDuration); err == nil {
+			SessionDurationSeconds = int32(duration.Seconds())
+		}
+	}
+	SubShell = d.Shell
+	if d.Color != "" {
+		ColorMode = d.Color
+	}
+	UpdateCheck = d.UpdateCheck
+	AuditLog = d.AuditLog
+	ReauthCommand = d.ReauthCommand
+	if d.ShellPrompt != nil {
+		ShellPrompt = *d.ShellPrompt
+	}
+	if d.CredentialCache != nil {
+		CredentialCache = *d.CredentialCache
+	}
+	STSEndpoint = d.STSEndpoint
+	STSRegion = d.STSRegion
+}
+
+// UseColor reports whether output to f should be colored under ColorMode.
+// --no-color (NoColor) always wins; NO_COLOR and a non-terminal f only matter
+// in auto mode.
+func UseColor(f *os.File) bool {
+	if NoColor {
+		return false
+	}
+	switch ColorMode {
+	case ColorAlways:
+		return true
+	case ColorNever:
+		return false
+	}
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
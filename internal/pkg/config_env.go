@@ -0,0 +1,106 @@
+package pkg
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// envRefPattern matches ${VAR} and ${VAR:-default}; a leading "$$" escapes the reference.
+var envRefPattern = regexp.MustCompile(`\$?\$\{([A-Za-z_][A-Za-z0-9_]*)(?::-([^}]*))?\}`)
+
+// expandEnvRefs replaces ${VAR} references in value. An unset variable without
+// a default is an error, so a missing variable never silently becomes "".
+func expandEnvRefs(value string) (string, error) {
+	if !strings.Contains(value, "${") {
+		return value, nil
+	}
+	var missing []string
+	expanded := envRefPattern.ReplaceAllStringFunc(value, func(ref string) string {
+		if strings.HasPrefix(ref, "$$") {
+			return ref[1:]
+		}
+		parts := envRefPattern.FindStringSubmatch(ref)
+		if envValue, ok := os.LookupEnv(parts[1]); ok {
+			return envValue
+		}
+		if strings.Contains(ref, ":-") {
+			return parts[2]
+		}
+		missing = append(missing, parts[1])
+		return ref
+	})
+	if len(missing) > 0 {
+		return "", fmt.Errorf("environment variable(s) %s referenced in '%s' are not set", strings.Join(missing, ", "), value)
+	}
+	return expanded, nil
+}
+
+// expandConfigNode expands ${VAR} references in every scalar value under node.
+// Mapping keys are left untouched. Plain scalars are re-resolved after expansion
+// so that e.g. `remote_port: ${DB_PORT}` still decodes as a number.
+func expandConfigNode(node *yaml.Node) error {
+	switch node.Kind {
+	case yaml.DocumentNode, yaml.SequenceNode:
+		for _, child := range node.Content {
+			if err := expandConfigNode(child); err != nil {
+				return err
+			}
+		}
+	case yaml.MappingNode:
+		for i := 1; i < len(node.Content); i += 2 {
+			if err := expandConfigNode(node.Content[i]); err != nil {
+				return err
+			}
+		}
+	case yaml.ScalarNode:
+		expanded, err := expandEnvRefs(node.Value)
+		if err != nil {
+			return fmt.Errorf("line %d: %w", node.Line, err)
+		}
+		if expanded != node.Value {
+			node.Value = expanded
+			if node.Style == 0 {
+				node.Tag = ""
+			}
+		}
+	}
+	return nil
+}
+
+// parseConfigNode parses data and expands its environment variable references.
+// An empty document yields a nil node.
+func parseConfigNode(data []byte) (*yaml.Node, error) {
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, err
+	}
+	if root.Kind == 0 {
+		return nil, nil
+	}
+	if err := expandConfigNode(&root); err != nil {
+		return nil, err
+	}
+	return &root, nil
+}
+
+// unmarshalConfig decodes config data into cfg after ${VAR} expansion,
+// ignoring unknown keys.
+func unmarshalConfig(data []byte, cfg *AppConfig) error {
+	root, err := parseConfigNode(data)
+	if err != nil || root == nil {
+		return err
+	}
+	details, _, err := splitAccountDetails(root)
+	if err != nil {
+		return err
+	}
+	if err := root.Decode(cfg); err != nil {
+		return err
+	}
+	applyAccountDetails(cfg, details)
+	return nil
+}
@@ -0,0 +1,228 @@
+package pkg
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// resolveIncludePaths expands the include: entries of the config at mainPath.
+// Relative paths are taken from the main config's directory, and a directory
+// contributes its *.yaml / *.yml / *.json files in lexical order (conf.d style).
+func resolveIncludePaths(mainPath string, includes []string) ([]string, error) {
+	baseDir := filepath.Dir(mainPath)
+	var paths []string
+	for _, include := range includes {
+		includePath := include
+		if strings.HasPrefix(includePath, "~") {
+			homeDir, err := os.UserHomeDir()
+			if err != nil {
+				return nil, fmt.Errorf("could not expand '~' in include '%s': %w", include, err)
+			}
+			includePath = filepath.Join(homeDir, includePath[1:])
+		} else if !filepath.IsAbs(includePath) {
+			includePath = filepath.Join(baseDir, includePath)
+		}
+
+		info, err := os.Stat(includePath)
+		if err != nil {
+			return nil, fmt.Errorf("include '%s' not found: %w", include, err)
+		}
+		if !info.IsDir() {
+			paths = append(paths, includePath)
+			continue
+		}
+		entries, err := os.ReadDir(includePath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read include directory '%s': %w", includePath, err)
+		}
+		var dirFiles []string
+		for _, entry := range entries {
+			ext := filepath.Ext(entry.Name())
+			if !entry.IsDir() && (ext == ".yaml" || ext == ".yml" || ext == ".json") {
+				dirFiles = append(dirFiles, filepath.Join(includePath, entry.Name()))
+			}
+		}
+		sort.Strings(dirFiles)
+		paths = append(paths, dirFiles...)
+	}
+	return paths, nil
+}
+
+// mergeStringMap adds src into dst, failing when a key already maps to a different value.
+func mergeStringMap(dst, src map[string]string, what, srcPath string, origins map[string]string) error {
+	for key, value := range src {
+		if existing, ok := dst[key]; ok && existing != value {
+			return fmt.Errorf("%s '%s' is '%s' in '%s' but '%s' in '%s'", what, key, existing, origins[key], value, srcPath)
+		}
+		dst[key] = value
+		if _, ok := origins[key]; !ok {
+			origins[key] = srcPath
+		}
+	}
+	return nil
+}
+
+// mainOnlyKeys lists the keys set in an included config that only the main
+// config may set, since mergeIncludes has no way to merge them.
+func mainOnlyKeys(included AppConfig) []string {
+	var keys []string
+	if included.BaseProfile != "" {
+		keys = append(keys, "base_profile")
+	}
+	if included.Partition != "" {
+		keys = append(keys, "partition")
+	}
+	if included.Defaults != (DefaultsConfig{}) {
+		keys = append(keys, "defaults")
+	}
+	if len(included.Contexts) > 0 {
+		keys = append(keys, "contexts")
+	}
+	if included.DefaultContext != "" {
+		keys = append(keys, "default_context")
+	}
+	if included.DockerCredentialRole != "" {
+		keys = append(keys, "docker_credential_role")
+	}
+	if included.AccountsSource != "" || included.Organizations != (OrganizationsConfig{}) {
+		keys = append(keys, "accounts_source/organizations")
+	}
+	return keys
+}
+
+// mergeIncludes loads every file listed under include: and merges its accounts,
+// roles, common_regions, groups, roles_by_account, tunnels, eks_clusters, and
+// codecommit_repos into cfg. Conflicting definitions are an
+// error; identical duplicates are allowed. Keys that only the main config may
+// set (see mainOnlyKeys) are an error too. Included files cannot include others.
+func mergeIncludes(cfg *AppConfig, mainPath string) error {
+	if len(cfg.Include) == 0 {
+		return nil
+	}
+	paths, err := resolveIncludePaths(mainPath, cfg.Include)
+	if err != nil {
+		return err
+	}
+
+	accountOrigins := make(map[string]string)
+	roleOrigins := make(map[string]string)
+	for name := range cfg.Accounts {
+		accountOrigins[name] = mainPath
+	}
+	for name := range cfg.Roles {
+		roleOrigins[name] = mainPath
+	}
+	detailOrigins := make(map[string]string)
+	for name := range cfg.AccountDetails {
+		detailOrigins[name] = mainPath
+	}
+	tunnelOrigins := make(map[string]string)
+	for name := range cfg.Tunnels {
+		tunnelOrigins[name] = mainPath
+	}
+	clusterOrigins := make(map[string]string)
+	for name := range cfg.EKSClusters {
+		clusterOrigins[name] = mainPath
+	}
+	groupOrigins := make(map[string]string)
+	for name := range cfg.Groups {
+		groupOrigins[name] = mainPath
+	}
+	knownRegions := make(map[string]bool)
+	for _, region := range cfg.CommonRegions {
+		knownRegions[region] = true
+	}
+
+	for _, includePath := range paths {
+		data, err := os.ReadFile(includePath)
+		if err != nil {
+			return fmt.Errorf("failed to read included config '%s': %w", includePath, err)
+		}
+		var included AppConfig
+		if err := decodeConfigFile(includePath, data, &included); err != nil {
+			return fmt.Errorf("failed to parse included config '%s': %w", includePath, err)
+		}
+		if len(included.Include) > 0 {
+			return fmt.Errorf("included config '%s' cannot itself use include:", includePath)
+		}
+		if included.Remote.URL != "" {
+			return fmt.Errorf("included config '%s' cannot use remote:", includePath)
+		}
+		if keys := mainOnlyKeys(included); len(keys) > 0 {
+			return fmt.Errorf("included config '%s' sets %s, which only the main config may set", includePath, strings.Join(keys, ", "))
+		}
+
+		if err := mergeStringMap(cfg.Accounts, included.Accounts, "account", includePath, accountOrigins); err != nil {
+			return err
+		}
+		if err := mergeStringMap(cfg.Roles, included.Roles, "role", includePath, roleOrigins); err != nil {
+			return err
+		}
+		for _, region := range included.CommonRegions {
+			if !knownRegions[region] {
+				knownRegions[region] = true
+				cfg.CommonRegions = append(cfg.CommonRegions, region)
+			}
+		}
+		for name, detail := range included.AccountDetails {
+			if origin, ok := detailOrigins[name]; ok {
+				return fmt.Errorf("account '%s' is described (long form) in both '%s' and '%s'", name, origin, includePath)
+			}
+			if cfg.AccountDetails == nil {
+				cfg.AccountDetails = make(map[string]AccountDetail)
+			}
+			cfg.AccountDetails[name] = detail
+			detailOrigins[name] = includePath
+		}
+		for name, members := range included.Groups {
+			if origin, ok := groupOrigins[name]; ok {
+				return fmt.Errorf("group '%s' is defined in both '%s' and '%s'", name, origin, includePath)
+			}
+			if cfg.Groups == nil {
+				cfg.Groups = make(map[string][]string)
+			}
+			cfg.Groups[name] = members
+			groupOrigins[name] = includePath
+		}
+		for pattern, roleNames := range included.RolesByAccount {
+			if cfg.RolesByAccount == nil {
+				cfg.RolesByAccount = make(map[string][]string)
+			}
+			cfg.RolesByAccount[pattern] = append(cfg.RolesByAccount[pattern], roleNames...)
+		}
+		for name, tunnel := range included.Tunnels {
+			if origin, ok := tunnelOrigins[name]; ok {
+				return fmt.Errorf("tunnel '%s' is defined in both '%s' and '%s'", name, origin, includePath)
+			}
+			if cfg.Tunnels == nil {
+				cfg.Tunnels = make(map[string]TunnelConfig)
+			}
+			cfg.Tunnels[name] = tunnel
+			tunnelOrigins[name] = includePath
+		}
+		for pattern, repo := range included.CodeCommitRepos {
+			if cfg.CodeCommitRepos == nil {
+				cfg.CodeCommitRepos = make(map[string]CodeCommitRepoConfig)
+			}
+			if existing, ok := cfg.CodeCommitRepos[pattern]; ok && existing != repo {
+				return fmt.Errorf("codecommit_repos entry '%s' is defined differently in '%s'", pattern, includePath)
+			}
+			cfg.CodeCommitRepos[pattern] = repo
+		}
+		for name, cluster := range included.EKSClusters {
+			if origin, ok := clusterOrigins[name]; ok {
+				return fmt.Errorf("eks_clusters entry '%s' is defined in both '%s' and '%s'", name, origin, includePath)
+			}
+			if cfg.EKSClusters == nil {
+				cfg.EKSClusters = make(map[string]EKSClusterConfig)
+			}
+			cfg.EKSClusters[name] = cluster
+			clusterOrigins[name] = includePath
+		}
+		LogVerbosef("Merged included config %s: %d accounts, %d roles, %d regions, %d tunnels", includePath, len(included.Accounts), len(included.Roles), len(included.CommonRegions), len(included.Tunnels))
+	}
+	return nil
+}
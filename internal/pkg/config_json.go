@@ -0,0 +1,42 @@
+package pkg
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// isJSONConfig reports whether path names a JSON config, by extension.
+func isJSONConfig(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), ".json")
+}
+
+// checkJSONSyntax rejects data that is not valid JSON, reporting the line and
+// column of a syntax error. JSON is a subset of YAML, so valid JSON configs
+// then go through the same decoding (${VAR} expansion, unknown keys) as YAML.
+func checkJSONSyntax(data []byte) error {
+	var v any
+	err := json.Unmarshal(data, &v)
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		before := data[:syntaxErr.Offset]
+		line := bytes.Count(before, []byte("\n")) + 1
+		column := len(before) - bytes.LastIndexByte(before, '\n')
+		return fmt.Errorf("line %d, column %d: %v", line, column, syntaxErr)
+	}
+	return err
+}
+
+// decodeConfigFile decodes the config data read from path, as JSON when path
+// ends in .json and as YAML otherwise.
+func decodeConfigFile(path string, data []byte, cfg *AppConfig) error {
+	if isJSONConfig(path) {
+		if err := checkJSONSyntax(data); err != nil {
+			return fmt.Errorf("invalid JSON: %w", err)
+		}
+	}
+	return decodeConfigStrict(data, cfg)
+}
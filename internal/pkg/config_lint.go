@@ -0,0 +1,145 @@
+package pkg
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// GroupSelectorPrefix marks a selector entry as a group name, e.g. -s "@payments".
+const GroupSelectorPrefix = "@"
+
+// externalIDPattern is what sts:AssumeRole accepts as an ExternalId, which is
+// also 2 to 1224 characters long.
+var externalIDPattern = regexp.MustCompile(`^[\w+=,.@:/-]+$`)
+
+// lintAccountSet reports collisions in one accounts/roles/groups set: account IDs
+// listed under several names, malformed external_ids, aliases that repeat or equal an account name,
+// friendly role names that shadow a real role name used elsewhere in roles:,
+// and groups naming accounts that do not exist.
+// scope prefixes messages, e.g. "context 'a': ".
+func lintAccountSet(scope string, accounts map[string]string, details map[string]AccountDetail, roles map[string]string, groups map[string][]string) []ConfigIssue {
+	var issues []ConfigIssue
+	add := func(level, format string, args ...any) {
+		issues = append(issues, ConfigIssue{Level: level, Message: scope + fmt.Sprintf(format, args...)})
+	}
+
+	namesByID := make(map[string][]string)
+	for name, id := range accounts {
+		namesByID[id] = append(namesByID[id], name)
+	}
+	ids := make([]string, 0, len(namesByID))
+	for id := range namesByID {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		if names := namesByID[id]; len(names) > 1 {
+			sort.Strings(names)
+			add(IssueWarning, "account ID %s is listed under several names (%s); selecting more than one of them targets the account once", id, strings.Join(names, ", "))
+		}
+	}
+
+	aliasOwners := make(map[string]string)
+	detailNames := make([]string, 0, len(details))
+	for name := range details {
+		detailNames = append(detailNames, name)
+	}
+	sort.Strings(detailNames)
+	for _, name := range detailNames {
+		if externalID := details[name].ExternalID; externalID != "" && (len(externalID) < 2 || len(externalID) > 1224 || !externalIDPattern.MatchString(externalID)) {
+			add(IssueError, "account '%s' has an external_id that STS rejects: 2 to 1224 letters, digits, or any of +=,.@:/-", name)
+		}
+		for _, alias := range details[name].Aliases {
+			if _, ok := accounts[alias]; ok && alias != name {
+				add(IssueError, "alias '%s' of account '%s' is also an account name", alias, name)
+			} else if owner, ok := aliasOwners[alias]; ok && owner != name {
+				add(IssueError, "alias '%s' is used by both '%s' and '%s'", alias, owner, name)
+			}
+			aliasOwners[alias] = name
+		}
+	}
+
+	friendlyNames := make([]string, 0, len(roles))
+	for friendly := range roles {
+		friendlyNames = append(friendlyNames, friendly)
+	}
+	sort.Strings(friendlyNames)
+	for _, friendly := range friendlyNames {
+		if roles[friendly] == friendly {
+			continue
+		}
+		for _, other := range friendlyNames {
+			if other != friendly && roles[other] == friendly {
+				add(IssueWarning, "role '%s' maps to '%s', shadowing the IAM role '%s' that role '%s' maps to; -r %s assumes '%s'", friendly, roles[friendly], friendly, other, friendly, roles[friendly])
+				break
+			}
+		}
+	}
+
+	groupNames := make([]string, 0, len(groups))
+	for name := range groups {
+		groupNames = append(groupNames, name)
+	}
+	sort.Strings(groupNames)
+	for _, name := range groupNames {
+		if len(groups[name]) == 0 {
+			add(IssueWarning, "group '%s' has no members", name)
+		}
+		for _, member := range groups[name] {
+			if _, ok := accounts[member]; !ok {
+				add(IssueError, "group '%s' references unknown account '%s'", name, member)
+			}
+		}
+	}
+	return issues
+}
+
+// reportLintIssues prints warnings to stderr and returns the errors as one error.
+func reportLintIssues(issues []ConfigIssue, filePath string) error {
+	var failures []string
+	for _, issue := range issues {
+		if issue.Level == IssueError {
+			failures = append(failures, issue.Message)
+			continue
+		}
+		fmt.Fprintf(os.Stderr, "Warning: SAWS config '%s': %s\n", filePath, issue.Message)
+	}
+	if len(failures) > 0 {
+		return fmt.Errorf("SAWS config validation failed in '%s': %s", filePath, strings.Join(failures, "; "))
+	}
+	return nil
+}
+
+// expandGroupSelector returns the members of the group named by an "@group"
+// selector entry, and false when pattern is not a group reference.
+func expandGroupSelector(groups map[string][]string, pattern string) ([]string, bool, error) {
+	if !strings.HasPrefix(pattern, GroupSelectorPrefix) {
+		return nil, false, nil
+	}
+	name := strings.TrimPrefix(pattern, GroupSelectorPrefix)
+	members, ok := groups[name]
+	if !ok {
+		return nil, true, fmt.Errorf("account group '%s' is not defined under groups: in the SAWS config", name)
+	}
+	return members, true, nil
+}
+
+// dedupeAccountsByID drops names whose account ID was already selected under
+// another name, so an account listed twice is never targeted twice.
+func dedupeAccountsByID(accounts map[string]string, names []string) []string {
+	seen := make(map[string]string)
+	unique := names[:0:0]
+	for _, name := range names {
+		id := accounts[name]
+		if first, ok := seen[id]; ok {
+			fmt.Fprintf(os.Stderr, "Warning: Skipping account '%s': it has the same ID (%s) as '%s'.\n", name, id, first)
+			continue
+		}
+		seen[id] = name
+		unique = append(unique, name)
+	}
+	return unique
+}
@@ -0,0 +1,327 @@
+package pkg
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/smithy-go"
+)
+
+const (
+	remoteConfigCacheDir = "saws-remote-config"
+	remoteConfigTimeout  = 15 * time.Second
+)
+
+// remoteConfigSignatureSuffix is appended to the URL of a remote config to get
+// its detached signature.
+const remoteConfigSignatureSuffix = ".sig"
+
+// RemoteConfigRef is the remote: block of a local config: the URL of a config
+// published by a platform team, and a pin that its content must match.
+//
+//	remote:
+//	  url: s3://platform-bucket/saws-config.yaml
+//	  public_key: "MCowBQYDK2VwAyEA..."   # or sha256: <hex digest of the file>
+type RemoteConfigRef struct {
+	URL string `yaml:"url"`
+	// SHA256 pins the exact content of the file, as a hex digest.
+	SHA256 string `yaml:"sha256"`
+	// PublicKey is a base64 ed25519 public key (raw 32 bytes); the file must
+	// come with a base64 ed25519 signature of its content at URL + ".sig".
+	PublicKey string `yaml:"public_key"`
+}
+
+// pinned reports whether the content of the remote config is checked.
+func (r RemoteConfigRef) pinned() bool {
+	return r.SHA256 != "" || r.PublicKey != ""
+}
+
+// verify checks data, and for PublicKey its signature sig, against the pin.
+func (r RemoteConfigRef) verify(data, sig []byte) error {
+	if r.SHA256 != "" {
+		sum := sha256.Sum256(data)
+		if !strings.EqualFold(hex.EncodeToString(sum[:]), strings.TrimSpace(r.SHA256)) {
+			return fmt.Errorf("remote config %s does not match the pinned sha256 %s", r.URL, r.SHA256)
+		}
+	}
+	if r.PublicKey != "" {
+		key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(r.PublicKey))
+		if err != nil || len(key) != ed25519.PublicKeySize {
+			return fmt.Errorf("remote.public_key is not a base64 ed25519 public key")
+		}
+		signature, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(sig)))
+		if err != nil || !ed25519.Verify(ed25519.PublicKey(key), data, signature) {
+			return fmt.Errorf("the signature %s%s does not verify remote config %s with the pinned public key", r.URL, remoteConfigSignatureSuffix, r.URL)
+		}
+	}
+	return nil
+}
+
+// remoteOnlyDefaults are the defaults: keys a remote config may not set: they
+// run commands, write files, or redirect credentials on every machine that
+// loads it, so only a local config may.
+func remoteOnlyDefaults(d DefaultsConfig) []string {
+	var keys []string
+	if d.Shell != "" {
+		keys = append(keys, "defaults.shell")
+	}
+	if d.ReauthCommand != "" {
+		keys = append(keys, "defaults.reauth_command")
+	}
+	if d.AuditLog != "" {
+		keys = append(keys, "defaults.audit_log")
+	}
+	if d.STSEndpoint != "" && d.STSEndpoint != STSEndpointRegional {
+		keys = append(keys, "defaults.sts_endpoint")
+	}
+	return keys
+}
+
+// isRemoteConfigCopy reports whether path is the local copy of a remote config.
+func isRemoteConfigCopy(path string) bool {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return false
+	}
+	return filepath.Dir(path) == filepath.Join(homeDir, AWSConfigDir, remoteConfigCacheDir)
+}
+
+// followRemoteConfig returns the path and content of the config to load for
+// the config at filePath with content data: the remote config its remote:
+// block points at, fetched and checked against the pin, or the file itself.
+// A config with remote: holds nothing else.
+func followRemoteConfig(filePath string, data []byte) (string, []byte, error) {
+	var stub AppConfig
+	if err := decodeConfigFile(filePath, data, &stub); err != nil || stub.Remote.URL == "" {
+		return filePath, data, nil
+	}
+	ref := stub.Remote
+	stub.Remote = RemoteConfigRef{}
+	switch {
+	case isRemoteConfigCopy(filePath):
+		return "", nil, fmt.Errorf("remote config '%s' cannot use remote: itself", filePath)
+	case !reflect.ValueOf(stub).IsZero():
+		return "", nil, fmt.Errorf("'%s' has a remote: block and other settings; a config pointing at a remote one holds only remote:", filePath)
+	case !IsRemoteConfig(ref.URL):
+		return "", nil, fmt.Errorf("remote.url '%s' in '%s' is not an s3:// or https:// URL", ref.URL, filePath)
+	}
+	remotePath, err := FetchRemoteConfig(ref)
+	if err != nil {
+		return "", nil, err
+	}
+	remoteData, err := os.ReadFile(remotePath)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to read the copy of remote config %s: %w", ref.URL, err)
+	}
+	LogVerbosef("Using remote SAWS config %s from '%s'.", ref.URL, filePath)
+	return remotePath, remoteData, nil
+}
+
+// errNotModified reports that the cached copy of a remote config is current.
+var errNotModified = errors.New("remote config not modified")
+
+// IsRemoteConfig reports whether path is an s3:// or https:// config location.
+func IsRemoteConfig(path string) bool {
+	return strings.HasPrefix(path, "s3://") || strings.HasPrefix(path, "https://")
+}
+
+// remoteConfigCachePaths returns where the copy of a remote config and its
+// ETag are cached. The copy keeps the URL's extension so JSON stays JSON.
+func remoteConfigCachePaths(configURL string) (string, string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", "", err
+	}
+	sum := sha256.Sum256([]byte(configURL))
+	ext := path.Ext(strings.SplitN(configURL, "?", 2)[0])
+	if !isJSONConfig(ext) {
+		ext = ".yaml"
+	}
+	base := filepath.Join(homeDir, AWSConfigDir, remoteConfigCacheDir, hex.EncodeToString(sum[:8]))
+	return base + ext, base + ".etag", nil
+}
+
+// fetchHTTPSConfig downloads configURL unless its ETag still matches etag.
+func fetchHTTPSConfig(ctx context.Context, configURL, etag string) ([]byte, string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, configURL, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusNotModified:
+		return nil, "", errNotModified
+	case http.StatusOK:
+		data, err := io.ReadAll(resp.Body)
+		return data, resp.Header.Get("ETag"), err
+	default:
+		return nil, "", fmt.Errorf("GET %s returned %s", configURL, resp.Status)
+	}
+}
+
+// fetchS3Config downloads s3://bucket/key with the default AWS credential chain
+// (AWS_PROFILE etc.), since the base profile is only known once the config is read.
+func fetchS3Config(ctx context.Context, configURL, etag string) ([]byte, string, error) {
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(configURL, "s3://"), "/")
+	if bucket == "" || key == "" {
+		return nil, "", fmt.Errorf("'%s' must look like s3://bucket/key", configURL)
+	}
+	// The profile's region picks the partition (GovCloud and China buckets
+	// are only reachable from their own); the bucket's region is looked up in it.
+	cfg, err := LoadAWSConfig(ctx)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to load AWS configuration to read %s: %w", configURL, err)
+	}
+	if cfg.Region == "" {
+		cfg.Region = FallbackRegion
+	}
+	location, err := s3.NewFromConfig(cfg).GetBucketLocation(ctx, &s3.GetBucketLocationInput{Bucket: aws.String(bucket)})
+	if err != nil {
+		return nil, "", fmt.Errorf("s3:GetBucketLocation failed for %s: %w", bucket, err)
+	}
+	switch region := string(location.LocationConstraint); region {
+	case "":
+		cfg.Region = "us-east-1"
+	case "EU":
+		cfg.Region = "eu-west-1"
+	default:
+		cfg.Region = region
+	}
+
+	input := &s3.GetObjectInput{Bucket: aws.String(bucket), Key: aws.String(key)}
+	if etag != "" {
+		input.IfNoneMatch = aws.String(etag)
+	}
+	out, err := s3.NewFromConfig(cfg).GetObject(ctx, input)
+	if err != nil {
+		var apiErr smithy.APIError
+		if errors.As(err, &apiErr) && apiErr.ErrorCode() == "NotModified" {
+			return nil, "", errNotModified
+		}
+		return nil, "", fmt.Errorf("s3:GetObject failed for %s: %w", configURL, err)
+	}
+	defer out.Body.Close()
+	data, err := io.ReadAll(out.Body)
+	return data, aws.ToString(out.ETag), err
+}
+
+// fetchRemote downloads an s3:// or https:// URL unless its ETag still matches etag.
+func fetchRemote(ctx context.Context, configURL, etag string) ([]byte, string, error) {
+	if strings.HasPrefix(configURL, "s3://") {
+		return fetchS3Config(ctx, configURL, etag)
+	}
+	return fetchHTTPSConfig(ctx, configURL, etag)
+}
+
+// FetchRemoteConfig makes a local copy of the config ref.URL points at and
+// returns its path. The copy is refreshed only when the ETag changed, and is
+// used as-is (with a warning) when the remote cannot be reached. With a pin,
+// the content, fresh or cached, must match it.
+func FetchRemoteConfig(ref RemoteConfigRef) (string, error) {
+	configURL := ref.URL
+	if !ref.pinned() {
+		LogVerbosef("Remote config %s is not pinned; set remote.sha256 or remote.public_key in a local config to verify it.", configURL)
+	}
+	cachePath, etagPath, err := remoteConfigCachePaths(configURL)
+	if err != nil {
+		return "", fmt.Errorf("could not determine remote config cache path: %w", err)
+	}
+	sigPath := strings.TrimSuffix(etagPath, ".etag") + remoteConfigSignatureSuffix
+	useCached := func() (string, error) {
+		if ref.pinned() {
+			data, err := os.ReadFile(cachePath)
+			if err != nil {
+				return "", err
+			}
+			sig, _ := os.ReadFile(sigPath)
+			if err := ref.verify(data, sig); err != nil {
+				return "", fmt.Errorf("cached copy: %w", err)
+			}
+		}
+		return cachePath, nil
+	}
+	// The copy and its ETag are updated together; a run that cannot get the
+	// lock uses the copy another run is refreshing, if it matches the pin.
+	unlock, err := LockState(cachePath)
+	if err != nil {
+		if _, errStat := os.Stat(cachePath); errStat == nil {
+			LogVerbosef("Warning: %v. Using cached copy of remote config %s.", err, configURL)
+			return useCached()
+		}
+		return "", err
+	}
+	defer unlock()
+	// Only a copy that matches the pin may be revalidated by its ETag.
+	etag := ""
+	if _, errStat := os.Stat(cachePath); errStat == nil {
+		if _, errPin := useCached(); errPin == nil {
+			if data, errRead := os.ReadFile(etagPath); errRead == nil {
+				etag = strings.TrimSpace(string(data))
+			}
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), remoteConfigTimeout)
+	defer cancel()
+	data, newETag, err := fetchRemote(ctx, configURL, etag)
+	switch {
+	case errors.Is(err, errNotModified):
+		LogVerbosef("Remote config %s unchanged (ETag %s); using cached copy %s.", configURL, etag, cachePath)
+		return useCached()
+	case err != nil:
+		if _, errStat := os.Stat(cachePath); errStat == nil {
+			fmt.Fprintf(os.Stderr, "Warning: could not refresh remote config %s: %v. Using cached copy.\n", configURL, err)
+			return useCached()
+		}
+		return "", fmt.Errorf("failed to fetch remote config %s: %w", configURL, err)
+	}
+	var sig []byte
+	if ref.PublicKey != "" {
+		if sig, _, err = fetchRemote(ctx, configURL+remoteConfigSignatureSuffix, ""); err != nil {
+			return "", fmt.Errorf("failed to fetch the signature of remote config %s: %w", configURL, err)
+		}
+	}
+	if err := ref.verify(data, sig); err != nil {
+		return "", err
+	}
+
+	if err := WriteFileAtomic(cachePath, data, 0o600); err != nil {
+		return "", fmt.Errorf("failed to cache remote config: %w", err)
+	}
+	if sig != nil {
+		if err := WriteFileAtomic(sigPath, sig, 0o600); err != nil {
+			return "", fmt.Errorf("failed to cache the signature of remote config: %w", err)
+		}
+	}
+	if newETag != "" {
+		if err := WriteFileAtomic(etagPath, []byte(newETag), 0o600); err != nil {
+			LogVerbosef("Warning: Could not write ETag for remote config: %v", err)
+		}
+	} else {
+		os.Remove(etagPath)
+	}
+	LogVerbosef("Fetched remote config %s (%d bytes) into %s.", configURL, len(data), cachePath)
+	return cachePath, nil
+}
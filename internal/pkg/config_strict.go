@@ -0,0 +1,173 @@
+package pkg
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// unknownConfigKey is a mapping key that does not exist in the config schema.
+type unknownConfigKey struct {
+	Line       int
+	Column     int
+	Path       string
+	Key        string
+	Suggestion string
+}
+
+func (k unknownConfigKey) String() string {
+	msg := fmt.Sprintf("line %d, column %d: unknown key '%s'", k.Line, k.Column, k.Key)
+	if k.Path != "" {
+		msg += fmt.Sprintf(" under '%s'", k.Path)
+	}
+	if k.Suggestion != "" {
+		msg += fmt.Sprintf(" (did you mean '%s'?)", k.Suggestion)
+	}
+	return msg
+}
+
+// yamlFields maps the yaml keys of struct type t to their field types.
+func yamlFields(t reflect.Type) map[string]reflect.Type {
+	fields := make(map[string]reflect.Type)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(field.Name)
+		}
+		fields[name] = field.Type
+	}
+	return fields
+}
+
+// findUnknownKeys walks node alongside type t and reports keys t does not declare.
+func findUnknownKeys(node *yaml.Node, t reflect.Type, path string) []unknownConfigKey {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if node.Kind == yaml.DocumentNode || node.Kind == yaml.AliasNode {
+		if node.Kind == yaml.AliasNode {
+			node = node.Alias
+		} else if len(node.Content) > 0 {
+			node = node.Content[0]
+		}
+		if node == nil {
+			return nil
+		}
+		return findUnknownKeys(node, t, path)
+	}
+
+	var found []unknownConfigKey
+	switch {
+	case t.Kind() == reflect.Struct && node.Kind == yaml.MappingNode:
+		fields := yamlFields(t)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			keyNode, valueNode := node.Content[i], node.Content[i+1]
+			fieldType, ok := fields[keyNode.Value]
+			if !ok {
+				known := make([]string, 0, len(fields))
+				for name := range fields {
+					known = append(known, name)
+				}
+				found = append(found, unknownConfigKey{
+					Line: keyNode.Line, Column: keyNode.Column, Path: path,
+					Key: keyNode.Value, Suggestion: closestKey(keyNode.Value, known),
+				})
+				continue
+			}
+			found = append(found, findUnknownKeys(valueNode, fieldType, joinKeyPath(path, keyNode.Value))...)
+		}
+	case t.Kind() == reflect.Map && node.Kind == yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			found = append(found, findUnknownKeys(node.Content[i+1], t.Elem(), joinKeyPath(path, node.Content[i].Value))...)
+		}
+	case t.Kind() == reflect.Slice && node.Kind == yaml.SequenceNode:
+		for i, item := range node.Content {
+			found = append(found, findUnknownKeys(item, t.Elem(), fmt.Sprintf("%s[%d]", path, i))...)
+		}
+	}
+	return found
+}
+
+// unknownKeysError reports every unknown key of a config file at once.
+type unknownKeysError []unknownConfigKey
+
+func (e unknownKeysError) Error() string {
+	messages := make([]string, len(e))
+	for i, k := range e {
+		messages[i] = k.String()
+	}
+	return strings.Join(messages, "; ")
+}
+
+func joinKeyPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// closestKey returns the candidate nearest to key by edit distance, or "" when
+// nothing is close enough to be a plausible typo.
+func closestKey(key string, candidates []string) string {
+	sort.Strings(candidates)
+	best, bestDistance := "", len(key)/2+2
+	for _, candidate := range candidates {
+		if d := editDistance(strings.ToLower(key), candidate); d < bestDistance {
+			best, bestDistance = candidate, d
+		}
+	}
+	return best
+}
+
+// editDistance is the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
+
+// decodeConfigStrict decodes data into cfg after ${VAR} expansion, rejecting
+// unknown keys. Errors carry the line and column of the offending key.
+func decodeConfigStrict(data []byte, cfg *AppConfig) error {
+	root, err := parseConfigNode(data)
+	if err != nil || root == nil {
+		return err
+	}
+	details, unknown, err := splitAccountDetails(root)
+	if err != nil {
+		return err
+	}
+	unknown = append(unknown, findUnknownKeys(root, reflect.TypeOf(*cfg), "")...)
+	if len(unknown) > 0 {
+		return unknownKeysError(unknown)
+	}
+	if err := root.Decode(cfg); err != nil {
+		return err
+	}
+	applyAccountDetails(cfg, details)
+	return nil
+}
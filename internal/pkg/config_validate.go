@@ -0,0 +1,203 @@
+package pkg
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"time"
+)
+
+const (
+	IssueError   = "FAIL"
+	IssueWarning = "WARN"
+)
+
+var accountIDPattern = regexp.MustCompile(`^\d{12}$`)
+
+// ConfigIssue is one finding of ValidateConfig.
+type ConfigIssue struct {
+	Level   string
+	Message string
+}
+
+// validateAccountSet checks one accounts/roles/groups/regions/tunnels set, from the
+// top level or from a context, whose accounts default to partition; scope
+// prefixes messages, e.g. "context 'a': ".
+func validateAccountSet(scope, partition string, accounts map[string]string, details map[string]AccountDetail, roles map[string]string, groups map[string][]string, regions []string, tunnels map[string]TunnelConfig) []ConfigIssue {
+	var issues []ConfigIssue
+	add := func(level, format string, args ...any) {
+		issues = append(issues, ConfigIssue{Level: level, Message: scope + fmt.Sprintf(format, args...)})
+	}
+
+	names := make([]string, 0, len(accounts))
+	for name := range accounts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if id := accounts[name]; !accountIDPattern.MatchString(id) {
+			add(IssueError, "account '%s' has ID '%s', which is not 12 digits (quote IDs with leading zeros)", name, id)
+		}
+	}
+	issues = append(issues, lintAccountSet(scope, accounts, details, roles, groups)...)
+
+	issues = append(issues, lintRegions(scope, regions, tunnels)...)
+	issues = append(issues, lintPartitions(scope, partition, accounts, details, regions)...)
+
+	for friendly, actual := range roles {
+		if actual == "" || actual == SSORolePrefix {
+			add(IssueError, "role '%s' maps to an empty IAM role name", friendly)
+		}
+	}
+
+	for name, tunnel := range tunnels {
+		if tunnel.Account == "" || tunnel.Bastion == "" || tunnel.RemoteHost == "" || tunnel.RemotePort == 0 {
+			add(IssueError, "tunnel '%s' must set account, bastion, remote_host, and remote_port", name)
+		}
+		if tunnel.Account != "" {
+			if _, ok := accounts[tunnel.Account]; !ok {
+				add(IssueError, "tunnel '%s' references unknown account '%s'", name, tunnel.Account)
+			}
+		}
+		if tunnel.RemotePort < 0 || tunnel.RemotePort > 65535 || tunnel.LocalPort < 0 || tunnel.LocalPort > 65535 {
+			add(IssueError, "tunnel '%s' has a port outside 1-65535", name)
+		}
+	}
+	return issues
+}
+
+// ValidateConfig performs the offline checks of `saws validate-config` on the
+// file at filePath: strict schema decoding (unknown keys), account ID format,
+// region names, duplicate IDs, tunnels, includes, and context references.
+// It returns the parsed config when the file could be decoded at all.
+func ValidateConfig(filePath string) (*AppConfig, []ConfigIssue) {
+	var issues []ConfigIssue
+	add := func(level, format string, args ...any) {
+		issues = append(issues, ConfigIssue{Level: level, Message: fmt.Sprintf(format, args...)})
+	}
+
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		add(IssueError, "cannot read config: %v", err)
+		return nil, issues
+	}
+	if filePath, data, err = followRemoteConfig(filePath, data); err != nil {
+		add(IssueError, "remote: %v", err)
+		return nil, issues
+	}
+	var cfg AppConfig
+	if errStrict := decodeConfigFile(filePath, data, &cfg); errStrict != nil {
+		var unknown unknownKeysError
+		if errors.As(errStrict, &unknown) {
+			for _, k := range unknown {
+				add(IssueError, "schema: %s", k)
+			}
+		} else {
+			add(IssueError, "schema: %v", errStrict)
+		}
+		cfg = AppConfig{}
+		if errLoose := unmarshalConfig(data, &cfg); errLoose != nil {
+			return nil, issues
+		}
+	}
+
+	switch cfg.AccountsSource {
+	case "", AccountsSourceStatic:
+		if len(cfg.Accounts) == 0 && len(cfg.Contexts) == 0 && len(cfg.Include) == 0 {
+			add(IssueError, "'accounts' is empty and no include:, contexts:, or accounts_source: organizations provides accounts")
+		}
+	case AccountsSourceOrganizations:
+		if cfg.Organizations.CacheTTL != "" {
+			if _, err := time.ParseDuration(cfg.Organizations.CacheTTL); err != nil {
+				add(IssueError, "organizations.cache_ttl '%s' is not a duration such as 1h or 15m", cfg.Organizations.CacheTTL)
+			}
+		}
+		if cfg.Organizations.ManagementAccountID != "" && !accountIDPattern.MatchString(cfg.Organizations.ManagementAccountID) {
+			add(IssueError, "organizations.management_account_id '%s' is not 12 digits", cfg.Organizations.ManagementAccountID)
+		}
+	default:
+		add(IssueError, "accounts_source '%s' is not '%s' or '%s'", cfg.AccountsSource, AccountsSourceStatic, AccountsSourceOrganizations)
+	}
+
+	if len(cfg.Include) > 0 {
+		if _, err := resolveIncludePaths(filePath, cfg.Include); err != nil {
+			add(IssueError, "include: %v", err)
+		} else {
+			merged := cfg
+			merged.Accounts = make(map[string]string, len(cfg.Accounts))
+			for k, v := range cfg.Accounts {
+				merged.Accounts[k] = v
+			}
+			merged.Roles = make(map[string]string, len(cfg.Roles))
+			for k, v := range cfg.Roles {
+				merged.Roles[k] = v
+			}
+			if err := mergeIncludes(&merged, filePath); err != nil {
+				add(IssueError, "include: %v", err)
+			} else {
+				cfg = merged
+			}
+		}
+	}
+
+	issues = append(issues, validateDefaults(cfg.Defaults)...)
+	if isRemoteConfigCopy(filePath) {
+		for _, key := range remoteOnlyDefaults(cfg.Defaults) {
+			add(IssueError, "%s may only be set in a local config, not in a remote one", key)
+		}
+	}
+	issues = append(issues, validateAccountSet("", cfg.Partition, cfg.Accounts, cfg.AccountDetails, cfg.Roles, cfg.Groups, cfg.CommonRegions, cfg.Tunnels)...)
+	issues = append(issues, lintRolesByAccount("", cfg.RolesByAccount, cfg.Accounts, cfg.Roles)...)
+	for name, cluster := range cfg.EKSClusters {
+		if cluster.Account == "" || cluster.Role == "" || cluster.Region == "" {
+			add(IssueError, "eks_clusters entry '%s' must set account, role, and region", name)
+		}
+		if _, ok := cfg.Accounts[cluster.Account]; cluster.Account != "" && !ok {
+			add(IssueError, "eks_clusters entry '%s' references unknown account '%s'", name, cluster.Account)
+		}
+		if cluster.Region != "" {
+			if err := ValidateRegions("eks_clusters", cluster.Region); err != nil {
+				add(IssueError, "eks_clusters entry '%s': %v", name, err)
+			}
+		}
+	}
+	for pattern, repo := range cfg.CodeCommitRepos {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			add(IssueError, "codecommit_repos pattern '%s' is invalid: %v", pattern, err)
+		}
+		if _, ok := cfg.Accounts[repo.Account]; !ok {
+			add(IssueError, "codecommit_repos entry '%s' references unknown account '%s'", pattern, repo.Account)
+		}
+	}
+	if len(cfg.Roles) == 0 {
+		add(IssueWarning, "'roles' is empty; roles must then be given with -r or %s", envRoleVar)
+	}
+
+	contextNames := make([]string, 0, len(cfg.Contexts))
+	for name := range cfg.Contexts {
+		contextNames = append(contextNames, name)
+	}
+	sort.Strings(contextNames)
+	for _, name := range contextNames {
+		c := cfg.Contexts[name]
+		scope := fmt.Sprintf("context '%s': ", name)
+		if len(c.Accounts) == 0 {
+			add(IssueError, "%s'accounts' is empty", scope)
+		}
+		partition := c.Partition
+		if partition == "" {
+			partition = cfg.Partition
+		}
+		issues = append(issues, validateAccountSet(scope, partition, c.Accounts, c.AccountDetails, c.Roles, c.Groups, c.CommonRegions, c.Tunnels)...)
+		issues = append(issues, lintRolesByAccount(scope, c.RolesByAccount, c.Accounts, c.Roles)...)
+	}
+	if cfg.DefaultContext != "" {
+		if _, ok := cfg.Contexts[cfg.DefaultContext]; !ok {
+			add(IssueError, "default_context '%s' is not defined under contexts:", cfg.DefaultContext)
+		}
+	}
+	return &cfg, issues
+}
@@ -0,0 +1,170 @@
+package pkg
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	ststypes "github.com/aws/aws-sdk-go-v2/service/sts/types"
+	"github.com/aws/smithy-go"
+	"github.com/aws/smithy-go/middleware"
+)
+
+const (
+	credentialCacheDirName = "saws-credential-cache"
+	// CredentialCacheMinLifetime is the least lifetime cached credentials must
+	// have left to be reused, as with the AWS CLI's cache.
+	CredentialCacheMinLifetime = 15 * time.Minute
+)
+
+// CredentialCache is defaults.credential_cache: whether assumed-role
+// credentials are kept in ~/.aws/saws-credential-cache/ and reused by later
+// runs until they are about to expire. -no-cache turns it off for a run.
+var CredentialCache = true
+
+// cachedCredentialFiles maps the access key IDs of credentials read from or
+// written to the cache to their file, for EvictCachedCredentials.
+var cachedCredentialFiles sync.Map
+
+// cachedCredentials is one file of the credential cache.
+type cachedCredentials struct {
+	RoleARN         string    `json:"role_arn"`
+	AccessKeyID     string    `json:"access_key_id"`
+	SecretAccessKey string    `json:"secret_access_key"`
+	SessionToken    string    `json:"session_token"`
+	Expiration      time.Time `json:"expiration"`
+}
+
+// credentialCachePath returns the cache file of role assumptions of roleArn
+// with the base credentials of baseCfg, the session name prefix, the session
+// duration, and externalID. The prefix is part of the key so credentials keep
+// the session name, which CloudTrail records, of the command that asked for
+// them. Base credentials that are not a shared-config profile, e.g. exported
+// keys, have no identity to key the cache with, so they are not cached.
+func credentialCachePath(baseCfg aws.Config, roleArn, sessionNamePrefix, externalID string) (string, bool) {
+	shared, ok := baseCfg.Credentials.(*baseCredentials)
+	if !ok || !CredentialCache {
+		return "", false
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", false
+	}
+	key := strings.Join([]string{shared.profile, roleArn, sessionNamePrefix, strconv.Itoa(int(SessionDurationSeconds)), externalID}, "|")
+	sum := sha1.Sum([]byte(key))
+	return filepath.Join(homeDir, AWSConfigDir, credentialCacheDirName, hex.EncodeToString(sum[:])+".json"), true
+}
+
+// readCachedCredentials returns the credentials cached at path when they
+// remain valid for at least minLifetime. Expired files are removed.
+func readCachedCredentials(path string, minLifetime time.Duration) (*ststypes.Credentials, bool) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+	var cached cachedCredentials
+	if err := json.Unmarshal(data, &cached); err != nil || cached.AccessKeyID == "" {
+		LogVerbosef("Warning: Ignoring unreadable credential cache '%s'.", path)
+		return nil, false
+	}
+	left := time.Until(cached.Expiration)
+	if left <= 0 {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			LogVerbosef("Warning: Could not remove expired credential cache '%s': %v", path, err)
+		}
+		return nil, false
+	}
+	if left < minLifetime {
+		LogVerbosef("Cached credentials of %s expire in %s; assuming the role again.", cached.RoleARN, left.Round(time.Second))
+		return nil, false
+	}
+	RegisterSecret(cached.AccessKeyID, cached.SecretAccessKey, cached.SessionToken)
+	cachedCredentialFiles.Store(cached.AccessKeyID, path)
+	LogVerbosef("Reusing cached credentials of %s, valid for %s.", cached.RoleARN, left.Round(time.Second))
+	return &ststypes.Credentials{
+		AccessKeyId:     aws.String(cached.AccessKeyID),
+		SecretAccessKey: aws.String(cached.SecretAccessKey),
+		SessionToken:    aws.String(cached.SessionToken),
+		Expiration:      aws.Time(cached.Expiration),
+	}, true
+}
+
+// writeCachedCredentials stores creds of roleArn at path; failures only cost
+// the reuse.
+func writeCachedCredentials(path, roleArn string, creds *ststypes.Credentials) {
+	if creds.Expiration == nil {
+		return
+	}
+	data, err := json.MarshalIndent(cachedCredentials{
+		RoleARN:         roleArn,
+		AccessKeyID:     aws.ToString(creds.AccessKeyId),
+		SecretAccessKey: aws.ToString(creds.SecretAccessKey),
+		SessionToken:    aws.ToString(creds.SessionToken),
+		Expiration:      *creds.Expiration,
+	}, "", "  ")
+	if err == nil {
+		err = WriteFileAtomic(path, data, 0o600)
+	}
+	if err != nil {
+		LogVerbosef("Warning: Could not cache the credentials of %s: %v", roleArn, err)
+		return
+	}
+	cachedCredentialFiles.Store(aws.ToString(creds.AccessKeyId), path)
+}
+
+// RejectsCredentials reports whether err means AWS refused the credentials of
+// a call because they expired or are unknown to the service. AccessDenied is
+// left out: it is mostly a permission the role lacks, which new credentials
+// would not change.
+func RejectsCredentials(err error) bool {
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.ErrorCode() {
+		case "ExpiredToken", "ExpiredTokenException", "InvalidClientTokenId", "UnrecognizedClientException":
+			return true
+		}
+	}
+	return false
+}
+
+// EvictCachedCredentials removes the cache file creds came from or went to,
+// so the next run assumes the role afresh instead of reusing credentials AWS
+// refused. Credentials that were not cached are left alone.
+func EvictCachedCredentials(creds *ststypes.Credentials) {
+	if creds == nil {
+		return
+	}
+	path, ok := cachedCredentialFiles.LoadAndDelete(aws.ToString(creds.AccessKeyId))
+	if !ok {
+		return
+	}
+	if err := os.Remove(path.(string)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		LogVerbosef("Warning: Could not remove credential cache '%s': %v", path, err)
+		return
+	}
+	LogVerbosef("Removed cached credentials '%s' after AWS refused them.", path)
+}
+
+// evictRejectedCredentials evicts creds from the cache when a call made with
+// them fails with an error RejectsCredentials matches.
+func evictRejectedCredentials(creds *ststypes.Credentials) func(*middleware.Stack) error {
+	return func(stack *middleware.Stack) error {
+		return stack.Initialize.Add(middleware.InitializeMiddlewareFunc("SawsEvictRejectedCredentials",
+			func(ctx context.Context, in middleware.InitializeInput, next middleware.InitializeHandler) (middleware.InitializeOutput, middleware.Metadata, error) {
+				out, metadata, err := next.HandleInitialize(ctx, in)
+				if err != nil && RejectsCredentials(err) {
+					EvictCachedCredentials(creds)
+				}
+				return out, metadata, err
+			}), middleware.After)
+	}
+}
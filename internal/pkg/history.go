@@ -0,0 +1,198 @@
+package pkg
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	historyFileName   = "saws-history.jsonl"
+	maxHistoryEntries = 500
+	// HistoryRedacted replaces argument values that must not be stored, such as
+	// the value of `-param put`; entries containing it cannot be replayed.
+	HistoryRedacted = "<redacted>"
+)
+
+// HistoryEnvVars are the environment variables that change what an invocation
+// does, so replay restores them.
+var HistoryEnvVars = []string{envConfigVar, envContextVar, envAccountVar, envRoleVar, envRegionVar}
+
+// HistoryEntry is one saws invocation in ~/.aws/saws-history.jsonl.
+type HistoryEntry struct {
+	ID        int               `json:"id"`
+	StartedAt time.Time         `json:"started_at"`
+	Duration  string            `json:"duration"`
+	Dir       string            `json:"dir"`
+	Args      []string          `json:"args"`
+	Env       map[string]string `json:"env,omitempty"`
+	Mode      string            `json:"mode,omitempty"`
+	Accounts  []string          `json:"accounts,omitempty"`
+	Regions   []string          `json:"regions,omitempty"`
+	Role      string            `json:"role,omitempty"`
+	ExitCode  int               `json:"exit_code"`
+}
+
+// currentInvocation is the entry being built for this run; nil when the run is
+// not recorded.
+var currentInvocation *HistoryEntry
+
+func historyPath() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(homeDir, AWSConfigDir, historyFileName), nil
+}
+
+// StartHistory begins recording this invocation with its arguments (after the
+// program name) and the SAWS_* environment.
+func StartHistory(args []string) {
+	dir, _ := os.Getwd()
+	entry := &HistoryEntry{StartedAt: time.Now(), Dir: dir}
+	for _, arg := range args {
+		entry.Args = append(entry.Args, RedactSecrets(arg))
+	}
+	for _, name := range HistoryEnvVars {
+		if value, ok := os.LookupEnv(name); ok {
+			if entry.Env == nil {
+				entry.Env = make(map[string]string)
+			}
+			entry.Env[name] = RedactSecrets(value)
+		}
+	}
+	currentInvocation = entry
+}
+
+// RedactHistoryArg replaces every argument equal to value in the recorded invocation.
+func RedactHistoryArg(value string) {
+	if currentInvocation == nil || value == "" {
+		return
+	}
+	for i, arg := range currentInvocation.Args {
+		if arg == value {
+			currentInvocation.Args[i] = HistoryRedacted
+		} else if strings.HasSuffix(arg, "="+value) {
+			currentInvocation.Args[i] = strings.TrimSuffix(arg, value) + HistoryRedacted
+		}
+	}
+}
+
+// NoteHistoryMode records the mode of this invocation, e.g. "-c" or "find".
+func NoteHistoryMode(mode string) {
+	if currentInvocation != nil {
+		currentInvocation.Mode = mode
+	}
+}
+
+// NoteHistoryTargets records the accounts and regions this invocation resolved.
+func NoteHistoryTargets(accountNames, regions []string) {
+	if currentInvocation != nil {
+		currentInvocation.Accounts = append(currentInvocation.Accounts, accountNames...)
+		currentInvocation.Regions = append(currentInvocation.Regions, regions...)
+	}
+}
+
+// NoteHistoryRole records the role this invocation assumed.
+func NoteHistoryRole(role string) {
+	if currentInvocation != nil {
+		currentInvocation.Role = role
+	}
+}
+
+// LoadHistory returns the recorded invocations, oldest first.
+func LoadHistory() ([]HistoryEntry, error) {
+	path, err := historyPath()
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var entries []HistoryEntry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var entry HistoryEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			LogVerbosef("Warning: Skipping unreadable history line in '%s': %v", path, err)
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	return entries, scanner.Err()
+}
+
+// FinishHistory appends this invocation with its exit code to the history,
+// keeping the newest maxHistoryEntries. Failures are only logged.
+func FinishHistory(exitCode int) {
+	entry := currentInvocation
+	if entry == nil {
+		return
+	}
+	currentInvocation = nil
+	entry.ExitCode = exitCode
+	entry.Duration = time.Since(entry.StartedAt).Round(time.Millisecond).String()
+
+	path, err := historyPath()
+	if err != nil {
+		LogVerbosef("Warning: Could not determine history path: %v", err)
+		return
+	}
+	// Concurrent runs (a cron fan-out next to an interactive session) would
+	// otherwise each rewrite the file without the other's entry.
+	unlock, err := LockState(path)
+	if err != nil {
+		LogVerbosef("Warning: Not recording this run in the history: %v", err)
+		return
+	}
+	defer unlock()
+	entries, err := LoadHistory()
+	if err != nil {
+		LogVerbosef("Warning: Could not read history '%s': %v", path, err)
+	}
+	entry.ID = 1
+	if len(entries) > 0 {
+		entry.ID = entries[len(entries)-1].ID + 1
+	}
+	entries = append(entries, *entry)
+	if len(entries) > maxHistoryEntries {
+		entries = entries[len(entries)-maxHistoryEntries:]
+	}
+
+	var buf strings.Builder
+	for _, e := range entries {
+		line, err := json.Marshal(e)
+		if err != nil {
+			continue
+		}
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+	if err := WriteFileAtomic(path, []byte(buf.String()), 0o600); err != nil {
+		LogVerbosef("Warning: Could not write history '%s': %v", path, err)
+	}
+}
+
+// FindHistoryEntry returns the entry with the given ID.
+func FindHistoryEntry(id int) (HistoryEntry, error) {
+	entries, err := LoadHistory()
+	if err != nil {
+		return HistoryEntry{}, fmt.Errorf("could not read history: %w", err)
+	}
+	for _, entry := range entries {
+		if entry.ID == id {
+			return entry, nil
+		}
+	}
+	return HistoryEntry{}, fmt.Errorf("no history entry %d (see 'saws history')", id)
+}
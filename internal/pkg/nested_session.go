@@ -0,0 +1,65 @@
+package pkg
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/AlecAivazis/survey/v2"
+)
+
+// Force (-force) skips the confirmation of a nested session.
+var Force bool
+
+// SkipNestedSessionCheck is set for the helper modes docker and kubectl call,
+// which run inside saws sub-shells by design.
+var SkipNestedSessionCheck bool
+
+// nestedSessionChecked makes the check run once per invocation.
+var nestedSessionChecked bool
+
+// CurrentSessionContext describes the AWS context this process already runs
+// in: a saws sub-shell (SAWS_INFO_*), or AWS credentials exported by something
+// else. It returns "" when there is none.
+func CurrentSessionContext() string {
+	if name := os.Getenv("SAWS_INFO_ACCOUNT_NAME"); name != "" {
+		return fmt.Sprintf("saws session Account=%s(%s), Role=%s, Region=%s",
+			name, os.Getenv("SAWS_INFO_ACCOUNT_ID"), os.Getenv("SAWS_INFO_ROLE_NAME"), os.Getenv("SAWS_INFO_REGION"))
+	}
+	if keyID := os.Getenv("AWS_ACCESS_KEY_ID"); keyID != "" || os.Getenv("AWS_SESSION_TOKEN") != "" {
+		current := "exported AWS credentials AWS_ACCESS_KEY_ID=" + RedactSecrets(keyID)
+		if region := os.Getenv("AWS_REGION"); region != "" {
+			current += ", Region=" + region
+		}
+		return current
+	}
+	return ""
+}
+
+// ConfirmNestedSession warns when a new context (newContext describes it) is
+// about to be set up inside an existing one, showing both, and asks to go on
+// unless Force is set. Declining, or --no-input without -force, is an error.
+func ConfirmNestedSession(newContext string) error {
+	if nestedSessionChecked || SkipNestedSessionCheck {
+		return nil
+	}
+	nestedSessionChecked = true
+	current := CurrentSessionContext()
+	if current == "" {
+		return nil
+	}
+	fmt.Fprintln(os.Stderr, Colorize(os.Stderr, ColorYellow, "Warning: saws is running inside another AWS context."))
+	fmt.Fprintf(os.Stderr, "  Current: %s\n", current)
+	fmt.Fprintf(os.Stderr, "  New:     %s\n", newContext)
+	if Force {
+		return nil
+	}
+	proceed := false
+	if err := AskOne(&survey.Confirm{Message: "Continue with the new context? (pass -force to skip this question)", Default: false}, &proceed); err != nil {
+		return fmt.Errorf("nested session not confirmed: %w", err)
+	}
+	if !proceed {
+		return errors.New("nested session declined; exit the current session first or pass -force")
+	}
+	return nil
+}
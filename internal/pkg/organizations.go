@@ -0,0 +1,153 @@
+package pkg
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/organizations"
+	orgtypes "github.com/aws/aws-sdk-go-v2/service/organizations/types"
+)
+
+const (
+	AccountsSourceStatic        = "static"
+	AccountsSourceOrganizations = "organizations"
+
+	accountsCacheFilePrefix = "saws-accounts-cache-"
+	defaultAccountsCacheTTL = time.Hour
+)
+
+// OrganizationsConfig controls live account discovery for `accounts_source: organizations`.
+type OrganizationsConfig struct {
+	// ManagementAccountID and Role name the account/role to call
+	// organizations:ListAccounts from; when empty the base profile is used directly.
+	ManagementAccountID string `yaml:"management_account_id"`
+	Role                string `yaml:"role"`
+	// CacheTTL is a Go duration such as "1h" or "15m"; "0" disables caching.
+	CacheTTL string `yaml:"cache_ttl"`
+}
+
+// accountsCache is the on-disk cache of the last Organizations listing.
+type accountsCache struct {
+	FetchedAt time.Time         `json:"fetched_at"`
+	Accounts  map[string]string `json:"accounts"`
+}
+
+// accountsCachePath returns the cache file of the listing made as orgCfg's
+// management account role, or the base profile, so contexts of different
+// organizations never share one.
+func accountsCachePath(orgCfg OrganizationsConfig) (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	key := strings.Join([]string{BaseProfileForAssume, orgCfg.ManagementAccountID, orgCfg.Role}, "|")
+	sum := sha1.Sum([]byte(key))
+	return filepath.Join(homeDir, AWSConfigDir, accountsCacheFilePrefix+hex.EncodeToString(sum[:8])+".json"), nil
+}
+
+func readAccountsCache(orgCfg OrganizationsConfig) (*accountsCache, error) {
+	cachePath, err := accountsCachePath(orgCfg)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(cachePath)
+	if err != nil {
+		return nil, err
+	}
+	var cache accountsCache
+	if err := json.Unmarshal(data, &cache); err != nil {
+		return nil, fmt.Errorf("failed to parse accounts cache '%s': %w", cachePath, err)
+	}
+	return &cache, nil
+}
+
+func writeAccountsCache(orgCfg OrganizationsConfig, cache *accountsCache) {
+	cachePath, err := accountsCachePath(orgCfg)
+	if err != nil {
+		LogVerbosef("Warning: Could not determine accounts cache path: %v", err)
+		return
+	}
+	data, err := json.MarshalIndent(cache, "", "  ")
+	if err != nil {
+		return
+	}
+	if err := WriteFileAtomic(cachePath, data, 0o600); err != nil {
+		LogVerbosef("Warning: Could not write accounts cache '%s': %v", cachePath, err)
+	}
+}
+
+// listOrganizationAccounts returns the ACTIVE accounts of the organization keyed by account name.
+func listOrganizationAccounts(ctx context.Context, orgCfg OrganizationsConfig) (map[string]string, error) {
+	cfg, err := LoadAWSConfig(ctx, awsconfig.WithSharedConfigProfile(BaseProfileForAssume), awsconfig.WithRegion(FallbackRegion))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load base AWS configuration for Organizations: %w", err)
+	}
+	if orgCfg.ManagementAccountID != "" && orgCfg.Role != "" {
+		creds, err := AssumeRole(ctx, cfg, orgCfg.ManagementAccountID, orgCfg.Role, "OrgDiscovery")
+		if err != nil {
+			return nil, err
+		}
+		if cfg, err = LoadAssumedRoleConfig(ctx, creds, FallbackRegion); err != nil {
+			return nil, err
+		}
+	}
+
+	discovered := make(map[string]string)
+	paginator := organizations.NewListAccountsPaginator(organizations.NewFromConfig(cfg), &organizations.ListAccountsInput{})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("organizations:ListAccounts failed: %w", err)
+		}
+		for _, acct := range page.Accounts {
+			if acct.Status != orgtypes.AccountStatusActive {
+				continue
+			}
+			discovered[aws.ToString(acct.Name)] = aws.ToString(acct.Id)
+		}
+	}
+	return discovered, nil
+}
+
+// discoverOrganizationAccounts returns the organization's accounts, served from
+// the cache while it is fresh. A stale cache is used when the live call fails.
+func discoverOrganizationAccounts(orgCfg OrganizationsConfig) (map[string]string, error) {
+	ttl := defaultAccountsCacheTTL
+	if orgCfg.CacheTTL != "" {
+		parsed, err := time.ParseDuration(orgCfg.CacheTTL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid organizations.cache_ttl '%s': %w", orgCfg.CacheTTL, err)
+		}
+		ttl = parsed
+	}
+
+	cache, errCache := readAccountsCache(orgCfg)
+	if errCache == nil && ttl > 0 && time.Since(cache.FetchedAt) < ttl {
+		LogVerbosef("Using %d cached Organizations accounts (fetched %s ago).", len(cache.Accounts), time.Since(cache.FetchedAt).Round(time.Second))
+		return cache.Accounts, nil
+	}
+
+	LogVerbosef("Fetching active accounts from AWS Organizations...")
+	discovered, err := listOrganizationAccounts(context.Background(), orgCfg)
+	if err != nil {
+		if errCache == nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v. Using cached account list from %s.\n", err, cache.FetchedAt.Local().Format(time.RFC822))
+			return cache.Accounts, nil
+		}
+		return nil, err
+	}
+	if ttl > 0 {
+		writeAccountsCache(orgCfg, &accountsCache{FetchedAt: time.Now(), Accounts: discovered})
+	}
+	LogVerbosef("Discovered %d active accounts from AWS Organizations.", len(discovered))
+	return discovered, nil
+}
@@ -0,0 +1,207 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+)
+
+const (
+	PartitionAWS      = "aws"
+	PartitionGovCloud = "aws-us-gov"
+	PartitionChina    = "aws-cn"
+)
+
+// partitionDefaultRegions are used for STS calls when a base profile's own
+// region is not in the account's partition.
+var partitionDefaultRegions = map[string]string{
+	PartitionAWS:      FallbackRegion,
+	PartitionGovCloud: "us-gov-west-1",
+	PartitionChina:    "cn-north-1",
+}
+
+// PartitionForRegion returns the partition a region belongs to.
+func PartitionForRegion(region string) string {
+	switch {
+	case strings.HasPrefix(region, "us-gov-"):
+		return PartitionGovCloud
+	case strings.HasPrefix(region, "cn-"):
+		return PartitionChina
+	default:
+		return PartitionAWS
+	}
+}
+
+// DefaultPartition is the top-level (or active context's) partition setting:
+// the partition of accounts that do not declare their own.
+var DefaultPartition = PartitionAWS
+
+// PartitionDefaultRegion returns the region saws calls partition-wide services
+// such as STS and S3 bucket lookups in, for partition.
+func PartitionDefaultRegion(partition string) string {
+	if region, ok := partitionDefaultRegions[partition]; ok {
+		return region
+	}
+	return FallbackRegion
+}
+
+// PartitionDNSSuffix returns the domain of the service endpoints of partition.
+func PartitionDNSSuffix(partition string) string {
+	if partition == PartitionChina {
+		return "amazonaws.com.cn"
+	}
+	return "amazonaws.com"
+}
+
+// knownPartition reports whether saws knows the partition's regions and endpoints.
+func knownPartition(partition string) bool {
+	_, ok := partitionDefaultRegions[partition]
+	return ok
+}
+
+// PartitionRegions returns the regions of regions that are in partition.
+func PartitionRegions(partition string, regions []string) []string {
+	var inPartition []string
+	for _, region := range regions {
+		if PartitionForRegion(region) == partition {
+			inPartition = append(inPartition, region)
+		}
+	}
+	return inPartition
+}
+
+// lintPartitions checks the partition setting of an accounts set and those of
+// its accounts, and warns about common_regions in a partition none of the
+// accounts is in, which fan-outs would always skip. scope prefixes messages.
+func lintPartitions(scope, partition string, accounts map[string]string, details map[string]AccountDetail, regions []string) []ConfigIssue {
+	var issues []ConfigIssue
+	add := func(level, format string, args ...any) {
+		issues = append(issues, ConfigIssue{Level: level, Message: scope + fmt.Sprintf(format, args...)})
+	}
+	validPartitions := true
+	if partition != "" && !knownPartition(partition) {
+		add(IssueError, "partition '%s' is not '%s', '%s', or '%s'", partition, PartitionAWS, PartitionGovCloud, PartitionChina)
+		validPartitions = false
+	}
+	if partition == "" {
+		partition = PartitionAWS
+	}
+
+	names := make([]string, 0, len(accounts))
+	for name := range accounts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	used := make(map[string]bool)
+	for _, name := range names {
+		switch accountPartition := details[name].Partition; {
+		case accountPartition == "":
+			used[partition] = true
+		case knownPartition(accountPartition):
+			used[accountPartition] = true
+		default:
+			add(IssueError, "account '%s' has partition '%s', which is not '%s', '%s', or '%s'", name, accountPartition, PartitionAWS, PartitionGovCloud, PartitionChina)
+			validPartitions = false
+		}
+	}
+	if !validPartitions || len(used) == 0 {
+		return issues
+	}
+	for _, region := range regions {
+		if regionPartition := PartitionForRegion(region); !used[regionPartition] {
+			add(IssueWarning, "common_regions: region '%s' is in partition %s, which no account is in; it is always skipped", region, regionPartition)
+		}
+	}
+	return issues
+}
+
+// SetAccountDetails replaces the account details used by AccountPartition,
+// AccountBaseProfile, and AccountExternalID, for callers that do not go
+// through LoadConfig.
+func SetAccountDetails(details map[string]AccountDetail) {
+	accountDetails = details
+}
+
+// AccountPartition returns the partition declared for the account, else DefaultPartition.
+func AccountPartition(accountName string) string {
+	if partition := accountDetails[accountName].Partition; partition != "" {
+		return partition
+	}
+	return DefaultPartition
+}
+
+// accountIDPartition returns the partition of the configured account with
+// accountID, or fallback for accounts not in the config.
+func accountIDPartition(accountID, fallback string) string {
+	for name, id := range accounts {
+		if id == accountID {
+			return AccountPartition(name)
+		}
+	}
+	return fallback
+}
+
+// AccountBaseProfile returns the base profile declared for the account, or BaseProfileForAssume.
+func AccountBaseProfile(accountName string) string {
+	if profile := accountDetails[accountName].BaseProfile; profile != "" {
+		return profile
+	}
+	return BaseProfileForAssume
+}
+
+// ExternalIDOverride is -external-id: the sts:ExternalId of every role
+// assumption, in place of the accounts' external_id.
+var ExternalIDOverride string
+
+// AccountExternalID returns the sts:ExternalId for assuming roles in the
+// account with accountID: ExternalIDOverride, else the external_id of the
+// account's config entry, else none.
+func AccountExternalID(accountID string) string {
+	if ExternalIDOverride != "" {
+		return ExternalIDOverride
+	}
+	for name, id := range accounts {
+		if id == accountID && accountDetails[name].ExternalID != "" {
+			return accountDetails[name].ExternalID
+		}
+	}
+	return ""
+}
+
+var (
+	baseConfigMu    sync.Mutex
+	baseConfigCache = make(map[string]aws.Config)
+)
+
+// BaseConfigForAccount returns the config whose credentials call sts:AssumeRole
+// for the account. Accounts without their own base_profile or partition get
+// fallback; others get a config loaded once per profile and partition, with a
+// region inside the partition so the role ARN and STS endpoint match.
+func BaseConfigForAccount(ctx context.Context, accountName string, fallback aws.Config) (aws.Config, error) {
+	profile, partition := AccountBaseProfile(accountName), AccountPartition(accountName)
+	if profile == BaseProfileForAssume && partition == PartitionForRegion(fallback.Region) {
+		return fallback, nil
+	}
+
+	key := profile + "|" + partition
+	baseConfigMu.Lock()
+	defer baseConfigMu.Unlock()
+	if cfg, ok := baseConfigCache[key]; ok {
+		return cfg, nil
+	}
+	cfg, err := LoadAWSConfig(ctx, awsconfig.WithSharedConfigProfile(profile))
+	if err != nil {
+		return aws.Config{}, fmt.Errorf("failed to load base AWS configuration (profile '%s') for account '%s': %w", profile, accountName, err)
+	}
+	if cfg.Region == "" || PartitionForRegion(cfg.Region) != partition {
+		cfg.Region = partitionDefaultRegions[partition]
+	}
+	LogVerbosef("Using base profile '%s' in %s (partition %s) for account '%s'.", profile, cfg.Region, partition, accountName)
+	baseConfigCache[key] = cfg
+	return cfg, nil
+}
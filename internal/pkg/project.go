@@ -0,0 +1,127 @@
+package pkg
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ProjectFileName is the per-directory file with a repository's saws defaults.
+const ProjectFileName = ".saws.yaml"
+
+// envNoProjectVar set to 1 ignores any .saws.yaml.
+const envNoProjectVar = "SAWS_NO_PROJECT"
+
+// ProjectFile is a .saws.yaml: the defaults saws uses when run inside the
+// directory tree that holds it. Flags and SAWS_* variables win over it.
+type ProjectFile struct {
+	// Context selects a named context of the SAWS config.
+	Context string `yaml:"context"`
+	// Account is the default account selector of single-account commands.
+	Account string `yaml:"account"`
+	// Accounts limits the account prompt to these accounts and is the default
+	// -s of fan-out commands.
+	Accounts []string `yaml:"accounts"`
+	Role     string   `yaml:"role"`
+	Region   string   `yaml:"region"`
+	// Runbook is a URL or a path relative to the file, shown when saws starts.
+	Runbook string `yaml:"runbook"`
+
+	// Path is where the file was found.
+	Path string `yaml:"-"`
+}
+
+// FindProjectFile looks for .saws.yaml in the working directory and its
+// parents, up to the root of the enclosing git repository. It returns nil
+// when there is none or SAWS_NO_PROJECT=1.
+func FindProjectFile() (*ProjectFile, error) {
+	if os.Getenv(envNoProjectVar) == "1" {
+		return nil, nil
+	}
+	dir, err := os.Getwd()
+	if err != nil {
+		return nil, nil
+	}
+	for {
+		path := filepath.Join(dir, ProjectFileName)
+		if _, err := os.Stat(path); err == nil {
+			return loadProjectFile(path)
+		}
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return nil, nil
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return nil, nil
+		}
+		dir = parent
+	}
+}
+
+func loadProjectFile(path string) (*ProjectFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read project file '%s': %w", path, err)
+	}
+	project := &ProjectFile{}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(project); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("invalid project file '%s': %w", path, err)
+	}
+	if project.Region != "" {
+		if err := ValidateRegions("region", project.Region); err != nil {
+			return nil, fmt.Errorf("invalid project file '%s': %w", path, err)
+		}
+	}
+	if project.Runbook != "" && !strings.Contains(project.Runbook, "://") && !filepath.IsAbs(project.Runbook) {
+		project.Runbook = filepath.Join(filepath.Dir(path), project.Runbook)
+	}
+	project.Path = path
+	LogVerbosef("Using project defaults from '%s'.", path)
+	return project, nil
+}
+
+// ContextName returns the context of the project unless -context or
+// SAWS_CONTEXT already chose one.
+func (p *ProjectFile) ContextName(contextFlag string) string {
+	if p == nil || contextFlag != "" || os.Getenv(envContextVar) != "" {
+		return contextFlag
+	}
+	return p.Context
+}
+
+// ApplyDefaults fills the account selector, role, and region left empty by
+// both the flags and the SAWS_* variables. Fan-out commands default to all of
+// the project's accounts; single-account commands to its account, or else a
+// prompt limited to its accounts.
+func (p *ProjectFile) ApplyDefaults(selector, role, region *string, fanOut bool) {
+	if p == nil {
+		return
+	}
+	if *selector == "" && os.Getenv(envAccountVar) == "" {
+		switch {
+		case fanOut && len(p.Accounts) > 0:
+			*selector = strings.Join(p.Accounts, ",")
+		case p.Account != "":
+			*selector = p.Account
+		default:
+			projectAccounts = p.Accounts
+		}
+	}
+	if *role == "" && os.Getenv(envRoleVar) == "" {
+		*role = p.Role
+	}
+	if *region == "" && os.Getenv(envRegionVar) == "" {
+		*region = p.Region
+	}
+}
+
+// projectAccounts, when set, are the only accounts the account prompt offers.
+var projectAccounts []string
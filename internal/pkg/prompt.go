@@ -0,0 +1,81 @@
+package pkg
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/AlecAivazis/survey/v2/core"
+)
+
+// FuzzyFilter matches an option when the typed filter is a case-insensitive
+// subsequence of it, so "prdweb" finds "prod-web-eu". It has the signature of
+// survey's option filter.
+func FuzzyFilter(filter, option string, _ int) bool {
+	option = strings.ToLower(option)
+	for _, r := range strings.ToLower(filter) {
+		i := strings.IndexRune(option, r)
+		if i < 0 {
+			return false
+		}
+		option = option[i+utf8.RuneLen(r):]
+	}
+	return true
+}
+
+// WithFuzzyFilter enables FuzzyFilter on a Select or MultiSelect prompt.
+var WithFuzzyFilter = survey.WithFilter(FuzzyFilter)
+
+// ExitInputRequired is the exit code of a run that stopped at a prompt because
+// NoInput is set.
+const ExitInputRequired = 4
+
+// NoInput makes every prompt fail instead of waiting for an answer (--no-input),
+// for scripts and CI jobs without a terminal.
+var NoInput bool
+
+// InputRequired reports whether a prompt was refused because of NoInput.
+var InputRequired bool
+
+// InputRequiredError is returned by AskOne for a prompt refused under NoInput.
+type InputRequiredError struct {
+	Prompt string
+}
+
+func (e *InputRequiredError) Error() string {
+	return fmt.Sprintf("input required for prompt '%s' but --no-input is set; pass the value as a flag", e.Prompt)
+}
+
+func promptMessage(p survey.Prompt) string {
+	switch prompt := p.(type) {
+	case *survey.Select:
+		return prompt.Message
+	case *survey.MultiSelect:
+		return prompt.Message
+	case *survey.Input:
+		return prompt.Message
+	case *survey.Confirm:
+		return prompt.Message
+	case *survey.Password:
+		return prompt.Message
+	}
+	return fmt.Sprintf("%T", p)
+}
+
+// AskOne is survey.AskOne honoring NoInput: instead of prompting it prints a
+// JSON line {"error":"input_required","prompt":...} to stderr for the calling
+// script and returns an *InputRequiredError.
+func AskOne(p survey.Prompt, response any, opts ...survey.AskOpt) error {
+	if !NoInput {
+		core.DisableColor = !UseColor(os.Stdout)
+		return survey.AskOne(p, response, opts...)
+	}
+	InputRequired = true
+	message := promptMessage(p)
+	line, _ := json.Marshal(map[string]string{"error": "input_required", "prompt": message})
+	fmt.Fprintln(os.Stderr, string(line))
+	return &InputRequiredError{Prompt: message}
+}
@@ -0,0 +1,198 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials/ssocreds"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/smithy-go"
+	"golang.org/x/term"
+)
+
+// ReauthCommand is defaults.reauth_command: a command (run without a shell)
+// that refreshes expired base credentials, e.g. "aws-mfa --profile default".
+// It takes precedence over the built-in SSO login (SSOLogin).
+var ReauthCommand string
+
+// baseCredentials are the credentials of one base profile, shared by every
+// config loaded for it during a run, so an MFA code is asked for once and a
+// re-authentication refreshes all of them.
+type baseCredentials struct {
+	profile string
+	optFns  []func(*awsconfig.LoadOptions) error
+
+	mu        sync.RWMutex
+	provider  aws.CredentialsProvider
+	reauthed  bool
+	reauthErr error
+}
+
+func (c *baseCredentials) Retrieve(ctx context.Context) (aws.Credentials, error) {
+	c.mu.RLock()
+	provider := c.provider
+	c.mu.RUnlock()
+	return provider.Retrieve(ctx)
+}
+
+var (
+	baseCredentialsMu        sync.Mutex
+	baseCredentialsByProfile = make(map[string]*baseCredentials)
+)
+
+// shareBaseCredentials gives cfg the shared credentials of its profile. Configs
+// with explicit credentials (assumed roles) and environment credentials are
+// left alone: there is nothing to re-authenticate.
+func shareBaseCredentials(cfg aws.Config, optFns []func(*awsconfig.LoadOptions) error) aws.Config {
+	var o awsconfig.LoadOptions
+	for _, fn := range optFns {
+		_ = fn(&o)
+	}
+	if o.Credentials != nil || cfg.Credentials == nil || os.Getenv("AWS_ACCESS_KEY_ID") != "" {
+		return cfg
+	}
+	profile := o.SharedConfigProfile
+	if profile == "" {
+		if profile = os.Getenv("AWS_PROFILE"); profile == "" {
+			profile = "default"
+		}
+	}
+
+	baseCredentialsMu.Lock()
+	defer baseCredentialsMu.Unlock()
+	shared, ok := baseCredentialsByProfile[profile]
+	if !ok {
+		shared = &baseCredentials{profile: profile, optFns: optFns, provider: cfg.Credentials}
+		baseCredentialsByProfile[profile] = shared
+	}
+	cfg.Credentials = shared
+	return cfg
+}
+
+// promptMFAToken asks for the MFA code of a base profile with mfa_serial and
+// role_arn, which the SDK needs to assume the profile's role.
+func promptMFAToken(serial string) (string, error) {
+	code := ""
+	message := "MFA code"
+	if serial != "" {
+		message += " for " + serial
+	}
+	err := AskOne(&survey.Input{Message: message + ":"}, &code, survey.WithValidator(survey.Required))
+	return strings.TrimSpace(code), err
+}
+
+// withMFAPrompt lets the SDK ask for MFA codes instead of failing on profiles with mfa_serial.
+func withMFAPrompt(o *stscreds.AssumeRoleOptions) {
+	o.TokenProvider = func() (string, error) {
+		return promptMFAToken(aws.ToString(o.SerialNumber))
+	}
+}
+
+// IsExpiredCredentials reports whether err means the base credentials have
+// expired: an expired or revoked SSO token, or an expired session token.
+func IsExpiredCredentials(err error) bool {
+	var tokenErr *ssocreds.InvalidTokenError
+	if errors.As(err, &tokenErr) {
+		return true
+	}
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.ErrorCode() {
+		case "ExpiredToken", "ExpiredTokenException", "InvalidGrantException", "UnauthorizedException":
+			return true
+		}
+	}
+	// The SDK's credential middleware does not always wrap with %w.
+	message := err.Error()
+	// A missing token file means the SSO profile was never logged in to.
+	for _, expired := range []string{"the SSO session has expired or is invalid", "refresh cached SSO token failed", "cached SSO token is expired", "failed to read cached SSO token file"} {
+		if strings.Contains(message, expired) {
+			return true
+		}
+	}
+	return false
+}
+
+// ReauthenticateBase refreshes the expired base credentials of cfg, at most
+// once per profile and run, so a fan-out whose targets all fail at once logs
+// in a single time. On success the caller retries its call with cfg.
+func ReauthenticateBase(ctx context.Context, cfg aws.Config) error {
+	shared, ok := cfg.Credentials.(*baseCredentials)
+	if !ok {
+		return errors.New("the base credentials have expired; refresh them and try again")
+	}
+	return shared.reauthenticate(ctx)
+}
+
+func (c *baseCredentials) reauthenticate(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.reauthed {
+		return c.reauthErr
+	}
+	c.reauthed = true
+	if c.reauthErr = c.runReauth(ctx); c.reauthErr != nil {
+		return c.reauthErr
+	}
+	cfg, err := awsconfig.LoadDefaultConfig(ctx, c.optFns...)
+	if err != nil {
+		c.reauthErr = fmt.Errorf("failed to reload base profile '%s' after re-authenticating: %w", c.profile, err)
+		return c.reauthErr
+	}
+	c.provider = cfg.Credentials
+	return nil
+}
+
+// runReauth runs defaults.reauth_command, else SSOLogin for a profile whose
+// credentials come from IAM Identity Center. A profile assuming a role
+// with mfa_serial needs nothing run: reloading it prompts for a new code.
+func (c *baseCredentials) runReauth(ctx context.Context) error {
+	if ReauthCommand != "" {
+		argv := strings.Fields(ReauthCommand)
+		fmt.Fprintf(os.Stderr, "The base credentials of profile '%s' have expired; running '%s'.\n", c.profile, ReauthCommand)
+		return runInteractive(ctx, argv, fmt.Sprintf("SAWS_BASE_PROFILE=%s", c.profile))
+	}
+
+	sharedCfg, err := awsconfig.LoadSharedConfigProfile(ctx, c.profile)
+	if err != nil {
+		return fmt.Errorf("the base credentials of profile '%s' have expired; refresh them, or set defaults.reauth_command to a command that does", c.profile)
+	}
+	for sc := &sharedCfg; sc != nil; sc = sc.Source {
+		if sc.SSOSessionName != "" || sc.SSOStartURL != "" {
+			if NoInput || !stdinIsTerminal() {
+				return fmt.Errorf("the SSO session of profile '%s' has expired or was never started; run 'saws sso-login %s'", sc.Profile, sc.Profile)
+			}
+			fmt.Fprintf(os.Stderr, "The SSO session of profile '%s' has expired or was never started; logging in.\n", sc.Profile)
+			return SSOLogin(ctx, sc.Profile)
+		}
+		if sc.MFASerial != "" && sc.RoleARN != "" {
+			fmt.Fprintf(os.Stderr, "The MFA session of profile '%s' has expired.\n", sc.Profile)
+			return nil
+		}
+	}
+	return fmt.Errorf("the base credentials of profile '%s' have expired; refresh them, or set defaults.reauth_command to a command that does", c.profile)
+}
+
+// runInteractive runs argv on the terminal; its output goes to stderr so it
+// never mixes with the output of the mode.
+func runInteractive(ctx context.Context, argv []string, env ...string) error {
+	cmd := exec.CommandContext(ctx, argv[0], argv[1:]...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stderr, os.Stderr
+	cmd.Env = append(os.Environ(), env...)
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("re-authentication with '%s' failed: %w", strings.Join(argv, " "), err)
+	}
+	return nil
+}
+
+func stdinIsTerminal() bool {
+	return term.IsTerminal(int(os.Stdin.Fd()))
+}
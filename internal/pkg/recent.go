@@ -0,0 +1,196 @@
+package pkg
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	recentFileName      = "saws-recent.json"
+	maxRecentSelections = 25
+)
+
+// RecentSelection is one account/role/region (and instance) combination saws
+// was used with, kept in ~/.aws/saws-recent.json newest first.
+type RecentSelection struct {
+	Account  string    `json:"account"`
+	Role     string    `json:"role"`
+	Region   string    `json:"region"`
+	Instance string    `json:"instance,omitempty"`
+	Session  string    `json:"session"`
+	UsedAt   time.Time `json:"used_at"`
+	Uses     int       `json:"uses"`
+}
+
+func (s RecentSelection) sameTarget(other RecentSelection) bool {
+	return s.Account == other.Account && s.Role == other.Role && s.Region == other.Region && s.Instance == other.Instance
+}
+
+func recentPath() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(homeDir, AWSConfigDir, recentFileName), nil
+}
+
+// LoadRecentSelections returns the remembered selections, newest first. A
+// missing or unreadable state file yields none.
+func LoadRecentSelections() []RecentSelection {
+	path, err := recentPath()
+	if err != nil {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	var recent []RecentSelection
+	if err := json.Unmarshal(data, &recent); err != nil {
+		LogVerbosef("Warning: Ignoring unreadable recent selections '%s': %v", path, err)
+		return nil
+	}
+	return recent
+}
+
+func writeRecentSelections(recent []RecentSelection) {
+	path, err := recentPath()
+	if err != nil {
+		LogVerbosef("Warning: Could not determine recent selections path: %v", err)
+		return
+	}
+	data, err := json.MarshalIndent(recent, "", "  ")
+	if err != nil {
+		return
+	}
+	if err := WriteFileAtomic(path, data, 0o600); err != nil {
+		LogVerbosef("Warning: Could not write recent selections '%s': %v", path, err)
+	}
+}
+
+// lockRecentSelections locks the recent selections for a read-modify-write;
+// when another run holds them, the selection is not remembered.
+func lockRecentSelections() (func(), bool) {
+	path, err := recentPath()
+	if err != nil {
+		return nil, false
+	}
+	unlock, err := LockState(path)
+	if err != nil {
+		LogVerbosef("Warning: Not remembering this selection: %v", err)
+		return nil, false
+	}
+	return unlock, true
+}
+
+// RecordRecentSelection moves sel to the front of the recent selections,
+// counting repeated uses of the same combination.
+func RecordRecentSelection(sel RecentSelection) {
+	unlock, ok := lockRecentSelections()
+	if !ok {
+		return
+	}
+	defer unlock()
+	recordRecentSelection(LoadRecentSelections(), sel)
+}
+
+// recordRecentSelection writes recent with sel moved to the front; the caller
+// holds the lock.
+func recordRecentSelection(recent []RecentSelection, sel RecentSelection) {
+	sel.UsedAt = time.Now()
+	sel.Uses = 1
+	kept := []RecentSelection{sel}
+	for _, r := range recent {
+		if r.sameTarget(sel) {
+			kept[0].Uses += r.Uses
+			continue
+		}
+		if len(kept) < maxRecentSelections {
+			kept = append(kept, r)
+		}
+	}
+	writeRecentSelections(kept)
+}
+
+// RecordRecentInstance adds the instance picked in the context to the
+// selection EstablishAWSContextAndAssumeRole just recorded for it.
+func RecordRecentInstance(sCtx *SelectedContext, instanceID string) {
+	sel := RecentSelection{Account: sCtx.AccountName, Role: sCtx.RoleName, Region: sCtx.Region}
+	unlock, ok := lockRecentSelections()
+	if !ok {
+		return
+	}
+	defer unlock()
+	recent := LoadRecentSelections()
+	if len(recent) > 0 && recent[0].sameTarget(sel) {
+		sel.Session = recent[0].Session
+		recent[0].Uses--
+		if recent[0].Uses <= 0 {
+			recent = recent[1:]
+		}
+	}
+	sel.Instance = instanceID
+	recordRecentSelection(recent, sel)
+}
+
+// recentValues returns the distinct non-empty values pick takes over the
+// recent selections keep accepts, newest first.
+func recentValues(pick func(RecentSelection) string, keep func(RecentSelection) bool) []string {
+	var values []string
+	seen := make(map[string]bool)
+	for _, r := range LoadRecentSelections() {
+		if keep != nil && !keep(r) {
+			continue
+		}
+		if v := pick(r); v != "" && !seen[v] {
+			seen[v] = true
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// recentFirst moves the options whose key is among recent to the front, in the
+// order of recent, leaving the rest in their original order.
+func recentFirst(options, recent []string, key func(string) string) []string {
+	rank := make(map[string]int, len(recent))
+	for i, v := range recent {
+		rank[v] = i
+	}
+	front := make([][]string, len(recent))
+	var rest []string
+	for _, option := range options {
+		if i, ok := rank[key(option)]; ok {
+			front[i] = append(front[i], option)
+		} else {
+			rest = append(rest, option)
+		}
+	}
+	ordered := make([]string, 0, len(options))
+	for _, group := range front {
+		ordered = append(ordered, group...)
+	}
+	return append(ordered, rest...)
+}
+
+// RecentInstancesFirst orders instance prompt options so the instances recently
+// used in the context's account and region come first; id maps an option to its
+// instance ID.
+func RecentInstancesFirst(sCtx *SelectedContext, options []string, id func(string) string) []string {
+	recent := recentValues(func(r RecentSelection) string { return r.Instance }, func(r RecentSelection) bool {
+		return r.Account == sCtx.AccountName && r.Region == sCtx.Region
+	})
+	return recentFirst(options, recent, id)
+}
+
+// FormatRecentSelection renders a selection for the `saws recent` picker.
+func FormatRecentSelection(r RecentSelection) string {
+	target := fmt.Sprintf("%s / %s / %s", r.Account, r.Role, r.Region)
+	if r.Instance != "" {
+		target += " / " + r.Instance
+	}
+	return fmt.Sprintf("%-60s %s ago, %d use(s)", target, time.Since(r.UsedAt).Round(time.Minute), r.Uses)
+}
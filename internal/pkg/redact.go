@@ -0,0 +1,82 @@
+package pkg
+
+import (
+	"io"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// redactedSecret replaces secret values in logs and output.
+const redactedSecret = "<redacted>"
+
+// minRegisteredSecretLength keeps short values, which could match ordinary
+// text, out of the registered secrets.
+const minRegisteredSecretLength = 8
+
+// secretPatterns match credentials and secrets by their shape or label: SDK
+// request/response dumps, AWS CLI output, and environment variables echoed by
+// commands. The groups are kept and the secret between them replaced.
+var secretPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)((?:X-Amz-Security-Token|X-Amz-Sso_bearer_token):\s*)\S+`),
+	regexp.MustCompile(`(?i)(X-Amz-Security-Token=)[^&\s]+`),
+	regexp.MustCompile(`(Signature=)[0-9a-f]+`),
+	regexp.MustCompile(`(<(?:SecretAccessKey|SessionToken)>)[^<]*`),
+	regexp.MustCompile(`(?i)("(?:secretAccessKey|sessionToken|accessToken|refreshToken|clientSecret|authorizationToken|Plaintext)"\s*:\s*")[^"]*`),
+	regexp.MustCompile(`("Type"\s*:\s*"SecureString"[^}]*"Value"\s*:\s*")[^"]*`),
+	regexp.MustCompile(`("Value"\s*:\s*")[^"]*("[^}]*"Type"\s*:\s*"SecureString")`),
+	regexp.MustCompile(`(?i)((?:AWS_SECRET_ACCESS_KEY|AWS_SESSION_TOKEN|AWS_SECURITY_TOKEN)\s*[=:]\s*["']?)[^"'\s]+`),
+	regexp.MustCompile(`\b((?:AKIA|ASIA))[A-Z0-9]{16}\b`),
+}
+
+var (
+	secretsMu sync.RWMutex
+	secrets   []string
+)
+
+// RegisterSecret makes RedactSecrets mask values wherever they appear, such as
+// the credentials of an assumed role in the output of a command run with them.
+func RegisterSecret(values ...string) {
+	secretsMu.Lock()
+	defer secretsMu.Unlock()
+	for _, value := range values {
+		if len(value) >= minRegisteredSecretLength {
+			secrets = append(secrets, value)
+		}
+	}
+}
+
+// RedactSecrets masks registered secrets and anything shaped like a credential
+// in s. Everything saws logs, reports, or records passes through it.
+func RedactSecrets(s string) string {
+	secretsMu.RLock()
+	for _, secret := range secrets {
+		s = strings.ReplaceAll(s, secret, redactedSecret)
+	}
+	secretsMu.RUnlock()
+	for _, pattern := range secretPatterns {
+		if pattern.NumSubexp() == 2 {
+			s = pattern.ReplaceAllString(s, "${1}"+redactedSecret+"${2}")
+		} else {
+			s = pattern.ReplaceAllString(s, "${1}"+redactedSecret)
+		}
+	}
+	return s
+}
+
+type redactingWriter struct {
+	w io.Writer
+}
+
+// RedactingWriter returns a writer that passes everything written to w through
+// RedactSecrets. Each write must hold whole lines, as the log package's do.
+func RedactingWriter(w io.Writer) io.Writer {
+	return redactingWriter{w: w}
+}
+
+func (r redactingWriter) Write(p []byte) (int, error) {
+	if _, err := io.WriteString(r.w, RedactSecrets(string(p))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
@@ -0,0 +1,188 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ststypes "github.com/aws/aws-sdk-go-v2/service/sts/types"
+)
+
+// knownRegions lists the regions (aws, aws-cn, aws-us-gov, and the ISO
+// partitions) known when this list was last updated; newer ones pass
+// checkRegionName with a warning as long as they are well formed.
+var knownRegions = []string{
+	"af-south-1", "ap-east-1", "ap-east-2", "ap-northeast-1", "ap-northeast-2", "ap-northeast-3",
+	"ap-south-1", "ap-south-2", "ap-southeast-1", "ap-southeast-2", "ap-southeast-3",
+	"ap-southeast-4", "ap-southeast-5", "ap-southeast-6", "ap-southeast-7", "ca-central-1", "ca-west-1",
+	"cn-north-1", "cn-northwest-1", "eu-central-1", "eu-central-2", "eu-isoe-west-1",
+	"eu-north-1", "eu-south-1", "eu-south-2", "eu-west-1", "eu-west-2", "eu-west-3",
+	"il-central-1", "me-central-1", "me-south-1", "mx-central-1", "sa-east-1",
+	"us-east-1", "us-east-2", "us-gov-east-1", "us-gov-west-1", "us-iso-east-1",
+	"us-iso-west-1", "us-isob-east-1", "us-isof-east-1", "us-isof-south-1",
+	"us-west-1", "us-west-2",
+}
+
+// regionNamePattern is the shape of every region name, e.g. us-gov-west-1 or
+// ap-southeast-6.
+var regionNamePattern = regexp.MustCompile(`^[a-z]{2}(-gov|-iso[a-z]?)?-(central|north|south|east|west|northeast|northwest|southeast|southwest)-[1-9][0-9]*$`)
+
+// maxRegionTypoDistance is how far a name may be from a known region to count as a typo of it.
+const maxRegionTypoDistance = 2
+
+// closestRegion returns the known region nearest to region when it is a likely typo.
+func closestRegion(region string) string {
+	best, bestDistance := "", maxRegionTypoDistance+1
+	for _, known := range knownRegions {
+		if d := editDistance(region, known); d < bestDistance {
+			best, bestDistance = known, d
+		}
+	}
+	return best
+}
+
+// checkRegionName classifies region: nil when it is known, an IssueError issue
+// when it is not shaped like a region name (with a suggestion when it is close
+// to a known one), and an IssueWarning issue when it is well formed but
+// unknown, possibly a region newer than this build.
+func checkRegionName(region string) *ConfigIssue {
+	i := sort.SearchStrings(knownRegions, region)
+	if i < len(knownRegions) && knownRegions[i] == region {
+		return nil
+	}
+	suggestion := closestRegion(region)
+	if !regionNamePattern.MatchString(region) {
+		if suggestion != "" {
+			return &ConfigIssue{Level: IssueError, Message: fmt.Sprintf("unknown region '%s' (did you mean '%s'?)", region, suggestion)}
+		}
+		return &ConfigIssue{Level: IssueError, Message: fmt.Sprintf("'%s' is not an AWS region name such as eu-west-1", region)}
+	}
+	if suggestion != "" {
+		return &ConfigIssue{Level: IssueWarning, Message: fmt.Sprintf("'%s' is not a known AWS region (did you mean '%s'?)", region, suggestion)}
+	}
+	return &ConfigIssue{Level: IssueWarning, Message: fmt.Sprintf("'%s' is not a known AWS region", region)}
+}
+
+// lintRegions checks common_regions and tunnel regions; scope prefixes messages.
+func lintRegions(scope string, regions []string, tunnels map[string]TunnelConfig) []ConfigIssue {
+	var issues []ConfigIssue
+	for _, region := range regions {
+		if issue := checkRegionName(region); issue != nil {
+			issues = append(issues, ConfigIssue{Level: issue.Level, Message: scope + "common_regions: " + issue.Message})
+		}
+	}
+	tunnelNames := make([]string, 0, len(tunnels))
+	for name := range tunnels {
+		tunnelNames = append(tunnelNames, name)
+	}
+	sort.Strings(tunnelNames)
+	for _, name := range tunnelNames {
+		if region := tunnels[name].Region; region != "" {
+			if issue := checkRegionName(region); issue != nil {
+				issues = append(issues, ConfigIssue{Level: issue.Level, Message: fmt.Sprintf("%stunnel '%s': %s", scope, name, issue.Message)})
+			}
+		}
+	}
+	return issues
+}
+
+// ValidateRegions rejects regions that are not shaped like region names, naming
+// the source (e.g. "-regions") in the error. Well-formed unknown regions only
+// produce a warning, so regions newer than this build still work.
+func ValidateRegions(source string, regions ...string) error {
+	for _, region := range regions {
+		issue := checkRegionName(region)
+		if issue == nil {
+			continue
+		}
+		if issue.Level == IssueError {
+			return fmt.Errorf("%s: %s", source, issue.Message)
+		}
+		fmt.Fprintf(os.Stderr, "Warning: %s: %s.\n", source, issue.Message)
+	}
+	return nil
+}
+
+// regionNames are the geographic names of the regions, as in the AWS console.
+var regionNames = map[string]string{
+	"af-south-1":     "Cape Town",
+	"ap-east-1":      "Hong Kong",
+	"ap-east-2":      "Taipei",
+	"ap-northeast-1": "Tokyo",
+	"ap-northeast-2": "Seoul",
+	"ap-northeast-3": "Osaka",
+	"ap-south-1":     "Mumbai",
+	"ap-south-2":     "Hyderabad",
+	"ap-southeast-1": "Singapore",
+	"ap-southeast-2": "Sydney",
+	"ap-southeast-3": "Jakarta",
+	"ap-southeast-4": "Melbourne",
+	"ap-southeast-5": "Malaysia",
+	"ap-southeast-6": "New Zealand",
+	"ap-southeast-7": "Thailand",
+	"ca-central-1":   "Canada Central",
+	"ca-west-1":      "Calgary",
+	"cn-north-1":     "Beijing",
+	"cn-northwest-1": "Ningxia",
+	"eu-central-1":   "Frankfurt",
+	"eu-central-2":   "Zurich",
+	"eu-north-1":     "Stockholm",
+	"eu-south-1":     "Milan",
+	"eu-south-2":     "Spain",
+	"eu-west-1":      "Ireland",
+	"eu-west-2":      "London",
+	"eu-west-3":      "Paris",
+	"il-central-1":   "Tel Aviv",
+	"me-central-1":   "UAE",
+	"me-south-1":     "Bahrain",
+	"mx-central-1":   "Mexico",
+	"sa-east-1":      "São Paulo",
+	"us-east-1":      "N. Virginia",
+	"us-east-2":      "Ohio",
+	"us-gov-east-1":  "GovCloud US-East",
+	"us-gov-west-1":  "GovCloud US-West",
+	"us-west-1":      "N. California",
+	"us-west-2":      "Oregon",
+}
+
+// RegionName returns the geographic name of region, or "" when it is unknown.
+func RegionName(region string) string {
+	return regionNames[region]
+}
+
+// RegionOption renders region for a prompt as "eu-west-1 (Ireland)". When
+// enabled is known, regions the account has not enabled are marked.
+func RegionOption(region string, enabled map[string]bool) string {
+	option := region
+	if name, ok := regionNames[region]; ok {
+		option += " (" + name + ")"
+	}
+	if enabled != nil && !enabled[region] {
+		option += " - not enabled in this account"
+	}
+	return option
+}
+
+// enabledRegions returns the regions enabled in the account of creds, or nil
+// when the role may not call ec2:DescribeRegions.
+func enabledRegions(ctx context.Context, creds *ststypes.Credentials, partition string) map[string]bool {
+	cfg, err := LoadAssumedRoleConfig(ctx, creds, partitionDefaultRegions[partition])
+	if err != nil {
+		return nil
+	}
+	out, err := ec2.NewFromConfig(cfg).DescribeRegions(ctx, &ec2.DescribeRegionsInput{})
+	if err != nil {
+		LogVerbosef("Could not list the account's enabled regions: %v", err)
+		return nil
+	}
+	enabled := make(map[string]bool, len(out.Regions))
+	for _, r := range out.Regions {
+		if r.RegionName != nil {
+			enabled[*r.RegionName] = true
+		}
+	}
+	return enabled
+}
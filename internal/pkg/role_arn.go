@@ -0,0 +1,69 @@
+package pkg
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+)
+
+// RoleARN is a role given to -r as a full IAM role ARN instead of a name, for
+// roles with a path or in another partition.
+type RoleARN struct {
+	ARN       string
+	Partition string
+	AccountID string
+	// Name is the role name without its path.
+	Name string
+}
+
+// ParseRoleARN returns the parts of role when it is an IAM role ARN such as
+// arn:aws:iam::123456789012:role/Weird/Path/Role.
+func ParseRoleARN(role string) (RoleARN, bool) {
+	if !strings.HasPrefix(role, "arn:") {
+		return RoleARN{}, false
+	}
+	parsed, err := arn.Parse(role)
+	if err != nil || parsed.Service != "iam" || !accountIDPattern.MatchString(parsed.AccountID) || !strings.HasPrefix(parsed.Resource, "role/") {
+		return RoleARN{}, false
+	}
+	return RoleARN{ARN: role, Partition: parsed.Partition, AccountID: parsed.AccountID, Name: path.Base(parsed.Resource)}, true
+}
+
+// ValidateRoleARN rejects a role that looks like an ARN but is not an IAM role
+// ARN, so a typo fails before any prompt.
+func ValidateRoleARN(role string) error {
+	if strings.HasPrefix(role, "arn:") {
+		if _, ok := ParseRoleARN(role); !ok {
+			return fmt.Errorf("'%s' is not an IAM role ARN (arn:<partition>:iam::<account-id>:role/<path/name>)", role)
+		}
+	}
+	return nil
+}
+
+// roleAccountSelector is the account ID of role when it is an ARN, so -s may be
+// left out; else "".
+func roleAccountSelector(role string) string {
+	if roleARN, ok := ParseRoleARN(role); ok {
+		return roleARN.AccountID
+	}
+	return ""
+}
+
+// ContextPartition returns the partition of the role ARN of sCtx, else of its account.
+func ContextPartition(sCtx *SelectedContext) string {
+	if roleARN, ok := ParseRoleARN(sCtx.RoleName); ok {
+		return roleARN.Partition
+	}
+	return AccountPartition(sCtx.AccountName)
+}
+
+// checkRoleARNAccount fails when a role ARN is in another account than the
+// one selected with -s.
+func checkRoleARNAccount(role, accountName, accountID string) error {
+	if roleARN, ok := ParseRoleARN(role); ok && roleARN.AccountID != accountID {
+		return fmt.Errorf("role ARN %s is in account %s, but account '%s' (%s) is selected; leave out -s to use the ARN's account", role, roleARN.AccountID, accountName, accountID)
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package pkg
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+)
+
+// allowedRoleNames returns the role names roles_by_account allows in the
+// account, and false when no pattern matches it (every role is allowed).
+func allowedRoleNames(rolesByAccount map[string][]string, accountName string) ([]string, bool) {
+	var allowed []string
+	matched := false
+	for pattern, roleNames := range rolesByAccount {
+		if ok, err := filepath.Match(pattern, accountName); err == nil && ok {
+			matched = true
+			allowed = append(allowed, roleNames...)
+		}
+	}
+	return allowed, matched
+}
+
+// RoleAllowedInAccount reports whether roles_by_account permits roleName, a
+// friendly or actual role name, a role ARN (by its name), or a permission set, in the account.
+// Accounts no pattern matches allow every role.
+func RoleAllowedInAccount(accountName, roleName string) bool {
+	allowed, restricted := allowedRoleNames(rolesByAccount, accountName)
+	if !restricted {
+		return true
+	}
+	if roleARN, ok := ParseRoleARN(roleName); ok {
+		roleName = roleARN.Name
+	}
+	permissionSet, _ := SSOPermissionSet(roleName)
+	for _, name := range allowed {
+		if name == roleName || roles[name] == roleName || (permissionSet != "" && name == permissionSet) {
+			return true
+		}
+	}
+	return false
+}
+
+// lintRolesByAccount checks that roles_by_account patterns are valid and match
+// an account, and that the roles they list exist; scope prefixes messages.
+func lintRolesByAccount(scope string, rolesByAccount map[string][]string, accounts, roles map[string]string) []ConfigIssue {
+	var issues []ConfigIssue
+	add := func(level, format string, args ...any) {
+		issues = append(issues, ConfigIssue{Level: level, Message: scope + fmt.Sprintf(format, args...)})
+	}
+	actualRoles := make(map[string]bool, len(roles))
+	for _, actual := range roles {
+		actualRoles[actual] = true
+		if permissionSet, ok := SSOPermissionSet(actual); ok {
+			actualRoles[permissionSet] = true
+		}
+	}
+
+	patterns := make([]string, 0, len(rolesByAccount))
+	for pattern := range rolesByAccount {
+		patterns = append(patterns, pattern)
+	}
+	sort.Strings(patterns)
+	for _, pattern := range patterns {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			add(IssueError, "roles_by_account pattern '%s' is invalid: %v", pattern, err)
+			continue
+		}
+		matchesAccount := false
+		for name := range accounts {
+			if ok, _ := filepath.Match(pattern, name); ok {
+				matchesAccount = true
+				break
+			}
+		}
+		if !matchesAccount {
+			add(IssueWarning, "roles_by_account pattern '%s' matches no account", pattern)
+		}
+		for _, roleName := range rolesByAccount[pattern] {
+			if _, ok := roles[roleName]; !ok && !actualRoles[roleName] {
+				add(IssueWarning, "roles_by_account pattern '%s' lists role '%s', which is not under roles:", pattern, roleName)
+			}
+		}
+	}
+	return issues
+}
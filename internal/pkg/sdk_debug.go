@@ -0,0 +1,75 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/aws/smithy-go/logging"
+	"github.com/aws/smithy-go/middleware"
+)
+
+// Verbosity levels of -v, -vv, and -vvv.
+const (
+	VerboseLog      = 1 // saws' own progress logging
+	VerboseTiming   = 2 // plus the duration and outcome of every AWS call
+	VerboseSDKTrace = 3 // plus SDK request/response logging, credentials redacted
+)
+
+// VerboseLevel is the -v level; VerboseMode is set from it for LogVerbosef.
+var VerboseLevel int
+
+// LoadAWSConfig is awsconfig.LoadDefaultConfig with the AWS call timing and SDK
+// request logging of the active VerboseLevel added. Configs of a base profile
+// share its credentials for the run (see ReauthenticateBase), and profiles
+// assuming a role with mfa_serial prompt for the code.
+func LoadAWSConfig(ctx context.Context, optFns ...func(*awsconfig.LoadOptions) error) (aws.Config, error) {
+	optFns = append([]func(*awsconfig.LoadOptions) error{awsconfig.WithAssumeRoleCredentialOptions(withMFAPrompt)}, optFns...)
+	if VerboseLevel >= VerboseTiming {
+		optFns = append(optFns, awsconfig.WithAPIOptions([]func(*middleware.Stack) error{addCallTiming}))
+	}
+	if VerboseLevel >= VerboseSDKTrace {
+		optFns = append(optFns,
+			awsconfig.WithLogger(redactingLogger{}),
+			awsconfig.WithClientLogMode(aws.LogRequestWithBody|aws.LogResponseWithBody|aws.LogRetries))
+	}
+	cfg, err := awsconfig.LoadDefaultConfig(ctx, optFns...)
+	if err != nil {
+		return cfg, err
+	}
+	return shareBaseCredentials(cfg, optFns), nil
+}
+
+// addCallTiming logs the service, operation, region, duration, and outcome of
+// every call, including its retries. AssumeRole calls also name the role ARN,
+// which tells apart the accounts of a fan-out run.
+func addCallTiming(stack *middleware.Stack) error {
+	return stack.Initialize.Add(middleware.InitializeMiddlewareFunc("SawsCallTiming",
+		func(ctx context.Context, in middleware.InitializeInput, next middleware.InitializeHandler) (middleware.InitializeOutput, middleware.Metadata, error) {
+			start := time.Now()
+			out, metadata, err := next.HandleInitialize(ctx, in)
+			call := fmt.Sprintf("%s.%s (%s)", awsmiddleware.GetServiceID(ctx), awsmiddleware.GetOperationName(ctx), awsmiddleware.GetRegion(ctx))
+			if input, ok := in.Parameters.(*sts.AssumeRoleInput); ok && input.RoleArn != nil {
+				call += " " + *input.RoleArn
+			}
+			outcome := "ok"
+			if err != nil {
+				outcome = "error: " + RedactSecrets(err.Error())
+			}
+			log.Printf("AWS call %s took %s: %s", call, time.Since(start).Round(time.Millisecond), outcome)
+			return out, metadata, err
+		}), middleware.After)
+}
+
+// redactingLogger prints the SDK's request/response logging to the verbose log
+// with credentials and secret values removed.
+type redactingLogger struct{}
+
+func (redactingLogger) Logf(classification logging.Classification, format string, v ...any) {
+	log.Printf("SDK %s %s", classification, RedactSecrets(fmt.Sprintf(format, v...)))
+}
@@ -0,0 +1,181 @@
+package pkg
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials/ssocreds"
+	"github.com/aws/aws-sdk-go-v2/service/ssooidc"
+	ssooidctypes "github.com/aws/aws-sdk-go-v2/service/ssooidc/types"
+)
+
+// ssoDeviceGrantType is the OAuth grant of the device authorization flow.
+const ssoDeviceGrantType = "urn:ietf:params:oauth:grant-type:device_code"
+
+// ssoTokenCache is an IAM Identity Center token cache file, in the format
+// `aws sso login` writes and the SDK reads. With an sso-session the client
+// registration and refresh token let the SDK renew the access token itself.
+type ssoTokenCache struct {
+	StartURL              string     `json:"startUrl"`
+	Region                string     `json:"region"`
+	AccessToken           string     `json:"accessToken"`
+	ExpiresAt             time.Time  `json:"expiresAt"`
+	RefreshToken          string     `json:"refreshToken,omitempty"`
+	ClientID              string     `json:"clientId,omitempty"`
+	ClientSecret          string     `json:"clientSecret,omitempty"`
+	RegistrationExpiresAt *time.Time `json:"registrationExpiresAt,omitempty"`
+}
+
+// ssoLoginSettings are the IAM Identity Center settings of a profile.
+type ssoLoginSettings struct {
+	profile  string
+	session  string
+	startURL string
+	region   string
+}
+
+// cacheKey names the token cache file: the sso-session, or the start URL of
+// legacy profiles.
+func (s ssoLoginSettings) cacheKey() string {
+	if s.session != "" {
+		return s.session
+	}
+	return s.startURL
+}
+
+// ssoSettings finds the IAM Identity Center settings of profile or of a
+// source_profile it assumes a role from.
+func ssoSettings(ctx context.Context, profile string) (ssoLoginSettings, error) {
+	sharedCfg, err := awsconfig.LoadSharedConfigProfile(ctx, profile)
+	if err != nil {
+		return ssoLoginSettings{}, fmt.Errorf("could not read AWS profile '%s': %w", profile, err)
+	}
+	for sc := &sharedCfg; sc != nil; sc = sc.Source {
+		switch {
+		case sc.SSOSession != nil:
+			return ssoLoginSettings{profile: sc.Profile, session: sc.SSOSessionName, startURL: sc.SSOSession.SSOStartURL, region: sc.SSOSession.SSORegion}, nil
+		case sc.SSOStartURL != "":
+			return ssoLoginSettings{profile: sc.Profile, startURL: sc.SSOStartURL, region: sc.SSORegion}, nil
+		}
+	}
+	return ssoLoginSettings{}, fmt.Errorf("profile '%s' has no sso_session or sso_start_url", profile)
+}
+
+// SSOLogin signs in to IAM Identity Center for profile with the device
+// authorization flow, like `aws sso login` but without the AWS CLI: it shows a
+// URL and code to confirm in a browser, waits for the confirmation, and writes
+// the token to the cache the SDK reads, so the profile works as the base
+// profile of every mode. The client registration is reused while it is valid.
+func SSOLogin(ctx context.Context, profile string) error {
+	settings, err := ssoSettings(ctx, profile)
+	if err != nil {
+		return err
+	}
+	if settings.startURL == "" || settings.region == "" {
+		return fmt.Errorf("the SSO settings of profile '%s' need both a start URL and a region", settings.profile)
+	}
+	cachePath, err := ssocreds.StandardCachedTokenFilepath(settings.cacheKey())
+	if err != nil {
+		return err
+	}
+	cfg, err := LoadAWSConfig(ctx, awsconfig.WithRegion(settings.region), awsconfig.WithCredentialsProvider(aws.AnonymousCredentials{}))
+	if err != nil {
+		return fmt.Errorf("failed to load AWS configuration for IAM Identity Center: %w", err)
+	}
+	client := ssooidc.NewFromConfig(cfg)
+
+	cache := ssoTokenCache{StartURL: settings.startURL, Region: settings.region}
+	if previous, err := readSSOTokenCache(cachePath); err == nil && previous.ClientID != "" &&
+		previous.RegistrationExpiresAt != nil && time.Until(*previous.RegistrationExpiresAt) > time.Hour {
+		cache.ClientID, cache.ClientSecret, cache.RegistrationExpiresAt = previous.ClientID, previous.ClientSecret, previous.RegistrationExpiresAt
+	} else {
+		input := &ssooidc.RegisterClientInput{ClientName: aws.String(fmt.Sprintf("saws-%d", time.Now().Unix())), ClientType: aws.String("public")}
+		if settings.session != "" {
+			// Only sso-session clients get refresh tokens.
+			input.Scopes = []string{"sso:account:access"}
+		}
+		registration, err := client.RegisterClient(ctx, input)
+		if err != nil {
+			return fmt.Errorf("sso-oidc:RegisterClient failed: %w", err)
+		}
+		expires := time.Unix(registration.ClientSecretExpiresAt, 0).UTC()
+		cache.ClientID, cache.ClientSecret, cache.RegistrationExpiresAt = aws.ToString(registration.ClientId), aws.ToString(registration.ClientSecret), &expires
+	}
+	RegisterSecret(cache.ClientSecret)
+
+	authorization, err := client.StartDeviceAuthorization(ctx, &ssooidc.StartDeviceAuthorizationInput{
+		ClientId: aws.String(cache.ClientID), ClientSecret: aws.String(cache.ClientSecret), StartUrl: aws.String(settings.startURL),
+	})
+	if err != nil {
+		return fmt.Errorf("sso-oidc:StartDeviceAuthorization failed: %w", err)
+	}
+	verificationURL := aws.ToString(authorization.VerificationUriComplete)
+	if verificationURL == "" {
+		verificationURL = aws.ToString(authorization.VerificationUri)
+	}
+	fmt.Fprintf(os.Stderr, "Signing in to IAM Identity Center (%s) for profile '%s'.\n", settings.startURL, settings.profile)
+	fmt.Fprintf(os.Stderr, "Open %s and confirm the code %s.\n", verificationURL, aws.ToString(authorization.UserCode))
+	OpenBrowser(verificationURL)
+
+	interval := max(time.Duration(authorization.Interval)*time.Second, time.Second)
+	deadline := time.Now().Add(time.Duration(authorization.ExpiresIn) * time.Second)
+	for {
+		if time.Now().After(deadline) {
+			return errors.New("the sign-in was not confirmed in time; run the login again")
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(interval):
+		}
+		token, err := client.CreateToken(ctx, &ssooidc.CreateTokenInput{
+			ClientId: aws.String(cache.ClientID), ClientSecret: aws.String(cache.ClientSecret),
+			DeviceCode: authorization.DeviceCode, GrantType: aws.String(ssoDeviceGrantType),
+		})
+		var pending *ssooidctypes.AuthorizationPendingException
+		var slowDown *ssooidctypes.SlowDownException
+		switch {
+		case errors.As(err, &pending):
+			continue
+		case errors.As(err, &slowDown):
+			interval += 5 * time.Second
+			continue
+		case err != nil:
+			return fmt.Errorf("sso-oidc:CreateToken failed: %w", err)
+		}
+		cache.AccessToken, cache.RefreshToken = aws.ToString(token.AccessToken), aws.ToString(token.RefreshToken)
+		cache.ExpiresAt = time.Now().Add(time.Duration(token.ExpiresIn) * time.Second).UTC().Truncate(time.Second)
+		break
+	}
+	RegisterSecret(cache.AccessToken, cache.RefreshToken)
+
+	data, err := json.MarshalIndent(cache, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(cachePath), 0o700); err != nil {
+		return fmt.Errorf("could not create the SSO token cache directory: %w", err)
+	}
+	if err := WriteFileAtomic(cachePath, data, 0o600); err != nil {
+		return fmt.Errorf("could not write the SSO token cache '%s': %w", cachePath, err)
+	}
+	fmt.Fprintf(os.Stderr, "Signed in; the session is valid until %s.\n", cache.ExpiresAt.Local().Format(time.RFC1123))
+	return nil
+}
+
+func readSSOTokenCache(path string) (ssoTokenCache, error) {
+	var cache ssoTokenCache
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return cache, err
+	}
+	err = json.Unmarshal(data, &cache)
+	return cache, err
+}
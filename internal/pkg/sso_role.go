@@ -0,0 +1,77 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials/ssocreds"
+	"github.com/aws/aws-sdk-go-v2/service/sso"
+	"github.com/aws/aws-sdk-go-v2/service/ssooidc"
+	ststypes "github.com/aws/aws-sdk-go-v2/service/sts/types"
+)
+
+// SSORolePrefix marks a role as an IAM Identity Center permission set, e.g.
+// "sso:AdministratorAccess". Its role in each account (AWSReservedSSO_<name>_<hash>)
+// only trusts the SSO SAML provider, so saws gets its credentials from the SSO
+// portal with the base profile's SSO token instead of calling sts:AssumeRole.
+const SSORolePrefix = "sso:"
+
+// SSOPermissionSet returns the permission set name of role when it has
+// SSORolePrefix.
+func SSOPermissionSet(role string) (string, bool) {
+	name, ok := strings.CutPrefix(role, SSORolePrefix)
+	return name, ok && name != ""
+}
+
+// ssoRoleCredentials returns credentials of permissionSet in accountID from
+// sso:GetRoleCredentials, signed in as the SSO user of the base profile of
+// baseCfg. An expired token fails with an error IsExpiredCredentials matches.
+func ssoRoleCredentials(ctx context.Context, baseCfg aws.Config, accountID, permissionSet string) (*ststypes.Credentials, error) {
+	profile := BaseProfileForAssume
+	if shared, ok := baseCfg.Credentials.(*baseCredentials); ok {
+		profile = shared.profile
+	}
+	settings, err := ssoSettings(ctx, profile)
+	if err != nil {
+		return nil, fmt.Errorf("permission set %s needs an IAM Identity Center base profile: %w", permissionSet, err)
+	}
+	cachePath, err := ssocreds.StandardCachedTokenFilepath(settings.cacheKey())
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := LoadAWSConfig(ctx, awsconfig.WithRegion(settings.region), awsconfig.WithCredentialsProvider(aws.AnonymousCredentials{}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS configuration for IAM Identity Center: %w", err)
+	}
+	// The token provider renews sso-session tokens with their refresh token.
+	token, err := ssocreds.NewSSOTokenProvider(ssooidc.NewFromConfig(cfg), cachePath).RetrieveBearerToken(ctx)
+	if err != nil {
+		return nil, &ssocreds.InvalidTokenError{Err: err}
+	}
+	RegisterSecret(token.Value)
+
+	LogVerbosef("Getting credentials of permission set %s in account %s from IAM Identity Center.", permissionSet, accountID)
+	out, err := sso.NewFromConfig(cfg).GetRoleCredentials(ctx, &sso.GetRoleCredentialsInput{
+		AccessToken: aws.String(token.Value),
+		AccountId:   aws.String(accountID),
+		RoleName:    aws.String(permissionSet),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("sso:GetRoleCredentials failed for permission set %s in account %s: %w", permissionSet, accountID, err)
+	}
+	roleCreds := out.RoleCredentials
+	if roleCreds == nil || roleCreds.AccessKeyId == nil || roleCreds.SecretAccessKey == nil || roleCreds.SessionToken == nil {
+		return nil, fmt.Errorf("sso:GetRoleCredentials response for permission set %s in account %s did not contain valid credentials", permissionSet, accountID)
+	}
+	RegisterSecret(*roleCreds.AccessKeyId, *roleCreds.SecretAccessKey, *roleCreds.SessionToken)
+	return &ststypes.Credentials{
+		AccessKeyId:     roleCreds.AccessKeyId,
+		SecretAccessKey: roleCreds.SecretAccessKey,
+		SessionToken:    roleCreds.SessionToken,
+		Expiration:      aws.Time(time.UnixMilli(roleCreds.Expiration)),
+	}, nil
+}
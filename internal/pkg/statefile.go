@@ -0,0 +1,81 @@
+package pkg
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// stateLockTimeout is how long a saws run waits for another one to release a
+// state file before giving up.
+const stateLockTimeout = 5 * time.Second
+
+// ErrStateLocked means another saws process kept a state file locked for
+// longer than stateLockTimeout.
+var ErrStateLocked = errors.New("another saws run holds the lock")
+
+// LockState takes an exclusive lock for the state file at path, held in
+// path+".lock" so the file itself can be replaced by WriteFileAtomic. It waits
+// up to stateLockTimeout for another saws process to finish with it. The
+// returned function releases the lock.
+func LockState(path string) (func(), error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, err
+	}
+	lockPath := path + ".lock"
+	f, err := os.OpenFile(lockPath, os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("could not open lock file '%s': %w", lockPath, err)
+	}
+	deadline := time.Now().Add(stateLockTimeout)
+	for {
+		locked, err := tryLockFile(f)
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("could not lock '%s': %w", lockPath, err)
+		}
+		if locked {
+			return func() {
+				unlockFile(f)
+				f.Close()
+			}, nil
+		}
+		if time.Now().After(deadline) {
+			f.Close()
+			return nil, fmt.Errorf("%w on '%s' (waited %s); try again when it has finished", ErrStateLocked, path, stateLockTimeout)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// WriteFileAtomic replaces path with data through a temporary file in the same
+// directory and a rename, so a concurrent reader sees the old or the new
+// contents, never a mix.
+func WriteFileAtomic(path string, data []byte, perm os.FileMode) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), perm); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,66 @@
+package pkg
+
+import (
+	"fmt"
+	"net/url"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+const (
+	// STSEndpointRegional keeps AssumeRole calls off the global STS endpoint,
+	// also for base profiles whose region is aws-global.
+	STSEndpointRegional = "regional"
+	// stsGlobalRegion is the pseudo-region the SDK sends to sts.amazonaws.com,
+	// which is served from stsGlobalHomeRegion.
+	stsGlobalRegion     = "aws-global"
+	stsGlobalHomeRegion = "us-east-1"
+)
+
+// Settings from defaults.sts_endpoint and defaults.sts_region, or -sts-endpoint
+// and -sts-region, for the STS calls of AssumeRole.
+var (
+	// STSEndpoint is "" (the SDK's endpoint for the region), STSEndpointRegional,
+	// or the URL of a custom endpoint such as an STS interface VPC endpoint.
+	STSEndpoint string
+	// STSRegion is the region of the STS endpoint, in place of the base
+	// profile's; roles in other partitions still use a region of theirs.
+	STSRegion string
+)
+
+// ValidateSTSEndpoint checks a defaults.sts_endpoint or -sts-endpoint value.
+func ValidateSTSEndpoint(endpoint string) error {
+	if endpoint == "" || endpoint == STSEndpointRegional {
+		return nil
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return fmt.Errorf("STS endpoint '%s' is not '%s' or an http(s) URL such as https://sts.eu-west-1.amazonaws.com", endpoint, STSEndpointRegional)
+	}
+	return nil
+}
+
+// stsRegion returns the region AssumeRole signs its STS calls for and, without
+// a custom endpoint, whose regional endpoint it calls: STSRegion when it is in
+// the partition of region, else region, with aws-global replaced in regional mode.
+func stsRegion(region string) string {
+	if STSRegion != "" && PartitionForRegion(STSRegion) == PartitionForRegion(region) {
+		return STSRegion
+	}
+	if region == stsGlobalRegion && STSEndpoint != "" {
+		LogVerbosef("Calling the regional STS endpoint of %s instead of the global one.", stsGlobalHomeRegion)
+		return stsGlobalHomeRegion
+	}
+	return region
+}
+
+// newSTSClient returns the STS client of AssumeRole for baseCfg, calling the
+// custom STSEndpoint if one is set.
+func newSTSClient(baseCfg aws.Config) *sts.Client {
+	return sts.NewFromConfig(baseCfg, func(o *sts.Options) {
+		if STSEndpoint != "" && STSEndpoint != STSEndpointRegional {
+			o.BaseEndpoint = aws.String(STSEndpoint)
+		}
+	})
+}
@@ -0,0 +1,148 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+)
+
+// ResolveTargetRegions parses a comma-separated -regions value. When it is empty
+// the default region of the base profile is used, falling back to FallbackRegion.
+func ResolveTargetRegions(ctx context.Context, regionsInput string) ([]string, error) {
+	var targetRegions []string
+	regionsInput = strings.TrimSpace(regionsInput)
+	if regionsInput != "" {
+		rawRegions := strings.Split(regionsInput, ",")
+		for _, r := range rawRegions {
+			trimmed := strings.TrimSpace(r)
+			if trimmed != "" {
+				targetRegions = append(targetRegions, trimmed)
+			}
+		}
+		if len(targetRegions) == 0 {
+			return nil, errors.New("-regions flag provided but contained no valid region names after trimming")
+		}
+		if err := ValidateRegions("-regions", targetRegions...); err != nil {
+			return nil, err
+		}
+		LogVerbosef("Using specified regions: %v", targetRegions)
+		return targetRegions, nil
+	}
+
+	LogVerbosef("No -regions flag provided. Determining default region...")
+	tempCfg, errCfg := LoadAWSConfig(ctx, awsconfig.WithSharedConfigProfile(BaseProfileForAssume))
+	defaultRegion := FallbackRegion
+	if errCfg != nil {
+		LogVerbosef("Warning: Could not load AWS config to determine default region: %v. Falling back to '%s'.", errCfg, defaultRegion)
+	} else if tempCfg.Region == "" {
+		LogVerbosef("Warning: Could not determine default region from AWS config/environment. Falling back to '%s'.", defaultRegion)
+	} else {
+		defaultRegion = tempCfg.Region
+		LogVerbosef("Using default region from AWS config/environment: %s", defaultRegion)
+	}
+	return []string{defaultRegion}, nil
+}
+
+// ResolveTargetAccounts returns the sorted account names selected either by -a
+// (all accounts) or by a comma-separated list of names/wildcards/aliases/@groups
+// from -s.
+// Names sharing an account ID are reduced to the first one.
+func ResolveTargetAccounts(appCfg *AppConfig, processAll bool, selector string) ([]string, error) {
+	allAccountNamesSorted := make([]string, 0, len(appCfg.Accounts))
+	for name := range appCfg.Accounts {
+		allAccountNamesSorted = append(allAccountNamesSorted, name)
+	}
+	sort.Strings(allAccountNamesSorted)
+	if processAll {
+		LogVerbosef("Processing all %d defined accounts.", len(allAccountNamesSorted))
+		return dedupeAccountsByID(appCfg.Accounts, allAccountNamesSorted), nil
+	}
+
+	rawPatterns := strings.Split(selector, ",")
+	selectorPatterns := []string{}
+	for _, p := range rawPatterns {
+		trimmed := strings.TrimSpace(p)
+		if trimmed != "" {
+			selectorPatterns = append(selectorPatterns, trimmed)
+		}
+	}
+	if len(selectorPatterns) == 0 {
+		return nil, fmt.Errorf("selector flag '-s \"%s\"' provided no valid names/patterns", selector)
+	}
+	matchedAccountsMap := make(map[string]struct{})
+	LogVerbosef("Applying selector patterns: %v", selectorPatterns)
+	namePatterns := selectorPatterns[:0:0]
+	for _, pattern := range selectorPatterns {
+		members, isGroup, err := expandGroupSelector(appCfg.Groups, pattern)
+		if err != nil {
+			return nil, err
+		}
+		if !isGroup {
+			namePatterns = append(namePatterns, pattern)
+			continue
+		}
+		for _, member := range members {
+			matchedAccountsMap[member] = struct{}{}
+		}
+	}
+	for _, accName := range allAccountNamesSorted {
+		for _, pattern := range namePatterns {
+			match, errMatch := filepath.Match(pattern, accName)
+			if errMatch != nil {
+				LogVerbosef("Warning: Invalid pattern '%s' in selector: %v.", pattern, errMatch)
+				continue
+			}
+			if match || accountMatchesAlias(appCfg.AccountDetails, accName, pattern) {
+				matchedAccountsMap[accName] = struct{}{}
+				break
+			}
+		}
+	}
+	targetAccountNames := make([]string, 0, len(matchedAccountsMap))
+	for accName := range matchedAccountsMap {
+		targetAccountNames = append(targetAccountNames, accName)
+	}
+	sort.Strings(targetAccountNames)
+	targetAccountNames = dedupeAccountsByID(appCfg.Accounts, targetAccountNames)
+	LogVerbosef("Selected %d account(s) using selector '%s': %v", len(targetAccountNames), selector, targetAccountNames)
+	if len(targetAccountNames) == 0 {
+		return nil, fmt.Errorf("no accounts found matching selector patterns: %v", selectorPatterns)
+	}
+	return targetAccountNames, nil
+}
+
+// OrderBySelector orders accountNames by the first -s pattern (or @group) that
+// selects each, so `-s dev,staging,prod` lists dev first. Accounts selected by
+// the same pattern keep their order.
+func OrderBySelector(appCfg *AppConfig, accountNames []string, selector string) []string {
+	var patterns []string
+	for _, p := range strings.Split(selector, ",") {
+		if trimmed := strings.TrimSpace(p); trimmed != "" {
+			patterns = append(patterns, trimmed)
+		}
+	}
+	rank := func(accName string) int {
+		for i, pattern := range patterns {
+			if members, isGroup, _ := expandGroupSelector(appCfg.Groups, pattern); isGroup {
+				for _, member := range members {
+					if member == accName {
+						return i
+					}
+				}
+				continue
+			}
+			if match, _ := filepath.Match(pattern, accName); match || accountMatchesAlias(appCfg.AccountDetails, accName, pattern) {
+				return i
+			}
+		}
+		return len(patterns)
+	}
+	ordered := append([]string(nil), accountNames...)
+	sort.SliceStable(ordered, func(i, j int) bool { return rank(ordered[i]) < rank(ordered[j]) })
+	return ordered
+}
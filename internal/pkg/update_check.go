@@ -0,0 +1,142 @@
+package pkg
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	latestReleaseURL    = "https://api.github.com/repos/hosein-yousefii/saws/releases/latest"
+	ReleasesPageURL     = "https://github.com/hosein-yousefii/saws/releases/latest"
+	updateCheckFileName = "saws-update-check.json"
+	updateCheckInterval = 24 * time.Hour
+	updateCheckTimeout  = 2 * time.Second
+	envUpdateCheckVar   = "SAWS_UPDATE_CHECK"
+)
+
+// UpdateCheck is set by defaults.update_check; the check is opt-in.
+var UpdateCheck bool
+
+// updateCheckState is the cached result of the last release lookup.
+type updateCheckState struct {
+	CheckedAt time.Time `json:"checked_at"`
+	Latest    string    `json:"latest"`
+}
+
+// UpdateCheckEnabled reports whether the release check is opted into, through
+// defaults.update_check or SAWS_UPDATE_CHECK=1.
+func UpdateCheckEnabled() bool {
+	if enabled, err := strconv.ParseBool(os.Getenv(envUpdateCheckVar)); err == nil {
+		return enabled
+	}
+	return UpdateCheck
+}
+
+func updateCheckPath() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(homeDir, AWSConfigDir, updateCheckFileName), nil
+}
+
+// LatestRelease returns the tag of the newest GitHub release, looked up at most
+// once per updateCheckInterval; the answer is cached in ~/.aws. Failures yield
+// the cached tag, if any.
+func LatestRelease(ctx context.Context) string {
+	path, err := updateCheckPath()
+	if err != nil {
+		return ""
+	}
+	var state updateCheckState
+	if data, err := os.ReadFile(path); err == nil {
+		_ = json.Unmarshal(data, &state)
+	}
+	if time.Since(state.CheckedAt) < updateCheckInterval {
+		return state.Latest
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, updateCheckTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, latestReleaseURL, nil)
+	if err != nil {
+		return state.Latest
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	// Record the attempt even if it fails, so an offline laptop is not slowed
+	// down on every run.
+	state.CheckedAt = time.Now()
+	if resp, err := http.DefaultClient.Do(req); err != nil {
+		LogVerbosef("Warning: Release check failed: %v", err)
+	} else {
+		var release struct {
+			TagName string `json:"tag_name"`
+		}
+		if resp.StatusCode == http.StatusOK && json.NewDecoder(resp.Body).Decode(&release) == nil && release.TagName != "" {
+			state.Latest = release.TagName
+		} else {
+			LogVerbosef("Warning: Release check returned %s.", resp.Status)
+		}
+		resp.Body.Close()
+	}
+	if data, err := json.Marshal(state); err == nil {
+		_ = WriteFileAtomic(path, data, 0o600)
+	}
+	return state.Latest
+}
+
+// parseVersion splits "v1.2.3" (optionally with a "-suffix") into numbers.
+func parseVersion(v string) ([]int, bool) {
+	v, _, _ = strings.Cut(strings.TrimPrefix(v, "v"), "-")
+	parts := strings.Split(v, ".")
+	numbers := make([]int, len(parts))
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return nil, false
+		}
+		numbers[i] = n
+	}
+	return numbers, true
+}
+
+// NewerVersion reports whether latest is a higher release than current. Builds
+// without a release version (e.g. "dev") are never reported as outdated.
+func NewerVersion(latest, current string) bool {
+	l, okLatest := parseVersion(latest)
+	c, okCurrent := parseVersion(current)
+	if !okLatest || !okCurrent {
+		return false
+	}
+	for i := 0; i < max(len(l), len(c)); i++ {
+		var a, b int
+		if i < len(l) {
+			a = l[i]
+		}
+		if i < len(c) {
+			b = c[i]
+		}
+		if a != b {
+			return a > b
+		}
+	}
+	return false
+}
+
+// NotifyUpdate prints a one-line notice on stderr when the opted-in release
+// check finds a release newer than current.
+func NotifyUpdate(ctx context.Context, current string) {
+	if !UpdateCheckEnabled() {
+		return
+	}
+	if latest := LatestRelease(ctx); NewerVersion(latest, current) {
+		fmt.Fprintf(os.Stderr, "A newer saws release %s is available (running %s): %s\n", latest, current, ReleasesPageURL)
+	}
+}